@@ -0,0 +1,353 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// AmbiguityError describes a part of the input that can be parsed in more than one way.
+type AmbiguityError struct {
+	// The type of the ambiguous part, and the tokens that it covers.
+	Symbol string
+	Span   Span
+
+	// Some of the ways that the part can be parsed, as trees of rule names and tokens.
+	Trees []string
+}
+
+func (e *AmbiguityError) Error() string {
+	return fmt.Sprintf(
+		"%s: %s at tokens %d to %d can be parsed as any of %s",
+		ErrAmbiguousParse, e.Symbol, e.Span.Start, e.Span.End, strings.Join(e.Trees, ", "),
+	)
+}
+
+func (e *AmbiguityError) Unwrap() error {
+	return ErrAmbiguousParse
+}
+
+// As Parse, but if any part of the input can be parsed in more than one way then return an
+// AmbiguityError describing up to samples of the ways that the smallest such part can be parsed.
+func ParseUnambiguous[T, U, V any](g Grammar[U, V], toks []T, samples int) (V, error) {
+	return parseHooked(g, toks, parseOptions{samples: max(samples, 1)})
+}
+
+// A symbol matched over a range of tokens, which may have been matched in several ways.
+type forestNode struct {
+	sym     *symbol
+	at, end int
+}
+
+// Part of a rule, from some progress onwards, matched over a range of tokens.
+type forestSeq struct {
+	rule     *rule
+	progress int
+	at, end  int
+}
+
+type forest struct {
+	b        *builder
+	nodes    map[forestNode]int
+	seqs     map[forestSeq]int
+	visiting map[forestNode]bool
+}
+
+func (b *builder) forest() *forest {
+	return &forest{
+		b:        b,
+		nodes:    map[forestNode]int{},
+		seqs:     map[forestSeq]int{},
+		visiting: map[forestNode]bool{},
+	}
+}
+
+// Search the parse forest for the smallest ambiguous part.
+func (b *builder) ambiguity(samples int) error {
+	f := b.forest()
+	root := forestNode{sym: b.root, at: 0, end: len(b.seen)}
+	if f.count(root) == 0 {
+		return nil
+	}
+
+	best, found := root, false
+	seen := map[forestNode]bool{}
+	var visit func(n forestNode)
+	visit = func(n forestNode) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		if f.count(n) > 1 && (!found || n.end-n.at < best.end-best.at) {
+			best, found = n, true
+		}
+		for _, c := range f.children(n) {
+			visit(c)
+		}
+	}
+	visit(root)
+
+	if !found {
+		return nil
+	}
+	return &AmbiguityError{
+		Symbol: best.sym.Type.String(),
+		Span:   Span{Start: best.at, End: best.end},
+		Trees:  f.trees(best, samples),
+	}
+}
+
+// The completed items that match a node.
+func (f *forest) items(n forestNode) []item {
+	var res []item
+	for _, x := range f.b.completed(n.at, n.sym) {
+		if x.position == n.end && f.b.layoutAllows(x.rule, n.at, n.end) {
+			res = append(res, x)
+		}
+	}
+	return res
+}
+
+// The positions that a symbol, starting at a position, can end at.
+func (f *forest) ends(sym *symbol, at, end int) []int {
+	var res []int
+	for _, x := range f.b.completed(at, sym) {
+		if x.position <= end && !slices.Contains(res, x.position) {
+			res = append(res, x.position)
+		}
+	}
+	return res
+}
+
+// Count the ways in which a node can be matched. Only whether there are none, one or more is of
+// interest, so the count stops at 2. A node that can contain itself can be matched in any number of
+// ways.
+func (f *forest) count(n forestNode) int {
+	if c, ok := f.nodes[n]; ok {
+		return c
+	}
+	if f.visiting[n] {
+		return 2
+	}
+	f.visiting[n] = true
+	defer delete(f.visiting, n)
+
+	c := 0
+	for _, x := range f.items(n) {
+		c = min(c+f.countSeq(forestSeq{rule: x.rule, at: n.at, end: n.end}), 2)
+	}
+	f.nodes[n] = c
+	return c
+}
+
+func (f *forest) countSeq(s forestSeq) int {
+	if c, ok := f.seqs[s]; ok {
+		return c
+	}
+
+	c := 0
+	deps := s.rule.Deps[s.progress:]
+	switch {
+	case len(deps) == 0:
+		if s.at == s.end {
+			c = 1
+		}
+	case deps[0].TokenType != nil:
+		if _, ok := f.b.tokenAt(deps[0], s.at); ok {
+			c = f.countSeq(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at + 1, end: s.end})
+		}
+	case deps[0].NotFollowedBy != nil:
+		if f.b.allows(deps[0], s.at) {
+			c = f.countSeq(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at, end: s.end})
+		}
+	case deps[0].Layout != nil:
+		c = f.countSeq(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at, end: s.end})
+	default:
+		if _, ok := f.b.holeAt(deps[0], s.at); ok {
+			c = f.countSeq(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at + 1, end: s.end})
+		}
+		for _, p := range f.ends(deps[0], s.at, s.end) {
+			rest := f.countSeq(forestSeq{rule: s.rule, progress: s.progress + 1, at: p, end: s.end})
+			if rest == 0 {
+				continue
+			}
+			c = min(c+f.count(forestNode{sym: deps[0], at: s.at, end: p})*rest, 2)
+		}
+	}
+
+	f.seqs[s] = c
+	return c
+}
+
+// Find the nodes that take part in some match of a node.
+func (f *forest) children(n forestNode) []forestNode {
+	var res []forestNode
+	var walk func(s forestSeq)
+	walk = func(s forestSeq) {
+		deps := s.rule.Deps[s.progress:]
+		if len(deps) == 0 {
+			return
+		}
+		if deps[0].TokenType != nil {
+			walk(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at + 1, end: s.end})
+			return
+		}
+		if deps[0].NotFollowedBy != nil || deps[0].Layout != nil {
+			walk(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at, end: s.end})
+			return
+		}
+		if _, ok := f.b.holeAt(deps[0], s.at); ok {
+			walk(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at + 1, end: s.end})
+		}
+		for _, p := range f.ends(deps[0], s.at, s.end) {
+			child := forestNode{sym: deps[0], at: s.at, end: p}
+			rest := forestSeq{rule: s.rule, progress: s.progress + 1, at: p, end: s.end}
+			if f.count(child) == 0 || f.countSeq(rest) == 0 {
+				continue
+			}
+			res = append(res, child)
+			walk(rest)
+		}
+	}
+	for _, x := range f.items(n) {
+		s := forestSeq{rule: x.rule, at: n.at, end: n.end}
+		if f.countSeq(s) > 0 {
+			walk(s)
+		}
+	}
+	return res
+}
+
+// Describe up to limit of the ways that a node can be matched.
+func (f *forest) trees(n forestNode, limit int) []string {
+	if f.visiting[n] {
+		return nil
+	}
+	f.visiting[n] = true
+	defer delete(f.visiting, n)
+
+	var res []string
+	for _, x := range f.items(n) {
+		for _, args := range f.treeSeqs(forestSeq{rule: x.rule, at: n.at, end: n.end}, limit-len(res)) {
+			res = append(res, fmt.Sprintf("%s(%s)", x.rule.Name, strings.Join(args, ", ")))
+		}
+		if len(res) >= limit {
+			break
+		}
+	}
+	return res
+}
+
+func (f *forest) treeSeqs(s forestSeq, limit int) [][]string {
+	deps := s.rule.Deps[s.progress:]
+	if limit <= 0 {
+		return nil
+	}
+	if len(deps) == 0 {
+		if s.at == s.end {
+			return [][]string{nil}
+		}
+		return nil
+	}
+	if deps[0].TokenType != nil {
+		value, ok := f.b.tokenAt(deps[0], s.at)
+		if !ok {
+			return nil
+		}
+		return f.prefixSeqs(fmt.Sprintf("%#v", value.Interface()), s, limit)
+	}
+	if deps[0].NotFollowedBy != nil || deps[0].Layout != nil {
+		if deps[0].NotFollowedBy != nil && !f.b.allows(deps[0], s.at) {
+			return nil
+		}
+		return f.treeSeqs(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at, end: s.end}, limit)
+	}
+
+	var res [][]string
+	if value, ok := f.b.holeAt(deps[0], s.at); ok {
+		res = f.prefixSeqs(fmt.Sprintf("%#v", value.Interface()), s, limit)
+	}
+	for _, p := range f.ends(deps[0], s.at, s.end) {
+		rests := f.treeSeqs(forestSeq{rule: s.rule, progress: s.progress + 1, at: p, end: s.end}, limit)
+		if len(rests) == 0 {
+			continue
+		}
+		for _, t := range f.trees(forestNode{sym: deps[0], at: s.at, end: p}, limit) {
+			for _, rest := range rests {
+				if len(res) == limit {
+					return res
+				}
+				res = append(res, append([]string{t}, rest...))
+			}
+		}
+	}
+	return res
+}
+
+// Describe the ways that a part of a rule can be matched, where its first symbol is matched by a
+// single token.
+func (f *forest) prefixSeqs(tok string, s forestSeq, limit int) [][]string {
+	var res [][]string
+	for _, rest := range f.treeSeqs(forestSeq{rule: s.rule, progress: s.progress + 1, at: s.at + 1, end: s.end}, limit) {
+		res = append(res, append([]string{tok}, rest...))
+	}
+	return res
+}
+
+// Search for the shortest input, of at most maxLen tokens, that the grammar can parse in more than
+// one way. The input is returned along with an AmbiguityError describing it. If there is no such
+// input then the result is nil.
+//
+// Inputs are generated from the grammar, in order of length, using the zero value of each token
+// type that can appear in an input of T. As the grammar is not consulted about the values of
+// tokens, this is only a search over the shapes of inputs. The number of inputs can grow very
+// quickly with their length, so maxLen should be kept small.
+func FindAmbiguity[T, U, V any](g Grammar[U, V], maxLen int) ([]T, error) {
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	terminals := terminalTypes[T](gr)
+
+	var res []T
+	var found error
+	sentences(gr, terminals, maxLen, func(toks []T, m *matcher) bool {
+		if err := m.builder(reflect.ValueOf(g)).ambiguity(2); err != nil {
+			res, found = toks, err
+			return false
+		}
+		return true
+	})
+	return res, found
+}
+
+// Generate the inputs that the grammar accepts, in order of length, passing each one to yield along
+// with the matcher that accepted it. Inputs are extended a token at a time, and only those that the
+// grammar can continue are extended further.
+func sentences[T any](gr *grammar, terminals []reflect.Type, maxLen int, yield func([]T, *matcher) bool) {
+	prefixes := [][]T{nil}
+	for len(prefixes) > 0 {
+		var next [][]T
+		for _, toks := range prefixes {
+			m := &matcher{
+				root:     gr.root,
+				brackets: gr.brackets,
+				toks:     tokenValues(toks),
+			}
+			err := m.run()
+			if err == nil && !yield(toks, m) {
+				return
+			}
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				continue
+			}
+			if len(toks) == maxLen {
+				continue
+			}
+			for _, t := range terminals {
+				next = append(next, append(slices.Clip(toks), reflect.Zero(t).Interface().(T)))
+			}
+		}
+		prefixes = next
+	}
+}
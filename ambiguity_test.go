@@ -0,0 +1,53 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleParseUnambiguous() {
+	toks := []any{
+		intTok{1},
+		plusTok{},
+		intTok{2},
+		plusTok{},
+		intTok{3},
+		plusTok{},
+		intTok{4},
+	}
+
+	x, err := tp.ParseUnambiguous(interfaceGrammar{}, toks[:3], 2)
+	fmt.Println(x, err)
+
+	// Only the first three numbers are needed to show the problem.
+	_, err = tp.ParseUnambiguous(interfaceGrammar{}, toks, 2)
+	var amb *tp.AmbiguityError
+	if errors.As(err, &amb) {
+		fmt.Println(amb.Symbol, amb.Span)
+		for _, t := range amb.Trees {
+			fmt.Println(t)
+		}
+	}
+
+	// Output:
+	// {{1} {2}} <nil>
+	// tp_test.expr {0 5}
+	// Add(Add(Int(tp_test.intTok{value:1}), tp_test.plusTok{}, Int(tp_test.intTok{value:2})), tp_test.plusTok{}, Int(tp_test.intTok{value:3}))
+	// Add(Int(tp_test.intTok{value:1}), tp_test.plusTok{}, Add(Int(tp_test.intTok{value:2}), tp_test.plusTok{}, Int(tp_test.intTok{value:3})))
+}
+
+func ExampleFindAmbiguity() {
+	toks, err := tp.FindAmbiguity[any](interfaceGrammar{}, 6)
+	fmt.Printf("%#v\n", toks)
+	fmt.Println(err != nil)
+
+	toks, err = tp.FindAmbiguity[any](ifStmtGrammar{}, 10)
+	fmt.Println(toks, err)
+
+	// Output:
+	// []interface {}{tp_test.intTok{value:0}, tp_test.plusTok{}, tp_test.intTok{value:0}, tp_test.plusTok{}, tp_test.intTok{value:0}}
+	// true
+	// [] <nil>
+}
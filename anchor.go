@@ -0,0 +1,182 @@
+package tp
+
+import (
+	"slices"
+	"strings"
+)
+
+// Where a token begins, which decides the states that the machine begins in. Each includes the ones
+// before it, since the start of the text is also the start of a line.
+const (
+	anchorNone = iota
+	anchorLine
+	anchorText
+	anchors
+)
+
+// Restrict a spec to tokens that begin at the start of a line, including the start of the text,
+// e.g. for preprocessor directives or Markdown headings. A line begins after each '\n'. Text skipped
+// by the lexer counts, so a token may follow whitespace that ends a line.
+func AtLineStart[T any](spec TokenSpec[T]) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		return anchorSpec(l, l.LineStart(), spec)
+	}
+}
+
+// Restrict a spec to tokens that begin at the start of the text, e.g. for a shebang line.
+func AtTextStart[T any](spec TokenSpec[T]) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		return anchorSpec(l, l.TextStart(), spec)
+	}
+}
+
+// Add a spec, with the transitions that it adds from the state that matching begins in moved to
+// another state.
+func anchorSpec[T any](l *Lexer[T], anchor LexerState, spec TokenSpec[T]) error {
+	closes, moves, actions := len(l.closeTransitions), len(l.moveTransitions), len(l.enterActions)
+	if err := spec(l); err != nil {
+		return err
+	}
+	for i := closes; i < len(l.closeTransitions); i++ {
+		if l.closeTransitions[i].Given == 0 {
+			l.closeTransitions[i].Given = anchor
+		}
+	}
+	for i := moves; i < len(l.moveTransitions); i++ {
+		if l.moveTransitions[i].Given == 0 {
+			l.moveTransitions[i].Given = anchor
+		}
+	}
+	for i := actions; i < len(l.enterActions); i++ {
+		if l.enterActions[i].Given == 0 {
+			l.enterActions[i].Given = anchor
+		}
+	}
+	return nil
+}
+
+// Return a state that the machine begins in, as well as state 0, when a token begins at the start
+// of a line, including the start of the text. Tokens that are matched by moving from this state are
+// only matched there. The state is created the first time it is asked for.
+func (p *Lexer[T]) LineStart() LexerState {
+	if p.lineStart == 0 {
+		p.lineStart = p.State()
+	}
+	return p.lineStart
+}
+
+// As LineStart, but the machine only begins in the state at the start of the text.
+func (p *Lexer[T]) TextStart() LexerState {
+	if p.textStart == 0 {
+		p.textStart = p.State()
+	}
+	return p.textStart
+}
+
+// The state that the transitions of a token spec with an anchor are added from.
+func (p *Lexer[T]) anchorState(anchor int) LexerState {
+	switch anchor {
+	case anchorLine:
+		return p.LineStart()
+	case anchorText:
+		return p.TextStart()
+	}
+	return 0
+}
+
+// Remove the ^ from the start of a pattern, after any flags that are set for the whole of it, and
+// find what it anchors the pattern to. With the m flag this is the start of a line, and otherwise
+// the start of the text.
+func leadingAnchor(toks []token) (int, []token, error) {
+	multiline := false
+	i := 0
+	for ; i < len(toks); i++ {
+		f, ok := toks[i].(flagGroup)
+		if !ok {
+			break
+		}
+		multiline = multiline || strings.ContainsRune(f.flags, 'm')
+	}
+	if i == len(toks) {
+		return anchorNone, toks, nil
+	}
+	if _, ok := toks[i].(charsetInvert); !ok {
+		return anchorNone, toks, nil
+	}
+
+	rest := slices.Delete(slices.Clone(toks), i, i+1)
+	if hasOuterChoice(rest) {
+		return 0, nil, &ErrUnsupportedRegex{
+			Construct:  "^ before one of several alternatives",
+			Suggestion: "^ anchors the whole pattern; group the alternatives, e.g. ^(?:a|b)",
+		}
+	}
+	if multiline {
+		return anchorLine, rest, nil
+	}
+	return anchorText, rest, nil
+}
+
+// Find whether a pattern is a choice between alternatives that are not inside a group.
+func hasOuterChoice(toks []token) bool {
+	depth := 0
+	inClass := false
+	for _, t := range toks {
+		switch t.(type) {
+		case charsetOpen:
+			inClass = true
+		case charsetClose:
+			inClass = false
+		case groupOpen, flagOpen:
+			if !inClass {
+				depth++
+			}
+		case groupClose:
+			if !inClass {
+				depth--
+			}
+		case bar:
+			if !inClass && depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The states of the machine that matching begins in for an anchor.
+func (p *Lexer[T]) startStates(anchor int) []LexerState {
+	res := []LexerState{0}
+	if anchor >= anchorLine && p.lineStart != 0 {
+		res = append(res, p.lineStart)
+	}
+	if anchor >= anchorText && p.textStart != 0 {
+		res = append(res, p.textStart)
+	}
+	return res
+}
+
+// Find the anchor of a token beginning at a position. All of the text before it has been scanned
+// for lines.
+func (l *Stream[T]) anchor(start int) int {
+	start = l.sourceOffset(start)
+	starts := l.lines.starts
+	switch {
+	case start == 0:
+		return anchorText
+	case len(starts) != 0 && starts[len(starts)-1] == start:
+		return anchorLine
+	}
+	return anchorNone
+}
+
+// Put the machine into the states that matching begins in, for a token beginning at a position.
+func (l *Stream[T]) enterStart(start int) {
+	l.this[0] = true
+	if l.prog.lineStart == 0 && l.prog.textStart == 0 {
+		return
+	}
+	for _, s := range l.prog.startStates(l.anchor(start)) {
+		l.this[s] = true
+	}
+}
@@ -0,0 +1,124 @@
+package tp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestAnchors(t *testing.T) {
+	word := func(kind string) TokenConstructor[string] {
+		return func(start int, text string) (string, error) {
+			return kind + ":" + text, nil
+		}
+	}
+	build := func() *Lexer[string] {
+		l, err := NewLexer(
+			Skip[string](`[ \n]+`),
+			AtTextStart(Regex(`#![^\n]*`, word("shebang"))),
+			AtLineStart(Regex(`#[a-z]+`, word("directive"))),
+			Regex(`#`, word("hash")),
+			Regex(`!`, word("bang")),
+			Regex(`[a-z]+`, word("word")),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	src := "#!/bin/sh\n#define x #y\n  #z\n#!no"
+	want := []string{
+		"shebang:#!/bin/sh",
+		"directive:#define", "word:x", "hash:#", "word:y",
+		"hash:#", "word:z",
+		"hash:#", "bang:!", "word:no",
+	}
+
+	for _, compiled := range []bool{false, true} {
+		t.Run(fmt.Sprint("Compiled", compiled), func(t *testing.T) {
+			l := build()
+			if compiled {
+				l.Compile()
+			}
+
+			toks, err := l.Tokenize([]byte(src)).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, want)
+
+			toks, err = l.TokenizeReader(io.MultiReader(strings.NewReader(src[:12]), strings.NewReader(src[12:]))).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, want)
+
+			// the anchored states are shared when lexers are merged
+			merged, err := NewLexer(Regex(`\d+`, word("num")))
+			if !assert.Nil(t, err) {
+				return
+			}
+			merged.Merge(build())
+			if compiled {
+				merged.Compile()
+			}
+			toks, err = merged.Tokenize([]byte("#!x\n#a 1")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"shebang:#!x", "directive:#a", "num:1"})
+		})
+	}
+}
+
+func TestRegexAnchors(t *testing.T) {
+	word := func(kind string) TokenConstructor[string] {
+		return func(start int, text string) (string, error) {
+			return kind + ":" + text, nil
+		}
+	}
+	l, err := NewLexer(
+		Skip[string](`[ \n]+`),
+		Regex(`^#![^\n]*`, word("shebang")),
+		Regex(`(?m)^#[a-z]+`, word("directive")),
+		Regex(`#`, word("hash")),
+		Regex(`!`, word("bang")),
+		Regex(`[a-z]+`, word("word")),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	toks, err := l.Tokenize([]byte("#!/bin/sh\n#define x #y\n  #z\n#!no")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{
+		"shebang:#!/bin/sh",
+		"directive:#define", "word:x", "hash:#", "word:y",
+		"hash:#", "word:z",
+		"hash:#", "bang:!", "word:no",
+	})
+}
+
+func TestAnchorsHandBuilt(t *testing.T) {
+	l := new(Lexer[string])
+	end := l.State()
+	l.Rune(l.LineStart(), end, '>')
+	l.Final(end, func(start int, text string) (string, error) {
+		return "quote", nil
+	})
+	other := l.State()
+	l.Range(0, other, 0, 0x10ffff)
+	l.Final(other, func(start int, text string) (string, error) {
+		return text, nil
+	})
+
+	toks, err := l.Tokenize([]byte(">>\n>")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{"quote", ">", "\n", "quote"})
+}
+
+func TestAnchorsTables(t *testing.T) {
+	l, err := NewLexer(AtLineStart(Regex(`#`, func(start int, text string) (string, error) {
+		return text, nil
+	})))
+	assert.Nil(t, err)
+	_, err = l.Tables()
+	assert.Equal(t, err.Error(), "tp: lexers with tokens anchored to the start of a line cannot be described by tables")
+}
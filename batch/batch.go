@@ -0,0 +1,109 @@
+// Package batch parses many files at once, for tools that work on whole projects.
+package batch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/bobappleyard/tp"
+)
+
+// The results of parsing many files, keyed by path. A file that was parsed successfully has a
+// result and no diagnostics, and one that was not has diagnostics and no result.
+type Result[V any] struct {
+	Results     map[string]V
+	Diagnostics map[string][]tp.Diagnostic
+}
+
+// Read and parse many files at once, using up to the given number of workers. If workers is not
+// positive, then GOMAXPROCS workers are used.
+//
+// Files that cannot be read or parsed are reported as diagnostics rather than stopping the batch.
+// If the context is cancelled then no more files are begun, and the results gathered so far are
+// returned along with the context's error.
+func ParseFiles[T, U, V any](ctx context.Context, lang *tp.Language[T, U, V], paths []string, workers int) (*Result[V], error) {
+	return ParseFilesCached(ctx, lang, nil, paths, workers)
+}
+
+// As ParseFiles, but results are taken from the cache where possible, and files that are parsed
+// successfully have their results added to it. If the cache is nil then this is ParseFiles.
+func ParseFilesCached[T, U, V any](ctx context.Context, lang *tp.Language[T, U, V], cache *Cache[V], paths []string, workers int) (*Result[V], error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	res := &Result[V]{
+		Results:     map[string]V{},
+		Diagnostics: map[string][]tp.Diagnostic{},
+	}
+	var mu sync.Mutex
+	todo := make(chan string)
+
+	var wg sync.WaitGroup
+	for range min(workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range todo {
+				v, diag, ok := parseFile(lang, cache, path)
+				mu.Lock()
+				if ok {
+					res.Results[path] = v
+				} else {
+					res.Diagnostics[path] = append(res.Diagnostics[path], diag)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := feedPaths(ctx, todo, paths)
+	close(todo)
+	wg.Wait()
+	return res, err
+}
+
+func feedPaths(ctx context.Context, todo chan<- string, paths []string) error {
+	for _, path := range paths {
+		// checked first, as select does not prefer one ready case over another
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case todo <- path:
+		}
+	}
+	return nil
+}
+
+func parseFile[T, U, V any](lang *tp.Language[T, U, V], cache *Cache[V], path string) (V, tp.Diagnostic, bool) {
+	var zero V
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return zero, tp.Diagnostic{Path: path, Err: err}, false
+	}
+	if cache != nil {
+		if v, ok := cache.load(src); ok {
+			return v, tp.Diagnostic{}, true
+		}
+	}
+	v, err := lang.Parse(src)
+	if err == nil {
+		if cache != nil {
+			cache.store(src, v)
+		}
+		return v, tp.Diagnostic{}, true
+	}
+	d := tp.Diagnostic{Path: path, Err: err}
+	var syntax *tp.SyntaxError
+	if errors.As(err, &syntax) {
+		d.Position = tp.NewLineIndex(src).Position(syntax.Offset)
+		d.Err = syntax.Err
+	}
+	return zero, d, false
+}
@@ -0,0 +1,86 @@
+package batch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+	"github.com/bobappleyard/tp/batch"
+	"github.com/bobappleyard/tp/internal/testlang"
+)
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.json": "[1, 22]",
+		"b.json": "[]",
+		"c.json": "[\n  1,,\n]",
+	}
+	var paths []string
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(src), 0o644))
+		paths = append(paths, path)
+	}
+	missing := filepath.Join(dir, "missing.json")
+	paths = append(paths, missing)
+
+	res, err := batch.ParseFiles(context.Background(), testlang.Numbers, paths, 2)
+	assert.Nil(t, err)
+
+	assert.Equal(t, len(res.Results), 2)
+	assert.Equal(t, res.Results[filepath.Join(dir, "b.json")], testlang.List{Values: []int{}})
+
+	comma := res.Diagnostics[filepath.Join(dir, "c.json")]
+	assert.Equal(t, len(comma), 1)
+	assert.Equal(t, comma[0].Position, tp.Position{Offset: 6, Line: 2, Column: 5})
+
+	assert.Equal(t, len(res.Diagnostics[missing]), 1)
+	assert.True(t, os.IsNotExist(res.Diagnostics[missing][0].Err))
+}
+
+func TestParseFilesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := batch.ParseFiles(ctx, testlang.Numbers, []string{"a.json", "b.json"}, 1)
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, len(res.Results)+len(res.Diagnostics), 0)
+}
+
+func TestParseFilesCached(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.json")
+	assert.Nil(t, os.WriteFile(path, []byte("[1]"), 0o644))
+
+	encoded := 0
+	cache := &batch.Cache[testlang.List]{
+		Dir: filepath.Join(dir, "cache"),
+		Encode: func(v testlang.List) ([]byte, error) {
+			encoded++
+			return []byte("cached"), nil
+		},
+		Decode: func(data []byte) (testlang.List, error) {
+			return testlang.List{Values: []int{len(data)}}, nil
+		},
+	}
+
+	res, err := batch.ParseFilesCached(context.Background(), testlang.Numbers, cache, []string{path}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, res.Results[path], testlang.List{Values: []int{1}})
+	assert.Equal(t, encoded, 1)
+
+	res, err = batch.ParseFilesCached(context.Background(), testlang.Numbers, cache, []string{path}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, res.Results[path], testlang.List{Values: []int{len("cached")}})
+	assert.Equal(t, encoded, 1)
+
+	cache.Version = "2"
+	res, err = batch.ParseFilesCached(context.Background(), testlang.Numbers, cache, []string{path}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, res.Results[path], testlang.List{Values: []int{1}})
+	assert.Equal(t, encoded, 2)
+}
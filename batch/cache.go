@@ -0,0 +1,70 @@
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// Cache keeps the results of parsing files on disk, keyed by the content of the files, so that
+// files that have not changed need not be parsed again. Results are converted to and from bytes by
+// functions that the user provides.
+//
+// The cache is only a way of saving time: results that cannot be read back are parsed again, and
+// failures to write results are ignored.
+type Cache[V any] struct {
+	// The directory that results are kept in. It is created if it does not exist.
+	Dir string
+
+	// Mixed into the key of every result, so that changing it, e.g. when the grammar changes, means
+	// that results from before the change are no longer used.
+	Version string
+
+	Encode func(V) ([]byte, error)
+	Decode func([]byte) (V, error)
+}
+
+func (c *Cache[V]) path(src []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.Version))
+	h.Write([]byte{0})
+	h.Write(src)
+	return filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil)))
+}
+
+func (c *Cache[V]) load(src []byte) (V, bool) {
+	var zero V
+	data, err := os.ReadFile(c.path(src))
+	if err != nil {
+		return zero, false
+	}
+	v, err := c.Decode(data)
+	if err != nil {
+		return zero, false
+	}
+	return v, true
+}
+
+func (c *Cache[V]) store(src []byte, v V) {
+	data, err := c.Encode(v)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return
+	}
+
+	// write to a temporary file first, so that a result is never read while it is half written
+	f, err := os.CreateTemp(c.Dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(f.Name(), c.path(src)) != nil {
+		os.Remove(f.Name())
+	}
+}
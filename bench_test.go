@@ -0,0 +1,61 @@
+package tp_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/tp"
+)
+
+// A JSON document with n records in an array.
+func jsonDocument(n int) []jsonToken {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := range n {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "item%d", "tags": ["a", "b"], "qty": [%d, 1.5]}`, i, i, i)
+	}
+	b.WriteString("]")
+	return removeWhitespace(must(lexicon.Tokenize([]byte(b.String())).Force()))
+}
+
+func BenchmarkParseJSON(b *testing.B) {
+	toks := jsonDocument(100)
+	b.ReportAllocs()
+	for b.Loop() {
+		must(tp.Parse(jsonGrammar{}, toks))
+	}
+}
+
+// A JSON document of arrays nested n deep.
+func nestedJSONDocument(n int) []jsonToken {
+	text := strings.Repeat("[1, ", n) + "1" + strings.Repeat("]", n)
+	return removeWhitespace(must(lexicon.Tokenize([]byte(text)).Force()))
+}
+
+func BenchmarkParseNestedJSON(b *testing.B) {
+	toks := nestedJSONDocument(200)
+	b.ReportAllocs()
+	for b.Loop() {
+		must(tp.Parse(jsonGrammar{}, toks))
+	}
+}
+
+func BenchmarkParseLeftRecursion(b *testing.B) {
+	toks := make([]intTok, 1000)
+	b.ReportAllocs()
+	for b.Loop() {
+		must(tp.Parse(leftListGrammar{}, toks))
+	}
+}
+
+func BenchmarkParseRightRecursion(b *testing.B) {
+	toks := make([]intTok, 1000)
+	b.ReportAllocs()
+	for b.Loop() {
+		must(tp.Parse(rightListGrammar{}, toks))
+	}
+}
@@ -0,0 +1,120 @@
+package tp
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+// A token constructor that is also given the text matched by each group in the pattern.
+type CaptureConstructor[T any] func(m Match, groups []Match) (T, error)
+
+// As Regex, but the token constructor is also given the text matched by each parenthesized group
+// in the pattern.
+//
+// The groups are numbered in the order that their opening parentheses appear, beginning at 1, with
+// groups[0] describing the whole match. The lexer decides how much text a token covers, and the
+// groups are then assigned by trying alternatives from left to right and repeating as many times
+// as possible, in the manner of a backtracking regular expression engine:
+//
+//   - A group that did not take part in the match has a Start and End of -1 and empty text.
+//   - A group that is repeated reports the text of the last repetition that it took part in.
+//
+// So, e.g. matching `((a)|b)+` against "ab" gives "b" for group 1 and "a" for group 2.
+func RegexCapture[T any](re string, yield CaptureConstructor[T]) TokenSpec[T] {
+	return regexSpec(re, func(l *Lexer[T], end LexerState, e parsedRegex) {
+		l.FinalMatch(end, func(m Match) (T, error) {
+			return yield(m, e.submatches(m))
+		})
+	})
+}
+
+type submatcher struct {
+	text   []byte
+	groups []int
+	spans  [][2]int
+}
+
+// Find the text matched by each of the groups in the expression, given a match of the whole thing.
+func (e parsedRegex) submatches(m Match) []Match {
+	sm := &submatcher{
+		text:   m.src[m.Start-m.base : m.End-m.base],
+		groups: e.groups,
+		spans:  make([][2]int, len(e.groups)),
+	}
+	for i := range sm.spans {
+		sm.spans[i] = [2]int{-1, -1}
+	}
+
+	e.expr.submatch(sm, 0, func(pos int) bool {
+		return pos == len(sm.text)
+	})
+
+	res := make([]Match, len(sm.spans)+1)
+	res[0] = m
+	for i, s := range sm.spans {
+		g := m
+		g.Start, g.End = s[0], s[1]
+		if s[0] != -1 {
+			g.Start += m.Start
+			g.End += m.Start
+		}
+		res[i+1] = g
+	}
+	return res
+}
+
+// Each expression tries to match the text at pos, and on success passes the position after the
+// match to k, which decides whether the rest of the pattern matches. This allows the expression to
+// try alternatives until one works out.
+
+func (e empty) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	return k(pos)
+}
+
+func (e match) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	if pos >= len(m.text) {
+		return false
+	}
+	c, n := utf8.DecodeRune(m.text[pos:])
+	if c < e.start || c > e.end {
+		return false
+	}
+	return k(pos + n)
+}
+
+func (e seq) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	return e.left.submatch(m, pos, func(pos int) bool {
+		return e.right.submatch(m, pos, k)
+	})
+}
+
+func (e choice) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	return e.left.submatch(m, pos, k) || e.right.submatch(m, pos, k)
+}
+
+func (e repeat) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	return e.repeated.submatch(m, pos, func(next int) bool {
+		// only repeat if progress was made, otherwise this would never end
+		if next > pos && e.submatch(m, next, k) {
+			return true
+		}
+		return k(next)
+	})
+}
+
+func (e nest) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	return e.nested.submatch(m, pos, k)
+}
+
+func (e capture) submatch(m *submatcher, pos int, k func(pos int) bool) bool {
+	i := slices.Index(m.groups, e.at)
+	return e.nested.submatch(m, pos, func(next int) bool {
+		prev := m.spans[i]
+		m.spans[i] = [2]int{pos, next}
+		if k(next) {
+			return true
+		}
+		m.spans[i] = prev
+		return false
+	})
+}
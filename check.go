@@ -0,0 +1,233 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Parser parses inputs of tokens of type T with a grammar that has been checked and prepared
+// beforehand.
+//
+// Parse prepares a grammar the first time that it is used, and panics if it finds a mistake in it.
+// This is convenient when the grammar is fixed, but where it is built at run time, as with a
+// RuleSet, or where a mistake should be reported rather than crash the program, NewParser finds
+// mistakes up front and reports them as errors.
+//
+// A Parser may be used by any number of goroutines at once, as long as the grammar's rules and its
+// BeforeParse and AfterParse methods may be. A grammar whose rules keep state about the parse, such
+// as a symbol table, can be made safe by creating a new one for each parse with NewParserFunc.
+type Parser[T, U, V any] struct {
+	g Grammar[U, V]
+
+	// if set, creates the grammar used for each parse
+	newGrammar func() Grammar[U, V]
+}
+
+// Check and prepare a grammar for parsing inputs of T, which is the type of the tokens, such as the
+// interface that they implement. U and V are inferred from the grammar, so e.g.
+//
+//	p, err := tp.NewParser[Token](grammar{})
+//
+// The grammar is invalid if there are mistakes in its rules, if no rule produces the type that its
+// Parse method accepts, or if that type cannot be produced from any input, e.g. because each of the
+// rules that produce it needs another of the same type.
+func NewParser[T, U, V any](g Grammar[U, V]) (*Parser[T, U, V], error) {
+	if err := checkGrammar(g); err != nil {
+		return nil, err
+	}
+	return &Parser[T, U, V]{g: g}, nil
+}
+
+// As NewParser, but calling newGrammar to create the grammar afresh for each parse. This allows
+// the rules to be defined on a pointer and to keep state about the parse in the value it points to,
+// without that state carrying over from one parse to the next or being shared between parses that
+// run at the same time, e.g.
+//
+//	p, err := tp.NewParserFunc[Token](func() *grammar {
+//		return &grammar{scope: newScope()}
+//	})
+//
+// The grammars created must be alike, i.e. of the same type and with the same configuration, as the
+// first one is checked and prepared on behalf of the rest. Grammars that are the fields of the ones
+// created, which supply the rules of other types, are not created afresh, so the race detector
+// still checks that their rules do not modify them.
+func NewParserFunc[T any, G Grammar[U, V], U, V any](newGrammar func() G) (*Parser[T, U, V], error) {
+	g := newGrammar()
+	if err := checkGrammar[U, V](g); err != nil {
+		return nil, err
+	}
+	return &Parser[T, U, V]{
+		g: g,
+		newGrammar: func() Grammar[U, V] {
+			return newGrammar()
+		},
+	}, nil
+}
+
+// Parse the tokens, as for Parse.
+func (p *Parser[T, U, V]) Parse(toks []T) (V, error) {
+	if p.newGrammar != nil {
+		return parseHooked(p.newGrammar(), toks, parseOptions{ownHost: true})
+	}
+	return Parse(p.g, toks)
+}
+
+// The grammar that the parser uses, for use with the other functions of this package. For a parser
+// created with NewParserFunc, this is the grammar that was checked, which is not used for parsing.
+func (p *Parser[T, U, V]) Grammar() Grammar[U, V] {
+	return p.g
+}
+
+// Prepare a grammar for parsing, reporting the mistakes found in it as errors.
+func checkGrammar[U, V any](g Grammar[U, V]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*GrammarError); ok {
+				err = e
+				return
+			}
+			err = fmt.Errorf("invalid grammar: %v", r)
+		}
+	}()
+
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	if len(gr.root.Predictions) == 0 {
+		return fmt.Errorf("invalid grammar: no rules produce %s", reflect.TypeFor[U]())
+	}
+	if !gr.productive()[gr.root] {
+		return fmt.Errorf("invalid grammar: %s cannot be produced from any input", reflect.TypeFor[U]())
+	}
+	if _, err := ruleExclusions(g, gr); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Find the symbols that can be produced from some input, i.e. the tokens, and the symbols with a
+// rule whose symbols can all be produced.
+func (gr *grammar) productive() map[*symbol]bool {
+	res := map[*symbol]bool{}
+	for _, sym := range gr.symbols {
+		if len(sym.Predictions) == 0 {
+			res[sym] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, sym := range gr.symbols {
+			if res[sym] {
+				continue
+			}
+		nextRule:
+			for _, r := range sym.Predictions {
+				for _, d := range r.Deps {
+					if !res[d] {
+						continue nextRule
+					}
+				}
+				res[sym] = true
+				changed = true
+				break
+			}
+		}
+	}
+	return res
+}
+
+// GrammarError reports a method of a grammar, or a function added to a RuleSet, that cannot be a
+// rule because of its signature, or a method that configures a grammar, such as Versions, whose
+// result is invalid. A rule must return either the value it produces, or that value and an error.
+type GrammarError struct {
+	// The name of the method or function, and its type.
+	Rule string
+	Type reflect.Type
+
+	// What is wrong, naming the method or function.
+	Reason string
+}
+
+func (e *GrammarError) Error() string {
+	return "invalid grammar: " + e.Reason
+}
+
+// The signatures of the methods that configure a grammar rather than being rules, without their
+// receivers. AfterParse is given the grammar's result, so is checked separately.
+var configMethods = map[string]reflect.Type{
+	"BeforeParse": reflect.TypeFor[func(int)](),
+	"RuleOrder":   reflect.TypeFor[func() []string](),
+	"Repetition":  reflect.TypeFor[func() Repetition](),
+	"Labels":      reflect.TypeFor[func() map[string]string](),
+	"Versions":    reflect.TypeFor[func() Versions](),
+	"Features":    reflect.TypeFor[func() Features](),
+}
+
+// Check that the methods whose names are reserved for configuring a grammar have the signatures for
+// it, returning a GrammarError if not, as they would otherwise be taken for rules that have gone
+// missing.
+func checkConfigMethods(hostType reflect.Type) error {
+	for i := range hostType.NumMethod() {
+		m := hostType.Method(i)
+		if _, ok := configMethods[m.Name]; !ok && m.Name != "AfterParse" {
+			continue
+		}
+		if err := checkConfigMethod(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkConfigMethod(m reflect.Method) error {
+	in := make([]reflect.Type, m.Type.NumIn()-1)
+	for i := range in {
+		in[i] = m.Type.In(i + 1)
+	}
+	out := make([]reflect.Type, m.Type.NumOut())
+	for i := range out {
+		out[i] = m.Type.Out(i)
+	}
+	ft := reflect.FuncOf(in, out, m.Type.IsVariadic())
+
+	var want string
+	if m.Name == "AfterParse" {
+		if len(in) == 2 && in[1] == errorType && len(out) == 0 && !ft.IsVariadic() {
+			return nil
+		}
+		want = "func(V, error)"
+	} else {
+		if ft == configMethods[m.Name] {
+			return nil
+		}
+		want = configMethods[m.Name].String()
+	}
+	return &GrammarError{
+		Rule:   m.Name,
+		Type:   m.Type,
+		Reason: fmt.Sprintf("%s is %s, but the name is reserved for a method that configures the grammar, which must be %s", m.Name, ft, want),
+	}
+}
+
+// Check that a method or function can be a rule, returning a GrammarError if not.
+func checkRuleSignature(name string, ft reflect.Type) error {
+	var reason string
+	switch {
+	case ft.IsVariadic():
+		reason = "is variadic"
+	case ft.NumOut() == 0:
+		reason = "returns nothing"
+	case ft.NumOut() > 2 || ft.NumOut() == 2 && ft.Out(1) != errorType:
+		results := make([]string, ft.NumOut())
+		for i := range results {
+			results[i] = ft.Out(i).String()
+		}
+		reason = "returns (" + strings.Join(results, ", ") + ")"
+	default:
+		return nil
+	}
+	return &GrammarError{
+		Rule:   name,
+		Type:   ft,
+		Reason: fmt.Sprintf("rule %s %s; rules must return T or (T, error)", name, reason),
+	}
+}
@@ -0,0 +1,170 @@
+package tp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func TestNewParser(t *testing.T) {
+	p, err := tp.NewParser[any](interfaceGrammar{})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	res, err := p.Parse([]any{intTok{1}, plusTok{}, intTok{2}})
+	assert.Nil(t, err)
+	assert.Equal[expr](t, res, add{left: intVal{1}, right: intVal{2}})
+}
+
+type endlessList struct{}
+
+// Every list must contain another list, so no input can be a list.
+type endlessGrammar struct{}
+
+func (endlessGrammar) Parse(x endlessList) (endlessList, error) {
+	return x, nil
+}
+
+func (endlessGrammar) More(_ intTok, rest endlessList) endlessList {
+	return rest
+}
+
+func TestNewParserInvalid(t *testing.T) {
+	_, err := tp.NewParser[any](endlessGrammar{})
+	assert.Equal(t, err.Error(), "invalid grammar: tp_test.endlessList cannot be produced from any input")
+
+	_, err = tp.NewParser[any](sliceRuleGrammar{})
+	assert.Equal(t, err.Error(), "invalid grammar: explicit slice rules are not supported")
+
+	_, err = tp.NewParser[any](tp.NewRuleSet(func(x expr) (expr, error) {
+		return x, nil
+	}))
+	assert.Equal(t, err.Error(), "invalid grammar: no rules produce tp_test.expr")
+}
+
+type noResultGrammar struct{}
+
+func (noResultGrammar) Parse(x intVal) (intVal, error) { return x, nil }
+func (noResultGrammar) Int(x intTok)                   {}
+
+type threeResultGrammar struct{}
+
+func (threeResultGrammar) Parse(x intVal) (intVal, error)     { return x, nil }
+func (threeResultGrammar) Int(x intTok) (intVal, bool, error) { return intVal{}, false, nil }
+
+type stringErrorGrammar struct{}
+
+func (stringErrorGrammar) Parse(x intVal) (intVal, error) { return x, nil }
+func (stringErrorGrammar) Int(x intTok) (intVal, string)  { return intVal{}, "" }
+
+func noResultRule(x intTok) {}
+
+// a grammar whose rule for labels has the name of the method that gives rules labels
+type labelsRuleGrammar struct{}
+
+func (labelsRuleGrammar) Parse(x intVal) (intVal, error) { return x, nil }
+func (labelsRuleGrammar) Labels(x intTok) intVal         { return intVal(x) }
+
+type afterParseGrammar struct{}
+
+func (afterParseGrammar) Parse(x intVal) (intVal, error) { return x, nil }
+func (afterParseGrammar) Int(x intTok) intVal            { return intVal(x) }
+func (afterParseGrammar) AfterParse(x intVal) error      { return nil }
+
+func TestNewParserRuleSignature(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		parser  func() error
+		message string
+	}{
+		{
+			name: "NoResult",
+			parser: func() error {
+				_, err := tp.NewParser[any](noResultGrammar{})
+				return err
+			},
+			message: "invalid grammar: rule Int returns nothing; rules must return T or (T, error)",
+		},
+		{
+			name: "ThreeResults",
+			parser: func() error {
+				_, err := tp.NewParser[any](threeResultGrammar{})
+				return err
+			},
+			message: "invalid grammar: rule Int returns (tp_test.intVal, bool, error); rules must return T or (T, error)",
+		},
+		{
+			name: "StringError",
+			parser: func() error {
+				_, err := tp.NewParser[any](stringErrorGrammar{})
+				return err
+			},
+			message: "invalid grammar: rule Int returns (tp_test.intVal, string); rules must return T or (T, error)",
+		},
+		{
+			name: "RuleSet",
+			parser: func() error {
+				return tp.NewRuleSet(func(x intVal) (intVal, error) {
+					return x, nil
+				}).AddRule(noResultRule)
+			},
+			message: "invalid grammar: rule tp_test.noResultRule returns nothing; rules must return T or (T, error)",
+		},
+		{
+			name: "ReservedName",
+			parser: func() error {
+				_, err := tp.NewParser[any](labelsRuleGrammar{})
+				return err
+			},
+			message: "invalid grammar: Labels is func(tp_test.intTok) tp_test.intVal, but the name is reserved for a method that configures the grammar, which must be func() map[string]string",
+		},
+		{
+			name: "AfterParse",
+			parser: func() error {
+				_, err := tp.NewParser[any](afterParseGrammar{})
+				return err
+			},
+			message: "invalid grammar: AfterParse is func(tp_test.intVal) error, but the name is reserved for a method that configures the grammar, which must be func(V, error)",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.parser()
+			var gerr *tp.GrammarError
+			assert.True(t, errors.As(err, &gerr))
+			assert.Equal(t, err.Error(), test.message)
+		})
+	}
+}
+
+// Numbers each integer in the order that it is read, which depends on state kept for the parse.
+type countingGrammar struct {
+	count int
+}
+
+func (g *countingGrammar) Parse(x []intVal) ([]intVal, error) {
+	return x, nil
+}
+
+func (g *countingGrammar) Int(x intTok) intVal {
+	g.count++
+	return intVal{g.count}
+}
+
+func TestNewParserFunc(t *testing.T) {
+	p, err := tp.NewParserFunc[any](func() *countingGrammar {
+		return &countingGrammar{}
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for range 2 {
+		res, err := p.Parse([]any{intTok{7}, intTok{7}})
+		assert.Nil(t, err)
+		assert.Equal(t, res, []intVal{{1}, {2}})
+	}
+	assert.Equal(t, p.Grammar().(*countingGrammar).count, 0)
+}
@@ -0,0 +1,42 @@
+package tp
+
+import "reflect"
+
+// Find the symbols whose rules each match a single token of a known type, such as a table of
+// operators. Rather than predicting every rule for such a symbol and then scanning the one that
+// matches, the matcher looks up the rules for the token directly.
+func (s *scanner) markChoices() {
+	for _, sym := range s.types {
+		if len(sym.Predictions) == 0 || sym.Nullable || sym.Conditional {
+			continue
+		}
+		choices := map[reflect.Type][]*rule{}
+		for _, r := range sym.Predictions {
+			if len(r.Deps) != 1 || !isChoiceToken(r.Deps[0]) {
+				choices = nil
+				break
+			}
+			t := r.Deps[0].TokenType
+			choices[t] = append(choices[t], r)
+		}
+		sym.Choices = choices
+	}
+}
+
+// Only tokens of named, concrete types can be looked up, as other types may be assigned from tokens
+// of different types.
+func isChoiceToken(sym *symbol) bool {
+	t := sym.TokenType
+	return t != nil && t.Kind() != reflect.Interface && t.Name() != ""
+}
+
+// Scan the rules of a choice that match a token.
+func (p *matcher) scanChoice(sym *symbol, tok reflect.Value) {
+	for _, r := range sym.Choices[tok.Type()] {
+		p.addToNext(item{
+			rule:     r,
+			position: p.cur,
+			progress: 1,
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package tp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+type minusTok struct{}
+type timesTok struct{}
+
+type binOp struct {
+	op string
+}
+
+type binExpr struct {
+	left  intVal
+	op    binOp
+	right intVal
+}
+
+type opGrammar struct{}
+
+func (opGrammar) Parse(x binExpr) (binExpr, error) {
+	return x, nil
+}
+
+func (opGrammar) Expr(left intTok, op binOp, right intTok) binExpr {
+	return binExpr{intVal(left), op, intVal(right)}
+}
+
+func (opGrammar) Plus(plusTok) binOp {
+	return binOp{"+"}
+}
+
+func (opGrammar) Minus(minusTok) binOp {
+	return binOp{"-"}
+}
+
+func (opGrammar) Times(timesTok) binOp {
+	return binOp{"*"}
+}
+
+func TestChoices(t *testing.T) {
+	gr := scanGrammar(reflect.ValueOf(opGrammar{}), reflect.TypeFor[binExpr]())
+	assert.Equal(t, len(gr.symbols[reflect.TypeFor[binOp]()].Choices), 3)
+	assert.Equal(t, len(gr.symbols[reflect.TypeFor[binExpr]()].Choices), 0)
+
+	for _, c := range []struct {
+		tok any
+		op  string
+	}{
+		{plusTok{}, "+"},
+		{minusTok{}, "-"},
+		{timesTok{}, "*"},
+	} {
+		res, err := Parse(opGrammar{}, []any{intTok{1}, c.tok, intTok{2}})
+		assert.Nil(t, err)
+		assert.Equal(t, res, binExpr{intVal{1}, binOp{c.op}, intVal{2}})
+	}
+
+	_, err := Parse(opGrammar{}, []any{intTok{1}, intTok{2}})
+	assert.Equal(t, err.(*ErrUnexpectedToken).Index, 1)
+}
+
+func TestChoicesAreNotPredicted(t *testing.T) {
+	m := &matcher{
+		root: scanGrammar(reflect.ValueOf(opGrammar{}), reflect.TypeFor[binExpr]()).root,
+		toks: tokenValues([]any{intTok{1}, minusTok{}, intTok{2}}),
+	}
+	assert.Nil(t, m.run())
+
+	// the operator rules are not predicted before the operator, and only the one for minus is
+	// scanned
+	assert.Equal(t, len(m.state[1]), 1)
+	assert.Equal(t, len(m.state[2]), 2)
+}
@@ -0,0 +1,47 @@
+package tp
+
+// Find the rules that predicting each symbol leads to. Predicting a rule predicts the symbols that it
+// begins with, and those that follow them if they can be empty. Working this out for every
+// prediction is repetitive, so it is done once here and the matcher adds the rules as a block.
+//
+// Choices are not predicted when they are found, so the closure stops at them.
+func (s *scanner) markClosures() {
+	id := 0
+	for _, sym := range s.types {
+		sym.ID = id
+		id++
+	}
+	for _, sym := range s.types {
+		seen := map[*symbol]bool{sym: true}
+		todo := []*symbol{sym}
+		for len(todo) > 0 {
+			next := todo[0]
+			todo = todo[1:]
+			sym.Closure = append(sym.Closure, next)
+			for _, r := range next.Predictions {
+				for _, d := range r.Deps {
+					if len(d.Predictions) > 0 && d.Choices == nil && !seen[d] {
+						seen[d] = true
+						todo = append(todo, d)
+					}
+					if !d.Nullable && !d.Conditional && d.NotFollowedBy == nil && d.Layout == nil {
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// Whether a symbol has already been predicted at the current position, marking it as predicted if
+// not.
+func (p *matcher) markPredicted(s *symbol) bool {
+	if s.ID >= len(p.predicted) {
+		p.predicted = append(p.predicted, make([]int, s.ID+1-len(p.predicted))...)
+	}
+	if p.predicted[s.ID] == p.cur+1 {
+		return true
+	}
+	p.predicted[s.ID] = p.cur + 1
+	return false
+}
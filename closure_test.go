@@ -0,0 +1,81 @@
+package tp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+// A ladder of precedence levels, each with its own operator, as found in expression grammars.
+type ladder[Op, Next any] struct {
+	value int
+}
+
+type ladderGrammar[Op, Next any] struct{}
+
+func (ladder[Op, Next]) Grammar() ladderGrammar[Op, Next] {
+	return ladderGrammar[Op, Next]{}
+}
+
+func (ladderGrammar[Op, Next]) Op(left ladder[Op, Next], _ Op, right Next) ladder[Op, Next] {
+	return ladder[Op, Next]{left.value + ladderValue(right)}
+}
+
+func (ladderGrammar[Op, Next]) Up(x Next) ladder[Op, Next] {
+	return ladder[Op, Next]{ladderValue(x)}
+}
+
+func ladderValue(x any) int {
+	if x, ok := x.(intTok); ok {
+		return x.value
+	}
+	return int(reflect.ValueOf(x).Field(0).Int())
+}
+
+type op1 struct{}
+type op2 struct{}
+type op3 struct{}
+type op4 struct{}
+type op5 struct{}
+
+type ladderTop = ladder[op1, ladder[op2, ladder[op3, ladder[op4, ladder[op5, intTok]]]]]
+
+type ladderRuleset struct{}
+
+func (ladderRuleset) Parse(x ladderTop) (int, error) {
+	return x.value, nil
+}
+
+// An input that uses every level of the ladder.
+func ladderInput(n int) []any {
+	ops := []any{op1{}, op2{}, op3{}, op4{}, op5{}}
+	toks := []any{intTok{1}}
+	for i := range n {
+		toks = append(toks, ops[i%len(ops)], intTok{1})
+	}
+	return toks
+}
+
+func TestLadderClosure(t *testing.T) {
+	gr := scanGrammar(reflect.ValueOf(ladderRuleset{}), reflect.TypeFor[ladderTop]())
+
+	// every level is predicted along with the top
+	assert.Equal(t, len(gr.root.Closure), 5)
+
+	res, err := Parse(ladderRuleset{}, ladderInput(20))
+	assert.Nil(t, err)
+	assert.Equal(t, res, 21)
+}
+
+func BenchmarkRecognizeLadder(b *testing.B) {
+	gr := scanGrammar(reflect.ValueOf(ladderRuleset{}), reflect.TypeFor[ladderTop]())
+	toks := ladderInput(500)
+	b.ReportAllocs()
+	for b.Loop() {
+		_, err := recognize(gr, toks)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
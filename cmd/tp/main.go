@@ -0,0 +1,71 @@
+// Command tp is a playground for languages built with tp. It loads a language from a Go plugin and
+// then reads texts from standard input, showing how the language tokenizes and parses each one.
+//
+// The plugin is a main package that declares the language as a variable:
+//
+//	var Language = &tp.Language[T, U, V]{...}
+//
+// It is built with
+//
+//	go build -buildmode=plugin -o lang.so ./path/to/plugin
+//
+// and then loaded with
+//
+//	tp lang.so
+//
+// The plugin must be built against the same version of tp as the command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"plugin"
+	"reflect"
+
+	"github.com/bobappleyard/tp/playground"
+)
+
+func main() {
+	symbol := flag.String("symbol", "Language", "the name of the variable that holds the language")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] plugin.so\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	lang, err := load(flag.Arg(0), *symbol)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := playground.Play(lang, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func load(path, symbol string) (playground.Language, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	// variables are looked up as pointers to them
+	v := reflect.ValueOf(sym)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		if lang, ok := v.Interface().(playground.Language); ok {
+			return lang, nil
+		}
+		v = v.Elem()
+	}
+	return nil, fmt.Errorf("%s: %s is a %T, not a *tp.Language", path, symbol, sym)
+}
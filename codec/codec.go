@@ -0,0 +1,306 @@
+// Package codec converts the trees that tp grammars build to and from JSON.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Codec converts trees built by a grammar to and from JSON, so that they can be stored or sent
+// to other processes. Trees are made of the types that appear in the grammar, and fields of these
+// types may be interfaces. JSON does not record which type a value in an interface has, so the codec
+// writes such values as
+//
+//	{"type": "pkg.Name", "value": ...}
+//
+// where the type is one that the codec knows about. The codec knows about every type that appears
+// in the grammar, and others can be added with Register.
+//
+// As with encoding/json, only exported fields are written and read.
+type Codec struct {
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+// Create a codec that knows about the types that appear in a grammar.
+func New[U, V any](g tp.Grammar[U, V]) *Codec {
+	c := &Codec{
+		types: map[string]reflect.Type{},
+		names: map[reflect.Type]string{},
+	}
+	for _, t := range tp.SymbolTypes(g) {
+		if t.Kind() != reflect.Interface {
+			c.register(t)
+		}
+	}
+	return c
+}
+
+// Tell the codec about the types of some values, so that values of those types can appear in
+// interfaces in the trees that it converts.
+func (c *Codec) Register(values ...any) {
+	for _, v := range values {
+		c.register(reflect.TypeOf(v))
+	}
+}
+
+func (c *Codec) register(t reflect.Type) {
+	name := t.String()
+	if prev, ok := c.types[name]; ok && prev != t {
+		panic(fmt.Sprintf("codec: types %s and %s have the same name", prev.PkgPath(), t.PkgPath()))
+	}
+	c.types[name] = t
+	c.names[t] = name
+}
+
+// Register the types that the codec knows about with encoding/gob, by the same names, so that trees
+// can also be sent using gob.
+func (c *Codec) RegisterGob() {
+	for name, t := range c.types {
+		gob.RegisterName(name, reflect.Zero(t).Interface())
+	}
+}
+
+// Convert a tree to JSON. Pointers are written as the values they point at, so a tree held in an
+// interface can be written with the interface's type by passing a pointer to it, e.g. Marshal(&tree)
+// to be read back with Unmarshal(data, &tree).
+func (c *Codec) Marshal(tree any) ([]byte, error) {
+	v := reflect.ValueOf(tree)
+	if !v.IsValid() {
+		return []byte("null"), nil
+	}
+	enc, err := c.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(enc)
+}
+
+// Read a tree from JSON into the value that tree points to.
+func (c *Codec) Unmarshal(data []byte, tree any) error {
+	v := reflect.ValueOf(tree)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("codec: cannot unmarshal into %T", tree)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var raw any
+	if err := d.Decode(&raw); err != nil {
+		return err
+	}
+	return c.decode(raw, v.Elem())
+}
+
+// Convert a value into something that encoding/json can write.
+func (c *Codec) encode(v reflect.Value) (any, error) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		name, ok := c.names[v.Elem().Type()]
+		if !ok {
+			return nil, fmt.Errorf("codec: type %s is not known to the codec", v.Elem().Type())
+		}
+		value, err := c.encode(v.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": name, "value": value}, nil
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return c.encode(v.Elem())
+
+	case reflect.Struct:
+		res := map[string]any{}
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			enc, err := c.encode(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			res[f.Name] = enc
+		}
+		return res, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		res := make([]any, v.Len())
+		for i := range res {
+			enc, err := c.encode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = enc
+		}
+		return res, nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("codec: cannot encode %s, as its keys are not strings", v.Type())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		res := map[string]any{}
+		for it := v.MapRange(); it.Next(); {
+			enc, err := c.encode(it.Value())
+			if err != nil {
+				return nil, err
+			}
+			res[it.Key().String()] = enc
+		}
+		return res, nil
+
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("codec: cannot encode %s", v.Type())
+}
+
+// Fill in a value from what encoding/json has read.
+func (c *Codec) decode(raw any, v reflect.Value) error {
+	if raw == nil {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		wrapped, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("codec: expected a typed value for %s", v.Type())
+		}
+		name, _ := wrapped["type"].(string)
+		t, ok := c.types[name]
+		if !ok {
+			return fmt.Errorf("codec: type %q is not known to the codec", name)
+		}
+		if !t.AssignableTo(v.Type()) {
+			return fmt.Errorf("codec: %s is not a %s", t, v.Type())
+		}
+		elem := reflect.New(t).Elem()
+		if err := c.decode(wrapped["value"], elem); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := c.decode(raw, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("codec: expected an object for %s", v.Type())
+		}
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if err := c.decode(fields[f.Name], v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]any)
+		if !ok {
+			return fmt.Errorf("codec: expected an array for %s", v.Type())
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		} else if len(items) != v.Len() {
+			return fmt.Errorf("codec: expected %d items for %s", v.Len(), v.Type())
+		}
+		for i, item := range items {
+			if err := c.decode(item, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		items, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("codec: expected an object for %s", v.Type())
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(items)))
+		for k, item := range items {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := c.decode(item, elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+		return nil
+
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("codec: expected a boolean for %s", v.Type())
+		}
+		v.SetBool(b)
+		return nil
+
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("codec: expected a string for %s", v.Type())
+		}
+		v.SetString(s)
+		return nil
+	}
+
+	n, ok := raw.(json.Number)
+	if !ok {
+		return fmt.Errorf("codec: expected a number for %s", v.Type())
+	}
+	var err error
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var x int64
+		x, err = strconv.ParseInt(string(n), 10, v.Type().Bits())
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var x uint64
+		x, err = strconv.ParseUint(string(n), 10, v.Type().Bits())
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		x, err = strconv.ParseFloat(string(n), v.Type().Bits())
+		v.SetFloat(x)
+	default:
+		return fmt.Errorf("codec: cannot decode %s", v.Type())
+	}
+	return err
+}
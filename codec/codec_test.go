@@ -0,0 +1,68 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+	"github.com/bobappleyard/tp/codec"
+)
+
+func ExampleCodec() {
+	tree, err := tp.Parse(sumGrammar{}, []any{intTok{1}, plusTok{}, intTok{2}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	c := codec.New(sumGrammar{})
+	data, err := c.Marshal(&tree)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(data))
+
+	var back SumExpr
+	err = c.Unmarshal(data, &back)
+	fmt.Printf("%+v %v\n", back, err)
+
+	// Output:
+	// {"type":"codec_test.Sum","value":{"Left":{"type":"codec_test.Num","value":{"Value":1}},"Right":{"Value":2}}}
+	// {Left:{Value:1} Right:{Value:2}} <nil>
+}
+
+func TestTreeCodecGob(t *testing.T) {
+	c := codec.New(sumGrammar{})
+	c.RegisterGob()
+
+	var tree SumExpr = Sum{Sum{Num{1}, Num{2}}, Num{3}}
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(&tree))
+
+	var back SumExpr
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(&back))
+	assert.Equal(t, back, tree)
+}
+
+func TestTreeCodecErrors(t *testing.T) {
+	c := codec.New(sumGrammar{})
+
+	var tree SumExpr = Other{"x"}
+	_, err := c.Marshal(&tree)
+	assert.Equal(t, err.Error(), "codec: type codec_test.Other is not known to the codec")
+
+	c.Register(Other{})
+	data, err := c.Marshal(&tree)
+	assert.Nil(t, err)
+
+	var back SumExpr
+	assert.Nil(t, c.Unmarshal(data, &back))
+	assert.Equal(t, back, tree)
+
+	err = c.Unmarshal([]byte(`{"type":"codec_test.Num","value":{"Value":"x"}}`), &back)
+	assert.Equal(t, err.Error(), "codec: expected a number for int")
+}
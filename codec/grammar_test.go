@@ -0,0 +1,45 @@
+package codec_test
+
+// A grammar of sums of numbers, whose trees have interface fields.
+
+type intTok struct {
+	value int
+}
+
+type plusTok struct{}
+
+type SumExpr interface {
+	sumExpr()
+}
+
+type Sum struct {
+	Left  SumExpr
+	Right Num
+}
+
+type Num struct {
+	Value int
+}
+
+// Not produced by the grammar.
+type Other struct {
+	Name string
+}
+
+func (Sum) sumExpr()   {}
+func (Num) sumExpr()   {}
+func (Other) sumExpr() {}
+
+type sumGrammar struct{}
+
+func (sumGrammar) Parse(x SumExpr) (SumExpr, error) {
+	return x, nil
+}
+
+func (sumGrammar) Num(x intTok) Num {
+	return Num{x.value}
+}
+
+func (sumGrammar) Sum(left SumExpr, _ plusTok, right Num) Sum {
+	return Sum{left, right}
+}
@@ -0,0 +1,163 @@
+package codec
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Describe the JSON that the codec writes for trees of the given type, as a JSON Schema. This lets
+// programs that are not written in Go make sense of the trees. Each named type is described once,
+// under "$defs", and an interface is described as a choice between the types that the codec knows
+// to implement it.
+func (c *Codec) Schema(root reflect.Type) ([]byte, error) {
+	s := &schemaBuilder{codec: c, defs: map[string]any{}}
+	top, err := s.schema(root)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$defs":   s.defs,
+	}
+	for k, v := range top {
+		doc[k] = v
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type schemaBuilder struct {
+	codec *Codec
+	defs  map[string]any
+}
+
+// Describe a named type under "$defs", returning a reference to it.
+func (s *schemaBuilder) ref(t reflect.Type, describe func() (map[string]any, error)) (map[string]any, error) {
+	name := t.String()
+	ref := map[string]any{"$ref": "#/$defs/" + name}
+	if _, ok := s.defs[name]; ok {
+		return ref, nil
+	}
+	// reserve the name first, so that recursive types refer back to it
+	s.defs[name] = nil
+	def, err := describe()
+	if err != nil {
+		return nil, err
+	}
+	s.defs[name] = def
+	return ref, nil
+}
+
+func (s *schemaBuilder) schema(t reflect.Type) (map[string]any, error) {
+	switch t.Kind() {
+	case reflect.Interface:
+		return s.ref(t, func() (map[string]any, error) {
+			return s.interfaceSchema(t)
+		})
+
+	case reflect.Pointer:
+		elem, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"anyOf": []any{elem, map[string]any{"type": "null"}}}, nil
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return s.ref(t, func() (map[string]any, error) {
+			return s.structSchema(t)
+		})
+
+	case reflect.Slice, reflect.Array:
+		items, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		res := map[string]any{"type": "array", "items": items}
+		if t.Kind() == reflect.Slice {
+			res["type"] = []any{"array", "null"}
+		} else {
+			res["minItems"] = t.Len()
+			res["maxItems"] = t.Len()
+		}
+		return res, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("codec: cannot describe %s, as its keys are not strings", t)
+		}
+		elem, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": []any{"object", "null"}, "additionalProperties": elem}, nil
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	}
+	return nil, fmt.Errorf("codec: cannot describe %s", t)
+}
+
+func (s *schemaBuilder) structSchema(t reflect.Type) (map[string]any, error) {
+	props := map[string]any{}
+	required := []any{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fs, err := s.schema(f.Type)
+		if err != nil {
+			return nil, err
+		}
+		props[f.Name] = fs
+		required = append(required, f.Name)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+func (s *schemaBuilder) interfaceSchema(t reflect.Type) (map[string]any, error) {
+	var impls []reflect.Type
+	for impl := range s.codec.names {
+		if impl.Kind() != reflect.Interface && impl.Implements(t) {
+			impls = append(impls, impl)
+		}
+	}
+	slices.SortFunc(impls, func(a, b reflect.Type) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+
+	choices := []any{map[string]any{"type": "null"}}
+	for _, impl := range impls {
+		value, err := s.schema(impl)
+		if err != nil {
+			return nil, err
+		}
+		choices = append(choices, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"type":  map[string]any{"const": s.codec.names[impl]},
+				"value": value,
+			},
+			"required":             []any{"type", "value"},
+			"additionalProperties": false,
+		})
+	}
+	return map[string]any{"oneOf": choices}, nil
+}
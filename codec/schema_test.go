@@ -0,0 +1,44 @@
+package codec_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp/codec"
+)
+
+func TestTreeSchema(t *testing.T) {
+	c := codec.New(sumGrammar{})
+	data, err := c.Schema(reflect.TypeFor[SumExpr]())
+	assert.Nil(t, err)
+
+	var schema struct {
+		Ref  string                     `json:"$ref"`
+		Defs map[string]json.RawMessage `json:"$defs"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &schema))
+	assert.Equal(t, schema.Ref, "#/$defs/codec_test.SumExpr")
+
+	var names []string
+	for name := range schema.Defs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	assert.Equal(t, names, []string{"codec_test.Num", "codec_test.Sum", "codec_test.SumExpr"})
+
+	assert.Equal(t, string(schema.Defs["codec_test.Num"]), `{
+      "additionalProperties": false,
+      "properties": {
+        "Value": {
+          "type": "integer"
+        }
+      },
+      "required": [
+        "Value"
+      ],
+      "type": "object"
+    }`)
+}
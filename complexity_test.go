@@ -0,0 +1,100 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type countList struct {
+	n int
+}
+
+type leftListGrammar struct{}
+
+func (leftListGrammar) Parse(x countList) (int, error) {
+	return x.n, nil
+}
+
+func (leftListGrammar) More(rest countList, _ intTok) countList {
+	return countList{rest.n + 1}
+}
+
+func (leftListGrammar) One(intTok) countList {
+	return countList{1}
+}
+
+type rightListGrammar struct{}
+
+func (rightListGrammar) Parse(x countList) (int, error) {
+	return x.n, nil
+}
+
+func (rightListGrammar) More(_ intTok, rest countList) countList {
+	return countList{rest.n + 1}
+}
+
+func (rightListGrammar) One(intTok) countList {
+	return countList{1}
+}
+
+func TestRightRecursion(t *testing.T) {
+	for _, n := range []int{1, 2, 10, 100} {
+		got, err := tp.Parse(rightListGrammar{}, make([]intTok, n))
+		assert.Nil(t, err)
+		assert.Equal(t, got, n)
+
+		got, err = tp.ParseUnambiguous(rightListGrammar{}, make([]intTok, n), 2)
+		assert.Nil(t, err)
+		assert.Equal(t, got, n)
+	}
+}
+
+type sliceListGrammar struct{}
+
+func (sliceListGrammar) Parse(x countList) (int, error) {
+	return x.n, nil
+}
+
+func (sliceListGrammar) List(xs []intTok) countList {
+	return countList{len(xs)}
+}
+
+// Grammars that are unambiguous, and whose recursion is on the left, on the right or within
+// brackets, parse in time linear in the length of their input. Matching an input 8 times as long
+// should take about 8 times as much work, where quadratic time would take 64 times as much.
+func TestLinearTime(t *testing.T) {
+	ints := func(n int) []intTok {
+		return make([]intTok, n)
+	}
+	for _, c := range []struct {
+		name string
+		work func(n int) int
+	}{
+		{"left recursion", func(n int) int {
+			return tp.MatcherWork(leftListGrammar{}, ints(n))
+		}},
+		{"right recursion", func(n int) int {
+			return tp.MatcherWork(rightListGrammar{}, ints(n))
+		}},
+		{"slice", func(n int) int {
+			return tp.MatcherWork(sliceListGrammar{}, ints(n))
+		}},
+		{"flat json", func(n int) int {
+			return tp.MatcherWork(jsonGrammar{}, jsonDocument(n/20))
+		}},
+		{"nested json", func(n int) int {
+			return tp.MatcherWork(jsonGrammar{}, nestedJSONDocument(n/4))
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			small := c.work(500)
+			large := c.work(4000)
+			ratio := float64(large) / float64(small)
+			if ratio > 9 {
+				t.Errorf("8 times the input took %.1f times as much work", ratio)
+			}
+		})
+	}
+}
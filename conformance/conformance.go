@@ -0,0 +1,177 @@
+// Package conformance runs suites of example texts against languages built with tp, and reports
+// the results in formats that test tools understand.
+package conformance
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Result describes the outcome of a single case in a conformance suite.
+type Result struct {
+	Name string
+
+	// Why the case failed, or the empty string if it passed.
+	Failure string
+}
+
+// Run a suite of conformance cases against a language.
+//
+// The cases are read from a file system, which can be a directory on disk by way of os.DirFS. Each
+// case is a pair of files with the same name, and is named after them: one with the extension .in
+// that contains the text to parse, and one with the extension .out that describes the expected
+// outcome. The first line of the .out file is one of:
+//
+//	accept       // the text parses
+//	reject       // the text does not parse
+//	reject 3:5   // the text does not parse, and the problem is at line 3, column 5
+//
+// For a text that is accepted, the rest of the .out file, if any, is compared with the result of
+// the parse as described by dump. If dump is nil then fmt.Sprint is used. Leading and trailing
+// whitespace is ignored in the comparison.
+func Run[T, U, V any](lang *tp.Language[T, U, V], fsys fs.FS, dump func(V) string) ([]Result, error) {
+	if dump == nil {
+		dump = func(v V) string {
+			return fmt.Sprint(v)
+		}
+	}
+
+	var res []Result
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".in" {
+			return nil
+		}
+		name := strings.TrimSuffix(p, ".in")
+
+		src, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		expected, err := fs.ReadFile(fsys, name+".out")
+		if errors.Is(err, fs.ErrNotExist) {
+			res = append(res, Result{Name: name, Failure: "no expected outcome"})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		res = append(res, Result{
+			Name:    name,
+			Failure: runCase(lang, src, string(expected), dump),
+		})
+		return nil
+	})
+	return res, err
+}
+
+func runCase[T, U, V any](lang *tp.Language[T, U, V], src []byte, expected string, dump func(V) string) string {
+	outcome, rest, _ := strings.Cut(expected, "\n")
+	verdict, pos, _ := strings.Cut(strings.TrimSpace(outcome), " ")
+
+	v, err := lang.Parse(src)
+
+	switch verdict {
+	case "accept":
+		if err != nil {
+			return fmt.Sprintf("expected accept, got %s", err)
+		}
+		want := strings.TrimSpace(rest)
+		if want == "" {
+			return ""
+		}
+		if got := strings.TrimSpace(dump(v)); got != want {
+			return fmt.Sprintf("expected:\n%s\ngot:\n%s", want, got)
+		}
+		return ""
+
+	case "reject":
+		if err == nil {
+			return "expected reject, got accept"
+		}
+		if pos == "" {
+			return ""
+		}
+		var syntax *tp.SyntaxError
+		if !errors.As(err, &syntax) {
+			return fmt.Sprintf("expected reject at %s, got %s", pos, err)
+		}
+		if got := tp.NewLineIndex(src).Position(syntax.Offset).String(); got != pos {
+			return fmt.Sprintf("expected reject at %s, got reject at %s: %s", pos, got, err)
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("unknown outcome %q", outcome)
+}
+
+// Write results in the Test Anything Protocol format.
+func WriteTAP(w io.Writer, results []Result) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(results))
+	for i, r := range results {
+		if r.Failure == "" {
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, r.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %d - %s\n", i+1, r.Name)
+		for _, line := range strings.Split(r.Failure, "\n") {
+			fmt.Fprintf(&b, "# %s\n", line)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Write results as a JUnit XML test suite with the given name.
+func WriteJUnit(w io.Writer, suite string, results []Result) error {
+	s := junitSuite{Name: suite, Tests: len(results)}
+	for _, r := range results {
+		c := junitCase{Name: r.Name}
+		if r.Failure != "" {
+			s.Failures++
+			message, _, _ := strings.Cut(r.Failure, "\n")
+			c.Failure = &junitFailure{Message: message, Text: r.Failure}
+		}
+		s.Cases = append(s.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(s); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
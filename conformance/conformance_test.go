@@ -0,0 +1,77 @@
+package conformance_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp/conformance"
+	"github.com/bobappleyard/tp/internal/testlang"
+)
+
+var conformanceSuite = fstest.MapFS{
+	"array.in":            {Data: []byte("[1, 22]")},
+	"array.out":           {Data: []byte("accept\n[1 22]\n")},
+	"empty.in":            {Data: []byte("[]")},
+	"empty.out":           {Data: []byte("accept")},
+	"errors/eof.in":       {Data: []byte("[\n  1,\n")},
+	"errors/eof.out":      {Data: []byte("reject 3:1")},
+	"errors/comma.in":     {Data: []byte("[\n  1,,\n]")},
+	"errors/comma.out":    {Data: []byte("reject 2:5")},
+	"errors/lex.in":       {Data: []byte("[x]")},
+	"errors/lex.out":      {Data: []byte("reject 1:2")},
+	"errors/wrong.in":     {Data: []byte("[1]")},
+	"errors/wrong.out":    {Data: []byte("reject")},
+	"errors/missing.in":   {Data: []byte("[1]")},
+	"mismatch.in":         {Data: []byte("[1]")},
+	"mismatch.out":        {Data: []byte("accept\n[2]")},
+	"errors/position.in":  {Data: []byte("[1 1]")},
+	"errors/position.out": {Data: []byte("reject 1:1")},
+}
+
+func ExampleRun() {
+	results, err := conformance.Run(testlang.Numbers, conformanceSuite, nil)
+	if err != nil {
+		panic(err)
+	}
+	conformance.WriteTAP(os.Stdout, results)
+
+	// Output:
+	// TAP version 13
+	// 1..9
+	// ok 1 - array
+	// ok 2 - empty
+	// ok 3 - errors/comma
+	// ok 4 - errors/eof
+	// ok 5 - errors/lex
+	// not ok 6 - errors/missing
+	// # no expected outcome
+	// not ok 7 - errors/position
+	// # expected reject at 1:1, got reject at 1:4: offset 3: unexpected token: testlang.Number{Value:1}
+	// not ok 8 - errors/wrong
+	// # expected reject, got accept
+	// not ok 9 - mismatch
+	// # expected:
+	// # [2]
+	// # got:
+	// # [1]
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var b strings.Builder
+	err := conformance.WriteJUnit(&b, "json", []conformance.Result{
+		{Name: "good"},
+		{Name: "bad", Failure: "expected reject\ngot <accept>"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, b.String(), `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="json" tests="2" failures="1">
+  <testcase name="good"></testcase>
+  <testcase name="bad">
+    <failure message="expected reject">expected reject&#xA;got &lt;accept&gt;</failure>
+  </testcase>
+</testsuite>
+`)
+}
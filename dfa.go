@@ -0,0 +1,121 @@
+package tp
+
+import (
+	"slices"
+)
+
+// A state of the compiled machine, standing for a set of states of the original machine.
+type dfaState struct {
+	// the runes that lead out of the state, in order, and the state that each range leads to
+	moves []subsetMove
+
+	// the state that each ASCII rune leads to, plus one, so that zero means there is no move
+	ascii [128]int32
+
+	// the final state that a token would be yielded for, or -1
+	final int
+
+	// the actions attached to the states of the original machine
+	enter []func(start, pos int)
+
+	// the states of the original machine that this state stands for
+	states []LexerState
+}
+
+// Freeze the machine and convert it into one that is only ever in a single state at a time, so that
+// each rune of the text is dealt with in constant time rather than in time proportional to the
+// number of states the machine might be in. Streams produce the same tokens, and invoke the same
+// actions, as they would without compiling.
+//
+// This is an optimization for lexers that are run over large amounts of text. Compiling takes time,
+// and a machine with n states can in the worst case require 2ⁿ states once compiled, so it is not
+// done automatically. The patterns that cause this are those where a choice must be kept open for
+// many runes, such as `[ab]*a[ab][ab][ab]`, which needs twice as many states for each `[ab]` added.
+//
+// As with Freeze, compiling must not be done while streams are running on the machine, so compile
+// the machine before it is used.
+func (p *Lexer[T]) Compile() {
+	if p.dfa != nil {
+		return
+	}
+	p.Freeze()
+
+	sets := p.subsets()
+	dfa := make([]dfaState, len(sets))
+	for i, set := range sets {
+		s := &dfa[i]
+		s.moves = set.Moves
+		s.states = set.States
+		s.final = p.subsetFinal(set.States)
+		for _, op := range p.enterActions {
+			if slices.Contains(set.States, op.Given) {
+				s.enter = append(s.enter, op.Do)
+			}
+		}
+		for _, m := range set.Moves {
+			for c := m.Min; c <= m.Max && c < 128; c++ {
+				s.ascii[c] = int32(m.Then) + 1
+			}
+		}
+	}
+	for a := range p.dfaStart {
+		states := p.closure(p.startStates(a))
+		p.dfaStart[a] = slices.IndexFunc(sets, func(set subsetState) bool {
+			return slices.Equal(set.States, states)
+		})
+	}
+	p.dfa = dfa
+}
+
+// As runNFA, but using the compiled machine. The state that it stopped in is left in l.stopped.
+func (l *Stream[T]) runDFA(start int) (int, int, int) {
+	pos := start
+	end := start
+	final := -1
+	state := l.prog.dfaStart[l.anchor(start)]
+
+	for {
+		s := &l.prog.dfa[state]
+		for _, do := range s.enter {
+			do(start, pos)
+		}
+		if pos != start && s.final != -1 {
+			final, end = s.final, pos
+		}
+
+		c, n, ok := l.char(start, pos)
+		if !ok {
+			break
+		}
+		next, ok := s.move(c)
+		if !ok {
+			break
+		}
+		state = next
+		pos += n
+	}
+
+	l.stopped = state
+	return final, end, pos
+}
+
+// Find the state that a rune leads to.
+func (s *dfaState) move(c rune) (int, bool) {
+	if c >= 0 && c < 128 {
+		next := s.ascii[c]
+		return int(next) - 1, next != 0
+	}
+	i, ok := slices.BinarySearchFunc(s.moves, c, func(m subsetMove, c rune) int {
+		switch {
+		case m.Max < c:
+			return -1
+		case m.Min > c:
+			return 1
+		}
+		return 0
+	})
+	if !ok {
+		return 0, false
+	}
+	return s.moves[i].Then, true
+}
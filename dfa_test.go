@@ -0,0 +1,108 @@
+package tp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func compileTestLexer(t testing.TB) *Lexer[string] {
+	var specs []TokenSpec[string]
+	for _, p := range []string{`if`, `[a-z]+`, `\d+(\.\d+)?`, `\s+`, `"([^"]|\\.)*"`, `[^ -~\s]+`, `[^a-z\d\s]`} {
+		specs = append(specs, Regex(p, func(start int, text string) (string, error) {
+			return text, nil
+		}))
+	}
+	l, err := NewLexer(specs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestCompile(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"if x1 > 12.5 { iffy = 3 }",
+		`say "hello \"there\"" to üü and 日本`,
+		"12.",
+		`"unterminated`,
+	} {
+		t.Run(src, func(t *testing.T) {
+			expect, expectErr := compileTestLexer(t).Tokenize([]byte(src)).Force()
+
+			l := compileTestLexer(t)
+			l.Compile()
+			got, err := l.Tokenize([]byte(src)).Force()
+			assert.Equal(t, err, expectErr)
+			assert.Equal(t, got, expect)
+
+			got, err = l.TokenizeReader(strings.NewReader(src)).Force()
+			assert.Equal(t, err, expectErr)
+			assert.Equal(t, got, expect)
+		})
+	}
+}
+
+func TestCompileFreezes(t *testing.T) {
+	l := compileTestLexer(t)
+	l.Compile()
+	defer func() {
+		assert.Equal(t, recover(), any("tp: modifying a frozen lexer"))
+	}()
+	l.State()
+}
+
+func TestCompileEnter(t *testing.T) {
+	var lp Lexer[int]
+
+	depth := 0
+	indent := lp.State()
+	lp.Rune(0, indent, '\n')
+	lp.Rune(indent, indent, ' ')
+	lp.Enter(indent, func(start, pos int) {
+		depth = pos - start - 1
+	})
+	lp.Final(indent, func(start int, text string) (int, error) {
+		return depth, nil
+	})
+
+	word := lp.State()
+	lp.Range(0, word, 'a', 'z')
+	lp.Range(word, word, 'a', 'z')
+	lp.Final(word, func(start int, text string) (int, error) {
+		return -1, nil
+	})
+
+	lp.Compile()
+
+	toks, err := lp.Tokenize([]byte("a\n  bc\n d\ne")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []int{-1, 2, -1, 1, -1, 0, -1})
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	src := []byte(strings.Repeat(`if x1 > 12.5 { iffy = "a \"b\" c" }`+"\n", 1000))
+	for _, mode := range []string{"NFA", "Frozen", "Compiled"} {
+		b.Run(mode, func(b *testing.B) {
+			l := compileTestLexer(b)
+			switch mode {
+			case "Frozen":
+				l.Freeze()
+			case "Compiled":
+				l.Compile()
+			}
+			b.SetBytes(int64(len(src)))
+			for b.Loop() {
+				s := l.Tokenize(src)
+				for s.Next() {
+				}
+				if s.Err() != nil {
+					b.Fatal(fmt.Sprint(s.Err()))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package tp
+
+// Diagnostic describes a problem found in a file.
+type Diagnostic struct {
+	Path string
+
+	// Where the problem was found. This is the zero Position if the problem is not tied to a
+	// location, such as when the file could not be read.
+	Position Position
+
+	Err error
+}
+
+func (d Diagnostic) Error() string {
+	return EnglishMessages{}.Diagnostic(d, d.Err.Error())
+}
+
+// Describe the problem using a catalog of messages, such as one that translates them into another
+// language. See FormatError.
+func (d Diagnostic) Format(m Messages) string {
+	return FormatError(d, m)
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
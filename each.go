@@ -0,0 +1,129 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+)
+
+// Parse a stream of tokens with a grammar whose Parse method accepts a slice, such as a file of
+// records, yielding each item of the slice once the tokens that follow it show that it is
+// complete. This allows the items of a large input to be dealt with one at a time, without holding
+// every token, or every item, at once. If the tokens cannot be parsed then the items before the
+// problem are yielded, followed by the error.
+//
+// The items are found by parsing the tokens as the item type, in the manner of ParseAs, rather than
+// as the slice, and so the grammar's Parse method is not called, nor BeforeParse or AfterParse.
+// Each item is as long as it can be: where the next token could either continue an item or begin
+// another, it continues the item. As each token is added to an item the item is parsed again, so
+// this is only fast where items are short.
+//
+// The indexes of tokens, such as those of an ErrUnexpectedToken or of the Span of a SpannedSlice,
+// count from the beginning of the stream.
+func ParseEach[I, T, V any](g Grammar[[]I, V], toks iter.Seq[T]) iter.Seq2[I, error] {
+	return func(yield func(I, error) bool) {
+		var zero I
+
+		gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[[]I]())
+		sym, ok := gr.symbols[reflect.TypeFor[I]()]
+		if !ok || len(sym.Predictions) == 0 {
+			yield(zero, fmt.Errorf("%s is not a nonterminal of the grammar", reflect.TypeFor[I]()))
+			return
+		}
+
+		p := eachParser[I]{g: reflect.ValueOf(g), gr: gr, sym: sym}
+		for tok := range toks {
+			if p.add(reflect.ValueOf(tok)) {
+				continue
+			}
+			if len(p.buf) == 1 {
+				yield(zero, p.err)
+				return
+			}
+
+			// the token may begin the next item
+			last, tokErr := p.buf[len(p.buf)-1], p.err
+			p.buf = p.buf[:len(p.buf)-1]
+			x, err := p.item()
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = tokErr
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(x, nil) {
+				return
+			}
+			p.start += len(p.buf)
+			p.buf = p.buf[:0]
+			if !p.add(last) {
+				yield(zero, p.err)
+				return
+			}
+		}
+		if len(p.buf) == 0 {
+			return
+		}
+		x, err := p.item()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		yield(x, nil)
+	}
+}
+
+// The state of ParseEach: the tokens of the item being read, and where they begin.
+type eachParser[I any] struct {
+	g   reflect.Value
+	gr  *grammar
+	sym *symbol
+
+	buf   []reflect.Value
+	start int
+
+	// the error of the last token added
+	err error
+}
+
+// Add a token to the item, reporting whether the item could still be parsed.
+func (p *eachParser[I]) add(tok reflect.Value) bool {
+	p.buf = append(p.buf, tok)
+	m := newMatcher(p.gr, p.sym, p.buf, true)
+	p.err = p.shift(m.run())
+	return p.err == nil || m.failedAt == len(p.buf)
+}
+
+// Build the item that the tokens describe.
+func (p *eachParser[I]) item() (I, error) {
+	var zero I
+	m := newMatcher(p.gr, p.sym, p.buf, true)
+	if err := m.run(); err != nil {
+		return zero, p.shift(err)
+	}
+	b := m.builder(p.g)
+	b.offset = p.start
+	v, err := b.build()
+	if err != nil {
+		return zero, err
+	}
+	return v.Interface().(I), nil
+}
+
+// Count the tokens in an error from the beginning of the stream, rather than of the item.
+func (p *eachParser[I]) shift(err error) error {
+	var (
+		unexpected *ErrUnexpectedToken
+		unclosed   *ErrUnclosedBracket
+	)
+	if errors.As(err, &unexpected) {
+		unexpected.Index += p.start
+	}
+	if errors.As(err, &unclosed) {
+		unclosed.OpenIndex += p.start
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleParseEach() {
+	toks := removeWhitespace(must(lexicon.Tokenize([]byte(`[1 2] [] [3]`)).Force()))
+	for b, err := range tp.ParseEach(numberBlockGrammar{}, slices.Values(toks)) {
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(len(b.values.Items), b.values.Span)
+	}
+
+	// Output:
+	// 2 {1 3}
+	// 0 {5 5}
+	// 1 {7 8}
+}
+
+// Yield tokens, counting how many have been taken.
+func countTokens(toks []jsonToken, taken *int) iter.Seq[jsonToken] {
+	return func(yield func(jsonToken) bool) {
+		for _, t := range toks {
+			*taken++
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+func TestParseEach(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		src   string
+		taken []int
+		err   string
+	}{
+		{
+			// each item is yielded once the token after it is taken
+			name:  "Items",
+			src:   `[1 2] [] [3]`,
+			taken: []int{5, 7, 9},
+		},
+		{
+			name: "Empty",
+			src:  ``,
+		},
+		{
+			name:  "UnexpectedToken",
+			src:   `[1] [2 , 3]`,
+			taken: []int{4},
+			err:   "unexpected token: tp_test.commaToken{} at token 5",
+		},
+		{
+			name:  "UnexpectedEOF",
+			src:   `[1] [2`,
+			taken: []int{4},
+			err:   "unexpected EOF",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			toks := removeWhitespace(must(lexicon.Tokenize([]byte(test.src)).Force()))
+
+			taken := 0
+			var got []int
+			var err string
+			for _, e := range tp.ParseEach(numberBlockGrammar{}, countTokens(toks, &taken)) {
+				var unexpected *tp.ErrUnexpectedToken
+				if errors.As(e, &unexpected) {
+					err = fmt.Sprintf("%s at token %d", e, unexpected.Index)
+					break
+				}
+				if e != nil {
+					err = e.Error()
+					break
+				}
+				got = append(got, taken)
+			}
+			assert.Equal(t, got, test.taken)
+			assert.Equal(t, err, test.err)
+		})
+	}
+}
+
+func TestParseEachStop(t *testing.T) {
+	toks := removeWhitespace(must(lexicon.Tokenize([]byte(`[1 2] [] [3]`)).Force()))
+
+	taken := 0
+	for range tp.ParseEach(numberBlockGrammar{}, countTokens(toks, &taken)) {
+		break
+	}
+	assert.Equal(t, taken, 5)
+}
+
+func TestParseEachNotNonterminal(t *testing.T) {
+	for _, err := range tp.ParseEach(tokenListGrammar{}, slices.Values([]jsonToken{commaToken{}})) {
+		assert.True(t, err != nil && !errors.Is(err, io.ErrUnexpectedEOF))
+		assert.Equal(t, err.Error(), "tp_test.commaToken is not a nonterminal of the grammar")
+	}
+}
+
+type tokenListGrammar struct{}
+
+func (tokenListGrammar) Parse(x []commaToken) ([]commaToken, error) {
+	return x, nil
+}
@@ -0,0 +1,137 @@
+package tp
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Describe a grammar in the Extended Backus-Naur Form used by the Go specification. There is a
+// production for each type that rules produce, beginning with the one that the grammar's Parse
+// method accepts and followed by the others in order of name. Its alternatives are in order of
+// preference, and those of rules with labels are followed by the label as a comment. An interface
+// is described by the types that implement it, slices are written as repetitions and the types of
+// tokens are left undefined.
+//
+// This allows the language that a grammar accepts to be documented, or checked by other tools,
+// without reading its rules.
+func EBNF[U, V any](g Grammar[U, V]) string {
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	return gr.ebnf(ebnfName)
+}
+
+// Write a production for each nonterminal of the grammar, naming symbols with name.
+func (gr *grammar) ebnf(name func(t reflect.Type) string) string {
+	var syms []*symbol
+	for _, sym := range gr.symbols {
+		if sym == gr.root || len(sym.Predictions) == 0 || sym.Type.Kind() == reflect.Slice {
+			continue
+		}
+		syms = append(syms, sym)
+	}
+	slices.SortFunc(syms, func(a, b *symbol) int {
+		return cmp.Compare(name(a.Type), name(b.Type))
+	})
+	syms = append([]*symbol{gr.root}, syms...)
+
+	var b strings.Builder
+	for _, sym := range syms {
+		alts, optional := sym.alternatives(name)
+		b.WriteString(name(sym.Type))
+		b.WriteString(" =")
+		switch {
+		case len(alts) == 0:
+		case optional:
+			b.WriteString(" [ " + strings.Join(alts, " | ") + " ]")
+		default:
+			b.WriteString(" " + strings.Join(alts, " | "))
+		}
+		b.WriteString(" .\n")
+	}
+	return b.String()
+}
+
+// The alternatives of a production for a symbol, other than an empty one, and whether there is an
+// empty one.
+func (sym *symbol) alternatives(name func(t reflect.Type) string) ([]string, bool) {
+	rules := slices.Clone(sym.Predictions)
+	slices.SortStableFunc(rules, func(a, b *rule) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	// the types that implement an interface are named rather than repeating their rules, unless they
+	// are named by way of another interface
+	var impls []reflect.Type
+	for _, r := range rules {
+		if r.Produces != sym.Type && !slices.Contains(impls, r.Produces) {
+			impls = append(impls, r.Produces)
+		}
+	}
+	implied := func(t reflect.Type) bool {
+		for _, u := range impls {
+			if u != t && u.Kind() == reflect.Interface && t.AssignableTo(u) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var res []string
+	optional := false
+	for _, r := range rules {
+		var alt string
+		switch {
+		case r.Produces != sym.Type:
+			if implied(r.Produces) {
+				continue
+			}
+			alt = name(r.Produces)
+		case len(r.Deps) == 0:
+			optional = true
+			continue
+		default:
+			parts := make([]string, len(r.Deps))
+			for i, d := range r.Deps {
+				parts[i] = name(d.Type)
+			}
+			alt = strings.Join(parts, " ")
+			if r.Label != "" {
+				alt += " /* " + r.Label + " */"
+			}
+		}
+		if !slices.Contains(res, alt) {
+			res = append(res, alt)
+		}
+	}
+	return res, optional
+}
+
+// Name a type as it would be written in its own package, and slices as repetitions.
+func ebnfName(t reflect.Type) string {
+	if t.Kind() == reflect.Slice {
+		return "{ " + ebnfName(t.Elem()) + " }"
+	}
+	if t.Name() == "" {
+		return t.String()
+	}
+	return unqualified(t.Name())
+}
+
+// Remove the package paths from the type arguments in the name of a type.
+func unqualified(name string) string {
+	var b strings.Builder
+	start := 0
+	for i, c := range name {
+		switch c {
+		case '[', ']', ',', ' ', '*':
+			b.WriteString(name[start:i])
+			b.WriteRune(c)
+			start = i + 1
+		case '.':
+			start = i + 1
+		}
+	}
+	b.WriteString(name[start:])
+	return b.String()
+}
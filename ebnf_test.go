@@ -0,0 +1,34 @@
+package tp_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleEBNF() {
+	fmt.Print(tp.EBNF(interfaceGrammar{}))
+
+	// Output:
+	// expr = add | intVal .
+	// add = expr plusTok expr .
+	// intVal = intTok .
+}
+
+func TestEBNF(t *testing.T) {
+	assert.Equal(t, tp.EBNF(jsonGrammar{}), ``+
+		`jsonValue = arrayStartToken delimited[jsonValue,commaToken] arrayEndToken | jsonNumber | objectStartToken delimited[jsonField,commaToken] objectEndToken | jsonString .
+delimitedItem[jsonField,commaToken] = commaToken jsonField .
+delimitedItem[jsonValue,commaToken] = commaToken jsonValue .
+delimited[jsonField,commaToken] = [ jsonField { delimitedItem[jsonField,commaToken] } ] .
+delimited[jsonValue,commaToken] = [ jsonValue { delimitedItem[jsonValue,commaToken] } ] .
+jsonField = stringToken colonToken jsonValue .
+jsonNumber = numberToken .
+jsonString = stringToken .
+`)
+
+	assert.Equal(t, tp.EBNF(leftListGrammar{}), "countList = countList intTok | intTok .\n")
+	assert.Equal(t, tp.EBNF(sliceListGrammar{}), "countList = { intTok } .\n")
+}
@@ -0,0 +1,133 @@
+package tp
+
+import (
+	"reflect"
+)
+
+// Span describes the tokens that part of a parse covers, as indexes into the tokens that were
+// given to Parse. End is exclusive.
+type Span struct {
+	Start, End int
+}
+
+// Values that are told which tokens they were parsed from.
+type spanned interface {
+	withSpan(s Span) any
+}
+
+var spannedType = reflect.TypeFor[spanned]()
+
+// Enclosed matches an O, then a T, then a C, such as a parenthesized expression. Use it as the
+// argument of a rule in place of the three separate arguments.
+//
+// The opening and closing tokens are treated as brackets, so if a parse fails because one is left
+// unclosed then the error is an ErrUnclosedBracket describing where it was opened.
+type Enclosed[O, T, C any] struct {
+	Open  O
+	Value T
+	Close C
+
+	// The tokens covered, including the opening and closing tokens.
+	Span Span
+}
+
+type enclosedGrammar[O, T, C any] struct{}
+
+func (Enclosed[O, T, C]) Grammar() enclosedGrammar[O, T, C] {
+	return enclosedGrammar[O, T, C]{}
+}
+
+func (e Enclosed[O, T, C]) withSpan(s Span) any {
+	e.Span = s
+	return e
+}
+
+func (enclosedGrammar[O, T, C]) Enclose(open O, value T, close C) Enclosed[O, T, C] {
+	return Enclosed[O, T, C]{Open: open, Value: value, Close: close}
+}
+
+// ErrUnclosedBracket is returned when a parse fails while inside an Enclosed, because either the
+// input ended or a token that closes some other Enclosed was found.
+type ErrUnclosedBracket struct {
+	// The token that opened the Enclosed, and its index in the input.
+	Open      any
+	OpenIndex int
+
+	// The zero value of the type of token that would have closed it.
+	Close any
+
+	// The reason that the parse failed, either an ErrUnexpectedToken or io.ErrUnexpectedEOF.
+	Err error
+}
+
+func (e *ErrUnclosedBracket) Error() string {
+	return EnglishMessages{}.UnclosedBracket(e, e.Err.Error())
+}
+
+func (e *ErrUnclosedBracket) Unwrap() error {
+	return e.Err
+}
+
+type bracketPair struct {
+	open, close reflect.Type
+}
+
+// Helper grammars that enclose their contents in a pair of tokens.
+type bracketed interface {
+	brackets() bracketPair
+}
+
+func (enclosedGrammar[O, T, C]) brackets() bracketPair {
+	return bracketPair{open: reflect.TypeFor[O](), close: reflect.TypeFor[C]()}
+}
+
+// Given the error that a parse failed with, find whether it failed inside an Enclosed that was not
+// closed, and if so describe that instead.
+func (p *matcher) bracketError(err error) error {
+	type opened struct {
+		at   int
+		pair bracketPair
+	}
+	var stack []opened
+
+	for i, t := range p.toks[:p.failedAt] {
+		for _, b := range p.brackets {
+			if t.Type().AssignableTo(b.open) {
+				stack = append(stack, opened{at: i, pair: b})
+				break
+			}
+			if len(stack) > 0 && stack[len(stack)-1].pair == b && t.Type().AssignableTo(b.close) {
+				stack = stack[:len(stack)-1]
+				break
+			}
+		}
+	}
+	if len(stack) == 0 {
+		return err
+	}
+
+	top := stack[len(stack)-1]
+	if p.failedAt < len(p.toks) {
+		// only a token that closes something else shows that the bracket was left unclosed
+		failed := p.toks[p.failedAt].Type()
+		if failed.AssignableTo(top.pair.close) || !p.closesAny(failed) {
+			return err
+		}
+	}
+
+	return &ErrUnclosedBracket{
+		Open:      p.toks[top.at].Interface(),
+		OpenIndex: top.at,
+		Close:     reflect.Zero(top.pair.close).Interface(),
+		Err:       err,
+	}
+}
+
+func (p *matcher) closesAny(t reflect.Type) bool {
+	for _, b := range p.brackets {
+		if t.AssignableTo(b.close) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package tp_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type nestedList struct {
+	span  tp.Span
+	items []nestedList
+}
+
+type nestedListGrammar struct{}
+
+func (nestedListGrammar) Parse(x nestedList) (nestedList, error) {
+	return x, nil
+}
+
+func (nestedListGrammar) List(x tp.Enclosed[arrayStartToken, tp.Delimited[nestedList, commaToken], arrayEndToken]) nestedList {
+	return nestedList{span: x.Span, items: x.Value.Items}
+}
+
+func printSpans(x nestedList, depth int) {
+	fmt.Printf("%*s%v\n", depth*2, "", x.span)
+	for _, y := range x.items {
+		printSpans(y, depth+1)
+	}
+}
+
+func ExampleEnclosed() {
+	toks := removeWhitespace(must(lexicon.Tokenize([]byte(`[[], [[]]]`)).Force()))
+	x := must(tp.Parse(nestedListGrammar{}, toks))
+	printSpans(x, 0)
+
+	// Output:
+	// {0 9}
+	//   {1 3}
+	//   {4 8}
+	//     {5 7}
+}
+
+func (nestedListGrammar) Object(x tp.Enclosed[objectStartToken, tp.Delimited[nestedList, commaToken], objectEndToken]) nestedList {
+	return nestedList{span: x.Span, items: x.Value.Items}
+}
+
+func TestUnclosedBracket(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  `[[]`,
+			err: "unexpected EOF: expected tp_test.arrayEndToken{} to close tp_test.arrayStartToken{} at token 0",
+		},
+		{
+			in:  `[{[]]`,
+			err: "unexpected token: tp_test.arrayEndToken{}: expected tp_test.objectEndToken{} to close tp_test.objectStartToken{} at token 1",
+		},
+		{
+			in:  `[{}]]`,
+			err: "unexpected token: tp_test.arrayEndToken{}",
+		},
+		{
+			in:  `[{} {}]`,
+			err: "unexpected token: tp_test.objectStartToken{}",
+		},
+		{
+			in:  `[{},]`,
+			err: "unexpected token: tp_test.arrayEndToken{}",
+		},
+	} {
+		t.Run(test.in, func(t *testing.T) {
+			toks := removeWhitespace(must(lexicon.Tokenize([]byte(test.in)).Force()))
+			_, err := tp.Parse(nestedListGrammar{}, toks)
+			if assert.True(t, err != nil) {
+				assert.Equal(t, err.Error(), test.err)
+			}
+		})
+	}
+}
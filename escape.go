@@ -0,0 +1,58 @@
+package tp
+
+import (
+	"fmt"
+)
+
+// Define a character class that can be written as \letter in the patterns of the token specs that
+// follow it, both on its own and within character sets. It does not produce any tokens itself. The
+// pattern must match a single character, e.g. a character set.
+//
+// The classes that are always available are
+//
+//	\d    // [0-9]
+//	\w    // [0-9A-Z_a-z]
+//	\c    // [A-Z_a-z]
+//	\s    // [\t\n ]
+//	\n \r \t
+//
+// and these, along with letters that have a meaning in other dialects such as \b or \p, cannot be
+// defined again.
+//
+// So, e.g. a lexer for hexadecimal numbers could be built as
+//
+//	tp.EscapeClass[Token]('h', `[0-9a-fA-F]`),
+//	tp.Regex(`0x\h+`, hexNumber),
+func EscapeClass[T any](letter rune, re string) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		l.describeRule(re)
+
+		if !(letter >= 'a' && letter <= 'z' || letter >= 'A' && letter <= 'Z') {
+			return fmt.Errorf("escape classes must be named by a letter, not %q", letter)
+		}
+		if _, ok := regexParser.escMap[letter]; ok {
+			return fmt.Errorf("\\%c is already defined", letter)
+		}
+		if _, ok := l.escapes[letter]; ok {
+			return fmt.Errorf("\\%c is already defined", letter)
+		}
+		if err := reservedEscape(letter); err != nil {
+			return fmt.Errorf("\\%c cannot be defined: %w", letter, err)
+		}
+
+		e, err := parseRegex(re, l.fragments, l.escapes)
+		if err != nil {
+			return err
+		}
+		ranges, ok := asSet(e.expr)
+		if !ok || e.anchor != anchorNone {
+			return fmt.Errorf("\\%c must match a single character", letter)
+		}
+
+		if l.escapes == nil {
+			l.escapes = map[rune]charset{}
+		}
+		l.escapes[letter] = charset{ranges: ranges}
+		return nil
+	}
+}
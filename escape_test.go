@@ -0,0 +1,94 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestEscapeClass(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	l, err := NewLexer(
+		EscapeClass[string]('h', `[0-9a-fA-F]`),
+		EscapeClass[string]('i', `[\c$]`),
+		Regex(`0x\h+`, yield),
+		Regex(`\i[\i\d]*`, yield),
+		Regex(`[ ]+`, yield),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	toks, err := l.Tokenize([]byte("0xBEEF $x1 _")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{"0xBEEF", " ", "$x1", " ", "_"})
+}
+
+func TestEscapeClassErrors(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	for _, test := range []struct {
+		name  string
+		specs []TokenSpec[string]
+		err   string
+	}{
+		{
+			name:  "Unknown",
+			specs: []TokenSpec[string]{Regex(`\h`, yield)},
+			err:   "rule 0: `\\h`: escape \\h is not supported: letters do not need escaping; use EscapeClass to give the escape a meaning",
+		},
+		{
+			name: "DefinedLater",
+			specs: []TokenSpec[string]{
+				Regex(`\h`, yield),
+				EscapeClass[string]('h', `[0-9a-f]`),
+			},
+			err: "rule 0: `\\h`: escape \\h is not supported: letters do not need escaping; use EscapeClass to give the escape a meaning",
+		},
+		{
+			name: "Redefined",
+			specs: []TokenSpec[string]{
+				EscapeClass[string]('h', `[0-9a-f]`),
+				EscapeClass[string]('h', `[0-9A-F]`),
+			},
+			err: "rule 1: `[0-9A-F]`: \\h is already defined",
+		},
+		{
+			name:  "Builtin",
+			specs: []TokenSpec[string]{EscapeClass[string]('d', `[0-7]`)},
+			err:   "rule 0: `[0-7]`: \\d is already defined",
+		},
+		{
+			name:  "Reserved",
+			specs: []TokenSpec[string]{EscapeClass[string]('b', `[01]`)},
+			err:   "rule 0: `[01]`: \\b cannot be defined: assertion \\b is not supported: there are no zero-width assertions; tokens are matched from where the previous token ended and are as long as possible",
+		},
+		{
+			name:  "NotALetter",
+			specs: []TokenSpec[string]{EscapeClass[string]('.', `[01]`)},
+			err:   "rule 0: `[01]`: escape classes must be named by a letter, not '.'",
+		},
+		{
+			name:  "NotACharacter",
+			specs: []TokenSpec[string]{EscapeClass[string]('h', `[0-9]+`)},
+			err:   "rule 0: `[0-9]+`: \\h must match a single character",
+		},
+		{
+			name:  "Anchored",
+			specs: []TokenSpec[string]{EscapeClass[string]('h', `^[0-9]`)},
+			err:   "rule 0: `^[0-9]`: \\h must match a single character",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewLexer(test.specs...)
+			if assert.True(t, err != nil) {
+				assert.Equal(t, err.Error(), test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package tp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExcerptOptions controls how much of a text Excerpt shows, and how.
+type ExcerptOptions struct {
+	// The number of lines to show before and after the lines that the range covers.
+	Context int
+
+	// The most columns of each line to show. Lines that are longer are cut down to a window that
+	// begins a little before the range, with an ellipsis where text has been left out. If this is
+	// zero then lines are shown in full.
+	Width int
+
+	// The columns between tab stops. Tabs are shown as spaces so that the carets line up with the
+	// text above them. If this is zero then tab stops are 4 columns apart.
+	TabWidth int
+
+	// Whether to highlight the range, and the carets beneath it, with ANSI escape sequences.
+	Color bool
+}
+
+const (
+	excerptHighlight = "\x1b[1;31m"
+	excerptReset     = "\x1b[0m"
+)
+
+// Show the lines of a text that a range of byte offsets falls on, with their line numbers, and mark
+// the range with carets beneath it, e.g.
+//
+//	2 | [
+//	3 |   1,,
+//	  |     ^
+//	4 | ]
+//
+// An empty range is marked with a single caret, as is one that only covers the end of a line. Each
+// rune takes up one column, so the carets may not line up beneath text in scripts whose characters
+// are shown wider than others.
+//
+// This is for tools that report problems found in texts, such as the Position of a Diagnostic or the
+// Start and End of a Match.
+func Excerpt(src []byte, start, end int, opts ExcerptOptions) string {
+	start = min(max(start, 0), len(src))
+	end = min(max(end, start), len(src))
+	if opts.TabWidth <= 0 {
+		opts.TabWidth = 4
+	}
+
+	lines := NewLineIndex(src)
+	first, last := lines.Position(start), lines.Position(end)
+	if last.Column == 1 && end > start {
+		// a range that ends with a line break does not go on to the next line
+		last = lines.Position(end - 1)
+	}
+	from := max(1, first.Line-opts.Context)
+	to := min(lines.Lines(), last.Line+opts.Context)
+	gutter := len(fmt.Sprint(to))
+
+	var left, right int
+	if opts.Width > 0 {
+		col := excerptLine(src, lines, first.Line, opts.TabWidth).column(start)
+		left = max(0, col-opts.Width/4)
+		right = left + opts.Width
+	}
+
+	var b strings.Builder
+	for n := from; n <= to; n++ {
+		line := excerptLine(src, lines, n, opts.TabWidth)
+		hs, he := -1, -1
+		if n >= first.Line && n <= last.Line {
+			hs, he = line.start, line.end+1
+			if n == first.Line {
+				hs = start
+			}
+			if n == last.Line {
+				he = end
+			}
+		}
+
+		cells := line.cells
+		if opts.Width > 0 {
+			cells = cells[min(left, len(cells)):min(right, len(cells))]
+		}
+		var text strings.Builder
+		switch {
+		case left > 0 && len(line.cells) > 0:
+			text.WriteString("…")
+		case left > 0:
+			text.WriteByte(' ')
+		}
+		lit := false
+		for _, c := range cells {
+			on := c.offset >= hs && c.offset < he
+			if opts.Color && on != lit {
+				text.WriteString(excerptColor(on))
+				lit = on
+			}
+			text.WriteString(c.text)
+		}
+		if lit {
+			text.WriteString(excerptReset)
+		}
+		if opts.Width > 0 && len(line.cells) > right {
+			text.WriteString("…")
+		}
+		excerptRow(&b, gutter, fmt.Sprint(n), text.String())
+
+		if hs == -1 {
+			continue
+		}
+		cs, ce := line.column(hs), line.column(he)
+		if ce <= cs {
+			ce = cs + 1
+		}
+		if opts.Width > 0 {
+			cs, ce = min(max(cs, left), right), min(max(ce, left), right)
+			if cs == ce {
+				continue
+			}
+		}
+		var carets strings.Builder
+		if left > 0 {
+			carets.WriteByte(' ')
+		}
+		carets.WriteString(strings.Repeat(" ", cs-left))
+		if opts.Color {
+			carets.WriteString(excerptHighlight)
+		}
+		carets.WriteString(strings.Repeat("^", ce-cs))
+		if opts.Color {
+			carets.WriteString(excerptReset)
+		}
+		excerptRow(&b, gutter, "", carets.String())
+	}
+	return b.String()
+}
+
+// A line of text as it is shown, a column at a time.
+type shownLine struct {
+	// the byte offsets of the beginning and end of the line, not including the line break
+	start, end int
+
+	cells []shownCell
+}
+
+type shownCell struct {
+	text   string
+	offset int
+}
+
+func excerptLine(src []byte, lines *LineIndex, n, tabWidth int) shownLine {
+	start, _ := lines.Offset(n, 1)
+	end := len(src)
+	if next, ok := lines.Offset(n+1, 1); ok {
+		end = next - 1
+	}
+	if end > start && src[end-1] == '\r' {
+		end--
+	}
+
+	line := shownLine{start: start, end: end}
+	for i := start; i < end; {
+		c, size := utf8.DecodeRune(src[i:])
+		switch {
+		case c == '\t':
+			for range tabWidth - len(line.cells)%tabWidth {
+				line.cells = append(line.cells, shownCell{text: " ", offset: i})
+			}
+		case c == utf8.RuneError && size == 1, c < ' ':
+			line.cells = append(line.cells, shownCell{text: "�", offset: i})
+		default:
+			line.cells = append(line.cells, shownCell{text: string(src[i : i+size]), offset: i})
+		}
+		i += size
+	}
+	return line
+}
+
+// The column that the text at an offset is shown in, where the end of the line is shown just after
+// its last column.
+func (l shownLine) column(offset int) int {
+	for i, c := range l.cells {
+		if c.offset >= offset {
+			return i
+		}
+	}
+	return len(l.cells)
+}
+
+func excerptRow(b *strings.Builder, gutter int, number, text string) {
+	row := fmt.Sprintf("%*s | %s", gutter, number, text)
+	b.WriteString(strings.TrimRight(row, " "))
+	b.WriteByte('\n')
+}
+
+func excerptColor(on bool) string {
+	if on {
+		return excerptHighlight
+	}
+	return excerptReset
+}
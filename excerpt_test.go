@@ -0,0 +1,116 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestExcerpt(t *testing.T) {
+	src := "[\n  1,,\n]\n"
+	for _, test := range []struct {
+		name       string
+		src        string
+		start, end int
+		opts       ExcerptOptions
+		out        string
+	}{
+		{
+			name:  "Caret",
+			src:   src,
+			start: 6,
+			end:   7,
+			out: "" +
+				"2 |   1,,\n" +
+				"  |     ^\n",
+		},
+		{
+			name:  "Context",
+			src:   src,
+			start: 6,
+			end:   6,
+			opts:  ExcerptOptions{Context: 1},
+			out: "" +
+				"1 | [\n" +
+				"2 |   1,,\n" +
+				"  |     ^\n" +
+				"3 | ]\n",
+		},
+		{
+			name:  "EndOfLine",
+			src:   src,
+			start: 7,
+			end:   8,
+			out: "" +
+				"2 |   1,,\n" +
+				"  |      ^\n",
+		},
+		{
+			name:  "EndOfText",
+			src:   src,
+			start: 10,
+			end:   10,
+			opts:  ExcerptOptions{Context: 1},
+			out: "" +
+				"3 | ]\n" +
+				"4 |\n" +
+				"  | ^\n",
+		},
+		{
+			name:  "Lines",
+			src:   "a = (1 +\n  2\n) * 3",
+			start: 4,
+			end:   15,
+			out: "" +
+				"1 | a = (1 +\n" +
+				"  |     ^^^^\n" +
+				"2 |   2\n" +
+				"  | ^^^\n" +
+				"3 | ) * 3\n" +
+				"  | ^^\n",
+		},
+		{
+			name:  "Tabs",
+			src:   "\tx\t= 1\r\n",
+			start: 2,
+			end:   3,
+			opts:  ExcerptOptions{TabWidth: 4},
+			out: "" +
+				"1 |     x   = 1\n" +
+				"  |      ^^^\n",
+		},
+		{
+			name:  "Window",
+			src:   "alpha beta gamma delta epsilon zeta eta theta",
+			start: 17,
+			end:   22,
+			opts:  ExcerptOptions{Width: 12},
+			out: "" +
+				"1 | …ma delta eps…\n" +
+				"  |     ^^^^^\n",
+		},
+		{
+			name:  "Color",
+			src:   "x = ?",
+			start: 4,
+			end:   5,
+			opts:  ExcerptOptions{Color: true},
+			out: "" +
+				"1 | x = \x1b[1;31m?\x1b[0m\n" +
+				"  |     \x1b[1;31m^\x1b[0m\n",
+		},
+		{
+			name:  "Unicode",
+			src:   "名前 = ?",
+			start: 9,
+			end:   10,
+			out: "" +
+				"1 | 名前 = ?\n" +
+				"  |      ^\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, Excerpt([]byte(test.src), test.start, test.end, test.opts), test.out)
+		})
+	}
+}
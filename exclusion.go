@@ -0,0 +1,73 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Find the rules of a grammar that are left out of a parse, because they are not in the version of
+// the language being parsed or need features that are not enabled, and the error that using each
+// would lead to.
+func ruleExclusions(g any, gr *grammar) (map[*rule]func(Span) error, error) {
+	versions, err := versionExclusions(g, gr)
+	if err != nil {
+		return nil, err
+	}
+	features := featureExclusions(g, gr)
+	if versions == nil {
+		return features, nil
+	}
+	for r, f := range features {
+		if _, ok := versions[r]; !ok {
+			versions[r] = f
+		}
+	}
+	return versions, nil
+}
+
+// Check that the rules named by a method of a grammar exist.
+func checkRuleNames[T any](method string, g any, rules map[string]T) {
+	for name := range rules {
+		if _, ok := reflect.TypeOf(g).MethodByName(name); !ok {
+			panic(fmt.Sprintf("%s names %s, which is not a rule of %T", method, name, g))
+		}
+	}
+}
+
+// Find the error of the first rule, in the order that rules would be applied, that is excluded from
+// the parse but that the preferred derivation of the input needs.
+func (b *builder) exclusionError() error {
+	excluded := b.excluded
+	b.excluded = nil
+	defer func() { b.excluded = excluded }()
+
+	for _, top := range b.completed(0, b.root) {
+		if top.position != len(b.seen) {
+			continue
+		}
+		s, ok := b.findSpan(top, 0)
+		if !ok {
+			continue
+		}
+		if err := b.firstExcluded(s, excluded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) firstExcluded(s span, excluded map[*rule]func(Span) error) error {
+	if s.item.rule == nil {
+		// a token
+		return nil
+	}
+	for _, c := range s.children {
+		if err := b.firstExcluded(c, excluded); err != nil {
+			return err
+		}
+	}
+	if f := excluded[s.item.rule]; f != nil {
+		return f(b.span(s))
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package tp
+
+import "reflect"
+
+// Match tokens against a grammar, reporting how much work the matcher did, so that tests can check
+// how the time taken to parse grows without timing it.
+func MatcherWork[T, U, V any](g Grammar[U, V], toks []T) int {
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	m := newMatcher(gr, gr.root, tokenValues(toks), true)
+	if err := m.run(); err != nil {
+		panic(err)
+	}
+	return m.work
+}
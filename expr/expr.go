@@ -0,0 +1,338 @@
+// Package expr evaluates expressions over variables, such as
+//
+//	age >= 18 && country == "GB" || admin
+//
+// for rule engines, query filters and calculators. It is built with tp, and is meant to be used as
+// it is, or copied as a starting point for languages of this kind.
+//
+// Values are numbers, which are float64, strings and booleans. The operators, from the loosest
+// binding to the tightest, are:
+//
+//	||              or, of booleans
+//	&&              and, of booleans
+//	== != < <= > >= comparison, of numbers or strings, or of booleans for == and !=
+//	+ -             addition and subtraction of numbers, and + joins strings
+//	* / %           multiplication, division and remainder, of numbers
+//	! -             not, of a boolean, and negation, of a number
+//
+// The right operands of && and || are only evaluated if they are needed. Numbers are written in
+// decimal, strings in double quotes with the escapes of Go strings, and booleans as true and false.
+// Other names are variables, found in the Env that the expression is evaluated in, and names joined
+// by dots, such as user.age, find variables within variables.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Expr is an expression that has been compiled, and can be evaluated any number of times, including
+// concurrently.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile an expression. Problems with its syntax are described by a tp.SyntaxError.
+func Compile(src string) (*Expr, error) {
+	root, err := language.Parse([]byte(src))
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Compile an expression, and evaluate it in an environment.
+func Eval(src string, env Env) (any, error) {
+	e, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(env)
+}
+
+// The text of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Evaluate the expression in an environment, returning a float64, a string or a bool. Problems
+// with the values involved are described by an EvalError.
+func (e *Expr) Eval(env Env) (any, error) {
+	return e.root.eval(env)
+}
+
+// Evaluate the expression, as with Eval, and report whether it is true, for using the expression as
+// a filter. It is an error for the expression not to be a boolean.
+func (e *Expr) Match(env Env) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, &EvalError{Offset: 0, Err: fmt.Errorf("%s is not a boolean", describe(v))}
+	}
+	return b, nil
+}
+
+// Env is the environment that an expression is evaluated in, which gives the values of variables.
+type Env interface {
+	// Find the value of a variable. Values of any integer or floating point type are numbers, and
+	// values that are themselves an Env hold the variables that are named after them with dots.
+	Lookup(name string) (any, bool)
+}
+
+// Vars is an Env that holds its variables in a map. Maps of type map[string]any are also treated as
+// Vars where they are the values of variables.
+type Vars map[string]any
+
+func (v Vars) Lookup(name string) (any, bool) {
+	x, ok := v[name]
+	return x, ok
+}
+
+// Layers is an Env that looks for variables in each of its environments in turn, so that e.g. the
+// variables of a request can be given along with variables that are the same for every request.
+type Layers []Env
+
+func (l Layers) Lookup(name string) (any, bool) {
+	for _, env := range l {
+		if x, ok := env.Lookup(name); ok {
+			return x, true
+		}
+	}
+	return nil, false
+}
+
+// EvalError describes a problem found while evaluating an expression, and where in the expression
+// it was found.
+type EvalError struct {
+	// The byte offset within the expression.
+	Offset int
+
+	Err error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("expr: offset %d: %s", e.Offset, e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// ErrUndefined is the error of a variable that the environment does not have.
+var ErrUndefined = errors.New("undefined variable")
+
+type node interface {
+	eval(env Env) (any, error)
+}
+
+type literal struct {
+	value any
+}
+
+func (n literal) eval(env Env) (any, error) {
+	return n.value, nil
+}
+
+type variable struct {
+	start int
+	path  []string
+}
+
+func (n variable) eval(env Env) (any, error) {
+	for i, name := range n.path {
+		x, ok := env.Lookup(name)
+		if !ok {
+			return nil, &EvalError{Offset: n.start, Err: fmt.Errorf("%w %s", ErrUndefined, joinPath(n.path[:i+1]))}
+		}
+		v, ok := value(x)
+		if i == len(n.path)-1 {
+			if !ok {
+				return nil, &EvalError{Offset: n.start, Err: fmt.Errorf("%s has a value of type %T", joinPath(n.path), x)}
+			}
+			return v, nil
+		}
+		if env, ok = x.(Env); !ok {
+			if m, isMap := x.(map[string]any); isMap {
+				env = Vars(m)
+			} else {
+				return nil, &EvalError{Offset: n.start, Err: fmt.Errorf("%s has no variables", joinPath(n.path[:i+1]))}
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+type unary struct {
+	start   int
+	op      string
+	operand node
+}
+
+func (n unary) eval(env Env) (any, error) {
+	x, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case bool:
+		if n.op == "!" {
+			return !x, nil
+		}
+	case float64:
+		switch n.op {
+		case "-":
+			return -x, nil
+		case "+":
+			return x, nil
+		}
+	}
+	return nil, &EvalError{Offset: n.start, Err: fmt.Errorf("cannot apply %s to %s", n.op, describe(x))}
+}
+
+type binary struct {
+	start       int
+	op          string
+	left, right node
+}
+
+func (n binary) eval(env Env) (any, error) {
+	x, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// the right operand is only evaluated if it is needed
+	if b, ok := x.(bool); ok && (n.op == "&&" && !b || n.op == "||" && b) {
+		return b, nil
+	}
+
+	y, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	res, ok, err := apply(n.op, x, y)
+	if err != nil {
+		return nil, &EvalError{Offset: n.start, Err: err}
+	}
+	if !ok {
+		return nil, &EvalError{Offset: n.start, Err: fmt.Errorf("cannot apply %s to %s and %s", n.op, describe(x), describe(y))}
+	}
+	return res, nil
+}
+
+// Apply a binary operator, reporting false if it does not apply to the operands.
+func apply(op string, x, y any) (any, bool, error) {
+	switch x := x.(type) {
+	case float64:
+		y, ok := y.(float64)
+		if !ok {
+			break
+		}
+		switch op {
+		case "+":
+			return x + y, true, nil
+		case "-":
+			return x - y, true, nil
+		case "*":
+			return x * y, true, nil
+		case "/", "%":
+			if y == 0 {
+				return nil, true, errors.New("division by zero")
+			}
+			if op == "%" {
+				return math.Mod(x, y), true, nil
+			}
+			return x / y, true, nil
+		}
+		return compare(op, x, y)
+
+	case string:
+		y, ok := y.(string)
+		if !ok {
+			break
+		}
+		if op == "+" {
+			return x + y, true, nil
+		}
+		return compare(op, x, y)
+
+	case bool:
+		y, ok := y.(bool)
+		if !ok {
+			break
+		}
+		switch op {
+		case "&&", "||":
+			// the left operand did not decide the result
+			return y, true, nil
+		case "==":
+			return x == y, true, nil
+		case "!=":
+			return x != y, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func compare[T float64 | string](op string, x, y T) (any, bool, error) {
+	switch op {
+	case "==":
+		return x == y, true, nil
+	case "!=":
+		return x != y, true, nil
+	case "<":
+		return x < y, true, nil
+	case "<=":
+		return x <= y, true, nil
+	case ">":
+		return x > y, true, nil
+	case ">=":
+		return x >= y, true, nil
+	}
+	return nil, false, nil
+}
+
+// Convert the value of a variable to one that expressions work with.
+func value(x any) (any, bool) {
+	switch x := x.(type) {
+	case float64, string, bool:
+		return x, true
+	}
+	v := reflect.ValueOf(x)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32:
+		return v.Float(), true
+	}
+	return nil, false
+}
+
+// Describe a value for an error message.
+func describe(x any) string {
+	switch x := x.(type) {
+	case float64:
+		return fmt.Sprintf("number %v", x)
+	case string:
+		return fmt.Sprintf("string %q", x)
+	case bool:
+		return fmt.Sprintf("boolean %v", x)
+	}
+	return fmt.Sprint(x)
+}
+
+func joinPath(path []string) string {
+	res := path[0]
+	for _, name := range path[1:] {
+		res += "." + name
+	}
+	return res
+}
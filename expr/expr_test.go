@@ -0,0 +1,125 @@
+package expr_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+	"github.com/bobappleyard/tp/expr"
+)
+
+func ExampleExpr_Match() {
+	e, err := expr.Compile(`age >= 18 && country == "GB" || admin`)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(e.Match(expr.Vars{"age": 21, "country": "GB", "admin": false}))
+	fmt.Println(e.Match(expr.Vars{"age": 16, "country": "GB", "admin": false}))
+	fmt.Println(e.Match(expr.Vars{"age": 16, "country": "FR", "admin": true}))
+
+	// Output:
+	// true <nil>
+	// false <nil>
+	// true <nil>
+}
+
+func TestEval(t *testing.T) {
+	env := expr.Layers{
+		expr.Vars{
+			"x":    3,
+			"y":    uint8(4),
+			"name": "tp",
+			"user": map[string]any{"age": 30.0, "address": expr.Vars{"city": "London"}},
+		},
+		expr.Vars{"x": "hidden", "pi": float32(0.5)},
+	}
+	for _, test := range []struct {
+		src  string
+		want any
+	}{
+		{`1 + 2 * 3`, 7.0},
+		{`(1 + 2) * 3`, 9.0},
+		{`10 - 4 - 3`, 3.0},
+		{`7 % 4`, 3.0},
+		{`1.5 / 2`, 0.75},
+		{`-x + y`, 1.0},
+		{`--2`, 2.0},
+		{`"t" + "p" == name`, true},
+		{`"a\tb"`, "a\tb"},
+		{`"abc" < "abd"`, true},
+		{`x <= 3 && y > 3`, true},
+		{`x != 3 || !true`, false},
+		{`true == !false`, true},
+		{`user.age / 2`, 15.0},
+		{`user.address.city`, "London"},
+		{`pi`, 0.5},
+		{`false && missing`, false},
+		{`true || missing`, true},
+	} {
+		t.Run(test.src, func(t *testing.T) {
+			got, err := expr.Eval(test.src, env)
+			assert.Nil(t, err)
+			assert.Equal(t, got, test.want)
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	env := expr.Vars{"n": 1, "s": "x", "m": map[string]any{}, "c": make(chan int)}
+	for _, test := range []struct {
+		src, err string
+	}{
+		{`missing + 1`, "expr: offset 0: undefined variable missing"},
+		{`m.a.b`, "expr: offset 0: undefined variable m.a"},
+		{`n.a`, "expr: offset 0: n has no variables"},
+		{`c`, "expr: offset 0: c has a value of type chan int"},
+		{`n / (n - 1)`, "expr: offset 2: division by zero"},
+		{`n + s`, `expr: offset 2: cannot apply + to number 1 and string "x"`},
+		{`s * 2`, `expr: offset 2: cannot apply * to string "x" and number 2`},
+		{`n && true`, "expr: offset 2: cannot apply && to number 1 and boolean true"},
+		{`true && n`, "expr: offset 5: cannot apply && to boolean true and number 1"},
+		{`true < false`, "expr: offset 5: cannot apply < to boolean true and boolean false"},
+		{`!s`, `expr: offset 0: cannot apply ! to string "x"`},
+		{`-true`, "expr: offset 0: cannot apply - to boolean true"},
+	} {
+		t.Run(test.src, func(t *testing.T) {
+			_, err := expr.Eval(test.src, env)
+			assert.Equal(t, err.Error(), test.err)
+			var evalErr *expr.EvalError
+			assert.True(t, errors.As(err, &evalErr))
+		})
+	}
+
+	_, err := expr.Eval(`missing`, env)
+	assert.True(t, errors.Is(err, expr.ErrUndefined))
+}
+
+func TestMatch(t *testing.T) {
+	e, err := expr.Compile(`1 + 1`)
+	assert.Nil(t, err)
+	assert.Equal(t, e.String(), `1 + 1`)
+	_, err = e.Match(nil)
+	assert.Equal(t, err.Error(), "expr: offset 0: number 2 is not a boolean")
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, test := range []struct {
+		src    string
+		offset int
+	}{
+		{`1 +`, 3},
+		{`(1`, 2},
+		{`1 < 2 < 3`, 6},
+		{`a b`, 2},
+		{`1 ? 2`, 2},
+	} {
+		t.Run(test.src, func(t *testing.T) {
+			_, err := expr.Compile(test.src)
+			var syntax *tp.SyntaxError
+			assert.True(t, errors.As(err, &syntax))
+			assert.Equal(t, syntax.Offset, test.offset)
+		})
+	}
+}
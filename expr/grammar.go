@@ -0,0 +1,192 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bobappleyard/tp"
+)
+
+type token interface {
+	token()
+}
+
+type numberToken struct {
+	value float64
+}
+
+type stringToken struct {
+	value string
+}
+
+type boolToken struct {
+	value bool
+}
+
+type nameToken struct {
+	start int
+	path  []string
+}
+
+// An operator, and where it appears.
+type opToken[Kind any] struct {
+	start int
+	op    string
+}
+
+// The kinds of operator, from the loosest binding to the tightest.
+type (
+	orKind      struct{}
+	andKind     struct{}
+	compareKind struct{}
+	addKind     struct{}
+	mulKind     struct{}
+	notKind     struct{}
+)
+
+type openToken struct{}
+type closeToken struct{}
+
+func (numberToken) token()   {}
+func (stringToken) token()   {}
+func (boolToken) token()     {}
+func (nameToken) token()     {}
+func (opToken[Kind]) token() {}
+func (openToken) token()     {}
+func (closeToken) token()    {}
+
+func op[Kind any](start int, text string) (token, error) {
+	return opToken[Kind]{start: start, op: text}, nil
+}
+
+var lexer = func() *tp.Lexer[token] {
+	l, err := tp.NewLexer(
+		tp.Skip[token](`\s+`),
+		tp.Regex(`\d+(\.\d+)?`, func(start int, text string) (token, error) {
+			f, err := strconv.ParseFloat(text, 64)
+			return numberToken{value: f}, err
+		}),
+		tp.Regex(`"([^"\\]|\\.)*"`, func(start int, text string) (token, error) {
+			s, err := strconv.Unquote(text)
+			return stringToken{value: s}, err
+		}),
+		tp.Prioritize(1, tp.Regex(`true|false`, func(start int, text string) (token, error) {
+			return boolToken{value: text == "true"}, nil
+		})),
+		tp.Regex(`\c\w*(\.\c\w*)*`, func(start int, text string) (token, error) {
+			return nameToken{start: start, path: strings.Split(text, ".")}, nil
+		}),
+		tp.Regex(`\|\|`, op[orKind]),
+		tp.Regex(`&&`, op[andKind]),
+		tp.Regex(`==|!=|<|<=|>|>=`, op[compareKind]),
+		tp.Regex(`\+|-`, op[addKind]),
+		tp.Regex(`\*|/|%`, op[mulKind]),
+		tp.Regex(`!`, op[notKind]),
+		tp.Regex(`\(`, func(start int, text string) (token, error) {
+			return openToken{}, nil
+		}),
+		tp.Regex(`\)`, func(start int, text string) (token, error) {
+			return closeToken{}, nil
+		}),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}()
+
+// Each level of precedence is a type of its own, so that the grammar can only put the operators
+// together in one way.
+type (
+	orLevel      struct{ node }
+	andLevel     struct{ node }
+	compareLevel struct{ node }
+	addLevel     struct{ node }
+	mulLevel     struct{ node }
+	unaryLevel   struct{ node }
+	primaryLevel struct{ node }
+)
+
+type grammar struct{}
+
+func (grammar) Parse(x orLevel) (node, error) {
+	return x.node, nil
+}
+
+func (grammar) Or(x orLevel, op opToken[orKind], y andLevel) orLevel {
+	return orLevel{binary{start: op.start, op: op.op, left: x.node, right: y.node}}
+}
+
+func (grammar) OrUp(x andLevel) orLevel {
+	return orLevel(x)
+}
+
+func (grammar) And(x andLevel, op opToken[andKind], y compareLevel) andLevel {
+	return andLevel{binary{start: op.start, op: op.op, left: x.node, right: y.node}}
+}
+
+func (grammar) AndUp(x compareLevel) andLevel {
+	return andLevel(x)
+}
+
+// Comparisons do not associate, so a < b < c is not allowed.
+func (grammar) Compare(x addLevel, op opToken[compareKind], y addLevel) compareLevel {
+	return compareLevel{binary{start: op.start, op: op.op, left: x.node, right: y.node}}
+}
+
+func (grammar) CompareUp(x addLevel) compareLevel {
+	return compareLevel(x)
+}
+
+func (grammar) Add(x addLevel, op opToken[addKind], y mulLevel) addLevel {
+	return addLevel{binary{start: op.start, op: op.op, left: x.node, right: y.node}}
+}
+
+func (grammar) AddUp(x mulLevel) addLevel {
+	return addLevel(x)
+}
+
+func (grammar) Mul(x mulLevel, op opToken[mulKind], y unaryLevel) mulLevel {
+	return mulLevel{binary{start: op.start, op: op.op, left: x.node, right: y.node}}
+}
+
+func (grammar) MulUp(x unaryLevel) mulLevel {
+	return mulLevel(x)
+}
+
+func (grammar) Not(op opToken[notKind], x unaryLevel) unaryLevel {
+	return unaryLevel{unary{start: op.start, op: op.op, operand: x.node}}
+}
+
+func (grammar) Negate(op opToken[addKind], x unaryLevel) unaryLevel {
+	return unaryLevel{unary{start: op.start, op: op.op, operand: x.node}}
+}
+
+func (grammar) UnaryUp(x primaryLevel) unaryLevel {
+	return unaryLevel(x)
+}
+
+func (grammar) Number(x numberToken) primaryLevel {
+	return primaryLevel{literal{x.value}}
+}
+
+func (grammar) Text(x stringToken) primaryLevel {
+	return primaryLevel{literal{x.value}}
+}
+
+func (grammar) Bool(x boolToken) primaryLevel {
+	return primaryLevel{literal{x.value}}
+}
+
+func (grammar) Name(x nameToken) primaryLevel {
+	return primaryLevel{variable{start: x.start, path: x.path}}
+}
+
+func (grammar) Group(_ openToken, x orLevel, _ closeToken) primaryLevel {
+	return primaryLevel(x)
+}
+
+var language = &tp.Language[token, orLevel, node]{
+	Lexer:   lexer,
+	Grammar: grammar{},
+}
@@ -0,0 +1,68 @@
+package tp
+
+import "slices"
+
+// Features describes optional parts of a language, such as extensions that some users of it allow,
+// for grammars whose rules are only used when a feature is enabled. A grammar declares its features
+// with a Features method, which is called for every parse:
+//
+//	func (g jsonGrammar) Features() tp.Features {
+//		return tp.Features{
+//			Enabled: g.Extensions,
+//			Rules:   map[string]string{"TrailingComma": "trailing-commas"},
+//		}
+//	}
+//
+// A text that can only be parsed with rules whose features are not enabled fails with an
+// ErrFeature, which names the feature that would be needed. Features are independent of versions,
+// see Versions, and a rule may need both.
+type Features struct {
+	// The features that are enabled for the parse.
+	Enabled []string
+
+	// The features that the grammar's own rules need, by the names of their methods. Rules that are
+	// not given are always used.
+	Rules map[string]string
+}
+
+// ErrFeature is the error of a parse that needs a rule whose feature is not enabled.
+type ErrFeature struct {
+	// The name of the rule's method, its label or its name if it does not have one, and the tokens
+	// that it matched.
+	Rule  string
+	Label string
+	Span  Span
+
+	Feature string
+}
+
+func (e *ErrFeature) Error() string {
+	return EnglishMessages{}.Feature(e)
+}
+
+// Find the rules of a grammar whose features are not enabled, and the error that each leads to.
+func featureExclusions(g any, gr *grammar) map[*rule]func(Span) error {
+	f, ok := g.(interface{ Features() Features })
+	if !ok {
+		return nil
+	}
+	fs := f.Features()
+	checkRuleNames("Features", g, fs.Rules)
+
+	res := map[*rule]func(Span) error{}
+	for _, sym := range gr.symbols {
+		for _, r := range sym.Predictions {
+			feature, ok := fs.Rules[r.Name]
+			if !ok || r.Host.IsValid() || slices.Contains(fs.Enabled, feature) {
+				continue
+			}
+			e := ErrFeature{Rule: r.Name, Label: r.label(), Feature: feature}
+			res[r] = func(s Span) error {
+				e := e
+				e.Span = s
+				return &e
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,57 @@
+package tp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type featureGrammar struct {
+	interfaceGrammar
+	extensions []string
+}
+
+func (g featureGrammar) Features() tp.Features {
+	return tp.Features{
+		Enabled: g.extensions,
+		Rules: map[string]string{
+			"Trailing": "trailing-plus",
+			"Implicit": "implicit-plus",
+		},
+	}
+}
+
+func (featureGrammar) Trailing(x expr, _ plusTok) expr {
+	return x
+}
+
+func (featureGrammar) Implicit(x intTok, y intTok) add {
+	return add{intVal(x), intVal(y)}
+}
+
+func TestFeatures(t *testing.T) {
+	g := featureGrammar{extensions: []string{"trailing-plus", "implicit-plus"}}
+	x, err := tp.Parse(g, []any{intTok{1}, intTok{2}, plusTok{}})
+	assert.Nil(t, err)
+	assert.Equal[expr](t, x, add{intVal{1}, intVal{2}})
+
+	_, err = tp.Parse(featureGrammar{}, []any{intTok{1}, plusTok{}, intTok{2}, intTok{3}})
+	var feature *tp.ErrFeature
+	assert.True(t, errors.As(err, &feature))
+	assert.Equal(t, *feature, tp.ErrFeature{
+		Rule:    "Implicit",
+		Label:   "Implicit",
+		Span:    tp.Span{Start: 2, End: 4},
+		Feature: "implicit-plus",
+	})
+	assert.Equal(t, err.Error(), "Implicit requires the implicit-plus feature, which is not enabled")
+
+	// each feature is enabled separately
+	g.extensions = []string{"implicit-plus"}
+	_, err = tp.Parse(g, []any{intTok{1}, intTok{2}, plusTok{}})
+	assert.True(t, errors.As(err, &feature))
+	assert.Equal(t, feature.Feature, "trailing-plus")
+	assert.Equal(t, feature.Span, tp.Span{Start: 0, End: 3})
+}
@@ -0,0 +1,140 @@
+package tp
+
+import "reflect"
+
+// The tokens that can begin a rule. Predicting a rule that cannot begin with the next token only
+// adds an item to the state set that goes no further, so such rules are not predicted.
+type firstSet struct {
+	// the concrete token types that can begin the rule
+	types map[reflect.Type]bool
+
+	// the interface token types that can begin the rule
+	interfaces []reflect.Type
+
+	// whether the rule can match no tokens, in which case it must always be predicted
+	empty bool
+}
+
+func (f *firstSet) add(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		for _, u := range f.interfaces {
+			if u == t {
+				return false
+			}
+		}
+		f.interfaces = append(f.interfaces, t)
+		return true
+	}
+	if f.types[t] {
+		return false
+	}
+	f.types[t] = true
+	return true
+}
+
+func (f *firstSet) allows(t reflect.Type) bool {
+	if f.empty || f.types[t] {
+		return true
+	}
+	for _, u := range f.interfaces {
+		if t.Implements(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the tokens that can begin each rule. A symbol's first tokens are those of its rules, so these
+// are found together, adding to them until nothing changes.
+func (s *scanner) markFirstSets() {
+	var rules []*rule
+	for _, sym := range s.types {
+		for _, r := range sym.Predictions {
+			r.First = firstSet{types: map[reflect.Type]bool{}}
+			rules = append(rules, r)
+		}
+	}
+
+	symFirst := map[*symbol]*firstSet{}
+	firstOf := func(sym *symbol) *firstSet {
+		f, ok := symFirst[sym]
+		if !ok {
+			f = &firstSet{types: map[reflect.Type]bool{}}
+			symFirst[sym] = f
+		}
+		return f
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			f := &r.First
+			empty := true
+			for _, d := range r.Deps {
+				if d.TokenType != nil {
+					changed = f.add(d.TokenType) || changed
+					empty = false
+					break
+				}
+				for t := range firstOf(d).types {
+					changed = f.add(t) || changed
+				}
+				for _, t := range firstOf(d).interfaces {
+					changed = f.add(t) || changed
+				}
+				if !d.Nullable && !d.Conditional && d.NotFollowedBy == nil && d.Layout == nil {
+					empty = false
+					break
+				}
+			}
+			if empty && !f.empty {
+				f.empty = true
+				changed = true
+			}
+
+			sf := firstOf(r.Implements)
+			for t := range f.types {
+				changed = sf.add(t) || changed
+			}
+			for _, t := range f.interfaces {
+				changed = sf.add(t) || changed
+			}
+		}
+	}
+}
+
+// Predict the rules for a symbol that can begin with the next token, along with those of the symbols
+// that they in turn predict. A placeholder can stand for any symbol, so every rule is predicted
+// before one.
+//
+// The rules of a symbol are only predicted once at each position, so they are added as a block
+// without checking whether they are already in the state set.
+func (p *matcher) predictFor(s *symbol, tok reflect.Value, isHole bool) {
+	for _, sym := range s.Closure {
+		if p.markPredicted(sym) {
+			continue
+		}
+		for _, prediction := range sym.Predictions {
+			if !isHole && !prediction.First.allows(tok.Type()) {
+				continue
+			}
+			p.appendTo(p.cur, item{
+				rule:     prediction,
+				position: p.cur,
+			})
+		}
+	}
+}
+
+// Predict the rules for a symbol that can match no tokens, at the end of the input.
+func (p *matcher) predictEmpty(s *symbol) {
+	for _, prediction := range s.Predictions {
+		if !prediction.First.empty {
+			continue
+		}
+		p.addToCur(item{
+			rule:     prediction,
+			position: p.cur,
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package tp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+type prefixGrammar struct{}
+
+func (prefixGrammar) Parse(x testExpr) (testExpr, error) {
+	return x, nil
+}
+
+func (prefixGrammar) Int(x intTok) intVal {
+	return intVal(x)
+}
+
+func (prefixGrammar) Add(_ plusTok, left, right testExpr) add {
+	return add{left, right}
+}
+
+func TestFirstSets(t *testing.T) {
+	gr := scanGrammar(reflect.ValueOf(prefixGrammar{}), reflect.TypeFor[testExpr]())
+	for _, r := range gr.root.Predictions {
+		switch r.Name {
+		case "Int":
+			assert.True(t, r.First.allows(reflect.TypeFor[intTok]()))
+			assert.False(t, r.First.allows(reflect.TypeFor[plusTok]()))
+		case "Add":
+			assert.False(t, r.First.allows(reflect.TypeFor[intTok]()))
+			assert.True(t, r.First.allows(reflect.TypeFor[plusTok]()))
+		}
+	}
+}
+
+func TestOnlyViableRulesArePredicted(t *testing.T) {
+	gr := scanGrammar(reflect.ValueOf(prefixGrammar{}), reflect.TypeFor[testExpr]())
+	m := &matcher{
+		root: gr.root,
+		toks: tokenValues([]any{plusTok{}, intTok{1}, intTok{2}}),
+	}
+	assert.Nil(t, m.run())
+
+	// only Add can begin with the plus, and only Int with the numbers that follow it
+	assert.Equal(t, len(m.state[0]), 1)
+	assert.Equal(t, m.state[0][0].rule.Name, "Add")
+	assert.Equal(t, len(m.state[1]), 2)
+	assert.Equal(t, m.state[1][1].rule.Name, "Int")
+}
@@ -0,0 +1,97 @@
+package tp
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+)
+
+// Apply inline flags to the tokens that they affect. A flag group affects the rest of the group that
+// it appears in, while a group with flags only affects its own contents.
+//
+// This is done before parsing so that characters are already folded when they are combined into
+// sets, which means that e.g. [^a] excludes both cases of a.
+func applyFlags(toks []token) error {
+	fold := false
+	var outer []bool
+
+	for i, t := range toks {
+		switch t := t.(type) {
+		case groupOpen:
+			outer = append(outer, fold)
+		case flagOpen:
+			outer = append(outer, fold)
+			f, err := parseFlags(t.flags, fold)
+			if err != nil {
+				return err
+			}
+			fold = f
+		case flagGroup:
+			f, err := parseFlags(t.flags, fold)
+			if err != nil {
+				return err
+			}
+			fold = f
+		case groupClose:
+			if len(outer) > 0 {
+				fold = outer[len(outer)-1]
+				outer = outer[:len(outer)-1]
+			}
+		case char:
+			t.fold = fold
+			toks[i] = t
+		case slash:
+			t.fold = fold
+			toks[i] = t
+		}
+	}
+
+	return nil
+}
+
+func parseFlags(flags string, fold bool) (bool, error) {
+	for _, f := range flags {
+		switch f {
+		case 'i':
+			fold = true
+		case 's':
+			// . already matches everything
+		case 'm':
+			// only changes what a leading ^ anchors to, see leadingAnchor
+		case '-':
+			return false, fmt.Errorf("clearing flags is not supported")
+		default:
+			return false, fmt.Errorf("unknown flag %q", f)
+		}
+	}
+	return fold, nil
+}
+
+// Find the ranges that match the runes in a range regardless of case.
+func foldRange(start, end rune) []match {
+	var extra []rune
+	for _, cr := range unicode.CaseRanges {
+		lo, hi := max(rune(cr.Lo), start), min(rune(cr.Hi), end)
+		for r := lo; r <= hi; r++ {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				if f < start || f > end {
+					extra = append(extra, f)
+				}
+			}
+		}
+	}
+
+	slices.Sort(extra)
+	extra = slices.Compact(extra)
+
+	res := []match{{start: start, end: end}}
+	for _, r := range extra {
+		last := &res[len(res)-1]
+		if len(res) > 1 && last.end+1 == r {
+			last.end = r
+			continue
+		}
+		res = append(res, match{start: r, end: r})
+	}
+	return res
+}
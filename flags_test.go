@@ -0,0 +1,121 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestRegexFlags(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		pattern string
+		accept  []string
+		reject  []string
+	}{
+		{
+			name:    "CaseInsensitive",
+			pattern: `(?i)select`,
+			accept:  []string{"select", "SELECT", "SeLeCt"},
+		},
+		{
+			name:    "Scoped",
+			pattern: `a(?i:b)c`,
+			accept:  []string{"abc", "aBc"},
+			reject:  []string{"ABc", "abC"},
+		},
+		{
+			name:    "ScopedToGroup",
+			pattern: `a((?i)b)c`,
+			accept:  []string{"abc", "aBc"},
+			reject:  []string{"Abc", "abC"},
+		},
+		{
+			name:    "Midway",
+			pattern: `a(?i)bc`,
+			accept:  []string{"abc", "aBC"},
+			reject:  []string{"Abc"},
+		},
+		{
+			name:    "MidwayInGroup",
+			pattern: `(a(?i)b)c|d`,
+			accept:  []string{"aBc", "d"},
+			reject:  []string{"aBC", "D"},
+		},
+		{
+			name:    "Charset",
+			pattern: `(?i)[a-c]+`,
+			accept:  []string{"AbC", "cab"},
+			reject:  []string{"d", "D"},
+		},
+		{
+			name:    "InverseCharset",
+			pattern: `(?i)[^a]`,
+			accept:  []string{"b", "B"},
+			reject:  []string{"a", "A"},
+		},
+		{
+			name:    "Unicode",
+			pattern: `(?i)k`,
+			accept:  []string{"k", "K", "K"},
+		},
+		{
+			name:    "DotAll",
+			pattern: `(?s)a.b`,
+			accept:  []string{"a\nb", "axb"},
+		},
+		{
+			name:    "NonCapturing",
+			pattern: `(?:ab)+`,
+			accept:  []string{"ab", "abab"},
+			reject:  []string{"AB"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewLexer(Regex(test.pattern, func(start int, text string) (string, error) {
+				return text, nil
+			}))
+			if !assert.Nil(t, err) {
+				return
+			}
+			for _, in := range test.accept {
+				_, n, err := p.Match([]byte(in))
+				assert.Nil(t, err)
+				assert.Equal(t, n, len(in))
+			}
+			for _, in := range test.reject {
+				_, n, _ := p.Match([]byte(in))
+				assert.True(t, n != len(in))
+			}
+		})
+	}
+}
+
+func TestRegexFlagErrors(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		err     string
+	}{
+		{`(?x:a)`, "rule 0: `(?x:a)`: unknown flag 'x'"},
+		{`(?i)(?-i:a)`, "rule 0: `(?i)(?-i:a)`: clearing flags is not supported"},
+	} {
+		_, err := NewLexer(Regex(test.pattern, func(start int, text string) (string, error) {
+			return text, nil
+		}))
+		if assert.True(t, err != nil) {
+			assert.Equal(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestNonCapturingGroup(t *testing.T) {
+	p, err := NewLexer(RegexCapture(`(?:a)(b)`, func(m Match, groups []Match) (int, error) {
+		return len(groups), nil
+	}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	n, _, err := p.Match([]byte("ab"))
+	assert.Nil(t, err)
+	assert.Equal(t, n, 2)
+}
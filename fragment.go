@@ -0,0 +1,60 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Define a named pattern that can be referred to as \g{name} in the patterns of the token specs
+// that follow it, including other fragments. It does not produce any tokens itself.
+//
+// A fragment is parsed on its own, so flags in the pattern that refers to it do not apply to it,
+// and it may not contain capturing groups. Use (?:s) for grouping instead. Nor may it begin with ^,
+// as it does not decide where a token begins.
+//
+// So, e.g. a lexer for numbers with exponents could be built as
+//
+//	tp.Fragment[Token]("digits", `[0-9]+`),
+//	tp.Regex(`\g{digits}(?:\.\g{digits})?(?:[eE][+\-]?\g{digits})?`, number),
+func Fragment[T any](name, re string) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		l.describeRule(re)
+
+		e, err := parseRegex(re, l.fragments, l.escapes)
+		if err != nil {
+			return err
+		}
+		if len(e.groups) != 0 {
+			return errors.New("fragments cannot contain capturing groups")
+		}
+		if e.anchor != anchorNone {
+			return errors.New("fragments cannot begin with ^")
+		}
+		if _, ok := l.fragments[name]; ok {
+			return fmt.Errorf("fragment %q is already defined", name)
+		}
+
+		if l.fragments == nil {
+			l.fragments = map[string]expr{}
+		}
+		l.fragments[name] = e.expr
+		return nil
+	}
+}
+
+// Look up the patterns that fragment references refer to.
+func resolveFragments(toks []token, fragments map[string]expr) error {
+	for i, t := range toks {
+		f, ok := t.(fragmentRef)
+		if !ok {
+			continue
+		}
+		e, ok := fragments[f.name]
+		if !ok {
+			return fmt.Errorf("unknown fragment %q", f.name)
+		}
+		f.expr = e
+		toks[i] = f
+	}
+	return nil
+}
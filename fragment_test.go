@@ -0,0 +1,78 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestFragment(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	l, err := NewLexer(
+		Fragment[string]("digits", `[0-9]+`),
+		Fragment[string]("exp", `[eE][+\-]?\g{digits}`),
+		Regex(`\g{digits}(?:\.\g{digits})?\g{exp}?`, yield),
+		Regex(`[ ]+`, yield),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	toks, err := l.Tokenize([]byte("12 1.5 3e10 2.5E-3")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{"12", " ", "1.5", " ", "3e10", " ", "2.5E-3"})
+}
+
+func TestFragmentErrors(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	for _, test := range []struct {
+		name  string
+		specs []TokenSpec[string]
+		err   string
+	}{
+		{
+			name:  "Unknown",
+			specs: []TokenSpec[string]{Regex(`\g{digit}`, yield)},
+			err:   "rule 0: `\\g{digit}`: unknown fragment \"digit\"",
+		},
+		{
+			name: "DefinedLater",
+			specs: []TokenSpec[string]{
+				Regex(`\g{digit}`, yield),
+				Fragment[string]("digit", `[0-9]`),
+			},
+			err: "rule 0: `\\g{digit}`: unknown fragment \"digit\"",
+		},
+		{
+			name: "Redefined",
+			specs: []TokenSpec[string]{
+				Fragment[string]("digit", `[0-9]`),
+				Fragment[string]("digit", `[0-7]`),
+			},
+			err: "rule 1: `[0-7]`: fragment \"digit\" is already defined",
+		},
+		{
+			name:  "Capture",
+			specs: []TokenSpec[string]{Fragment[string]("pair", `(a)(b)`)},
+			err:   "rule 0: `(a)(b)`: fragments cannot contain capturing groups",
+		},
+		{
+			name:  "Anchored",
+			specs: []TokenSpec[string]{Fragment[string]("digit", `^[0-9]`)},
+			err:   "rule 0: `^[0-9]`: fragments cannot begin with ^",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewLexer(test.specs...)
+			if assert.True(t, err != nil) {
+				assert.Equal(t, err.Error(), test.err)
+			}
+		})
+	}
+}
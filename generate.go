@@ -0,0 +1,172 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+)
+
+// RandomSource is a source of random numbers, such as those of math/rand/v2, which are all
+// RandomSources. It is declared here so that programs that do not generate inputs do not need
+// math/rand.
+type RandomSource interface {
+	Uint64() uint64
+}
+
+// The most times in a row that RandomSentences starts an input again before giving up.
+const maxRandomAttempts = 100
+
+// Generate random inputs, of at most maxLen tokens, that the grammar accepts, for testing the code
+// that deals with what is parsed. The choices are made with src, so a source seeded the same way,
+// such as rand.NewPCG(seed, 0), gives the same inputs each time, which keeps tests reproducible.
+//
+// As with FindAmbiguity, the inputs are made from the zero value of each token type that can appear
+// in an input of T, so only the shapes of inputs are random. Each input is built a token at a time,
+// choosing among the tokens that the grammar can continue with, and stopping at random once the
+// input is accepted. An input that cannot be completed within maxLen tokens is started again, and
+// if this happens too often, as with grammars that only accept longer inputs, the sequence ends.
+func RandomSentences[T, U, V any](g Grammar[U, V], src RandomSource, maxLen int) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+		terminals := terminalTypes[T](gr)
+
+		for attempts := 0; attempts < maxRandomAttempts; attempts++ {
+			toks, ok := randomSentence[T](gr, terminals, src, maxLen)
+			if !ok {
+				continue
+			}
+			if !yield(toks) {
+				return
+			}
+			attempts = -1
+		}
+	}
+}
+
+// Build an input a token at a time, reporting false if it could not be completed.
+func randomSentence[T any](gr *grammar, terminals []reflect.Type, src RandomSource, maxLen int) ([]T, bool) {
+	var toks []T
+	accepted := sentenceViable(gr, toks) == nil
+	for {
+		var next []T
+		if len(toks) < maxLen {
+			for _, t := range terminals {
+				tok := reflect.Zero(t).Interface().(T)
+				if err := sentenceViable(gr, append(toks, tok)); err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+					next = append(next, tok)
+				}
+			}
+		}
+
+		// stopping is as likely as any one of the tokens
+		if accepted && randomIndex(src, len(next)+1) == 0 {
+			return toks, true
+		}
+		if len(next) == 0 {
+			return nil, false
+		}
+		toks = append(toks, next[randomIndex(src, len(next))])
+		accepted = sentenceViable(gr, toks) == nil
+	}
+}
+
+// Choose a number in [0, n). This is slightly biased for large n, which does not matter for the
+// handful of choices that are made here.
+func randomIndex(src RandomSource, n int) int {
+	return int(src.Uint64() % uint64(n))
+}
+
+// Match an input against the grammar, returning io.ErrUnexpectedEOF if it is only the beginning of
+// an input that the grammar accepts.
+func sentenceViable[T any](gr *grammar, toks []T) error {
+	m := newMatcher(gr, gr.root, tokenValues(toks), true)
+	err := m.run()
+	if err != nil && m.failedAt == len(toks) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Shrink an input that fails a test to one that still fails it, but where removing any single token
+// would make it pass. The input is not modified, and it must fail the test to begin with. This is
+// for turning an input that was found to cause a problem, such as by RandomSentences or by a fuzz
+// test, into one that is short enough to debug.
+//
+// Tokens are removed in runs, beginning with halves of the input and then shorter runs, so that long
+// inputs shrink quickly.
+func Shrink[T any](toks []T, fails func([]T) bool) []T {
+	toks = append([]T(nil), toks...)
+	n := max(len(toks)/2, 1)
+	for len(toks) > 0 {
+		removed := false
+		for i := 0; i+n <= len(toks); {
+			candidate := append(append([]T(nil), toks[:i]...), toks[i+n:]...)
+			if fails(candidate) {
+				toks = candidate
+				removed = true
+				continue
+			}
+			i += n
+		}
+		switch {
+		case n > 1:
+			n = max(n/2, 1)
+		case !removed:
+			return toks
+		}
+	}
+	return toks
+}
+
+// Shrink an input that a parse fails on to one that it fails on in the same way, but where removing
+// any single token would make it succeed or fail differently, returning it along with the error that
+// the parse gives for it. If the parse succeeds then the input is returned as it is, with a nil error.
+// The parse can be anything that parses a slice of tokens, such as a Parser's Parse method, or
+// ParseUnambiguous with the samples given, to shrink an input that is ambiguous.
+//
+// Failures are alike if they are:
+//
+//   - ErrUnclosedBracket, opened by tokens of the same type;
+//   - ErrUnexpectedToken, found at tokens of the same type;
+//   - io.ErrUnexpectedEOF;
+//   - RuleError, from the same rule;
+//   - AmbiguityError, of the same type of part;
+//   - otherwise, errors with the same message.
+func ShrinkError[T, V any](toks []T, parse func([]T) (V, error)) ([]T, error) {
+	_, err := parse(toks)
+	if err == nil {
+		return toks, nil
+	}
+	class := errorClass(err)
+	toks = Shrink(toks, func(toks []T) bool {
+		_, err := parse(toks)
+		return err != nil && errorClass(err) == class
+	})
+	_, err = parse(toks)
+	return toks, err
+}
+
+// Describe the way that a parse failed, leaving out where it failed.
+func errorClass(err error) string {
+	var (
+		unclosed   *ErrUnclosedBracket
+		unexpected *ErrUnexpectedToken
+		rule       *RuleError
+		ambiguity  *AmbiguityError
+	)
+	switch {
+	case errors.As(err, &unclosed):
+		return fmt.Sprintf("unclosed %T", unclosed.Open)
+	case errors.As(err, &unexpected):
+		return fmt.Sprintf("unexpected %T", unexpected.Token)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	case errors.As(err, &rule):
+		return "rule " + rule.Rule
+	case errors.As(err, &ambiguity):
+		return "ambiguous " + ambiguity.Symbol
+	}
+	return "error " + err.Error()
+}
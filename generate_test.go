@@ -0,0 +1,154 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func TestRandomSentences(t *testing.T) {
+	generate := func(seed uint64) [][]jsonToken {
+		var res [][]jsonToken
+		for toks := range tp.RandomSentences[jsonToken](numberBlockGrammar{}, rand.NewPCG(seed, 0), 8) {
+			res = append(res, toks)
+			if len(res) == 20 {
+				break
+			}
+		}
+		return res
+	}
+
+	sentences := generate(1)
+	assert.Equal(t, len(sentences), 20)
+	for _, toks := range sentences {
+		assert.True(t, len(toks) <= 8)
+		_, err := tp.Parse(numberBlockGrammar{}, toks)
+		assert.Nil(t, err)
+	}
+
+	// the same seed gives the same inputs
+	assert.Equal(t, generate(1), sentences)
+	assert.False(t, slices.EqualFunc(generate(2), sentences, func(a, b []jsonToken) bool {
+		return slices.Equal(a, b)
+	}))
+}
+
+func TestRandomSentencesTooShort(t *testing.T) {
+	// every list has at least two tokens
+	count := 0
+	for range tp.RandomSentences[jsonToken](numberListGrammar{}, rand.NewPCG(1, 0), 1) {
+		count++
+	}
+	assert.Equal(t, count, 0)
+}
+
+func TestShrink(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		in    []int
+		fails func([]int) bool
+		out   []int
+	}{
+		{
+			name: "Single",
+			in:   []int{1, 2, 3, 4, 5, 6, 7, 8, 9},
+			fails: func(xs []int) bool {
+				return slices.Contains(xs, 7)
+			},
+			out: []int{7},
+		},
+		{
+			name: "Pair",
+			in:   []int{5, 1, 8, 2, 9, 3, 4},
+			fails: func(xs []int) bool {
+				return slices.Index(xs, 2) != -1 && slices.Index(xs, 2) < slices.Index(xs, 3)
+			},
+			out: []int{2, 3},
+		},
+		{
+			name: "Sum",
+			in:   []int{1, 1, 1, 1, 1, 1, 1, 1},
+			fails: func(xs []int) bool {
+				sum := 0
+				for _, x := range xs {
+					sum += x
+				}
+				return sum >= 3
+			},
+			out: []int{1, 1, 1},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			in := slices.Clone(test.in)
+			assert.Equal(t, tp.Shrink(in, test.fails), test.out)
+			assert.Equal(t, in, test.in)
+		})
+	}
+}
+
+func ExampleShrinkError() {
+	var toks []any
+	for i := range 9 {
+		if i > 0 {
+			toks = append(toks, plusTok{})
+		}
+		toks = append(toks, intTok{i + 1})
+	}
+
+	toks, err := tp.ShrinkError(toks, func(toks []any) (expr, error) {
+		return tp.ParseUnambiguous(interfaceGrammar{}, toks, 2)
+	})
+	fmt.Println(toks)
+	fmt.Println(errors.Is(err, tp.ErrAmbiguousParse))
+
+	// Output:
+	// [{7} {} {8} {} {9}]
+	// true
+}
+
+func TestShrinkError(t *testing.T) {
+	parse := func(toks []jsonToken) (numberList, error) {
+		return tp.Parse(numberListGrammar{}, toks)
+	}
+
+	for _, test := range []struct {
+		name string
+		src  string
+		out  []jsonToken
+		err  string
+	}{
+		{
+			name: "UnexpectedToken",
+			src:  `[1, 2, 3,]`,
+			out:  []jsonToken{arrayStartToken{}, numberToken{1}, commaToken{}, arrayEndToken{}},
+			err:  "unexpected token: tp_test.arrayEndToken{}",
+		},
+		{
+			name: "UnexpectedEOF",
+			src:  `[1, 2, 3`,
+			err:  "unexpected EOF",
+		},
+		{
+			name: "Success",
+			src:  `[1, 2]`,
+			out:  removeWhitespace(must(lexicon.Tokenize([]byte(`[1, 2]`)).Force())),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			toks := removeWhitespace(must(lexicon.Tokenize([]byte(test.src)).Force()))
+			out, err := tp.ShrinkError(toks, parse)
+			assert.Equal(t, out, test.out)
+			if test.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.True(t, err != nil)
+				assert.Equal(t, err.Error(), test.err)
+			}
+		})
+	}
+}
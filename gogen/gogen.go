@@ -0,0 +1,183 @@
+// Package gogen writes lexers as Go source, so that programs can tokenize with tables that are
+// built when the program is compiled rather than when it starts.
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"unicode"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Write a Go source file, in the named package, holding a lexer that matches the same text as l
+// with tables rather than by running the machine. The generated code only depends on the standard
+// library, and does not need to build the machine when the program starts. It declares the
+// following, each named beginning with name:
+//
+//	// a token: the spec that matched it, and the byte offsets where its text begins and ends
+//	type nameToken struct {
+//		Rule, Start, End int
+//	}
+//
+//	// split a text into tokens, leaving out the text of specs given to Skip, and returning the
+//	// offset where no token matches the text, or -1 if the whole text was split into tokens
+//	func nameTokens(text []byte) ([]nameToken, int)
+//
+//	// find the longest token at the beginning of a text, returning its spec and the length of its
+//	// text, or -1 and 0 if there is none
+//	func nameMatch(text []byte) (rule, n int)
+//
+// Specs are identified as in tp.TableFinal. The token constructors are not called, so the program
+// must build tokens for itself.
+//
+// This is for programs that need to start quickly, or to tokenize as fast as they can. The tables
+// are those of Lexer.Tables, so l is left as it was, and the lexers that it cannot describe cannot
+// be generated.
+func Lexer[T any](w io.Writer, l *tp.Lexer[T], pkg, name string) error {
+	if !token.IsIdentifier(pkg) || !token.IsIdentifier(name) {
+		return fmt.Errorf("gogen: cannot generate package %q with names beginning %q", pkg, name)
+	}
+	tables, err := l.Tables()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, generatedHeader, pkg)
+	fmt.Fprintf(&b, generatedCode, name)
+
+	fmt.Fprintf(&b, "var %sFinals = []%[1]sFinal{\n", name)
+	for _, f := range tables.Finals {
+		fmt.Fprintf(&b, "{rule: %d, skip: %t},\n", f.Rule, f.Skip)
+	}
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "var %sStates = []%[1]sState{\n", name)
+	for _, s := range tables.States {
+		fmt.Fprintf(&b, "{final: %d, moves: []%sRange{", s.Final, name)
+		for i, m := range s.Moves {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "{%s, %s, %d}", generatedRune(m.Min), generatedRune(m.Max), m.Then)
+		}
+		b.WriteString("}},\n")
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// Write a rune as a Go literal that is easily read.
+func generatedRune(r rune) string {
+	if r < unicode.MaxASCII && unicode.IsGraphic(r) {
+		return fmt.Sprintf("%q", r)
+	}
+	return fmt.Sprintf("%#x", r)
+}
+
+const generatedHeader = `// Code generated by tp. DO NOT EDIT.
+
+package %s
+
+import "unicode/utf8"
+
+`
+
+const generatedCode = `// A token: the index of the spec that matched it, and the byte offsets where its text begins and
+// ends.
+type %[1]sToken struct {
+	Rule, Start, End int
+}
+
+// Split a text into tokens, leaving out the text of specs that skip it. If no token matches the text
+// at some offset then the tokens before it are returned with that offset, otherwise with -1.
+func %[1]sTokens(text []byte) ([]%[1]sToken, int) {
+	var toks []%[1]sToken
+	for pos := 0; pos < len(text); {
+		final, n := %[1]sScan(text[pos:])
+		if final == -1 {
+			return toks, pos
+		}
+		if f := %[1]sFinals[final]; !f.skip {
+			toks = append(toks, %[1]sToken{Rule: f.rule, Start: pos, End: pos + n})
+		}
+		pos += n
+	}
+	return toks, -1
+}
+
+// Find the longest token at the beginning of a text, returning the index of the spec that matched it
+// and the length of its text, or -1 and 0 if no token does.
+func %[1]sMatch(text []byte) (rule, n int) {
+	final, n := %[1]sScan(text)
+	if final == -1 {
+		return -1, 0
+	}
+	return %[1]sFinals[final].rule, n
+}
+
+func %[1]sScan(text []byte) (final, n int) {
+	final = -1
+	state, pos := 0, 0
+	for {
+		s := &%[1]sStates[state]
+		if pos != 0 && s.final != -1 {
+			final, n = s.final, pos
+		}
+		if pos == len(text) {
+			return final, n
+		}
+		c, size := rune(text[pos]), 1
+		if c >= utf8.RuneSelf {
+			c, size = utf8.DecodeRune(text[pos:])
+		}
+		state = %[1]sMove(s.moves, c)
+		if state == -1 {
+			return final, n
+		}
+		pos += size
+	}
+}
+
+func %[1]sMove(moves []%[1]sRange, c rune) int {
+	lo, hi := 0, len(moves)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		switch {
+		case moves[m].max < c:
+			lo = m + 1
+		case moves[m].min > c:
+			hi = m
+		default:
+			return moves[m].next
+		}
+	}
+	return -1
+}
+
+type %[1]sFinal struct {
+	rule int
+	skip bool
+}
+
+type %[1]sState struct {
+	final int
+	moves []%[1]sRange
+}
+
+type %[1]sRange struct {
+	min, max rune
+	next     int
+}
+
+`
@@ -0,0 +1,52 @@
+package gogen
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func TestLexerInvalid(t *testing.T) {
+	l, err := tp.NewLexer(tp.Regex(`a`, func(start int, text string) (int, error) {
+		return 0, nil
+	}))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	err = Lexer(io.Discard, l, "my-pkg", "lex")
+	assert.Equal(t, err.Error(), `gogen: cannot generate package "my-pkg" with names beginning "lex"`)
+
+	// the lexer can still be changed
+	assert.Nil(t, Lexer(io.Discard, l, "pkg", "lex"))
+	l.State()
+}
+
+func TestLexerImports(t *testing.T) {
+	l, err := tp.NewLexer(tp.Regex(`[a-z]+`, func(start int, text string) (int, error) {
+		return 0, nil
+	}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	var b bytes.Buffer
+	if !assert.Nil(t, Lexer(&b, l, "pkg", "lex")) {
+		return
+	}
+
+	// generated lexers do not need tp, or reflect, to run
+	f, err := parser.ParseFile(token.NewFileSet(), "lex.go", b.Bytes(), parser.ImportsOnly)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var imports []string
+	for _, spec := range f.Imports {
+		imports = append(imports, spec.Path.Value)
+	}
+	assert.Equal(t, imports, []string{`"unicode/utf8"`})
+}
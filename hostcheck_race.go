@@ -0,0 +1,75 @@
+//go:build race
+
+package tp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Call a rule's method, checking that it does not modify its host. A host that is shared between
+// parses running in different goroutines is a data race waiting to happen if its rules modify it,
+// so this is checked whenever the race detector is enabled.
+//
+// Only the host's own fields are compared, so changes made through maps, slices or pointers that
+// the host holds are not detected.
+func callRule(r *rule, args []reflect.Value) []reflect.Value {
+	host := args[0]
+	if host.Kind() != reflect.Pointer || host.IsNil() {
+		return r.Method(args)
+	}
+
+	before := reflect.New(host.Type().Elem()).Elem()
+	before.Set(host.Elem())
+
+	res := r.Method(args)
+
+	if !shallowEqual(before, host.Elem()) {
+		panic(fmt.Sprintf("tp: rule %s modified its grammar host %s", r.Name, host.Type()))
+	}
+	return res
+}
+
+// Compare two values without following references, so that e.g. two slices are equal if they share
+// the same backing array and length.
+func shallowEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if !shallowEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Array:
+		for i := range a.Len() {
+			if !shallowEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return shallowEqual(a.Elem(), b.Elem())
+
+	case reflect.Slice:
+		return a.UnsafePointer() == b.UnsafePointer() && a.Len() == b.Len() && a.Cap() == b.Cap()
+
+	case reflect.Map, reflect.Func, reflect.Chan, reflect.Pointer, reflect.UnsafePointer:
+		return a.UnsafePointer() == b.UnsafePointer()
+
+	case reflect.Float32, reflect.Float64:
+		// a NaN that has not been changed is still a NaN
+		return a.Float() == b.Float() || math.IsNaN(a.Float()) && math.IsNaN(b.Float())
+	}
+
+	return a.Equal(b)
+}
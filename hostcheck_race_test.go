@@ -0,0 +1,37 @@
+//go:build race
+
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+type countingRuleset struct {
+	count int
+}
+
+func (r *countingRuleset) Parse(x intList) (intList, error) {
+	return x, nil
+}
+
+func (r *countingRuleset) ParseInt(val intTok) intList {
+	r.count++
+	return intList{vals: []int{val.value}}
+}
+
+func TestHostModified(t *testing.T) {
+	defer func() {
+		assert.Equal(t, recover(), any("tp: rule ParseInt modified its grammar host *tp.countingRuleset"))
+	}()
+	Parse(&countingRuleset{}, []testTok{intTok{1}})
+	t.Error("expected a panic")
+}
+
+func TestHostUnmodified(t *testing.T) {
+	// writing through a map that the host holds is allowed, as the hooks can make it per-parse
+	r := &hookedRuleset{scale: 1}
+	_, err := Parse(r, []testTok{intTok{1}})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,174 @@
+// Package ini parses configuration files made of sections and key = value pairs, in the style of
+// INI and properties files:
+//
+//	; settings that come before any section header are in the global section
+//	name = example
+//
+//	[server]
+//	host = localhost
+//	port: 8080
+//
+// Keys and values are separated by '=' or ':', and the whitespace around them is ignored. A value is
+// the rest of its line. Lines that begin with ';' or '#' are comments.
+//
+// As well as being ready to use, this is meant as a starting point for languages of configuration
+// files, to be copied and changed as they need.
+package ini
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bobappleyard/tp"
+)
+
+// File is a parsed configuration file.
+type File struct {
+	// The sections of the file, in the order they appear. The first section is the global section,
+	// with an empty name, which holds the keys that come before any section header.
+	Sections []Section
+}
+
+// Section is a header and the keys that follow it.
+type Section struct {
+	Name string
+	Keys []Key
+
+	// The byte offset of the section's header, or 0 for the global section.
+	Offset int
+}
+
+// Key is a key and its value.
+type Key struct {
+	Name, Value string
+
+	// The byte offset of the key.
+	Offset int
+}
+
+// Parse a configuration file. Problems with its syntax are described by a tp.SyntaxError.
+func Parse(src []byte) (*File, error) {
+	if len(src) != 0 && src[len(src)-1] != '\n' {
+		src = append(src[:len(src):len(src)], '\n')
+	}
+	f, err := language.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Find the value of a key in a section, using the empty string for the global section. If the key
+// is given more than once, the last value is used, and sections with the same name are treated as
+// one section.
+func (f *File) Get(section, key string) (string, bool) {
+	for _, s := range slices.Backward(f.Sections) {
+		if s.Name != section {
+			continue
+		}
+		for _, k := range slices.Backward(s.Keys) {
+			if k.Name == key {
+				return k.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+type token interface {
+	token()
+}
+
+type headerToken struct {
+	start int
+	name  string
+}
+
+type keyToken struct {
+	start int
+	name  string
+}
+
+type valueToken struct {
+	value string
+}
+
+type newlineToken struct{}
+
+func (headerToken) token()  {}
+func (keyToken) token()     {}
+func (valueToken) token()   {}
+func (newlineToken) token() {}
+
+var language = &tp.Language[token, File, File]{
+	Lexer: func() *tp.Lexer[token] {
+		l, err := tp.NewLexer(
+			tp.Skip[token](`[ \t]+`),
+			tp.Skip[token](`[;#][^\r\n]*`),
+			tp.Regex(`\r?\n`, func(start int, text string) (token, error) {
+				return newlineToken{}, nil
+			}),
+			tp.Regex(`\[[^\]\r\n]*\]`, func(start int, text string) (token, error) {
+				return headerToken{start: start, name: strings.TrimSpace(text[1 : len(text)-1])}, nil
+			}),
+			tp.Regex(`[^ \t\r\n=:;#\[][^\r\n=:]*`, func(start int, text string) (token, error) {
+				return keyToken{start: start, name: strings.TrimSpace(text)}, nil
+			}),
+			tp.Regex(`[=:][^\r\n]*`, func(start int, text string) (token, error) {
+				return valueToken{value: strings.TrimSpace(text[1:])}, nil
+			}),
+		)
+		if err != nil {
+			panic(err)
+		}
+		return l
+	}(),
+	Grammar: grammar{},
+}
+
+// The lines of a file, which are grouped into sections once they have been parsed.
+type line interface {
+	line()
+}
+
+type header struct {
+	Section
+}
+
+type blank struct{}
+
+func (header) line() {}
+func (Key) line()    {}
+func (blank) line()  {}
+
+type grammar struct{}
+
+func (grammar) Parse(x File) (File, error) {
+	return x, nil
+}
+
+func (grammar) File(lines []line) File {
+	f := File{Sections: []Section{{}}}
+	for _, l := range lines {
+		switch l := l.(type) {
+		case header:
+			f.Sections = append(f.Sections, l.Section)
+		case Key:
+			s := &f.Sections[len(f.Sections)-1]
+			s.Keys = append(s.Keys, l)
+		}
+	}
+	return f
+}
+
+func (grammar) Header(h headerToken, _ newlineToken) header {
+	return header{Section{Name: h.name, Offset: h.start}}
+}
+
+func (grammar) Key(k keyToken, v valueToken, _ newlineToken) Key {
+	return Key{Name: k.name, Value: v.value, Offset: k.start}
+}
+
+func (grammar) Blank(_ newlineToken) blank {
+	return blank{}
+}
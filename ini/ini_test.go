@@ -0,0 +1,84 @@
+package ini_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+	"github.com/bobappleyard/tp/ini"
+)
+
+func ExampleParse() {
+	f, err := ini.Parse([]byte(`
+; settings that come before any section header are in the global section
+name = example
+
+[server]
+host = localhost
+port: 8080
+`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(f.Get("", "name"))
+	fmt.Println(f.Get("server", "port"))
+	fmt.Println(f.Get("server", "name"))
+
+	// Output:
+	// example true
+	// 8080 true
+	//  false
+}
+
+func TestParse(t *testing.T) {
+	f, err := ini.Parse([]byte("a=1\r\n# comment\r\n[ one ]\r\nb key = x = y ; z\r\n\r\n[two]\r\nc :\r\n[one]\r\nd=2"))
+	assert.Nil(t, err)
+	assert.Equal(t, f, &ini.File{Sections: []ini.Section{
+		{Keys: []ini.Key{{Name: "a", Value: "1", Offset: 0}}},
+		{Name: "one", Offset: 16, Keys: []ini.Key{{Name: "b key", Value: "x = y ; z", Offset: 25}}},
+		{Name: "two", Offset: 46, Keys: []ini.Key{{Name: "c", Value: "", Offset: 53}}},
+		{Name: "one", Offset: 58, Keys: []ini.Key{{Name: "d", Value: "2", Offset: 65}}},
+	}})
+
+	// sections with the same name are one section
+	v, ok := f.Get("one", "b key")
+	assert.True(t, ok)
+	assert.Equal(t, v, "x = y ; z")
+	v, ok = f.Get("one", "d")
+	assert.True(t, ok)
+	assert.Equal(t, v, "2")
+}
+
+func TestParseEmpty(t *testing.T) {
+	f, err := ini.Parse(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, f, &ini.File{Sections: []ini.Section{{}}})
+}
+
+func TestParseLater(t *testing.T) {
+	f, err := ini.Parse([]byte("a = 1\na = 2\n"))
+	assert.Nil(t, err)
+	v, _ := f.Get("", "a")
+	assert.Equal(t, v, "2")
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		src    string
+		offset int
+	}{
+		{"NoValue", "[a]\nkey\n", 7},
+		{"Unclosed", "[a\n", 0},
+		{"Trailing", "[a] b = c\n", 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ini.Parse([]byte(test.src))
+			var syntax *tp.SyntaxError
+			assert.True(t, errors.As(err, &syntax))
+			assert.Equal(t, syntax.Offset, test.offset)
+		})
+	}
+}
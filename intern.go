@@ -0,0 +1,33 @@
+package tp
+
+// Interner deduplicates token text, so that tokens with the same text share a string rather than
+// each allocating their own. This is useful for inputs that repeat the same identifiers many times.
+//
+// An Interner may be shared between several streams, but it is not safe for concurrent use.
+type Interner struct {
+	strings map[string]string
+}
+
+// Find the string with the same contents as b, allocating it if it has not been seen before.
+func (i *Interner) Intern(b []byte) string {
+	if s, ok := i.strings[string(b)]; ok {
+		return s
+	}
+	if i.strings == nil {
+		i.strings = map[string]string{}
+	}
+	s := string(b)
+	i.strings[s] = s
+	return s
+}
+
+// The number of distinct strings that have been interned.
+func (i *Interner) Len() int {
+	return len(i.strings)
+}
+
+// Deduplicate the text of matches using the interner. Returns the stream for convenience.
+func (l *Stream[T]) Intern(i *Interner) *Stream[T] {
+	l.interner = i
+	return l
+}
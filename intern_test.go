@@ -0,0 +1,37 @@
+package tp
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestInterner(t *testing.T) {
+	p, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(` `, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var in Interner
+	toks, err := p.Tokenize([]byte("abc def abc")).Intern(&in).Force()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, toks, []string{"abc", " ", "def", " ", "abc"})
+	assert.Equal(t, in.Len(), 3)
+	assert.True(t, unsafe.StringData(toks[0]) == unsafe.StringData(toks[4]))
+
+	more, err := p.Tokenize([]byte("def")).Intern(&in).Force()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, unsafe.StringData(toks[2]) == unsafe.StringData(more[0]))
+}
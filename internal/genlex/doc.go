@@ -0,0 +1,5 @@
+// Package genlex holds a lexer generated by gogen.Lexer, so that its tests can check that the
+// generated code matches the same text as the lexer that it was generated from.
+package genlex
+
+//go:generate go test -run TestGenerated -update
@@ -0,0 +1,54 @@
+package genlex
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+	"github.com/bobappleyard/tp/gogen"
+)
+
+var update = flag.Bool("update", false, "rewrite the generated lexer")
+
+func TestGenerated(t *testing.T) {
+	var b bytes.Buffer
+	if !assert.Nil(t, gogen.Lexer(&b, lexer, "genlex", "numbers")) {
+		return
+	}
+	if *update {
+		assert.Nil(t, os.WriteFile("numbers_gen.go", b.Bytes(), 0o644))
+		return
+	}
+	src, err := os.ReadFile("numbers_gen.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(src), b.String())
+}
+
+func TestTokens(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"[1, -22.5,\n333]",
+		`  [ "a \"b\"", true, nulls ] `,
+		"[1, ü日本]",
+		"[1, !]",
+		"[1, 2.]",
+		"[1\xff]",
+	} {
+		t.Run(src, func(t *testing.T) {
+			expect, err := lexer.Tokenize([]byte(src)).Force()
+			stop := -1
+			var noMatch *tp.ErrNoMatch
+			if errors.As(err, &noMatch) {
+				stop = noMatch.Start
+			}
+
+			toks, at := numbersTokens([]byte(src))
+			assert.Equal(t, toks, expect)
+			assert.Equal(t, at, stop)
+		})
+	}
+}
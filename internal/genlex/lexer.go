@@ -0,0 +1,31 @@
+package genlex
+
+import (
+	"github.com/bobappleyard/tp"
+)
+
+// The lexer that numbers_gen.go is generated from. Its tokens are those that the generated code
+// would yield for the same text.
+var lexer = func() *tp.Lexer[numbersToken] {
+	l, err := tp.NewLexer(
+		tp.Regex(`\[`, token(0)),
+		tp.Regex(`\]`, token(1)),
+		tp.Regex(`,`, token(2)),
+		tp.Skip[numbersToken](`\s+`),
+		tp.Regex(`-?\d+(\.\d+)?`, token(4)),
+		tp.Regex(`"([^"\\]|\\.)*"`, token(5)),
+		tp.Regex(`true|false|null`, token(6)),
+		tp.Regex(`[a-z]+`, token(7)),
+		tp.Regex(`[^ -~\s]+`, token(8)),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}()
+
+func token(rule int) tp.TokenConstructor[numbersToken] {
+	return func(start int, text string) (numbersToken, error) {
+		return numbersToken{Rule: rule, Start: start, End: start + len(text)}, nil
+	}
+}
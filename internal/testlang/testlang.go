@@ -0,0 +1,90 @@
+// Package testlang provides a small language, of lists of numbers, for testing the packages that
+// are built on tp.
+package testlang
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bobappleyard/tp"
+)
+
+type Token interface {
+	token()
+}
+
+type Open struct{}
+type Close struct{}
+type Comma struct{}
+type Space struct{}
+
+type Number struct {
+	Value int
+}
+
+func (Open) token()   {}
+func (Close) token()  {}
+func (Comma) token()  {}
+func (Space) token()  {}
+func (Number) token() {}
+
+type List struct {
+	Values []int
+}
+
+func (l List) String() string {
+	return fmt.Sprint(l.Values)
+}
+
+type Grammar struct{}
+
+func (Grammar) Parse(x List) (List, error) {
+	return x, nil
+}
+
+func (Grammar) List(_ Open, xs tp.Delimited[Number, Comma], _ Close) List {
+	res := List{Values: []int{}}
+	for _, x := range xs.Items {
+		res.Values = append(res.Values, x.Value)
+	}
+	return res
+}
+
+// Lists is a grammar for texts of several lists, such as [1] [2, 3], whose lists can be parsed one
+// at a time.
+type Lists struct{}
+
+func (Lists) Parse(xs []List) ([]List, error) {
+	return xs, nil
+}
+
+func (Lists) List(open Open, xs tp.Delimited[Number, Comma], close Close) List {
+	return Grammar{}.List(open, xs, close)
+}
+
+func token[T Token](start int, text string) (Token, error) {
+	var t T
+	return t, nil
+}
+
+// Numbers is a language for texts such as [1, 2, 3].
+var Numbers = &tp.Language[Token, List, List]{
+	Lexer: func() *tp.Lexer[Token] {
+		l, err := tp.NewLexer(
+			tp.Regex(`\[`, token[Open]),
+			tp.Regex(`\]`, token[Close]),
+			tp.Regex(`,`, token[Comma]),
+			tp.Categorize("trivia", tp.Regex(`\s+`, token[Space])),
+			tp.Regex(`[0-9]+`, func(start int, text string) (Token, error) {
+				n, err := strconv.Atoi(text)
+				return Number{n}, err
+			}),
+		)
+		if err != nil {
+			panic(err)
+		}
+		return l
+	}(),
+	Grammar: Grammar{},
+	Skip:    []string{"trivia"},
+}
@@ -0,0 +1,19 @@
+// Command wasmsize is a program that tokenizes and parses a text with tp, built for js/wasm by the
+// tests of tp to check that the core package builds for browsers and stays small when it does.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bobappleyard/tp/internal/testlang"
+)
+
+func main() {
+	res, err := testlang.Numbers.Parse([]byte("[1, 2, 3]"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(res)
+}
@@ -0,0 +1,51 @@
+package tp
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Match words, such as identifiers, with a regular expression, as Regex does, but yield a token
+// from a table of keywords when the text of a word is one of them. The keyword's constructor is
+// called in place of yield. However many keywords there are, the machine only needs the states of
+// the one pattern, where giving each keyword its own spec would add states for every one of them.
+//
+// So, e.g. a lexer for a language with a few reserved words could be built as
+//
+//	tp.Keywords(`[a-zA-Z_]\w*`, map[string]tp.TokenConstructor[Token]{
+//		"if":   keyword(If),
+//		"else": keyword(Else),
+//	}, identifier),
+//
+// Each keyword must be matched by the pattern, as it could never be produced otherwise.
+func Keywords[T any](re string, keywords map[string]TokenConstructor[T], yield TokenConstructor[T]) TokenSpec[T] {
+	keywords = maps.Clone(keywords)
+	return func(l *Lexer[T]) error {
+		l.describeRule(re)
+
+		e, err := parseRegex(re, l.fragments, l.escapes)
+		if err != nil {
+			return err
+		}
+
+		words := new(Lexer[bool])
+		end := words.State()
+		words.Final(end, func(start int, text string) (bool, error) {
+			return true, nil
+		})
+		e.expr.compile(words, 0, end)
+		for _, k := range slices.Sorted(maps.Keys(keywords)) {
+			if _, n, err := words.Match([]byte(k)); err != nil || n != len(k) {
+				return fmt.Errorf("keyword %q is not matched by the pattern", k)
+			}
+		}
+
+		return Regex(re, func(start int, text string) (T, error) {
+			if k, ok := keywords[text]; ok {
+				return k(start, text)
+			}
+			return yield(start, text)
+		})(l)
+	}
+}
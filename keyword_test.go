@@ -0,0 +1,48 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestKeywords(t *testing.T) {
+	type token struct {
+		kind, text string
+	}
+	keyword := func(start int, text string) (token, error) {
+		return token{"keyword", text}, nil
+	}
+
+	l, err := NewLexer(
+		Keywords(`[a-z]+`, map[string]TokenConstructor[token]{
+			"if":   keyword,
+			"else": keyword,
+		}, func(start int, text string) (token, error) {
+			return token{"ident", text}, nil
+		}),
+		Skip[token](`\s+`),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	toks, err := l.Tokenize([]byte("if iffy else x")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []token{
+		{"keyword", "if"},
+		{"ident", "iffy"},
+		{"keyword", "else"},
+		{"ident", "x"},
+	})
+}
+
+func TestKeywordsNotMatched(t *testing.T) {
+	_, err := NewLexer(
+		Keywords(`[a-z]+`, map[string]TokenConstructor[string]{
+			"if":  nil,
+			"_if": nil,
+		}, nil),
+	)
+	assert.Equal(t, err.Error(), "rule 0: `[a-z]+`: keyword \"_if\" is not matched by the pattern")
+}
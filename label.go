@@ -0,0 +1,48 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The labels given to the rules of a host by its Labels method, if it has one.
+func ruleLabels(hostType reflect.Type, host reflect.Value) map[string]string {
+	m, ok := hostType.MethodByName("Labels")
+	if !ok {
+		return nil
+	}
+	labels := m.Func.Call([]reflect.Value{host})[0].Interface().(map[string]string)
+	for name := range labels {
+		if _, ok := hostType.MethodByName(name); !ok {
+			panic(fmt.Sprintf("Labels names %s, which is not a rule of %s", name, hostType))
+		}
+	}
+	return labels
+}
+
+// The label of a rule, or its name if it does not have one.
+func (r *rule) label() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return r.Name
+}
+
+// Find the label of the innermost labelled rule that is part way through matching at a position,
+// i.e. the one that began last. Where several began at the same place, the preferred one is chosen.
+func (p *matcher) labelAt(at int) string {
+	var found *item
+	for i, x := range p.state[at] {
+		if x.progress == 0 || x.rule.Label == "" {
+			continue
+		}
+		if found == nil || x.position > found.position ||
+			x.position == found.position && x.rule.Index < found.rule.Index {
+			found = &p.state[at][i]
+		}
+	}
+	if found == nil {
+		return ""
+	}
+	return found.rule.Label
+}
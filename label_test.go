@@ -0,0 +1,52 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type labelledGrammar struct {
+	interfaceGrammar
+}
+
+func (labelledGrammar) Labels() map[string]string {
+	return map[string]string{
+		"Add": "addition",
+	}
+}
+
+func TestLabels(t *testing.T) {
+	_, trace, err := tp.ParseTrace(labelledGrammar{}, []any{intTok{1}, plusTok{}, intTok{2}})
+	assert.Nil(t, err)
+	var labels []string
+	for _, d := range trace {
+		labels = append(labels, d.Label)
+	}
+	assert.Equal(t, labels, []string{"Int", "Int", "addition"})
+	assert.Equal(t, trace[2].Rule, "Add")
+
+	_, err = tp.Parse(labelledGrammar{}, []any{intTok{1}, plusTok{}, plusTok{}})
+	assert.Equal(t, err.Error(), "unexpected token: tp_test.plusTok{} while parsing addition")
+
+	assert.Equal(t, tp.EBNF(labelledGrammar{}), `expr = add | intVal .
+add = expr plusTok expr /* addition */ .
+intVal = intTok .
+`)
+}
+
+type mislabelledGrammar struct {
+	interfaceGrammar
+}
+
+func (mislabelledGrammar) Labels() map[string]string {
+	return map[string]string{
+		"Sub": "subtraction",
+	}
+}
+
+func TestLabelsInvalid(t *testing.T) {
+	_, err := tp.NewParser[any](mislabelledGrammar{})
+	assert.Equal(t, err.Error(), "invalid grammar: Labels names Sub, which is not a rule of tp_test.mislabelledGrammar")
+}
@@ -0,0 +1,129 @@
+package tp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+)
+
+// Language bundles a lexer with a grammar, so that a text can be parsed in one step.
+type Language[T, U, V any] struct {
+	Lexer   *Lexer[T]
+	Grammar Grammar[U, V]
+
+	// Tokens in any of these categories are dropped before parsing, e.g. "trivia" for whitespace
+	// and comments.
+	Skip []string
+
+	// If set, each token is given the text skipped around it before it is parsed, so that e.g. a
+	// formatter can keep comments. See Trivia.
+	Trivia func(tok T, leading, trailing Trivia) T
+}
+
+// Trivia is text that comes between tokens that are parsed, either because the lexer skipped it or
+// because it was lexed as tokens in one of a Language's Skip categories.
+//
+// The text between two tokens is split at the end of the first line it contains. The part up to and
+// including that line break is the trailing trivia of the token before it, so that a comment at the
+// end of a line stays with the token that it follows, and the rest is the leading trivia of the token
+// after it. The text before the first token is its leading trivia, and the text after the last token
+// is its trailing trivia.
+type Trivia struct {
+	// The byte offset of the text.
+	Offset int
+
+	Text string
+}
+
+// SyntaxError describes a text that could not be parsed, and where the problem was found.
+type SyntaxError struct {
+	// The byte offset within the text.
+	Offset int
+
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return EnglishMessages{}.SyntaxError(e, e.Err.Error())
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Tokenize and parse a text. If the text could not be tokenized, or the tokens do not fit the
+// grammar, or only fit it with rules that are not in the version being parsed or whose features are
+// not enabled, then the error is a SyntaxError. Errors returned by rules are returned as RuleErrors,
+// and those returned by the grammar's Parse method as they are.
+func (l *Language[T, U, V]) Parse(src []byte) (V, error) {
+	var zero V
+
+	var toks []T
+	var starts, ends []int
+	s := l.Lexer.Tokenize(src)
+	for s.Next() {
+		if slices.Contains(l.Skip, s.Category()) {
+			continue
+		}
+		toks = append(toks, s.This())
+		starts = append(starts, s.tokPos)
+		ends = append(ends, s.srcPos)
+	}
+	if err := s.Err(); err != nil {
+		return zero, &SyntaxError{Offset: s.srcPos, Err: err}
+	}
+	if s.srcPos < len(src) {
+		return zero, &SyntaxError{Offset: s.srcPos, Err: ErrFailedMatch}
+	}
+	if l.Trivia != nil {
+		attachTrivia(l.Trivia, src, toks, starts, ends)
+	}
+
+	res, err := Parse(l.Grammar, toks)
+
+	var unexpected *ErrUnexpectedToken
+	var version *ErrVersion
+	var feature *ErrFeature
+	switch {
+	case errors.As(err, &unexpected):
+		return zero, &SyntaxError{Offset: starts[unexpected.Index], Err: err}
+	case errors.As(err, &version):
+		return zero, &SyntaxError{Offset: tokenOffset(src, starts, version.Span.Start), Err: err}
+	case errors.As(err, &feature):
+		return zero, &SyntaxError{Offset: tokenOffset(src, starts, feature.Span.Start), Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return zero, &SyntaxError{Offset: len(src), Err: err}
+	}
+
+	return res, err
+}
+
+// The offset of the token at an index, or of the end of the text if there is no token there.
+func tokenOffset(src []byte, starts []int, index int) int {
+	if index < len(starts) {
+		return starts[index]
+	}
+	return len(src)
+}
+
+// Give each token the trivia around it, given where the tokens begin and end.
+func attachTrivia[T any](attach func(tok T, leading, trailing Trivia) T, src []byte, toks []T, starts, ends []int) {
+	leading := Trivia{Offset: 0}
+	for i := range toks {
+		leading.Text = string(src[leading.Offset:starts[i]])
+
+		// the trailing trivia runs to the end of the line, or of the text after the last token
+		next := len(src)
+		if i+1 < len(toks) {
+			next = starts[i+1]
+			if n := bytes.IndexByte(src[ends[i]:next], '\n'); n != -1 {
+				next = ends[i] + n + 1
+			}
+		}
+		trailing := Trivia{Offset: ends[i], Text: string(src[ends[i]:next])}
+
+		toks[i] = attach(toks[i], leading, trailing)
+		leading = Trivia{Offset: next}
+	}
+}
@@ -0,0 +1,78 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type triviaWord struct {
+	text              string
+	leading, trailing tp.Trivia
+}
+
+type triviaGrammar struct{}
+
+type triviaWords struct {
+	words []triviaWord
+}
+
+func (triviaGrammar) Parse(x triviaWords) (triviaWords, error) {
+	return x, nil
+}
+
+func (triviaGrammar) Words(words []triviaWord) triviaWords {
+	return triviaWords{words}
+}
+
+func TestLanguageTrivia(t *testing.T) {
+	lang := &tp.Language[triviaWord, triviaWords, triviaWords]{
+		Lexer: must(tp.NewLexer(
+			tp.Skip[triviaWord](`[ \n]+`),
+			tp.Categorize("comment", tp.Regex(`#[^\n]*`, func(start int, text string) (triviaWord, error) {
+				return triviaWord{}, nil
+			})),
+			tp.Regex(`\w+`, func(start int, text string) (triviaWord, error) {
+				return triviaWord{text: text}, nil
+			}),
+		)),
+		Grammar: triviaGrammar{},
+		Skip:    []string{"comment"},
+		Trivia: func(tok triviaWord, leading, trailing tp.Trivia) triviaWord {
+			tok.leading, tok.trailing = leading, trailing
+			return tok
+		},
+	}
+
+	x, err := lang.Parse([]byte("# doc\none two # end\n\n  three four \n"))
+	assert.Nil(t, err)
+	assert.Equal(t, x.words, []triviaWord{
+		{
+			text:     "one",
+			leading:  tp.Trivia{Offset: 0, Text: "# doc\n"},
+			trailing: tp.Trivia{Offset: 9, Text: " "},
+		},
+		{
+			text:     "two",
+			leading:  tp.Trivia{Offset: 10, Text: ""},
+			trailing: tp.Trivia{Offset: 13, Text: " # end\n"},
+		},
+		{
+			text:     "three",
+			leading:  tp.Trivia{Offset: 20, Text: "\n  "},
+			trailing: tp.Trivia{Offset: 28, Text: " "},
+		},
+		{
+			text:     "four",
+			leading:  tp.Trivia{Offset: 29, Text: ""},
+			trailing: tp.Trivia{Offset: 33, Text: " \n"},
+		},
+	})
+
+	// without the option, tokens are parsed as they are
+	lang.Trivia = nil
+	x, err = lang.Parse([]byte("one # two"))
+	assert.Nil(t, err)
+	assert.Equal(t, x.words, []triviaWord{{text: "one"}})
+}
@@ -0,0 +1,71 @@
+package tp
+
+import "reflect"
+
+// Positioned is implemented by tokens that know where they were found in the text. Layout
+// constraints, such as SameLine, use it to find out where the tokens of a rule are.
+type Positioned interface {
+	Pos() Position
+}
+
+// SameLine restricts the rule that it appears in to matching tokens that are all on the same line.
+// It matches no tokens itself, and is given to the rule as the zero value.
+//
+// Tokens that do not implement Positioned are not constrained.
+type SameLine struct{}
+
+// Indented restricts the rule that it appears in to matching tokens that are either on the same
+// line as the rule's first token, or further to the right than it. This is the offside rule of
+// languages like Haskell, where a block continues for as long as its lines are indented. It matches
+// no tokens itself, and is given to the rule as the zero value.
+//
+// Tokens that do not implement Positioned are not constrained.
+type Indented struct{}
+
+type layout interface {
+	allows(first, next Position) bool
+}
+
+var layoutType = reflect.TypeFor[layout]()
+
+func (SameLine) allows(first, next Position) bool {
+	return next.Line == first.Line
+}
+
+func (Indented) allows(first, next Position) bool {
+	return next.Line == first.Line || next.Column > first.Column
+}
+
+// Check the tokens that a rule matches against the rule's layout constraints.
+func (b *builder) layoutAllows(r *rule, at, end int) bool {
+	if len(r.Layout) == 0 || at == end {
+		return true
+	}
+	first, ok := b.seen[at].Interface().(Positioned)
+	if !ok {
+		return true
+	}
+	for _, t := range b.seen[at+1 : end] {
+		next, ok := t.Interface().(Positioned)
+		if !ok {
+			continue
+		}
+		for _, l := range r.Layout {
+			if !l.allows(first.Pos(), next.Pos()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (b *builder) layoutSpan(deps []*symbol, at, end int) ([]span, bool) {
+	next, ok := b.findSpanChildren(deps[1:], at, end)
+	if !ok {
+		return nil, false
+	}
+	return append([]span{{
+		value: reflect.Zero(deps[0].Type),
+		at:    at,
+	}}, next...), true
+}
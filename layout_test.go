@@ -0,0 +1,85 @@
+package tp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type wordTok struct {
+	text string
+	pos  tp.Position
+}
+
+func (w wordTok) Pos() tp.Position {
+	return w.pos
+}
+
+// Split a text into words, recording where each one is.
+func words(src string) []wordTok {
+	var res []wordTok
+	for i, line := range strings.Split(src, "\n") {
+		col := 1
+		for _, f := range strings.Split(line, " ") {
+			if f != "" {
+				res = append(res, wordTok{f, tp.Position{Line: i + 1, Column: col}})
+			}
+			col += len(f) + 1
+		}
+	}
+	return res
+}
+
+type layoutStmt struct {
+	head string
+	args []string
+}
+
+func wordTexts(ws []wordTok) []string {
+	var res []string
+	for _, w := range ws {
+		res = append(res, w.text)
+	}
+	return res
+}
+
+type lineGrammar struct{}
+
+func (lineGrammar) Parse(x []layoutStmt) ([]layoutStmt, error) {
+	return x, nil
+}
+
+func (lineGrammar) Stmt(head wordTok, args []wordTok, _ tp.SameLine) layoutStmt {
+	return layoutStmt{head.text, wordTexts(args)}
+}
+
+type blockGrammar struct{}
+
+func (blockGrammar) Parse(x []layoutStmt) ([]layoutStmt, error) {
+	return x, nil
+}
+
+func (blockGrammar) Block(head wordTok, body []wordTok, _ tp.Indented) layoutStmt {
+	return layoutStmt{head.text, wordTexts(body)}
+}
+
+func TestSameLine(t *testing.T) {
+	out, err := tp.Parse(lineGrammar{}, words("a b c\nd e\nf"))
+	assert.Nil(t, err)
+	assert.Equal(t, out, []layoutStmt{
+		{"a", []string{"b", "c"}},
+		{"d", []string{"e"}},
+		{"f", nil},
+	})
+}
+
+func TestIndented(t *testing.T) {
+	out, err := tp.Parse(blockGrammar{}, words("a b\n  c\n d\ne\n f"))
+	assert.Nil(t, err)
+	assert.Equal(t, out, []layoutStmt{
+		{"a", []string{"b", "c", "d"}},
+		{"e", []string{"f"}},
+	})
+}
@@ -0,0 +1,117 @@
+package tp
+
+import (
+	"iter"
+	"math"
+	"slices"
+)
+
+// A MaxLen for symbols that can match any number of tokens.
+const unbounded = math.MaxInt
+
+// Find the least and greatest number of tokens that each symbol can match. The builder uses these to
+// avoid trying matches for part of a rule that leave the wrong number of tokens for the rest of it,
+// which would otherwise make building a list quadratic in its length.
+//
+// A placeholder can stand for any symbol, so any symbol can match a single token, and only empty
+// symbols can match fewer.
+func (s *scanner) markLengths() {
+	for _, sym := range s.types {
+		switch {
+		case sym.TokenType != nil:
+			sym.MinLen, sym.MaxLen = 1, 1
+		case sym.NotFollowedBy != nil || sym.Layout != nil:
+			sym.MinLen, sym.MaxLen = 0, 0
+		case sym.Nullable || sym.Conditional:
+			sym.MinLen, sym.MaxLen = 0, 1
+		default:
+			sym.MinLen, sym.MaxLen = 1, 1
+		}
+		for _, r := range sym.Predictions {
+			if !slices.Contains(sym.Indexes, r.Index) {
+				sym.Indexes = append(sym.Indexes, r.Index)
+			}
+		}
+		slices.Sort(sym.Indexes)
+	}
+
+	// the greatest lengths only go up, so repeat until they settle, or until it is clear that they
+	// never will because the symbol contains itself
+	for round := 0; ; round++ {
+		changed := false
+		for _, sym := range s.types {
+			for _, r := range sym.Predictions {
+				_, maxLen := seqLength(r.Deps)
+				if maxLen <= sym.MaxLen {
+					continue
+				}
+				sym.MaxLen = maxLen
+				if round > len(s.types) {
+					sym.MaxLen = unbounded
+				}
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+}
+
+// The least and greatest number of tokens that a sequence of symbols can match.
+func seqLength(deps []*symbol) (int, int) {
+	minLen, maxLen := 0, 0
+	for _, d := range deps {
+		minLen += d.MinLen
+		if maxLen == unbounded || d.MaxLen == unbounded {
+			maxLen = unbounded
+		} else {
+			maxLen += d.MaxLen
+		}
+	}
+	return minLen, maxLen
+}
+
+// The completed items for a symbol that begin at a position and end within a range, in order of
+// preference.
+func (b *builder) completedWithin(at int, sym *symbol, lo, hi int) iter.Seq[item] {
+	return func(yield func(item) bool) {
+		set := b.completed(at, sym)
+		for _, index := range sym.Indexes {
+			i, _ := slices.BinarySearchFunc(set, lo, func(x item, lo int) int {
+				if x.rule.Index != index {
+					return x.rule.Index - index
+				}
+				return x.position - lo
+			})
+			if sym.Greedy {
+				// the items that end within the range, from the last
+				j := i
+				for j < len(set) && set[j].rule.Index == index && set[j].position <= hi {
+					j++
+				}
+				for j--; j >= i; j-- {
+					if !yield(set[j]) {
+						return
+					}
+				}
+				continue
+			}
+			for ; i < len(set) && set[i].rule.Index == index && set[i].position <= hi; i++ {
+				if !yield(set[i]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// The positions that the first of a sequence of symbols can end at, if the sequence ends at end.
+func endRange(rest []*symbol, at, end int) (int, int) {
+	minLen, maxLen := seqLength(rest)
+	lo := at
+	if maxLen != unbounded {
+		lo = max(lo, end-maxLen)
+	}
+	return lo, end - minLen
+}
@@ -0,0 +1,153 @@
+package tp
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+// Right recursion, as in List(x Item, xs List), is slow to match in the usual way. When the last
+// List is completed, so is every List that contains it, one after another, each adding an item to
+// the state set. A list of n items adds n items at its end, and as each of its items may be the end
+// of a list, this is quadratic in the length of the list.
+//
+// Joop Leo's improvement to Earley's algorithm avoids this. Where a state set has only one item that
+// awaits a symbol, and that symbol is the last of the item's rule, then completing the symbol can
+// only complete the item. When such items form a chain, only the one at the top of it is added to
+// the state set. See https://doi.org/10.1016/0304-3975(91)90180-A for more information.
+//
+// Here, chains are only followed through rules that end with the symbol that they produce, which is
+// where long chains come from. Following shorter chains saves little, and makes more work for the
+// builder.
+//
+// The builder needs the items in the middle of a chain, so the matcher records the links that they
+// were skipped over, and the builder follows these to find them again.
+
+type leoKey struct {
+	at  int
+	sym *symbol
+}
+
+type leoResult struct {
+	top item
+	ok  bool
+}
+
+// A skipped item: the item's symbol, beginning where the key says, was completed by the rule when the
+// symbol awaited by it was completed from the given position.
+type leoLink struct {
+	rule   *rule
+	from   int
+	awaits *symbol
+}
+
+// Find the item at the top of the chain that completing a symbol, which began at a position, leads
+// to. The position must be before the current one, so that its state set is finished.
+func (p *matcher) leoItem(at int, sym *symbol) (item, bool) {
+	key := leoKey{at, sym}
+	if r, ok := p.leoMemo[key]; ok {
+		return r.top, r.ok
+	}
+
+	// this is the answer while the chain is being followed, in case it loops
+	p.leoMemo[key] = leoResult{}
+
+	var found item
+	count := 0
+	if awaiting, ok := p.indexedAwaiting(at, sym); ok {
+		count = len(awaiting)
+		if count != 0 {
+			found = awaiting[0]
+		}
+	} else {
+		for _, y := range p.state[at] {
+			p.work++
+			if next, ok := y.nextSymbol(); ok && next == sym {
+				found = y
+				count++
+			}
+		}
+	}
+	if count != 1 || found.progress+1 != len(found.rule.Deps) || found.rule.Implements != sym {
+		return item{}, false
+	}
+
+	top := found.makeProgress()
+	implements := found.rule.Implements
+	p.leoLinks[leoKey{top.position, implements}] = append(p.leoLinks[leoKey{top.position, implements}], leoLink{
+		rule:   found.rule,
+		from:   at,
+		awaits: sym,
+	})
+
+	// the root must be completed at the start of the input for the match to be seen
+	if top.position != 0 || implements != p.root {
+		if higher, ok := p.leoItem(top.position, implements); ok {
+			top = higher
+		}
+	}
+
+	p.leoMemo[key] = leoResult{top: top, ok: true}
+	return top, true
+}
+
+// The completed items for a symbol that begin at a position and end within a range, whether they are
+// in the state or were skipped over, in order of preference.
+func (b *builder) candidates(at int, sym *symbol, lo, hi int) iter.Seq[item] {
+	links := b.leoLinks[leoKey{at, sym}]
+	if len(links) == 0 {
+		return b.completedWithin(at, sym, lo, hi)
+	}
+	return func(yield func(item) bool) {
+		res := slices.Collect(b.completedWithin(at, sym, lo, hi))
+		for _, l := range links {
+			for _, end := range b.ends(l.from, l.awaits, lo, hi) {
+				res = append(res, item{
+					rule:     l.rule,
+					position: end,
+					progress: len(l.rule.Deps),
+				})
+			}
+		}
+		slices.SortStableFunc(res, func(a, b item) int {
+			if a.rule.Index != b.rule.Index {
+				return cmp.Compare(a.rule.Index, b.rule.Index)
+			}
+			if sym.Greedy {
+				return cmp.Compare(b.position, a.position)
+			}
+			return cmp.Compare(a.position, b.position)
+		})
+		for _, x := range res {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// The positions within a range that a symbol, beginning at a position, can end at.
+func (b *builder) ends(at int, sym *symbol, lo, hi int) []int {
+	key := endsKey{at, sym, lo, hi}
+	if res, ok := b.endsMemo[key]; ok {
+		return res
+	}
+
+	// this is the answer while the links are being followed, in case they loop
+	b.endsMemo[key] = nil
+
+	var res []int
+	for x := range b.candidates(at, sym, lo, hi) {
+		if !slices.Contains(res, x.position) {
+			res = append(res, x.position)
+		}
+	}
+	b.endsMemo[key] = res
+	return res
+}
+
+type endsKey struct {
+	at     int
+	sym    *symbol
+	lo, hi int
+}
@@ -1,7 +1,15 @@
 package tp
 
 import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"unicode"
 	"unicode/utf8"
+	"unsafe"
 )
 
 type LexerState int
@@ -10,23 +18,168 @@ type LexerState int
 //
 // It maintains a description of a state machine where movement between states is driven by reading
 // an input text.
+//
+// Tokens are matched by running the machine from the current position until it can make no further
+// progress. The token is then decided as follows:
+//
+//   - The longest text that left the machine in a final state is matched. The machine does not
+//     backtrack into a shorter match to allow a later token to succeed.
+//   - If that text left the machine in more than one final state, the one with the highest priority
+//     is used, and of those the one that was declared first. Final states have a priority of zero
+//     unless they are added by a spec given to Prioritize. For lexers built with NewLexer, this
+//     means that among specs of the same priority the spec that appears first wins.
+//   - Empty text is never matched.
+//
+// Once a Lexer has been built it is only read from, so a single Lexer may be used by any number of
+// streams running concurrently in different goroutines. It must not be modified while it is in use;
+// Freeze can be used to guarantee this.
 type Lexer[T any] struct {
 	closeTransitions []closeTransition
 	moveTransitions  []moveTransition
 	finalStates      []finalState[T]
+	enterActions     []enterAction
+	rules            []lexerRule
 	maxState         LexerState
+
+	// patterns defined by Fragment, by name
+	fragments map[string]expr
+
+	// character classes defined by EscapeClass, by the letter that follows the backslash
+	escapes map[rune]charset
+
+	// states that matching also begins in at the start of a line and of the text, or 0 if there are
+	// none, see LineStart and TextStart
+	lineStart, textStart LexerState
+
+	// set once the lexer is frozen, along with the position in the sorted transition tables that
+	// each state's transitions begin at
+	frozen                bool
+	closeIndex, moveIndex []int
+
+	// set once the lexer is compiled, see Compile, along with the states of dfa that matching
+	// begins in for each anchor
+	dfa      []dfaState
+	dfaStart [anchors]int
 }
 
 type TokenSpec[T any] func(l *Lexer[T]) error
 
+// Label the tokens produced by a spec with a category, such as "trivia" or "keyword". The category
+// of each token is available from the stream alongside the token itself, which allows generic
+// processing of tokens without knowing their concrete types.
+func Categorize[T any](category string, spec TokenSpec[T]) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		from := len(l.finalStates)
+		if err := spec(l); err != nil {
+			return err
+		}
+		for i := from; i < len(l.finalStates); i++ {
+			l.finalStates[i].Category = category
+		}
+		return nil
+	}
+}
+
+// ErrTokenSpec reports a token spec that could not be added to a lexer. The spec is identified by
+// its position in the arguments to NewLexer and, if it has one, its pattern.
+type ErrTokenSpec struct {
+	Index   int
+	Pattern string
+	Err     error
+}
+
+func (e *ErrTokenSpec) Error() string {
+	return fmt.Sprintf("%s: %s", lexerRule{Index: e.Index, Pattern: e.Pattern}, e.Err)
+}
+
+func (e *ErrTokenSpec) Unwrap() error {
+	return e.Err
+}
+
+// ErrNoMatch is the error of a stream that stopped because no token matches the text at its
+// position. It wraps ErrFailedMatch.
+type ErrNoMatch struct {
+	// The byte offset that the token would have begun at, and the offset where the machine found it
+	// could go no further. These differ where the start of some token was matched but not the rest
+	// of it, e.g. a string literal that is not closed.
+	Start, Offset int
+
+	// The rune at Offset, or -1 if the text ended there.
+	Rune rune
+
+	// The states that the machine was in at Offset.
+	States []LexerState
+}
+
+func (e *ErrNoMatch) Error() string {
+	return EnglishMessages{}.NoMatch(e)
+}
+
+func (e *ErrNoMatch) Unwrap() error {
+	return ErrFailedMatch
+}
+
+// Give the tokens produced by a spec a priority, so that where the same text is matched by this and
+// another spec of lower priority, this one produces the token whatever order the specs are given in.
+// Specs that are not given a priority have a priority of zero, and specs with the same priority are
+// decided by the order that they are given in.
+//
+// So, e.g. a lexer can give keywords priority over identifiers with
+//
+//	tp.Regex(`[a-z]+`, identifier),
+//	tp.Prioritize(1, tp.Regex(`if|else`, keyword)),
+//
+// Keywords is another way to do this, which does not need states for each keyword.
+func Prioritize[T any](priority int, spec TokenSpec[T]) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		from := len(l.finalStates)
+		if err := spec(l); err != nil {
+			return err
+		}
+		for i := from; i < len(l.finalStates); i++ {
+			l.finalStates[i].Priority = priority
+		}
+		return nil
+	}
+}
+
+// Build a lexer from token specs, in order. When a spec cannot be added, the ones after it are still
+// tried, so that every mistake in a lexicon is reported at once. The error is an ErrTokenSpec if only
+// one spec failed, otherwise it joins the ErrTokenSpec of each spec that failed. A spec that refers
+// to one that failed, such as through a fragment, may fail as a result.
 func NewLexer[T any](tokens ...TokenSpec[T]) (*Lexer[T], error) {
 	l := new(Lexer[T])
-	for _, s := range tokens {
+	var errs []error
+	for i, s := range tokens {
+		l.rules = append(l.rules, lexerRule{Index: i})
 		if err := s(l); err != nil {
-			return nil, err
+			errs = append(errs, &ErrTokenSpec{
+				Index:   i,
+				Pattern: l.rules[i].Pattern,
+				Err:     err,
+			})
 		}
 	}
-	return l, nil
+	switch len(errs) {
+	case 0:
+		return l, nil
+	case 1:
+		return nil, errs[0]
+	}
+	return nil, errors.Join(errs...)
+}
+
+// The token spec that a part of the machine was created by.
+type lexerRule struct {
+	Index   int
+	Pattern string
+}
+
+func (r lexerRule) String() string {
+	if r.Pattern == "" {
+		return fmt.Sprintf("rule %d", r.Index)
+	}
+	return fmt.Sprintf("rule %d: `%s`", r.Index, r.Pattern)
 }
 
 type closeTransition struct {
@@ -38,24 +191,152 @@ type moveTransition struct {
 	Min, Max    rune
 }
 
-type finalState[T any] struct {
+type enterAction struct {
 	Given LexerState
-	Then  TokenConstructor[T]
+	Do    func(start, pos int)
+}
+
+type finalState[T any] struct {
+	Given     LexerState
+	Rule      int
+	Category  string
+	Then      TokenConstructor[T]
+	ThenMatch MatchConstructor[T]
+	ThenBytes BytesConstructor[T]
+
+	// set if the text is skipped rather than yielding a token
+	Skip bool
+
+	// final states of higher priority are preferred, see Prioritize
+	Priority int
 }
 
 type TokenConstructor[T any] func(start int, text string) (T, error)
 
+// A token constructor that is given a full description of the matched text, including the lines and
+// columns that it begins and ends at.
+type MatchConstructor[T any] func(m Match) (T, error)
+
+// A token constructor that is given a view of the matched text, rather than a copy of it. This
+// avoids allocating a string for tokens that do not need one, such as punctuation, or that convert
+// the text into something else, such as numbers.
+//
+// The view is only valid until the stream is advanced, so a constructor that keeps the text must
+// copy it first, e.g. with Copy. For a stream made by TokenizeString, the view is part of the
+// string, so it must not be modified.
+type BytesConstructor[T any] func(start int, text []byte) (T, error)
+
+// Copy text given to a BytesConstructor, so that it can be kept.
+func Copy(text []byte) []byte {
+	return bytes.Clone(text)
+}
+
+// Use a TokenConstructor where a BytesConstructor is expected. The text is converted to a string for
+// every token, as it would be had the constructor been given to Final.
+func FromText[T any](then TokenConstructor[T]) BytesConstructor[T] {
+	return func(start int, text []byte) (T, error) {
+		return then(start, string(text))
+	}
+}
+
+// Match describes a piece of text that the lexer has matched.
+type Match struct {
+	// Byte offsets of the beginning and end of the text within the source.
+	Start, End int
+
+	// the text, or as much of it as is held, and the offset that it begins at
+	src        []byte
+	base       int
+	str        string
+	lines      *LineIndex
+	interner   *Interner
+	normalizer Normalizer
+
+	// the text that was matched, if the source was normalized before it was matched
+	text []byte
+}
+
+// The line and column that the matched text begins at.
+func (m Match) Position() Position {
+	return m.lines.Position(m.Start)
+}
+
+// The line and column just after the matched text, which is where the next token begins.
+func (m Match) EndPosition() Position {
+	return m.lines.Position(m.End)
+}
+
+// The matched text.
+func (m Match) Text() string {
+	if m.Start < 0 {
+		return ""
+	}
+	text := m.text
+	if text == nil {
+		text = m.src[m.Start-m.base : m.End-m.base]
+	}
+	if m.normalizer != nil {
+		text = m.normalizer.Bytes(text)
+	}
+	if m.interner != nil {
+		return m.interner.Intern(text)
+	}
+	if m.normalizer == nil && m.str != "" {
+		return m.str[m.Start:m.End]
+	}
+	return string(text)
+}
+
+// As Text, but the text is not copied. The result is only valid until the stream is advanced, and for
+// a stream made by TokenizeString it must not be modified.
+func (m Match) Bytes() []byte {
+	if m.Start < 0 {
+		return nil
+	}
+	text := m.text
+	if text == nil {
+		text = m.src[m.Start-m.base : m.End-m.base]
+	}
+	if m.normalizer != nil {
+		text = m.normalizer.Bytes(text)
+	}
+	return text
+}
+
+// The text that was being tokenized. For a stream that reads from an io.Reader, this is only the
+// part of the text that is held in memory, which includes the matched text. For a stream made by
+// TokenizeString, it must not be modified.
+func (m Match) Source() []byte {
+	return m.src
+}
+
 type Stream[T any] struct {
 	prog       *Lexer[T]
 	src        []byte
+	base       int
+	str        string
+	in         *readerCursor
 	srcPos     int
+	tokPos     int
+	stopped    int
 	this, next []bool
 	tok        T
+	category   string
 	err        error
+	interner   *Interner
+	normalizer Normalizer
+	lines      LineIndex
+
+	// the original text, if it was normalized before it was matched
+	norm *normalizedText
+
+	// tokens matched by Peek, each held as the stream would be once Next had matched it
+	peeked []Stream[T]
 }
 
 // Create a new state in the state machine.
 func (p *Lexer[T]) State() LexerState {
+	p.checkMutable()
 	p.maxState++
 	return p.maxState
 }
@@ -69,6 +350,7 @@ func (p *Lexer[T]) Rune(from, to LexerState, r rune) {
 // Given two states to move between, declare that encountering any rune in the specified range
 // (inclusive) when in the from state will cause the machine to enter the to state.
 func (p *Lexer[T]) Range(from, to LexerState, min, max rune) {
+	p.checkMutable()
 	p.moveTransitions = append(p.moveTransitions, moveTransition{
 		Given: from,
 		Then:  to,
@@ -77,9 +359,34 @@ func (p *Lexer[T]) Range(from, to LexerState, min, max rune) {
 	})
 }
 
+// Given two states to move between, declare that encountering any rune in the table when in the
+// from state will cause the machine to enter the to state, so that e.g. unicode.Letter can be used
+// without listing its ranges. The tables of the unicode package have hundreds of ranges, each of
+// which is a transition, so a lexer that uses them will run faster once compiled. See Compile.
+func (p *Lexer[T]) RangeTable(from, to LexerState, table *unicode.RangeTable) {
+	for _, r := range table.R16 {
+		p.tableRange(from, to, rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	for _, r := range table.R32 {
+		p.tableRange(from, to, rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+}
+
+// Add the transitions for a range of a table, where only every stride-th rune is in the range.
+func (p *Lexer[T]) tableRange(from, to LexerState, lo, hi, stride rune) {
+	if stride == 1 {
+		p.Range(from, to, lo, hi)
+		return
+	}
+	for r := lo; r <= hi; r += stride {
+		p.Rune(from, to, r)
+	}
+}
+
 // Create an empty transition, which is to say that entering the from state will cause the machine
 // to immediately enter the to state as well.
 func (p *Lexer[T]) Empty(from, to LexerState) {
+	p.checkMutable()
 	var pending []closeTransition
 	for _, t := range p.closeTransitions {
 		// avoid adding duplicates
@@ -110,15 +417,208 @@ func (p *Lexer[T]) Empty(from, to LexerState) {
 }
 
 // Indicate that a particular state is a final state, and attach a token constructor to it that will
-// be invoked if the machine terminates in that state. The behaviour is undefined if the machine
-// terminates in two final states, so be careful not to allow that to happen.
+// be invoked if the machine terminates in that state. If the machine terminates in two final states
+// of the same priority, the one declared first is used.
 func (p *Lexer[T]) Final(given LexerState, then TokenConstructor[T]) {
+	p.checkMutable()
 	p.finalStates = append(p.finalStates, finalState[T]{
 		Given: given,
+		Rule:  len(p.rules) - 1,
 		Then:  then,
 	})
 }
 
+// As Final, but the token constructor is given a description of the match.
+func (p *Lexer[T]) FinalMatch(given LexerState, then MatchConstructor[T]) {
+	p.checkMutable()
+	p.finalStates = append(p.finalStates, finalState[T]{
+		Given:     given,
+		Rule:      len(p.rules) - 1,
+		ThenMatch: then,
+	})
+}
+
+// As Final, but the token constructor is given a view of the text. See BytesConstructor.
+func (p *Lexer[T]) FinalBytes(given LexerState, then BytesConstructor[T]) {
+	p.checkMutable()
+	p.finalStates = append(p.finalStates, finalState[T]{
+		Given:     given,
+		Rule:      len(p.rules) - 1,
+		ThenBytes: then,
+	})
+}
+
+// As Final, but the matched text is skipped over rather than yielding a token, e.g. for whitespace
+// and comments.
+func (p *Lexer[T]) FinalSkip(given LexerState) {
+	p.checkMutable()
+	p.finalStates = append(p.finalStates, finalState[T]{
+		Given: given,
+		Rule:  len(p.rules) - 1,
+		Skip:  true,
+	})
+}
+
+// Attach an action to a state that will be invoked whenever the machine is in that state while
+// matching a token. The action is given the offset that the token began at and the offset that the
+// machine has reached. This allows measurements, such as the depth of indentation at the start of a
+// line, to be taken while matching rather than by inspecting the text afterwards.
+//
+// The machine looks for the longest match by following every path at once, so an action may be
+// invoked for paths that do not lead to the token that is eventually matched, or at offsets beyond
+// its end. It is invoked at most once per offset, in increasing order of offset, and always before
+// the token constructor is called.
+//
+// Actions are run by whichever stream is executing the machine, so if streams are used concurrently
+// then any state that an action updates must be per-stream or protected against concurrent access.
+func (p *Lexer[T]) Enter(given LexerState, action func(start, pos int)) {
+	p.checkMutable()
+	p.enterActions = append(p.enterActions, enterAction{
+		Given: given,
+		Do:    action,
+	})
+}
+
+// Add the tokens of another lexer to this one, as though the specs that built it had been given
+// after those that built this one, so that lexers built separately, such as one for the tokens that
+// several languages share and one for the tokens of a particular language, can be combined. The
+// states of the other lexer are renumbered to follow this one's, apart from the state that matching
+// begins in, which they share. Where both lexers match the same text, this one's token is preferred
+// unless the other's has a higher priority.
+//
+// Fragments and escape classes of the other lexer are added too, unless this lexer defines its own
+// by the same name. The other lexer is not changed, and it may be frozen, but this one may not, so
+// a lexer cannot be merged into once it has been frozen or compiled.
+func (p *Lexer[T]) Merge(other *Lexer[T]) {
+	p.checkMutable()
+
+	// take what is needed before anything is added, in case the lexers are the same
+	offset := p.maxState
+	rules := len(p.rules)
+	closes := slices.Clone(other.closeTransitions)
+	moves := slices.Clone(other.moveTransitions)
+	finals := slices.Clone(other.finalStates)
+	actions := slices.Clone(other.enterActions)
+	otherRules := slices.Clone(other.rules)
+	maxState := other.maxState
+	lineStart, textStart := other.lineStart, other.textStart
+
+	state := func(s LexerState) LexerState {
+		switch {
+		case s == 0:
+			return 0
+		case s == lineStart:
+			return p.LineStart()
+		case s == textStart:
+			return p.TextStart()
+		}
+		return s + offset
+	}
+
+	p.maxState += maxState
+	for _, t := range closes {
+		p.Empty(state(t.Given), state(t.Then))
+	}
+	for _, t := range moves {
+		p.Range(state(t.Given), state(t.Then), t.Min, t.Max)
+	}
+	for _, f := range finals {
+		f.Given = state(f.Given)
+		if f.Rule >= 0 {
+			f.Rule += rules
+		}
+		p.finalStates = append(p.finalStates, f)
+	}
+	for _, a := range actions {
+		p.Enter(state(a.Given), a.Do)
+	}
+	for _, r := range otherRules {
+		r.Index += rules
+		p.rules = append(p.rules, r)
+	}
+
+	for name, e := range other.fragments {
+		if _, ok := p.fragments[name]; ok {
+			continue
+		}
+		if p.fragments == nil {
+			p.fragments = map[string]expr{}
+		}
+		p.fragments[name] = e
+	}
+	for letter, c := range other.escapes {
+		if _, ok := p.escapes[letter]; ok {
+			continue
+		}
+		if p.escapes == nil {
+			p.escapes = map[rune]charset{}
+		}
+		p.escapes[letter] = c
+	}
+}
+
+// Prevent any further changes to the machine, and prepare it for faster execution. Any attempt to
+// modify the machine after it has been frozen will panic.
+//
+// Freezing rearranges the machine's transitions in place, so it must not be done while streams are
+// running on the machine. Freeze the machine before it is used.
+func (p *Lexer[T]) Freeze() {
+	if p.frozen {
+		return
+	}
+
+	slices.SortFunc(p.closeTransitions, func(a, b closeTransition) int {
+		return cmp.Or(cmp.Compare(a.Given, b.Given), cmp.Compare(a.Then, b.Then))
+	})
+	p.closeTransitions = slices.Compact(p.closeTransitions)
+
+	slices.SortFunc(p.moveTransitions, func(a, b moveTransition) int {
+		return cmp.Or(
+			cmp.Compare(a.Given, b.Given),
+			cmp.Compare(a.Min, b.Min),
+			cmp.Compare(a.Max, b.Max),
+			cmp.Compare(a.Then, b.Then),
+		)
+	})
+	p.moveTransitions = slices.Compact(p.moveTransitions)
+
+	p.closeIndex = transitionIndex(p.closeTransitions, p.maxState, func(t closeTransition) LexerState {
+		return t.Given
+	})
+	p.moveIndex = transitionIndex(p.moveTransitions, p.maxState, func(t moveTransition) LexerState {
+		return t.Given
+	})
+
+	p.frozen = true
+}
+
+// Given transitions sorted by the state they begin from, find where each state's transitions are.
+// The transitions for state s are at [index[s], index[s+1]).
+func transitionIndex[X any](ts []X, maxState LexerState, given func(X) LexerState) []int {
+	index := make([]int, maxState+2)
+	for _, t := range ts {
+		index[given(t)+1]++
+	}
+	for i := 1; i < len(index); i++ {
+		index[i] += index[i-1]
+	}
+	return index
+}
+
+func (p *Lexer[T]) checkMutable() {
+	if p.frozen {
+		panic("tp: modifying a frozen lexer")
+	}
+}
+
+// Record the pattern of the token spec currently being added, for use in diagnostics.
+func (p *Lexer[T]) describeRule(pattern string) {
+	if len(p.rules) == 0 {
+		return
+	}
+	p.rules[len(p.rules)-1].Pattern = pattern
+}
+
 // Begin executing the described machine against a particular piece of text.
 func (p *Lexer[T]) Tokenize(src []byte) *Stream[T] {
 	return &Stream[T]{
@@ -129,6 +629,42 @@ func (p *Lexer[T]) Tokenize(src []byte) *Stream[T] {
 	}
 }
 
+// As Tokenize, but for text held in a string. The string is not copied, and the text given to token
+// constructors is part of the string rather than a copy of it, unless the stream has an Interner or
+// a Normalizer, so tokens that keep their text do not allocate for it. BytesConstructors are given
+// views of the string's memory, which must not be modified.
+func (p *Lexer[T]) TokenizeString(src string) *Stream[T] {
+	s := p.Tokenize(unsafe.Slice(unsafe.StringData(src), len(src)))
+	s.str = src
+	return s
+}
+
+// Match exactly one token at the start of the text, returning it along with the number of bytes
+// that it consumed. If no token matches then the error is an ErrNoMatch, or ErrFailedMatch if the
+// text is empty.
+//
+// Text that would be skipped, such as by Skip, is not passed over. If the longest match at the start
+// of the text is skipped then there is no token, and the error is ErrFailedMatch along with the
+// length of the skipped text.
+func (p *Lexer[T]) Match(src []byte) (T, int, error) {
+	var zero T
+	l := p.Tokenize(src)
+	final, end, ok := l.longest(0)
+	if !ok {
+		if l.err != nil {
+			return zero, 0, l.err
+		}
+		return zero, 0, ErrFailedMatch
+	}
+	if p.finalStates[final].Skip {
+		return zero, end, ErrFailedMatch
+	}
+	if !l.emit(final, 0, end) {
+		return zero, 0, l.err
+	}
+	return l.tok, end, nil
+}
+
 // Execute the machine until there are no more tokens and collect the tokens into a slice.
 func (l *Stream[T]) Force() ([]T, error) {
 	var res []T
@@ -138,61 +674,297 @@ func (l *Stream[T]) Force() ([]T, error) {
 	return res, l.Err()
 }
 
-// The error state of the execution. Once entered, the error state is permanent.
+// Iterate over the tokens of the stream, as with Next and This. If the machine fails then the error
+// is yielded last, with the zero token.
+func (l *Stream[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for l.Next() {
+			if !yield(l.This(), nil) {
+				return
+			}
+		}
+		if err := l.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// The error state of the execution. Once entered, the error state is permanent, unless the stream is
+// returned to an earlier position with Reset.
 func (l *Stream[T]) Err() error {
 	return l.err
 }
 
 // Execute the machine against the text and return whether successful.
 func (l *Stream[T]) Next() bool {
+	if len(l.peeked) != 0 {
+		this, next, in, peeked := l.this, l.next, l.in, l.peeked[1:]
+		*l = l.peeked[0]
+		l.this, l.next, l.in, l.peeked = this, next, in, peeked
+		return true
+	}
 	if l.err != nil {
 		return false
 	}
 	return l.exec()
 }
 
+// Create a copy of the stream at its current position. The copy shares the text and the machine
+// with the original, so this is cheap, but it can be advanced independently, which allows reading
+// ahead without disturbing the original.
+//
+// The copy uses the same Interner as the original, so they must be used from the same goroutine if
+// one has been set.
+func (l *Stream[T]) Clone() *Stream[T] {
+	c := *l
+	if l.in != nil {
+		c.in = l.in.cursor(l.srcPos)
+	}
+	c.this = make([]bool, len(l.this))
+	c.next = make([]bool, len(l.next))
+	c.lines.starts = slices.Clip(l.lines.starts)
+	c.peeked = slices.Clone(l.peeked)
+	return &c
+}
+
+// Return the token n places ahead without advancing the stream, so that Peek(1) is the token that
+// the next call to Next will match. Reports false if there are fewer than n tokens left, or if
+// matching them fails, in which case Err reports the failure once Next has reached it.
+//
+// The tokens are matched once, and held until Next reaches them. For a stream that reads from an
+// io.Reader, peeking reads ahead as Next does, so the text of a Match must be taken before peeking.
+// Peeking at fewer than one token ahead panics; the current token is This.
+func (l *Stream[T]) Peek(n int) (T, bool) {
+	if n < 1 {
+		panic("tp: peeking at fewer than one token ahead")
+	}
+	for len(l.peeked) < n {
+		last := l
+		if len(l.peeked) != 0 {
+			last = &l.peeked[len(l.peeked)-1]
+		}
+		c := *last
+		c.this, c.next, c.peeked = l.this, l.next, nil
+		c.lines.starts = slices.Clip(c.lines.starts)
+		if c.err != nil || !c.exec() {
+			var zero T
+			return zero, false
+		}
+		c.lines.starts = slices.Clip(c.lines.starts)
+		l.peeked = append(l.peeked, c)
+	}
+	return l.peeked[n-1].tok, true
+}
+
+// StreamMark is a position in a stream, made by Mark, that the stream can be returned to.
+type StreamMark[T any] struct {
+	s Stream[T]
+}
+
+// Mark the current position of the stream, so that tokens can be read speculatively and the stream
+// returned to this position with Reset if they turn out not to be wanted. This is as cheap as Clone,
+// and for a stream that reads from an io.Reader, it also keeps the text after the mark from being
+// discarded for as long as the mark is kept.
+func (l *Stream[T]) Mark() StreamMark[T] {
+	m := l.Clone()
+	m.this, m.next = nil, nil
+	return StreamMark[T]{s: *m}
+}
+
+// Return the stream to a position marked by Mark, including the token it held and its error state,
+// so that the tokens after the mark will be matched again. The mark must have been made by this
+// stream or by a clone of it, and can be used any number of times.
+func (l *Stream[T]) Reset(m StreamMark[T]) {
+	this, next, in := l.this, l.next, l.in
+	*l = m.s
+	l.this, l.next, l.in = this, next, in
+	if l.in != nil {
+		l.in.pos = l.srcPos
+	}
+	l.lines.starts = slices.Clip(l.lines.starts)
+	l.peeked = slices.Clone(l.peeked)
+}
+
 // Return the last matched token.
 func (l *Stream[T]) This() T {
 	return l.tok
 }
 
+// Return an index of the lines in the text that has been matched so far.
+func (l *Stream[T]) Lines() *LineIndex {
+	return &l.lines
+}
+
+// Return the category of the last matched token, or the empty string if it does not have one.
+func (l *Stream[T]) Category() string {
+	return l.category
+}
+
 func (l *Stream[T]) exec() bool {
-	pos := l.srcPos
-	start := pos
-	end := pos
+	start := l.srcPos
+	if l.in != nil {
+		// a clone may have read more of the text
+		l.src, l.base = l.in.buf, l.in.base
+	}
+
+	for {
+		final, end, ok := l.longest(start)
+		if !ok {
+			return false
+		}
+		if !l.prog.finalStates[final].Skip {
+			return l.emit(final, start, end)
+		}
+		l.scanLines(start, end)
+		l.srcPos = end
+		start = end
+	}
+}
+
+// Find the longest match at a position, returning its final state and where it ends. If there is
+// none then the stream enters the error state, unless the text ends there.
+func (l *Stream[T]) longest(start int) (int, int, bool) {
+	var final, end, stop int
+	if l.prog.dfa != nil {
+		final, end, stop = l.runDFA(start)
+	} else {
+		final, end, stop = l.runNFA(start)
+	}
+	if l.err != nil {
+		return 0, 0, false
+	}
+	if final == -1 {
+		l.noMatch(start, stop)
+		return 0, 0, false
+	}
+	return final, end, true
+}
+
+// Construct the token for a match, making it the stream's current token.
+func (l *Stream[T]) emit(final, start, end int) bool {
+	l.tokPos = start
+
+	// the lines are scanned first so that the constructor can find where the token ends
+	l.scanLines(start, end)
+
+	op := l.prog.finalStates[final]
+	l.category = op.Category
+	m := Match{
+		Start:      start,
+		End:        end,
+		src:        l.src,
+		base:       l.base,
+		str:        l.str,
+		lines:      &l.lines,
+		interner:   l.interner,
+		normalizer: l.normalizer,
+	}
+	if l.norm != nil {
+		m.Start, m.End = l.norm.source(start), l.norm.source(end)
+		m.src, m.text = l.norm.src, l.src[start:end]
+	}
+	l.tok, l.err = op.construct(m)
+	l.srcPos = end
+
+	return l.err == nil
+}
+
+// Run the machine from a position, following every path at once, and return the final state of the
+// longest match along with where it ends, and where the machine stopped. If there is no match then
+// the final state is -1. The states that the machine was in when it stopped are left in l.this.
+func (l *Stream[T]) runNFA(start int) (int, int, int) {
+	pos := start
+	end := start
 	final := -1
 	running := true
-	l.this[0] = true
+	clear(l.this)
+	l.enterStart(start)
 
-	for running {
-		c, n := utf8.DecodeRune(l.src[pos:])
+	for {
 		running = false
 		clear(l.next)
 
 		l.closeState()
-		l.detectFinal(&final, &end, pos)
+		l.enterStates(start, pos)
+		l.detectFinal(&final, &end, start, pos)
 
-		if pos >= len(l.src) {
+		c, n, ok := l.char(start, pos)
+		if !ok {
 			break
 		}
 
 		l.moveState(&running, c)
+		if !running {
+			break
+		}
 
 		l.this, l.next = l.next, l.this
 		pos = pos + n
 	}
 
-	if final == -1 {
-		return false
+	return final, end, pos
+}
+
+// Enter the error state because no token matches the text at start. If the text ends there then
+// this is not an error.
+func (l *Stream[T]) noMatch(start, stop int) {
+	c, _, ok := l.char(start, stop)
+	if l.err != nil || !ok && stop == start {
+		return
+	}
+	if !ok {
+		c = -1
 	}
 
-	l.tok, l.err = l.prog.finalStates[final].Then(start, string(l.src[start:end]))
-	l.srcPos = end
+	var states []LexerState
+	if l.prog.dfa != nil {
+		states = l.prog.dfa[l.stopped].states
+	} else {
+		for s, on := range l.this {
+			if on {
+				states = append(states, LexerState(s))
+			}
+		}
+	}
+	l.err = &ErrNoMatch{Start: l.sourceOffset(start), Offset: l.sourceOffset(stop), Rune: c, States: states}
+}
 
-	return l.err == nil
+// Read the rune at a position, reporting false if the text ends there. Text before start is no
+// longer needed. If the text could not be read to its end then the stream enters the error state,
+// as the token may have continued in the text that was not read.
+func (l *Stream[T]) char(start, pos int) (rune, int, bool) {
+	if l.in != nil && l.in.needs(pos) {
+		l.in.pos = start
+		l.in.fill(pos)
+		l.src, l.base = l.in.buf, l.in.base
+		l.lines.forget(l.base)
+	}
+	if pos-l.base >= len(l.src) {
+		if l.in != nil && l.in.err != nil {
+			l.err = l.in.err
+		}
+		return 0, 0, false
+	}
+	c, n := utf8.DecodeRune(l.src[pos-l.base:])
+	return c, n, true
+}
+
+func (op finalState[T]) construct(m Match) (T, error) {
+	if op.ThenMatch != nil {
+		return op.ThenMatch(m)
+	}
+	if op.ThenBytes != nil {
+		return op.ThenBytes(m.Start, m.Bytes())
+	}
+	return op.Then(m.Start, m.Text())
 }
 
 func (l *Stream[T]) closeState() {
+	if l.prog.frozen {
+		l.closeStateIndexed()
+		return
+	}
 	for _, op := range l.prog.closeTransitions {
 		if !l.this[op.Given] {
 			continue
@@ -201,20 +973,41 @@ func (l *Stream[T]) closeState() {
 	}
 }
 
-func (l *Stream[T]) detectFinal(final, end *int, pos int) {
+// Invoke the actions attached to the states that the machine is in.
+func (l *Stream[T]) enterStates(start, pos int) {
+	for _, op := range l.prog.enterActions {
+		if l.this[op.Given] {
+			op.Do(start, pos)
+		}
+	}
+}
+
+// Record the final state that a token would be yielded for if the machine stopped at this position.
+// This is called at increasing positions, so any match found here is longer than previous ones.
+func (l *Stream[T]) detectFinal(final, end *int, start, pos int) {
+	if pos == start {
+		return
+	}
+	best := -1
 	for i, op := range l.prog.finalStates {
 		if !l.this[op.Given] {
 			continue
 		}
-
-		if pos > *end || (pos == *end && i < *final) {
-			*end = pos
-			*final = i
+		if best == -1 || op.Priority > l.prog.finalStates[best].Priority {
+			best = i
 		}
 	}
+	if best != -1 {
+		*end = pos
+		*final = best
+	}
 }
 
 func (l *Stream[T]) moveState(running *bool, c rune) {
+	if l.prog.frozen {
+		l.moveStateIndexed(running, c)
+		return
+	}
 	for _, op := range l.prog.moveTransitions {
 		if !l.this[op.Given] {
 			continue
@@ -228,3 +1021,37 @@ func (l *Stream[T]) moveState(running *bool, c rune) {
 		*running = true
 	}
 }
+
+// As closeState, but only visiting the transitions of states that the machine is in. The empty
+// transitions are transitively closed, so states reached here do not need visiting themselves.
+func (l *Stream[T]) closeStateIndexed() {
+	p := l.prog
+	for s, on := range l.this {
+		if !on {
+			continue
+		}
+		for _, op := range p.closeTransitions[p.closeIndex[s]:p.closeIndex[s+1]] {
+			l.this[op.Then] = true
+		}
+	}
+}
+
+// As moveState, but only visiting the transitions of states that the machine is in.
+func (l *Stream[T]) moveStateIndexed(running *bool, c rune) {
+	p := l.prog
+	for s, on := range l.this {
+		if !on {
+			continue
+		}
+		for _, op := range p.moveTransitions[p.moveIndex[s]:p.moveIndex[s+1]] {
+			if c < op.Min {
+				break
+			}
+			if c > op.Max {
+				continue
+			}
+			l.next[op.Then] = true
+			*running = true
+		}
+	}
+}
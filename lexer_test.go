@@ -1,8 +1,16 @@
 package tp
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"unicode"
+	"unsafe"
 
 	"github.com/bobappleyard/assert"
 )
@@ -239,3 +247,856 @@ func TestTypedLexer(t *testing.T) {
 func TestFailingLex(t *testing.T) {
 
 }
+
+func TestTokenSpecError(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	_, err := NewLexer(
+		Regex(`a`, yield),
+		Regex(`(b`, yield),
+	)
+
+	var specErr *ErrTokenSpec
+	if !assert.True(t, errors.As(err, &specErr)) {
+		return
+	}
+	assert.Equal(t, specErr.Index, 1)
+	assert.Equal(t, specErr.Pattern, `(b`)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Equal(t, err.Error(), "rule 1: `(b`: unexpected EOF")
+}
+
+func TestTokenSpecErrors(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	_, err := NewLexer(
+		Regex(`(a`, yield),
+		Regex(`b`, yield),
+		Regex(`c\q`, yield),
+	)
+
+	var joined interface{ Unwrap() []error }
+	if !assert.True(t, errors.As(err, &joined)) {
+		return
+	}
+	var indexes []int
+	for _, err := range joined.Unwrap() {
+		var specErr *ErrTokenSpec
+		if assert.True(t, errors.As(err, &specErr)) {
+			indexes = append(indexes, specErr.Index)
+		}
+	}
+	assert.Equal(t, indexes, []int{0, 2})
+	assert.Equal(t, err.Error(), "rule 0: `(a`: unexpected EOF\n"+
+		"rule 2: `c\\q`: escape \\q is not supported: letters do not need escaping; use EscapeClass to give the escape a meaning")
+}
+
+func TestLexerMatch(t *testing.T) {
+	p, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\d+`, func(start int, text string) (string, error) {
+			return "", strconv.ErrSyntax
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tok, n, err := p.Match([]byte("hello world"))
+	assert.Nil(t, err)
+	assert.Equal(t, tok, "hello")
+	assert.Equal(t, n, 5)
+
+	_, n, err = p.Match([]byte(" hello"))
+	assert.Equal(t, err, ErrFailedMatch)
+	assert.Equal(t, n, 0)
+
+	_, _, err = p.Match([]byte("123"))
+	assert.Equal(t, err, strconv.ErrSyntax)
+}
+
+func TestLexerMatchSkip(t *testing.T) {
+	p, err := NewLexer(
+		Skip[string](`\s+`),
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tok, n, err := p.Match([]byte("hello world"))
+	assert.Nil(t, err)
+	assert.Equal(t, tok, "hello")
+	assert.Equal(t, n, 5)
+
+	// skipped text is not passed over
+	_, n, err = p.Match([]byte("  hello"))
+	assert.Equal(t, err, ErrFailedMatch)
+	assert.Equal(t, n, 2)
+
+	_, n, err = p.Match([]byte("  "))
+	assert.Equal(t, err, ErrFailedMatch)
+	assert.Equal(t, n, 2)
+}
+
+func TestLexerDisambiguation(t *testing.T) {
+	type Token struct {
+		Rule int
+		Text string
+	}
+
+	for _, test := range []struct {
+		name     string
+		patterns []string
+		in       string
+		out      []Token
+
+		// whether the stream stops before the end of the input
+		stuck bool
+	}{
+		{
+			name:     "KeywordFirst",
+			patterns: []string{`if`, `[a-z]+`},
+			in:       "if",
+			out:      []Token{{0, "if"}},
+		},
+		{
+			name:     "IdentFirst",
+			patterns: []string{`[a-z]+`, `if`},
+			in:       "if",
+			out:      []Token{{0, "if"}},
+		},
+		{
+			name:     "LongerBeatsPriority",
+			patterns: []string{`if`, `[a-z]+`},
+			in:       "iffy",
+			out:      []Token{{1, "iffy"}},
+		},
+		{
+			name:     "Prefixes",
+			patterns: []string{`a`, `ab`, `abc`},
+			in:       "abcaba",
+			out:      []Token{{2, "abc"}, {1, "ab"}, {0, "a"}},
+		},
+		{
+			name:     "PrefixesReversed",
+			patterns: []string{`abc`, `ab`, `a`},
+			in:       "abcaba",
+			out:      []Token{{0, "abc"}, {1, "ab"}, {2, "a"}},
+		},
+		{
+			name:     "NoBacktracking",
+			patterns: []string{`ab*c`, `a`, `b`},
+			in:       "abbb",
+			out:      []Token{{1, "a"}, {2, "b"}, {2, "b"}, {2, "b"}},
+		},
+		{
+			name:     "EqualLength",
+			patterns: []string{`[0-9]+`, `[0-9a-f]+`},
+			in:       "12",
+			out:      []Token{{0, "12"}},
+		},
+		{
+			name:     "LongerLaterRule",
+			patterns: []string{`[0-9]+`, `[0-9a-f]+`},
+			in:       "12ab",
+			out:      []Token{{1, "12ab"}},
+		},
+		{
+			name:     "SameRuleTwice",
+			patterns: []string{`a|ab`, `b`},
+			in:       "abb",
+			out:      []Token{{0, "ab"}, {1, "b"}},
+		},
+		{
+			name:     "EmptyMatch",
+			patterns: []string{`a*`},
+			in:       "b",
+			stuck:    true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var specs []TokenSpec[Token]
+			for i, p := range test.patterns {
+				specs = append(specs, Regex(p, func(start int, text string) (Token, error) {
+					return Token{Rule: i, Text: text}, nil
+				}))
+			}
+			p, err := NewLexer(specs...)
+			if !assert.Nil(t, err) {
+				return
+			}
+			toks, err := p.Tokenize([]byte(test.in)).Force()
+			assert.Equal(t, errors.Is(err, ErrFailedMatch), test.stuck)
+			assert.Equal(t, toks, test.out)
+		})
+	}
+}
+
+func TestMatchConstructor(t *testing.T) {
+	p, err := NewLexer(
+		RegexMatch(`[^\s]+`, func(m Match) (Match, error) {
+			return m, nil
+		}),
+		RegexMatch(`\s`, func(m Match) (Match, error) {
+			return m, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	src := []byte("éé é")
+	toks, err := p.Tokenize(src).Force()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var spans [][2]int
+	var texts []string
+	for _, m := range toks {
+		spans = append(spans, [2]int{m.Start, m.End})
+		texts = append(texts, m.Text())
+		assert.Equal(t, m.Source(), src)
+	}
+	assert.Equal(t, spans, [][2]int{{0, 4}, {4, 5}, {5, 7}})
+	assert.Equal(t, texts, []string{"éé", " ", "é"})
+}
+
+func TestBytesConstructor(t *testing.T) {
+	src := []byte("12 ab")
+	p, err := NewLexer(
+		RegexBytes(`[0-9]+`, func(start int, text []byte) (any, error) {
+			return strconv.Atoi(string(text))
+		}),
+		RegexBytes(`[a-z]+`, func(start int, text []byte) (any, error) {
+			// the text refers to the source, so must be copied to be kept
+			assert.True(t, &text[0] == &src[start])
+			return Copy(text), nil
+		}),
+		RegexBytes(`\s`, FromText(func(start int, text string) (any, error) {
+			return text, nil
+		})),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	toks, err := p.Tokenize(src).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []any{12, " ", []byte("ab")})
+}
+
+func TestFreeze(t *testing.T) {
+	newLexer := func() *Lexer[string] {
+		var specs []TokenSpec[string]
+		for _, p := range []string{`if`, `[a-z]+`, `\d+(\.\d+)?`, `\s+`, `[^a-z\d\s]`} {
+			specs = append(specs, Regex(p, func(start int, text string) (string, error) {
+				return text, nil
+			}))
+		}
+		l, err := NewLexer(specs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	src := []byte("if x1 > 12.5 { iffy = 3 }")
+
+	expect, err := newLexer().Tokenize(src).Force()
+	assert.Nil(t, err)
+
+	l := newLexer()
+	l.Freeze()
+	got, err := l.Tokenize(src).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, got, expect)
+
+	defer func() {
+		assert.Equal(t, recover(), any("tp: modifying a frozen lexer"))
+	}()
+	l.State()
+}
+
+func TestConcurrentStreams(t *testing.T) {
+	l, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\d+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\s+`, func(start int, text string) (string, error) {
+			return "", nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	l.Freeze()
+
+	var wg sync.WaitGroup
+	results := make([][]string, 16)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src := strings.Repeat(fmt.Sprintf("abc %d ", i), 100)
+			toks, err := l.Tokenize([]byte(src)).Force()
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = toks
+		}()
+	}
+	wg.Wait()
+
+	for i, toks := range results {
+		assert.Equal(t, len(toks), 400)
+		assert.Equal(t, toks[2], strconv.Itoa(i))
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	p, err := NewLexer(
+		Categorize("keyword", Regex(`if|else`, yield)),
+		Regex(`[a-z]+`, yield),
+		Categorize("trivia", Regex(`\s+`, yield)),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	l := p.Tokenize([]byte("if x else"))
+	var got [][2]string
+	for l.Next() {
+		got = append(got, [2]string{l.This(), l.Category()})
+	}
+	assert.Nil(t, l.Err())
+	assert.Equal(t, got, [][2]string{
+		{"if", "keyword"},
+		{" ", "trivia"},
+		{"x", ""},
+		{" ", "trivia"},
+		{"else", "keyword"},
+	})
+}
+
+func TestPrioritize(t *testing.T) {
+	yield := func(kind string) TokenConstructor[string] {
+		return func(start int, text string) (string, error) {
+			return kind + " " + text, nil
+		}
+	}
+
+	for _, mode := range []string{"NFA", "Frozen", "Compiled"} {
+		t.Run(mode, func(t *testing.T) {
+			p, err := NewLexer(
+				Skip[string](`\s+`),
+				Regex(`[a-z]+`, yield("ident")),
+				Prioritize(2, Regex(`if|else`, yield("keyword"))),
+				Prioritize(1, Regex(`if|iffy`, yield("lower"))),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			switch mode {
+			case "Frozen":
+				p.Freeze()
+			case "Compiled":
+				p.Compile()
+			}
+
+			toks, err := p.Tokenize([]byte("if x else iffy")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"keyword if", "ident x", "keyword else", "lower iffy"})
+
+			// every spec still produces the tokens for some text
+			assert.Equal(t, len(p.Shadowed()), 0)
+		})
+	}
+}
+
+func TestEnter(t *testing.T) {
+	for _, frozen := range []bool{false, true} {
+		t.Run(fmt.Sprint("Frozen", frozen), func(t *testing.T) {
+			var lp Lexer[int]
+
+			// a line break followed by indentation yields the depth of the indentation, while a
+			// word yields -1
+			depth := 0
+			indent := lp.State()
+			lp.Rune(0, indent, '\n')
+			lp.Rune(indent, indent, ' ')
+			lp.Enter(indent, func(start, pos int) {
+				depth = pos - start - 1
+			})
+			lp.Final(indent, func(start int, text string) (int, error) {
+				return depth, nil
+			})
+
+			word := lp.State()
+			lp.Range(0, word, 'a', 'z')
+			lp.Range(word, word, 'a', 'z')
+			lp.Final(word, func(start int, text string) (int, error) {
+				return -1, nil
+			})
+
+			if frozen {
+				lp.Freeze()
+			}
+
+			toks, err := lp.Tokenize([]byte("a\n  bc\n d\ne")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []int{-1, 2, -1, 1, -1, 0, -1})
+		})
+	}
+}
+
+func TestRangeTable(t *testing.T) {
+	for _, mode := range []string{"NFA", "Frozen", "Compiled"} {
+		t.Run(mode, func(t *testing.T) {
+			var lp Lexer[string]
+
+			// identifiers are a letter followed by letters and digits, and anything else is a
+			// token of its own
+			ident := lp.State()
+			lp.RangeTable(0, ident, unicode.Letter)
+			lp.RangeTable(ident, ident, unicode.Letter)
+			lp.RangeTable(ident, ident, unicode.Digit)
+			lp.Final(ident, func(start int, text string) (string, error) {
+				return "ident " + text, nil
+			})
+
+			other := lp.State()
+			lp.Range(0, other, 0, unicode.MaxRune)
+			lp.Final(other, func(start int, text string) (string, error) {
+				return "other " + text, nil
+			})
+
+			switch mode {
+			case "Frozen":
+				lp.Freeze()
+			case "Compiled":
+				lp.Compile()
+			}
+
+			// Ǆ is in a range of Lu with a stride of 3, and ǅ, in between, is not Lu but Lt
+			toks, err := lp.Tokenize([]byte("añb٣ 9ǄǅǇ日本")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"ident añb٣", "other  ", "other 9", "ident ǄǅǇ日本"})
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	word := func(kind string) TokenConstructor[string] {
+		return func(start int, text string) (string, error) {
+			return kind + ":" + text, nil
+		}
+	}
+
+	for _, compiled := range []bool{false, true} {
+		t.Run(fmt.Sprint("Compiled", compiled), func(t *testing.T) {
+			common, err := NewLexer(
+				Skip[string](`\s+`),
+				Fragment[string]("digits", `\d+`),
+				Regex(`\g{digits}`, word("num")),
+				Regex(`[a-z]+`, word("ident")),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			common.Freeze()
+
+			lang, err := NewLexer(
+				Regex(`if|else`, word("keyword")),
+				Regex(`[a-z]+`, word("name")),
+				Prioritize(1, Regex(`\d+\.\d+`, word("float"))),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			lang.Merge(common)
+			if compiled {
+				lang.Compile()
+			}
+
+			toks, err := lang.Tokenize([]byte("if x 12 1.5")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"keyword:if", "name:x", "num:12", "float:1.5"})
+
+			// the other lexer's specs follow this one's
+			assert.Equal(t, lang.rules[3].Index, 3)
+			assert.Equal(t, lang.rules[5].Index, 5)
+			_, ok := lang.fragments["digits"]
+			assert.True(t, ok)
+
+			// the other lexer still works alone
+			toks, err = common.Tokenize([]byte("if x")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"ident:if", "ident:x"})
+		})
+	}
+}
+
+func TestMergeIntoCompiled(t *testing.T) {
+	l := compileTestLexer(t)
+	l.Compile()
+	defer func() {
+		assert.Equal(t, recover(), any("tp: modifying a frozen lexer"))
+	}()
+	l.Merge(compileTestLexer(t))
+	t.Error("expected a panic")
+}
+
+func TestStreamClone(t *testing.T) {
+	l, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\n`, func(start int, text string) (string, error) {
+			return "nl", nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	s := l.Tokenize([]byte("a\nb\nc"))
+	assert.True(t, s.Next())
+	assert.True(t, s.Next())
+
+	ahead := s.Clone()
+	rest, err := ahead.Force()
+	assert.Nil(t, err)
+	assert.Equal(t, rest, []string{"b", "nl", "c"})
+	assert.Equal(t, ahead.Lines().Lines(), 3)
+
+	// the original is where it was left
+	assert.Equal(t, s.This(), "nl")
+	assert.Equal(t, s.Lines().Lines(), 2)
+	assert.True(t, s.Next())
+	assert.Equal(t, s.This(), "b")
+	assert.True(t, s.Next())
+	assert.Equal(t, s.Lines().Position(4), Position{Offset: 4, Line: 3, Column: 1})
+}
+
+func TestStreamPeekNotAhead(t *testing.T) {
+	l, err := NewLexer(Regex(`[a-z]+`, func(start int, text string) (string, error) {
+		return text, nil
+	}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	s := l.Tokenize([]byte("a"))
+	defer func() {
+		assert.Equal(t, recover(), any("tp: peeking at fewer than one token ahead"))
+	}()
+	s.Peek(0)
+	t.Error("expected a panic")
+}
+
+func TestStreamPeek(t *testing.T) {
+	l, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\n`, func(start int, text string) (string, error) {
+			return "nl", nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, test := range []struct {
+		name string
+		s    *Stream[string]
+	}{
+		{"Bytes", l.Tokenize([]byte("a\nb\nc?"))},
+		{"Reader", l.TokenizeReader(strings.NewReader("a\nb\nc?"))},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := test.s
+			assert.True(t, s.Next())
+
+			tok, ok := s.Peek(2)
+			assert.True(t, ok)
+			assert.Equal(t, tok, "b")
+			tok, ok = s.Peek(1)
+			assert.True(t, ok)
+			assert.Equal(t, tok, "nl")
+
+			// the failure is not reported until it is reached
+			_, ok = s.Peek(5)
+			assert.False(t, ok)
+			assert.Nil(t, s.Err())
+
+			// the stream is where it was left
+			assert.Equal(t, s.This(), "a")
+			assert.Equal(t, s.Lines().Lines(), 1)
+
+			ahead := s.Clone()
+			rest, err := s.Force()
+			assert.Equal(t, rest, []string{"nl", "b", "nl", "c"})
+			assert.Equal(t, err.Error(), "failed to match: no token begins with '?' at offset 5")
+			assert.Equal(t, s.Lines().Lines(), 3)
+
+			// a clone has its own peeked tokens
+			assert.True(t, ahead.Next())
+			assert.Equal(t, ahead.This(), "nl")
+			assert.Equal(t, ahead.Lines().Lines(), 2)
+		})
+	}
+}
+
+func TestStreamMark(t *testing.T) {
+	l, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\n`, func(start int, text string) (string, error) {
+			return "nl", nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, test := range []struct {
+		name string
+		s    *Stream[string]
+	}{
+		{"Bytes", l.Tokenize([]byte("a\nb\nc?"))},
+		{"Reader", l.TokenizeReader(strings.NewReader("a\nb\nc?"))},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := test.s
+			assert.True(t, s.Next())
+			m := s.Mark()
+
+			rest, err := s.Force()
+			assert.Equal(t, rest, []string{"nl", "b", "nl", "c"})
+			assert.True(t, err != nil)
+
+			// the tokens are matched again, and the error is forgotten
+			s.Reset(m)
+			assert.Nil(t, s.Err())
+			assert.Equal(t, s.This(), "a")
+			assert.Equal(t, s.Lines().Lines(), 1)
+			tok, ok := s.Peek(2)
+			assert.True(t, ok)
+			assert.Equal(t, tok, "b")
+			assert.True(t, s.Next())
+			assert.Equal(t, s.This(), "nl")
+
+			// marks hold peeked tokens, and can be used more than once
+			m = s.Mark()
+			for range 2 {
+				s.Reset(m)
+				rest, err = s.Force()
+				assert.Equal(t, rest, []string{"b", "nl", "c"})
+				assert.True(t, err != nil)
+				assert.Equal(t, s.Lines().Lines(), 3)
+			}
+		})
+	}
+}
+
+func TestStreamAll(t *testing.T) {
+	l, err := NewLexer(
+		Skip[string](`\s+`),
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var toks []string
+	var errs []error
+	for tok, err := range l.Tokenize([]byte("a b ? c")).All() {
+		toks = append(toks, tok)
+		errs = append(errs, err)
+	}
+	assert.Equal(t, toks, []string{"a", "b", ""})
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, errs[2].Error(), "failed to match: no token begins with '?' at offset 4")
+
+	// stopping early leaves the rest of the stream to be read
+	s := l.Tokenize([]byte("a b c"))
+	for range s.All() {
+		break
+	}
+	assert.True(t, s.Next())
+	assert.Equal(t, s.This(), "b")
+}
+
+func TestTokenizeString(t *testing.T) {
+	l, err := NewLexer(
+		Skip[string](`\s+`),
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	src := "hello there world"
+	toks, err := l.TokenizeString(src).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{"hello", "there", "world"})
+
+	// the text of each token is part of the source
+	assert.True(t, unsafe.StringData(toks[1]) == unsafe.StringData(src[6:]))
+
+	s := l.TokenizeString(strings.Repeat("abc ", 20))
+	allocs := testing.AllocsPerRun(10, func() {
+		assert.True(t, s.Next())
+	})
+	assert.Equal(t, allocs, 0.0)
+
+	// interning still applies
+	var in Interner
+	toks, err = l.TokenizeString("a b a").Intern(&in).Force()
+	assert.Nil(t, err)
+	assert.True(t, unsafe.StringData(toks[0]) == unsafe.StringData(toks[2]))
+}
+
+func TestSkip(t *testing.T) {
+	for _, compiled := range []bool{false, true} {
+		t.Run(fmt.Sprint("Compiled", compiled), func(t *testing.T) {
+			l, err := NewLexer(
+				Skip[string](`\s+`),
+				Skip[string](`//[^\n]*`),
+				Regex(`[a-z]+`, func(start int, text string) (string, error) {
+					return text, nil
+				}),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			if compiled {
+				l.Compile()
+			}
+
+			s := l.Tokenize([]byte("a // b\n  c // d"))
+			toks, err := s.Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"a", "c"})
+
+			// the lines of the skipped text are still counted
+			assert.Equal(t, s.Lines().Lines(), 2)
+		})
+	}
+}
+
+func TestLiteralCI(t *testing.T) {
+	for _, compiled := range []bool{false, true} {
+		t.Run(fmt.Sprint("Compiled", compiled), func(t *testing.T) {
+			l, err := NewLexer(
+				Skip[string](`\s+`),
+				LiteralCI("select", func(start int, text string) (string, error) {
+					return "SELECT", nil
+				}),
+				LiteralCI("straße", func(start int, text string) (string, error) {
+					return "STRASSE", nil
+				}),
+				Regex(`[a-zA-Z]+`, func(start int, text string) (string, error) {
+					return text, nil
+				}),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			if compiled {
+				l.Compile()
+			}
+
+			toks, err := l.Tokenize([]byte("select SELECT SeLeCt selects sel STRAßE")).Force()
+			assert.Nil(t, err)
+			assert.Equal(t, toks, []string{"SELECT", "SELECT", "SELECT", "selects", "sel", "STRASSE"})
+		})
+	}
+}
+
+func TestLiteralCIEmpty(t *testing.T) {
+	_, err := NewLexer(LiteralCI("", func(start int, text string) (string, error) {
+		return text, nil
+	}))
+	if assert.True(t, err != nil) {
+		assert.Equal(t, err.Error(), "rule 0: a case-insensitive literal must have some text")
+	}
+}
+
+func TestErrNoMatch(t *testing.T) {
+	for _, compiled := range []bool{false, true} {
+		t.Run(fmt.Sprint("Compiled", compiled), func(t *testing.T) {
+			l, err := NewLexer(
+				Regex(`[a-z]+`, func(start int, text string) (string, error) {
+					return text, nil
+				}),
+				Regex(`"[^"\n]*"`, func(start int, text string) (string, error) {
+					return text, nil
+				}),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			if compiled {
+				l.Compile()
+			}
+
+			for _, test := range []struct {
+				in      string
+				err     ErrNoMatch
+				message string
+			}{
+				{
+					in:      "ab!",
+					err:     ErrNoMatch{Start: 2, Offset: 2, Rune: '!'},
+					message: "failed to match: no token begins with '!' at offset 2",
+				},
+				{
+					in:      `ab"cd`,
+					err:     ErrNoMatch{Start: 2, Offset: 5, Rune: -1},
+					message: "failed to match: text ended at offset 5 in a token that began at offset 2",
+				},
+				{
+					in:      "\"c\nd",
+					err:     ErrNoMatch{Start: 0, Offset: 2, Rune: '\n'},
+					message: `failed to match: unexpected '\n' at offset 2 in a token that began at offset 0`,
+				},
+			} {
+				_, err := l.Tokenize([]byte(test.in)).Force()
+				var noMatch *ErrNoMatch
+				if !assert.True(t, errors.As(err, &noMatch)) {
+					continue
+				}
+				assert.True(t, errors.Is(err, ErrFailedMatch))
+				assert.Equal(t, err.Error(), test.message)
+				assert.Equal(t, noMatch.Start, test.err.Start)
+				assert.Equal(t, noMatch.Offset, test.err.Offset)
+				assert.Equal(t, noMatch.Rune, test.err.Rune)
+				assert.True(t, slices.Contains(noMatch.States, 0) == (test.err.Offset == test.err.Start))
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package tp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Position describes a location in a text. Lines and columns are numbered from 1, and columns are
+// counted in bytes.
+type Position struct {
+	Offset, Line, Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// LineIndex records where the lines of a text begin, so that byte offsets can be translated into
+// lines and columns and back again without rescanning the text.
+type LineIndex struct {
+	// the offsets of the beginning of every line except the first, apart from those that have been
+	// forgotten
+	starts []int
+
+	// the number of line beginnings that have been forgotten, see forget
+	forgotten int
+}
+
+// Build a line index for a complete text.
+func NewLineIndex(src []byte) *LineIndex {
+	x := new(LineIndex)
+	x.scan(src, 0)
+	return x
+}
+
+// Record the lines that begin in a piece of text found at the given offset. Text must be scanned in
+// order.
+func (x *LineIndex) scan(text []byte, offset int) {
+	for {
+		i := bytes.IndexByte(text, '\n')
+		if i == -1 {
+			return
+		}
+		offset += i + 1
+		text = text[i+1:]
+		x.starts = append(x.starts, offset)
+	}
+}
+
+// Forget where the lines that end before an offset begin, so that an index of a long text that is
+// read a piece at a time does not keep growing. The lines are still counted.
+func (x *LineIndex) forget(offset int) {
+	n := sort.SearchInts(x.starts, offset+1) - 1
+	if n <= 0 {
+		return
+	}
+	// the slice is not compacted in place, as clones of a stream share it
+	x.forgotten += n
+	x.starts = x.starts[n:]
+}
+
+// The number of lines that have been seen.
+func (x *LineIndex) Lines() int {
+	return x.forgotten + len(x.starts) + 1
+}
+
+// Find the line and column of a byte offset. If the line it is in has been forgotten, as happens
+// for streams made by TokenizeReader, then only the offset is given.
+func (x *LineIndex) Position(offset int) Position {
+	n := sort.SearchInts(x.starts, offset+1)
+	if n == 0 && x.forgotten != 0 {
+		return Position{Offset: offset}
+	}
+	lineStart := 0
+	if n > 0 {
+		lineStart = x.starts[n-1]
+	}
+	return Position{
+		Offset: offset,
+		Line:   x.forgotten + n + 1,
+		Column: offset - lineStart + 1,
+	}
+}
+
+// Find the byte offset of a line and column. If the line has not been seen, or has been forgotten,
+// or the column lies beyond the end of the line, then this returns false.
+func (x *LineIndex) Offset(line, column int) (int, bool) {
+	// where the line's beginning is in starts, or -1 for the first line
+	i := line - 2 - x.forgotten
+	if i < -1 || i == -1 && x.forgotten != 0 || line > x.Lines() || column < 1 {
+		return 0, false
+	}
+	offset := column - 1
+	if i >= 0 {
+		offset += x.starts[i]
+	}
+	if i+1 < len(x.starts) && offset >= x.starts[i+1] {
+		return 0, false
+	}
+	return offset, true
+}
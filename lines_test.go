@@ -0,0 +1,82 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestLineIndex(t *testing.T) {
+	x := NewLineIndex([]byte("ab\ncde\n\nf"))
+
+	assert.Equal(t, x.Lines(), 4)
+
+	for _, test := range []struct {
+		offset int
+		pos    Position
+	}{
+		{0, Position{Offset: 0, Line: 1, Column: 1}},
+		{2, Position{Offset: 2, Line: 1, Column: 3}},
+		{3, Position{Offset: 3, Line: 2, Column: 1}},
+		{6, Position{Offset: 6, Line: 2, Column: 4}},
+		{7, Position{Offset: 7, Line: 3, Column: 1}},
+		{8, Position{Offset: 8, Line: 4, Column: 1}},
+		{9, Position{Offset: 9, Line: 4, Column: 2}},
+	} {
+		pos := x.Position(test.offset)
+		assert.Equal(t, pos, test.pos)
+
+		offset, ok := x.Offset(pos.Line, pos.Column)
+		assert.True(t, ok)
+		assert.Equal(t, offset, test.offset)
+	}
+
+	_, ok := x.Offset(1, 4)
+	assert.False(t, ok)
+	_, ok = x.Offset(5, 1)
+	assert.False(t, ok)
+	_, ok = x.Offset(0, 1)
+	assert.False(t, ok)
+}
+
+func TestStreamLines(t *testing.T) {
+	p, err := NewLexer(
+		Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+		Regex(`\s+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	l := p.Tokenize([]byte("one two\nthree\n\nfour"))
+	var positions []string
+	for l.Next() {
+		if l.This()[0] == ' ' || l.This()[0] == '\n' {
+			continue
+		}
+		positions = append(positions, l.Lines().Position(l.srcPos-len(l.This())).String())
+	}
+	assert.Equal(t, positions, []string{"1:1", "1:5", "2:1", "4:1"})
+	assert.Equal(t, l.Lines().Lines(), 4)
+}
+
+func TestMatchPosition(t *testing.T) {
+	p, err := NewLexer(
+		RegexMatch(`[a-z]+|\s+`, func(m Match) (string, error) {
+			return m.Position().String() + "-" + m.EndPosition().String(), nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	toks, err := p.Tokenize([]byte("one two\nthree\n\nfour")).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{
+		"1:1-1:4", "1:4-1:5", "1:5-1:8", "1:8-2:1", "2:1-2:6", "2:6-4:1", "4:1-4:5",
+	})
+}
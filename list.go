@@ -0,0 +1,76 @@
+package tp
+
+// Delimited matches zero or more of T, separated by D. Use it as the argument of a rule.
+//
+// By default a delimiter may only appear between items. To also accept a delimiter after the last
+// item, as in [1, 2, 3,], give the grammar an exported field of this type with Trailing set. This
+// applies to every use of the type in the grammar.
+type Delimited[T, D any] struct {
+	Items []T
+
+	// Whether a delimiter may follow the last item. This is configuration, rather than something
+	// that is set by parsing.
+	Trailing bool
+}
+
+type delimitedGrammar[T, D any] struct{}
+
+type trailingDelimitedGrammar[T, D any] struct {
+	delimitedGrammar[T, D]
+}
+
+type delimitedItem[T, D any] struct {
+	value T
+}
+
+func (d Delimited[T, D]) Grammar() any {
+	if d.Trailing {
+		return trailingDelimitedGrammar[T, D]{}
+	}
+	return delimitedGrammar[T, D]{}
+}
+
+func (delimitedGrammar[T, D]) None() Delimited[T, D] {
+	return Delimited[T, D]{}
+}
+
+func (delimitedGrammar[T, D]) Some(first T, rest []delimitedItem[T, D]) Delimited[T, D] {
+	items := make([]T, 0, len(rest)+1)
+	items = append(items, first)
+	for _, x := range rest {
+		items = append(items, x.value)
+	}
+	return Delimited[T, D]{Items: items}
+}
+
+func (delimitedGrammar[T, D]) Item(_ D, x T) delimitedItem[T, D] {
+	return delimitedItem[T, D]{value: x}
+}
+
+func (g trailingDelimitedGrammar[T, D]) SomeTrailing(first T, rest []delimitedItem[T, D], _ D) Delimited[T, D] {
+	return g.Some(first, rest)
+}
+
+// SpannedSlice matches zero or more of T, as an argument of type []T does, and also records the
+// tokens that they cover. Use it as the argument of a rule where a list needs a location, e.g. to
+// report where a block of statements begins and ends. If there are no items then the span is empty
+// and begins where the items would have.
+type SpannedSlice[T any] struct {
+	Items []T
+	Span  Span
+}
+
+type spannedSliceGrammar[T any] struct{}
+
+func (SpannedSlice[T]) Grammar() spannedSliceGrammar[T] {
+	return spannedSliceGrammar[T]{}
+}
+
+func (s SpannedSlice[T]) withSpan(sp Span) any {
+	s.Span = sp
+	return s
+}
+
+func (spannedSliceGrammar[T]) Items(xs []T) SpannedSlice[T] {
+	return SpannedSlice[T]{Items: xs}
+}
@@ -0,0 +1,74 @@
+package tp_test
+
+import (
+	"fmt"
+
+	"github.com/bobappleyard/tp"
+)
+
+type numberList struct {
+	values []float64
+}
+
+type numberListGrammar struct {
+	Lists tp.Delimited[numberToken, commaToken]
+}
+
+func (numberListGrammar) Parse(x numberList) (numberList, error) {
+	return x, nil
+}
+
+func (numberListGrammar) List(_ arrayStartToken, xs tp.Delimited[numberToken, commaToken], _ arrayEndToken) numberList {
+	res := numberList{values: []float64{}}
+	for _, x := range xs.Items {
+		res.values = append(res.values, x.value)
+	}
+	return res
+}
+
+func ExampleDelimited() {
+	strict := numberListGrammar{}
+	loose := numberListGrammar{Lists: tp.Delimited[numberToken, commaToken]{Trailing: true}}
+
+	for _, src := range []string{`[]`, `[1, 2, 3]`, `[1, 2, 3,]`} {
+		toks := removeWhitespace(must(lexicon.Tokenize([]byte(src)).Force()))
+		_, strictErr := tp.Parse(strict, toks)
+		xs, looseErr := tp.Parse(loose, toks)
+		fmt.Println(src, xs.values, strictErr, looseErr)
+	}
+
+	// Output:
+	// [] [] <nil> <nil>
+	// [1, 2, 3] [1 2 3] <nil> <nil>
+	// [1, 2, 3,] [1 2 3] unexpected token: tp_test.arrayEndToken{} <nil>
+}
+
+type numberBlock struct {
+	values tp.SpannedSlice[numberToken]
+}
+
+type numberBlockGrammar struct{}
+
+func (numberBlockGrammar) Parse(x []numberBlock) ([]numberBlock, error) {
+	return x, nil
+}
+
+func (numberBlockGrammar) Block(_ arrayStartToken, xs tp.SpannedSlice[numberToken], _ arrayEndToken) numberBlock {
+	return numberBlock{values: xs}
+}
+
+func ExampleSpannedSlice() {
+	toks := removeWhitespace(must(lexicon.Tokenize([]byte(`[1 2] [] [3]`)).Force()))
+	blocks, err := tp.Parse(numberBlockGrammar{}, toks)
+	if err != nil {
+		panic(err)
+	}
+	for _, b := range blocks {
+		fmt.Println(len(b.values.Items), b.values.Span)
+	}
+
+	// Output:
+	// 2 {1 3}
+	// 0 {5 5}
+	// 1 {7 8}
+}
@@ -0,0 +1,24 @@
+package tp
+
+import "reflect"
+
+// NotFollowedBy restricts the rule that it appears in to places where the next token is not a T.
+// It matches no tokens itself, and is given to the rule as the zero value.
+//
+// This allows choices that depend on what comes next to be made in the grammar. So, e.g. a variable
+// can be told apart from a function call by
+//
+//	func (g) Var(name identTok, _ tp.NotFollowedBy[openParen]) varExpr
+//
+// At the end of the input, there is no next token, so the restriction always allows it.
+type NotFollowedBy[T any] struct{}
+
+type lookahead interface {
+	notFollowedBy() reflect.Type
+}
+
+var lookaheadType = reflect.TypeFor[lookahead]()
+
+func (NotFollowedBy[T]) notFollowedBy() reflect.Type {
+	return reflect.TypeFor[T]()
+}
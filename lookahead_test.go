@@ -0,0 +1,77 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type callTerm interface {
+	callTerm()
+}
+
+type variable struct{ name string }
+type call struct{ name string }
+type unit struct{}
+
+func (variable) callTerm() {}
+func (call) callTerm()     {}
+func (unit) callTerm()     {}
+
+type varEnd struct{}
+
+type callGrammar struct{}
+
+func (callGrammar) Parse(x []callTerm) ([]callTerm, error) {
+	return x, nil
+}
+
+func (callGrammar) Var(name identTok, _ varEnd) variable {
+	return variable{name.name}
+}
+
+func (callGrammar) VarEnd(_ tp.NotFollowedBy[openTok]) varEnd {
+	return varEnd{}
+}
+
+func (callGrammar) Call(name identTok, _ openTok, _ closeTok) call {
+	return call{name.name}
+}
+
+func (callGrammar) Unit(_ openTok, _ closeTok) unit {
+	return unit{}
+}
+
+func TestNotFollowedBy(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		in   []any
+		out  []callTerm
+	}{
+		{
+			name: "Call",
+			in:   []any{identTok{"f"}, openTok{}, closeTok{}},
+			out:  []callTerm{call{"f"}},
+		},
+		{
+			name: "Variables",
+			in:   []any{identTok{"f"}, identTok{"g"}},
+			out:  []callTerm{variable{"f"}, variable{"g"}},
+		},
+		{
+			name: "Unit",
+			in:   []any{openTok{}, closeTok{}, identTok{"f"}, openTok{}, closeTok{}, identTok{"g"}},
+			out:  []callTerm{unit{}, call{"f"}, variable{"g"}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := tp.ParseUnambiguous(callGrammar{}, test.in, 2)
+			assert.Nil(t, err)
+			assert.Equal(t, out, test.out)
+		})
+	}
+
+	_, err := tp.FindAmbiguity[any](callGrammar{}, 6)
+	assert.Nil(t, err)
+}
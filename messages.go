@@ -0,0 +1,132 @@
+package tp
+
+import (
+	"fmt"
+	"io"
+)
+
+// Messages supplies the text that describes the errors reported when a text cannot be parsed, so
+// that applications can present syntax errors in a language other than English. Where an error
+// wraps another, the message for the wrapped error is passed in as cause.
+//
+// A catalog that only translates some messages can embed EnglishMessages for the rest.
+type Messages interface {
+	UnexpectedToken(e *ErrUnexpectedToken) string
+
+	// The text ended before the grammar was satisfied.
+	UnexpectedEOF() string
+
+	// No token matches the text, but the stream did not say why.
+	FailedMatch() string
+
+	NoMatch(e *ErrNoMatch) string
+	UnclosedBracket(e *ErrUnclosedBracket, cause string) string
+	RuleError(e *RuleError, cause string) string
+	Version(e *ErrVersion) string
+	Feature(e *ErrFeature) string
+	SyntaxError(e *SyntaxError, cause string) string
+	Diagnostic(d Diagnostic, cause string) string
+}
+
+// EnglishMessages is the catalog of the messages that errors describe themselves with.
+type EnglishMessages struct{}
+
+func (EnglishMessages) UnexpectedToken(e *ErrUnexpectedToken) string {
+	msg := "unexpected token: " + FormatToken(e.Token)
+	if o := originOf(e.Token); o != nil {
+		msg += " at " + o.Trace()
+	}
+	if e.While != "" {
+		msg += " while parsing " + e.While
+	}
+	return msg
+}
+
+func (EnglishMessages) UnexpectedEOF() string {
+	return io.ErrUnexpectedEOF.Error()
+}
+
+func (EnglishMessages) FailedMatch() string {
+	return ErrFailedMatch.Error()
+}
+
+func (EnglishMessages) NoMatch(e *ErrNoMatch) string {
+	if e.Rune == -1 {
+		return fmt.Sprintf("%s: text ended at offset %d in a token that began at offset %d", ErrFailedMatch, e.Offset, e.Start)
+	}
+	if e.Offset == e.Start {
+		return fmt.Sprintf("%s: no token begins with %q at offset %d", ErrFailedMatch, e.Rune, e.Offset)
+	}
+	return fmt.Sprintf("%s: unexpected %q at offset %d in a token that began at offset %d", ErrFailedMatch, e.Rune, e.Offset, e.Start)
+}
+
+func (EnglishMessages) UnclosedBracket(e *ErrUnclosedBracket, cause string) string {
+	return fmt.Sprintf("%s: expected %s to close %s at token %d", cause, FormatToken(e.Close), FormatToken(e.Open), e.OpenIndex)
+}
+
+func (EnglishMessages) RuleError(e *RuleError, cause string) string {
+	return fmt.Sprintf("%s at tokens %d to %d: %s", e.Label, e.Span.Start, e.Span.End, cause)
+}
+
+func (EnglishMessages) Version(e *ErrVersion) string {
+	if e.Added != "" {
+		return fmt.Sprintf("%s requires version %s or later, but the version is %s", e.Label, e.Added, e.Version)
+	}
+	return fmt.Sprintf("%s was removed in version %s, but the version is %s", e.Label, e.Removed, e.Version)
+}
+
+func (EnglishMessages) Feature(e *ErrFeature) string {
+	return fmt.Sprintf("%s requires the %s feature, which is not enabled", e.Label, e.Feature)
+}
+
+func (EnglishMessages) SyntaxError(e *SyntaxError, cause string) string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, cause)
+}
+
+func (EnglishMessages) Diagnostic(d Diagnostic, cause string) string {
+	if d.Position.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.Path, cause)
+	}
+	return fmt.Sprintf("%s:%s: %s", d.Path, d.Position, cause)
+}
+
+// Describe a token for the messages of errors. Tokens that implement fmt.Stringer are described by
+// their String method, so that e.g. a comma can be shown to users as "," rather than as the Go syntax
+// of its type, which is how other tokens are described. A catalog of messages can instead look up
+// the names of tokens in a TokenSet.
+func FormatToken(tok any) string {
+	if s, ok := tok.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%#v", tok)
+}
+
+// Describe an error using a catalog of messages. Errors that the catalog has no message for, such as
+// those returned by rules, are described by their own Error method.
+func FormatError(err error, m Messages) string {
+	switch e := err.(type) {
+	case Diagnostic:
+		return m.Diagnostic(e, FormatError(e.Err, m))
+	case *ErrUnexpectedToken:
+		return m.UnexpectedToken(e)
+	case *ErrNoMatch:
+		return m.NoMatch(e)
+	case *ErrUnclosedBracket:
+		return m.UnclosedBracket(e, FormatError(e.Err, m))
+	case *RuleError:
+		return m.RuleError(e, FormatError(e.Err, m))
+	case *ErrVersion:
+		return m.Version(e)
+	case *ErrFeature:
+		return m.Feature(e)
+	case *SyntaxError:
+		return m.SyntaxError(e, FormatError(e.Err, m))
+	}
+	switch err {
+	case io.ErrUnexpectedEOF:
+		return m.UnexpectedEOF()
+	case ErrFailedMatch:
+		return m.FailedMatch()
+	}
+	return err.Error()
+}
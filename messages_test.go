@@ -0,0 +1,89 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+type frenchMessages struct {
+	EnglishMessages
+}
+
+func (frenchMessages) UnexpectedEOF() string {
+	return "fin de texte inattendue"
+}
+
+func (frenchMessages) UnexpectedToken(e *ErrUnexpectedToken) string {
+	return fmt.Sprintf("jeton inattendu : %#v", e.Token)
+}
+
+func (frenchMessages) UnclosedBracket(e *ErrUnclosedBracket, cause string) string {
+	return fmt.Sprintf("%s : %#v attendu pour fermer %#v", cause, e.Close, e.Open)
+}
+
+func (frenchMessages) Diagnostic(d Diagnostic, cause string) string {
+	return fmt.Sprintf("%s, ligne %d : %s", d.Path, d.Position.Line, cause)
+}
+
+func TestFormatError(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		err    error
+		french string
+	}{
+		{
+			name:   "EOF",
+			err:    io.ErrUnexpectedEOF,
+			french: "fin de texte inattendue",
+		},
+		{
+			name: "Diagnostic",
+			err: Diagnostic{
+				Path:     "a.json",
+				Position: Position{Line: 2, Column: 3},
+				Err: &ErrUnclosedBracket{
+					Open:  "[",
+					Close: "]",
+					Err:   &ErrUnexpectedToken{Token: "}", Index: 4},
+				},
+			},
+			french: `a.json, ligne 2 : jeton inattendu : "}" : "]" attendu pour fermer "["`,
+		},
+		{
+			// the messages that the catalog does not override are in English
+			name:   "Untranslated",
+			err:    &SyntaxError{Offset: 3, Err: &ErrNoMatch{Start: 3, Offset: 3, Rune: '!'}},
+			french: `offset 3: failed to match: no token begins with '!' at offset 3`,
+		},
+		{
+			// as are errors that no catalog has a message for
+			name:   "Rule",
+			err:    &RuleError{Label: "Items", Span: Span{Start: 1, End: 2}, Err: errors.New("too few")},
+			french: "Items at tokens 1 to 2: too few",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, FormatError(test.err, EnglishMessages{}), test.err.Error())
+			assert.Equal(t, FormatError(test.err, frenchMessages{}), test.french)
+		})
+	}
+}
+
+type shownToken struct{}
+
+func (shownToken) String() string {
+	return `","`
+}
+
+func TestFormatToken(t *testing.T) {
+	err := &ErrUnclosedBracket{
+		Open:  shownToken{},
+		Close: literal[int]{value: 1},
+		Err:   &ErrUnexpectedToken{Token: shownToken{}, Index: 3},
+	}
+	assert.Equal(t, err.Error(), `unexpected token: ",": expected tp.literal[int]{value:1} to close "," at token 0`)
+}
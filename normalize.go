@@ -0,0 +1,129 @@
+package tp
+
+import (
+	"bytes"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Normalizer converts text into a normal form. The forms provided by golang.org/x/text/unicode/norm,
+// such as norm.NFC, satisfy this interface.
+type Normalizer interface {
+	Bytes(b []byte) []byte
+}
+
+// Normalize the text of matches before it is given to token constructors, so that text that is
+// written differently but means the same thing, such as composed and decomposed accents, produces the
+// same tokens. Offsets continue to refer to the original text. Returns the stream for convenience.
+//
+// Only the text given to constructors is normalized. The machine still matches the original text,
+// so a pattern that is to match every form of a text must accept each of them: `caf\x{e9}`, with a
+// composed é, does not match a decomposed one. Where this is impractical, use TokenizeNormalized.
+//
+// Tokens are split where their patterns end, without regard to grapheme clusters, so a pattern that
+// does not accept combining marks ends its token before any that follow a base character.
+func (l *Stream[T]) Normalize(n Normalizer) *Stream[T] {
+	l.normalizer = n
+	return l
+}
+
+// As Tokenize, but the text is normalized before it is matched, so that text that is written
+// differently but means the same thing is matched by the same patterns and produces the same tokens.
+// Offsets, positions and the text returned by Match.Source refer to the original text, while the
+// text given to constructors is normalized.
+//
+// The text is normalized a piece at a time, each piece being a character and the combining marks
+// that follow it, so that where each piece came from is known. An offset within a piece that
+// normalizing changed refers to somewhere within the original piece.
+func (p *Lexer[T]) TokenizeNormalized(src []byte, n Normalizer) *Stream[T] {
+	norm := normalizeText(n, src)
+	s := p.Tokenize(norm.text)
+	s.norm = norm
+	return s
+}
+
+// Text normalized a piece at a time, along with where each piece came from.
+type normalizedText struct {
+	src, text []byte
+
+	// the offsets that each piece begins at in the text and in the source, followed by the ends of
+	// both
+	at, from []int
+}
+
+func normalizeText(n Normalizer, src []byte) *normalizedText {
+	res := &normalizedText{src: src}
+	start := 0
+	for line := range bytes.Lines(src) {
+		// most lines are already normalized, and need only be recorded as a single piece
+		if norm := n.Bytes(line); bytes.Equal(norm, line) {
+			res.add(start, norm)
+		} else {
+			for i := 0; i < len(line); {
+				j := i + pieceLen(line[i:])
+				res.add(start+i, n.Bytes(line[i:j]))
+				i = j
+			}
+		}
+		start += len(line)
+	}
+	res.at = append(res.at, len(res.text))
+	res.from = append(res.from, len(src))
+	return res
+}
+
+func (t *normalizedText) add(from int, text []byte) {
+	t.at = append(t.at, len(t.text))
+	t.from = append(t.from, from)
+	t.text = append(t.text, text...)
+}
+
+// Find the offset in the source that an offset in the normalized text came from.
+func (t *normalizedText) source(offset int) int {
+	i := sort.SearchInts(t.at, offset+1) - 1
+	if i == len(t.at)-1 {
+		return t.from[i]
+	}
+	return min(t.from[i]+offset-t.at[i], t.from[i+1])
+}
+
+// The offset in the original text of an offset in the text that is matched.
+func (l *Stream[T]) sourceOffset(offset int) int {
+	if l.norm == nil {
+		return offset
+	}
+	return l.norm.source(offset)
+}
+
+// Record the lines that begin in the text between two offsets in the text that is matched.
+func (l *Stream[T]) scanLines(start, end int) {
+	if l.norm == nil {
+		l.lines.scan(l.src[start-l.base:end-l.base], start)
+		return
+	}
+	start, end = l.norm.source(start), l.norm.source(end)
+	l.lines.scan(l.norm.src[start:end], start)
+}
+
+// The length of the character at the start of some text, along with the characters after it that
+// may combine with it when it is normalized.
+func pieceLen(text []byte) int {
+	_, n := utf8.DecodeRune(text)
+	for n < len(text) {
+		r, size := utf8.DecodeRune(text[n:])
+		if !combines(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// Whether a character may combine with the one before it: combining marks, and the vowels and
+// final consonants of Hangul syllables.
+func combines(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me) ||
+		r >= 0x1161 && r <= 0x1175 ||
+		r >= 0x11a8 && r <= 0x11c2
+}
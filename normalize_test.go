@@ -0,0 +1,112 @@
+package tp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+// composes the one decomposed character used in the tests
+type testNormalizer struct{}
+
+func (testNormalizer) Bytes(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte("é"), []byte("é"))
+}
+
+func TestNormalize(t *testing.T) {
+	p, err := NewLexer(
+		RegexMatch(`[^\s]+`, func(m Match) (Match, error) {
+			return m, nil
+		}),
+		RegexMatch(`\s+`, func(m Match) (Match, error) {
+			return m, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var in Interner
+	toks, err := p.Tokenize([]byte("café café")).
+		Normalize(testNormalizer{}).
+		Intern(&in).
+		Force()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, toks[0].Text(), toks[2].Text())
+	assert.Equal(t, toks[2].Start, 6)
+	assert.Equal(t, toks[2].End, 12)
+	assert.Equal(t, in.Len(), 1)
+}
+
+func TestNormalizeMatchesOriginal(t *testing.T) {
+	p, err := NewLexer(
+		Regex(`caf\x{e9}`, func(start int, text string) (string, error) {
+			return "word:" + text, nil
+		}),
+		Regex(`[a-z]+|.`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// the decomposed é is not matched by the pattern, and its accent is a token of its own
+	toks, err := p.Tokenize([]byte("caf\u00e9 cafe\u0301")).Normalize(testNormalizer{}).Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{"word:caf\u00e9", " ", "cafe", "\u0301"})
+}
+
+func TestTokenizeNormalized(t *testing.T) {
+	p, err := NewLexer(
+		RegexMatch(`caf\x{e9}`, func(m Match) (string, error) {
+			return fmt.Sprintf("word:%s@%d-%d:%v", m.Text(), m.Start, m.End, m.Position()), nil
+		}),
+		Skip[string](`[ \n]+`),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// the decomposed é is normalized before it is matched, and offsets refer to the original text
+	src := []byte("caf\u00e9 cafe\u0301\ncafe\u0301 ?")
+	s := p.TokenizeNormalized(src, testNormalizer{})
+	toks, err := s.Force()
+	assert.Equal(t, toks, []string{
+		"word:caf\u00e9@0-5:1:1",
+		"word:caf\u00e9@6-12:1:7",
+		"word:caf\u00e9@13-19:2:1",
+	})
+
+	var noMatch *ErrNoMatch
+	if assert.True(t, errors.As(err, &noMatch)) {
+		assert.Equal(t, noMatch.Offset, 20)
+		assert.Equal(t, s.Lines().Position(noMatch.Offset).String(), "2:8")
+	}
+}
+
+func TestNormalizedOffsets(t *testing.T) {
+	src := []byte("ae\u0301\u0301b\ncd")
+	norm := normalizeText(testNormalizer{}, src)
+	assert.Equal(t, string(norm.text), "a\u00e9\u0301b\ncd")
+
+	for _, test := range []struct{ text, source int }{
+		{0, 0},
+		{1, 1},
+		// within the normalized é and its extra accent
+		{2, 2},
+		{3, 3},
+		{5, 6},
+		{6, 7},
+		{7, 8},
+		{9, 10},
+	} {
+		assert.Equal(t, norm.source(test.text), test.source)
+	}
+}
@@ -0,0 +1,65 @@
+package tp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Origin records where a token came from. Tokens that are read from one text and then placed in
+// another, such as by including a file or expanding a macro, keep a chain of origins leading back to
+// the text that was being parsed, so that problems can be reported in terms that lead to the source.
+type Origin struct {
+	File     string
+	Position Position
+
+	// How the text that the token was read from came to be in its parent, e.g. "included" or
+	// "expanded", and the place that it was brought in at. The chain ends with an origin that has no
+	// parent.
+	How    string
+	Parent *Origin
+}
+
+// Originated is implemented by tokens that know their origin. Errors that report such tokens
+// describe where they came from.
+type Originated interface {
+	Origin() *Origin
+}
+
+// Describe the origin of text that has been brought in at another place, e.g. for a file included
+// at a directive, Via("included", directive). The receiver is not modified.
+func (o *Origin) Via(how string, parent *Origin) *Origin {
+	res := *o
+	if res.Parent != nil {
+		res.Parent = res.Parent.Via(how, parent)
+		return &res
+	}
+	res.How = how
+	res.Parent = parent
+	return &res
+}
+
+func (o *Origin) String() string {
+	return fmt.Sprintf("%s:%s", o.File, o.Position)
+}
+
+// Describe the whole chain of origins, beginning with this one and following on with one line per
+// parent, e.g.
+//
+//	defs.h:3:5
+//		included from main.c:1:1
+func (o *Origin) Trace() string {
+	var b strings.Builder
+	b.WriteString(o.String())
+	for p := o; p.Parent != nil; p = p.Parent {
+		fmt.Fprintf(&b, "\n\t%s from %s", p.How, p.Parent)
+	}
+	return b.String()
+}
+
+// Find the origin of a token, if it has one.
+func originOf(tok any) *Origin {
+	if o, ok := tok.(Originated); ok {
+		return o.Origin()
+	}
+	return nil
+}
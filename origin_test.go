@@ -0,0 +1,31 @@
+package tp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type sourcedTok struct {
+	origin *tp.Origin
+}
+
+func (t sourcedTok) Origin() *tp.Origin {
+	return t.origin
+}
+
+func TestOrigin(t *testing.T) {
+	include := &tp.Origin{File: "main.c", Position: tp.Position{Line: 1, Column: 1}}
+	expand := &tp.Origin{File: "defs.h", Position: tp.Position{Line: 2, Column: 9}}
+	macro := &tp.Origin{File: "defs.h", Position: tp.Position{Line: 1, Column: 16}}
+
+	// a token from a macro defined in defs.h, expanded later in defs.h, which is included by main.c
+	o := macro.Via("expanded", expand).Via("included", include)
+	assert.Equal(t, o.Trace(), "defs.h:1:16\n\texpanded from defs.h:2:9\n\tincluded from main.c:1:1")
+	assert.True(t, macro.Parent == nil)
+
+	_, err := tp.Parse(interfaceGrammar{}, []any{intTok{1}, sourcedTok{o}})
+	assert.True(t, strings.HasSuffix(err.Error(), " at "+o.Trace()))
+}
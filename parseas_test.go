@@ -0,0 +1,20 @@
+package tp_test
+
+import (
+	"fmt"
+
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleParseAs() {
+	toks := removeWhitespace(must(lexicon.Tokenize([]byte(`"qty": 5`)).Force()))
+	field, err := tp.ParseAs[jsonField](jsonGrammar{}, toks)
+	fmt.Printf("%#v %v\n", field, err)
+
+	_, err = tp.ParseAs[numberToken](jsonGrammar{}, toks)
+	fmt.Println(err)
+
+	// Output:
+	// tp_test.jsonField{name:"qty", value:5} <nil>
+	// tp_test.numberToken is not a nonterminal of the grammar
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"reflect"
 	"slices"
+	"strings"
 	"sync"
 )
 
@@ -16,10 +17,17 @@ var (
 
 type ErrUnexpectedToken struct {
 	Token any
+
+	// The index of the token in the input.
+	Index int
+
+	// The label of the innermost rule that was part way through matching when the token was found,
+	// if any such rule has a label.
+	While string
 }
 
 func (e *ErrUnexpectedToken) Error() string {
-	return fmt.Sprintf("unexpected token: %#v", e.Token)
+	return EnglishMessages{}.UnexpectedToken(e)
 }
 
 // A specification of a context-free grammar. These are grammars that are sufficiently expressive to
@@ -58,6 +66,51 @@ func (e *ErrUnexpectedToken) Error() string {
 // method is called once per type, and whatever it returns is treated as if it is part of the
 // grammar, which is to say that its public methods are also treated as rules. When combined with
 // Go's parametric types, this offers a flexible and powerful way to reuse syntax rules.
+//
+// The Grammar method is normally called on the zero value of its type. If the grammar has an
+// exported field of that type, then the method is called on the value of the field instead, so the
+// field can be used to configure the rules that the method furnishes. The method may return an
+// interface, in which case the rules are taken from the value that it holds, allowing the
+// configuration to decide which rules are used.
+//
+// A grammar may also have methods named BeforeParse and AfterParse, which are not rules. If the
+// grammar has a method BeforeParse(int), it is called with the number of tokens before each parse
+// begins. If it has a method AfterParse(U, error), it is called with the result of each parse,
+// whether or not it succeeded. These allow a grammar to set up state that its rules use during a
+// parse and to release it afterwards, rather than leaving it behind for the next parse.
+//
+// A rule may return an error as its second result, which stops the parse. The error is returned as
+// a RuleError, giving the rule and the tokens that it matched. If the error wraps ErrReject then
+// the parse continues instead, as if the rule did not match those tokens, and only fails if there
+// is no other way to parse the input. This allows a rule to check what the grammar cannot express,
+// such as whether a name has been declared. As the input is then parsed again, rules may be called
+// more than once for the same tokens.
+//
+// Where an input can be parsed in more than one way, the tree built is the one that uses the
+// preferred rules. By default, rules are preferred in order of their method names, so renaming a
+// rule can change the tree. A grammar, or a type furnished by a Grammar method, may instead have a
+// method RuleOrder() []string that names its rules from most to least preferred. This is not a
+// rule, and is called once per type of grammar. Rules that it does not name are least preferred,
+// and keep the order of their names. CheckRuleOrder reports where this differs from the default.
+// Similarly, a method Labels() map[string]string gives rules labels, such as "function
+// declaration", that are shown in place of their names in errors, derivations and EBNF.
+// Slices match as few items as they can, unless the grammar has a method Repetition() Repetition
+// that chooses otherwise. A method with any of these names, or named Versions or Features, is never
+// a rule, and makes the grammar invalid if its signature is not the one described.
+//
+// Any context-free grammar can be parsed, but not all of them quickly. A grammar that is
+// unambiguous, and whose recursion is on the left (as in List(xs List, x Item)), on the right (as
+// in List(x Item, xs List)) or enclosed in brackets, parses in time linear in the length of its
+// input. Slices and Delimited are written this way. A rule whose first symbol can end in many
+// places and is followed by symbols of unbounded length, such as a long chain of binary operators
+// in one expression, takes time quadratic in its length. Ambiguous grammars can take longer still.
+// ParseUnambiguous does not skip any work, and so parses right recursion in quadratic time.
+//
+// Rules should not modify the fields of the grammar, as the same grammar is often used for many
+// parses, possibly at the same time. Information that a rule needs from elsewhere in the parse is
+// better passed to it in the values that its arguments are built from, or kept in a grammar that
+// is created for each parse by NewParserFunc. Otherwise, when the race detector is enabled, a rule
+// that assigns to a field of the grammar causes a panic.
 type Grammar[T, U any] interface {
 	// Called on the parse tree, yielding the result of the parse. The argument type, T, indicates
 	// where matching should begin.
@@ -67,40 +120,188 @@ type Grammar[T, U any] interface {
 // Parse an input, given as a slice of tokens, using the set of rules described by the provided
 // grammar. If it fails to parse, it will return an error indicating the problem.
 func Parse[T, U, V any](g Grammar[U, V], toks []T) (V, error) {
-	var zero V
+	return parseHooked(g, toks, parseOptions{})
+}
 
-	tokVals := make([]reflect.Value, len(toks))
-	for i, t := range toks {
-		tokVals[i] = reflect.ValueOf(t)
+// Ways in which a parse can be modified.
+type parseOptions struct {
+	// if set, the rules are recorded here as they are applied
+	trace *[]Derivation
+
+	// if nonzero, check for ambiguity and describe up to this many of the ways that an ambiguous
+	// part of the input can be parsed
+	samples int
+
+	// set if the grammar was created for this parse, so its rules may modify it
+	ownHost bool
+}
+
+// Derivation describes the application of a rule during a parse.
+type Derivation struct {
+	// The name of the rule's method, and the type that it produced.
+	Rule, Produces string
+
+	// The rule's label, or its name if it does not have one.
+	Label string
+
+	// The tokens that the rule matched.
+	Span Span
+}
+
+// As Parse, but also return the derivation of the result: every rule that was applied to build it,
+// in the order that they were applied. A rule is applied after the rules that built its arguments.
+func ParseTrace[T, U, V any](g Grammar[U, V], toks []T) (V, []Derivation, error) {
+	var trace []Derivation
+	res, err := parseHooked(g, toks, parseOptions{trace: &trace})
+	if err != nil {
+		return res, nil, err
 	}
+	return res, trace, nil
+}
 
-	m := &matcher{
-		root:  scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]()),
-		state: make([][]item, min(1, len(tokVals)), len(tokVals)),
-		toks:  tokVals,
+func parseHooked[T, U, V any](g Grammar[U, V], toks []T, opts parseOptions) (V, error) {
+	if h, ok := g.(interface{ BeforeParse(int) }); ok {
+		h.BeforeParse(len(toks))
 	}
+	res, err := parse(g, toks, opts)
+	if h, ok := g.(interface{ AfterParse(V, error) }); ok {
+		h.AfterParse(res, err)
+	}
+	return res, err
+}
 
-	if err := m.run(); err != nil {
+func parse[T, U, V any](g Grammar[U, V], toks []T, opts parseOptions) (V, error) {
+	var zero V
+
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	rv, err := parseSymbol(g, gr, gr.root, toks, opts)
+	if err != nil {
 		return zero, err
 	}
 
-	rv, err := m.builder().build()
+	return g.Parse(rv.Interface().(U))
+}
+
+// Parse an input as a particular nonterminal of a grammar, N, rather than as the one that the
+// grammar's Parse method accepts. The value built for N is returned, without being passed to Parse.
+// This is intended for testing the rules for part of a language in isolation.
+//
+// BeforeParse and AfterParse are called as with Parse, with AfterParse given the zero value of V.
+func ParseAs[N, T, U, V any](g Grammar[U, V], toks []T) (N, error) {
+	if h, ok := g.(interface{ BeforeParse(int) }); ok {
+		h.BeforeParse(len(toks))
+	}
+	res, err := parseAs[N](g, toks)
+	if h, ok := g.(interface{ AfterParse(V, error) }); ok {
+		var zero V
+		h.AfterParse(zero, err)
+	}
+	return res, err
+}
+
+func parseAs[N, T, U, V any](g Grammar[U, V], toks []T) (N, error) {
+	var zero N
+
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	root, ok := gr.symbols[reflect.TypeFor[N]()]
+	if !ok || len(root.Predictions) == 0 {
+		return zero, fmt.Errorf("%s is not a nonterminal of the grammar", reflect.TypeFor[N]())
+	}
+
+	rv, err := parseSymbol(g, gr, root, toks, parseOptions{})
 	if err != nil {
 		return zero, err
 	}
 
-	return g.Parse(rv.Interface().(U))
+	return rv.Interface().(N), nil
+}
+
+// The types of the symbols of a grammar, i.e. those that its rules produce and the tokens that they
+// consume, in order of name. This allows tools that work with the values that a grammar produces to
+// be built outside of this package.
+func SymbolTypes[U, V any](g Grammar[U, V]) []reflect.Type {
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	res := make([]reflect.Type, 0, len(gr.symbols))
+	for t := range gr.symbols {
+		res = append(res, t)
+	}
+	slices.SortFunc(res, func(a, b reflect.Type) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return res
+}
+
+func tokenValues[T any](toks []T) []reflect.Value {
+	res := make([]reflect.Value, len(toks))
+	for i, t := range toks {
+		res[i] = reflect.ValueOf(t)
+	}
+	return res
+}
+
+// Parse the tokens as the given symbol of a grammar, and build the value that they describe.
+func parseSymbol[T any](g any, gr *grammar, root *symbol, toks []T, opts parseOptions) (reflect.Value, error) {
+	// the search for ambiguity needs every item
+	m := newMatcher(gr, root, tokenValues(toks), opts.samples == 0)
+	if err := m.run(); err != nil {
+		return reflect.Value{}, err
+	}
+
+	b := m.builder(reflect.ValueOf(g))
+	b.trace = opts.trace
+	b.ownHost = opts.ownHost
+	excluded, err := ruleExclusions(g, gr)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	b.excluded = excluded
+	if opts.samples > 0 {
+		if err := b.ambiguity(opts.samples); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return b.build()
 }
 
 type symbol struct {
+	// the type that this symbol describes
+	Type reflect.Type
+
 	// this symbol can be empty
 	Nullable bool
 
+	// this symbol can be empty, but only where the next token allows it
+	Conditional bool
+
+	// if this is a NotFollowedBy restriction, the type of token that must not follow
+	NotFollowedBy reflect.Type
+
+	// if this is a layout constraint, such as SameLine
+	Layout layout
+
 	// if this is a token rule
 	TokenType reflect.Type
 
 	// if this is a nonterminal rule
 	Predictions []*rule
+
+	// if every rule for this symbol matches a single token, the rules by the type of that token
+	Choices map[reflect.Type][]*rule
+
+	// identifies the symbol within its grammar
+	ID int
+
+	// the least and greatest number of tokens that the symbol can match
+	MinLen, MaxLen int
+
+	// the distinct Index values of the symbol's rules, in order
+	Indexes []int
+
+	// the symbols whose rules are predicted along with this one's, including this one
+	Closure []*symbol
+
+	// if this is a slice that matches as many items as it can, see Repetition
+	Greedy bool
 }
 
 type rule struct {
@@ -110,15 +311,26 @@ type rule struct {
 	// array of symbols to match
 	Deps []*symbol
 
-	// reusable grammars imply multiple hosts
+	// layout constraints on the tokens that the rule matches
+	Layout []layout
+
+	// reusable grammars imply multiple hosts, while rules on the grammar passed to Parse have no
+	// host recorded, as the scan is shared between every value of the grammar's type
 	Host reflect.Value
 
-	// debug: the rule's method Name
-	Name string
+	// debug: the rule's method Name, and the type that it produces
+	Name     string
+	Produces reflect.Type
 
-	// Index of method into host
+	// the rule's label, if its host's Labels method gives it one
+	Label string
+
+	// preference of the rule over the other rules of its host, where lower is preferred
 	Index int
 
+	// the tokens that the rule can begin with
+	First firstSet
+
 	// function to call when building the parse tree
 	Method func(args []reflect.Value) []reflect.Value
 }
@@ -127,16 +339,39 @@ type scanner struct {
 	host     reflect.Value
 	rootType reflect.Type
 	types    map[reflect.Type]*symbol
+	brackets []bracketPair
 }
 
-var cache = map[reflect.Type]*symbol{}
+// The result of scanning a grammar.
+type grammar struct {
+	root *symbol
+
+	// every symbol in the grammar, by the type that it describes
+	symbols map[reflect.Type]*symbol
+
+	// pairs of tokens that enclose part of the input, found from uses of Enclosed
+	brackets []bracketPair
+}
+
+// Grammars are scanned once for each type, and configuration, of grammar.
+type cacheKey struct {
+	host   reflect.Type
+	config string
+}
+
+var cache = map[cacheKey]*grammar{}
 var lock sync.Mutex
 
-func scanGrammar(ruleSet reflect.Value, rootType reflect.Type) *symbol {
+func scanGrammar(ruleSet reflect.Value, rootType reflect.Type) *grammar {
+	if r, ok := ruleSet.Interface().(interface{ funcRules() *funcRules }); ok {
+		return r.funcRules().scan(ruleSet, rootType)
+	}
+
 	lock.Lock()
 	defer lock.Unlock()
 
-	if p, ok := cache[ruleSet.Type()]; ok {
+	key := cacheKey{host: ruleSet.Type(), config: grammarConfig(ruleSet)}
+	if p, ok := cache[key]; ok {
 		return p
 	}
 
@@ -146,55 +381,133 @@ func scanGrammar(ruleSet reflect.Value, rootType reflect.Type) *symbol {
 		types:    map[reflect.Type]*symbol{},
 	}
 
-	root := s.scan()
-	cache[ruleSet.Type()] = root
-	return root
+	g := s.scan()
+	cache[key] = g
+	return g
 }
 
-func (s *scanner) scan() *symbol {
+// Find the fields of a grammar that configure the grammars of other types, i.e. those of types with
+// a Grammar method.
+func configFields(host reflect.Value) []reflect.Value {
+	if host.Kind() == reflect.Pointer {
+		if host.IsNil() {
+			return nil
+		}
+		host = host.Elem()
+	}
+	if host.Kind() != reflect.Struct {
+		return nil
+	}
+	var res []reflect.Value
+	for i := range host.NumField() {
+		f := host.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if _, ok := f.Type.MethodByName("Grammar"); !ok {
+			continue
+		}
+		res = append(res, host.Field(i))
+	}
+	return res
+}
+
+// Describe the configuration of a grammar, so that grammars of the same type but with different
+// configurations are scanned separately.
+func grammarConfig(host reflect.Value) string {
+	var b strings.Builder
+	for _, f := range configFields(host) {
+		fmt.Fprintf(&b, "%#v;", f.Interface())
+	}
+	return b.String()
+}
+
+func (s *scanner) scan() *grammar {
 	s.ensure(s.rootType)
-	s.scanMethods(s.host)
+	s.scanMethods(s.host.Type(), reflect.Value{})
+	return s.finish()
+}
+
+// Work out the properties of the symbols once all of the rules have been found.
+func (s *scanner) finish() *grammar {
 	s.markNullableTypes()
 	s.fillOutInterfaces()
+	s.markConditionalTypes()
 	s.markTokenTypes()
-
-	return s.types[s.rootType]
+	s.markChoices()
+	s.markFirstSets()
+	s.markRepetition()
+	s.markClosures()
+	s.markLengths()
+
+	return &grammar{
+		root:     s.types[s.rootType],
+		symbols:  s.types,
+		brackets: s.brackets,
+	}
 }
 
-func (s *scanner) scanMethods(host reflect.Value) {
-	hostType := host.Type()
+func (s *scanner) scanMethods(hostType reflect.Type, host reflect.Value) {
+	orderHost := host
+	if !orderHost.IsValid() {
+		orderHost = s.host
+	}
+	if err := checkConfigMethods(hostType); err != nil {
+		panic(err)
+	}
+	order := ruleOrder(hostType, orderHost)
+	labels := ruleLabels(hostType, orderHost)
 	for i := hostType.NumMethod() - 1; i >= 0; i-- {
 		m := hostType.Method(i)
-		if m.Name == "Parse" {
+		switch m.Name {
+		case "Parse", "BeforeParse", "AfterParse", "RuleOrder", "Repetition", "Labels", "Versions", "Features":
 			continue
 		}
 		if !m.IsExported() {
 			continue
 		}
-		deps := make([]*symbol, m.Type.NumIn()-1)
-		for i := m.Type.NumIn() - 1; i >= 1; i-- {
-			deps[i-1] = s.ensure(m.Type.In(i))
-		}
-		if m.Type.Out(0).Kind() == reflect.Slice {
-			panic("explicit slice rules are not supported")
-		}
-		produces := s.ensure(m.Type.Out(0))
-		produces.Predictions = append(produces.Predictions, &rule{
-			Implements: produces,
-			Deps:       deps,
-			Host:       host,
-			Name:       m.Name,
-			Index:      m.Index,
-			Method: func(args []reflect.Value) []reflect.Value {
-				return m.Func.Call(args)
-			},
+		r := s.addRule(m.Name, m.Type, 1, host, ruleIndex(order, m), func(args []reflect.Value) []reflect.Value {
+			return m.Func.Call(args)
 		})
+		r.Label = labels[m.Name]
+	}
+}
+
+// Add a rule described by the type of a function. Its arguments, from the first given, are what the
+// rule matches and its result is what the rule produces.
+func (s *scanner) addRule(name string, ft reflect.Type, first int, host reflect.Value, index int, call func(args []reflect.Value) []reflect.Value) *rule {
+	if err := checkRuleSignature(name, ft); err != nil {
+		panic(err)
+	}
+	deps := make([]*symbol, ft.NumIn()-first)
+	var layouts []layout
+	for i := ft.NumIn() - 1; i >= first; i-- {
+		deps[i-first] = s.ensure(ft.In(i))
+		if l := deps[i-first].Layout; l != nil {
+			layouts = append(layouts, l)
+		}
 	}
+	if ft.Out(0).Kind() == reflect.Slice {
+		panic("explicit slice rules are not supported")
+	}
+	produces := s.ensure(ft.Out(0))
+	r := &rule{
+		Implements: produces,
+		Deps:       deps,
+		Layout:     layouts,
+		Host:       host,
+		Name:       name,
+		Produces:   ft.Out(0),
+		Index:      index,
+		Method:     call,
+	}
+	produces.Predictions = append(produces.Predictions, r)
+	return r
 }
 
 func (s *scanner) markTokenTypes() {
 	for k, v := range s.types {
-		if len(v.Predictions) == 0 {
+		if len(v.Predictions) == 0 && v.NotFollowedBy == nil && v.Layout == nil {
 			v.TokenType = k
 			continue
 		}
@@ -206,6 +519,10 @@ func (s *scanner) markNullableTypes() {
 	symUsers := map[*symbol][]*rule{}
 
 	for _, sym := range s.types {
+		if sym.Layout != nil {
+			sym.Nullable = true
+			needsWork.Enqueue(sym)
+		}
 		for _, r := range sym.Predictions {
 			for _, s := range r.Deps {
 				symUsers[s] = append(symUsers[s], r)
@@ -234,6 +551,35 @@ func (s *scanner) markNullableTypes() {
 	}
 }
 
+// Find the symbols that can only be empty where the next token allows it, because they contain a
+// NotFollowedBy restriction.
+func (s *scanner) markConditionalTypes() {
+	for changed := true; changed; {
+		changed = false
+		for _, sym := range s.types {
+			if sym.Nullable || sym.Conditional {
+				continue
+			}
+			if sym.NotFollowedBy != nil {
+				sym.Conditional = true
+				changed = true
+				continue
+			}
+		nextRule:
+			for _, r := range sym.Predictions {
+				for _, d := range r.Deps {
+					if !d.Nullable && !d.Conditional {
+						continue nextRule
+					}
+				}
+				sym.Conditional = true
+				changed = true
+				break
+			}
+		}
+	}
+}
+
 func (s *scanner) fillOutInterfaces() {
 	var itfs []reflect.Type
 	for k := range s.types {
@@ -268,6 +614,8 @@ func (s *scanner) fillOutInterface(itfs *[]reflect.Type, todo reflect.Type) {
 				Deps:       r.Deps,
 				Host:       r.Host,
 				Name:       r.Name,
+				Label:      r.Label,
+				Produces:   r.Produces,
 				Index:      r.Index,
 				Method:     r.Method,
 			})
@@ -293,27 +641,48 @@ func (s *scanner) ensure(key reflect.Type) *symbol {
 	if v, ok := s.types[key]; ok {
 		return v
 	}
-	v := new(symbol)
+	v := &symbol{Type: key}
 	s.types[key] = v
 	if key.Kind() == reflect.Slice {
 		s.sliceTypeSymbol(v, key)
+	} else if key.Implements(lookaheadType) {
+		v.NotFollowedBy = reflect.Zero(key).Interface().(lookahead).notFollowedBy()
+	} else if key.Implements(layoutType) {
+		v.Layout = reflect.Zero(key).Interface().(layout)
 	} else if m, ok := key.MethodByName("Grammar"); ok {
 		host := m.Func.Call([]reflect.Value{
-			reflect.New(key).Elem(),
+			s.configFor(key),
 		})[0]
-		s.scanMethods(host)
+		if host.Kind() == reflect.Interface {
+			host = host.Elem()
+		}
+		if b, ok := host.Interface().(bracketed); ok {
+			s.brackets = append(s.brackets, b.brackets())
+		}
+		s.scanMethods(host.Type(), host)
 	}
 	return v
 }
 
+// Find the value to call a type's Grammar method on. This is taken from a field of that type on the
+// grammar being scanned if there is one, otherwise it is the zero value.
+func (s *scanner) configFor(key reflect.Type) reflect.Value {
+	for _, f := range configFields(s.host) {
+		if f.Type() == key {
+			return f
+		}
+	}
+	return reflect.New(key).Elem()
+}
+
 func (s *scanner) sliceTypeSymbol(sliceSym *symbol, slice reflect.Type) {
 	elem := slice.Elem()
 	elemSym := s.ensure(elem)
 	sliceSym.Predictions = append(sliceSym.Predictions, &rule{
 		Implements: sliceSym,
 		Deps:       []*symbol{},
-		Host:       s.host,
 		Name:       fmt.Sprintf("[]%s(nil)", elem),
+		Produces:   slice,
 		Index:      -1,
 		Method: func(args []reflect.Value) []reflect.Value {
 			res := reflect.MakeSlice(slice, 0, 0)
@@ -323,8 +692,8 @@ func (s *scanner) sliceTypeSymbol(sliceSym *symbol, slice reflect.Type) {
 	sliceSym.Predictions = append(sliceSym.Predictions, &rule{
 		Implements: sliceSym,
 		Deps:       []*symbol{sliceSym, elemSym},
-		Host:       s.host,
 		Name:       fmt.Sprintf("[]%s(append)", elem),
+		Produces:   slice,
 		Index:      -1,
 		Method: func(args []reflect.Value) []reflect.Value {
 			res := reflect.Append(args[1], args[2])
@@ -334,10 +703,49 @@ func (s *scanner) sliceTypeSymbol(sliceSym *symbol, slice reflect.Type) {
 }
 
 type matcher struct {
-	root  *symbol
-	state [][]item
-	toks  []reflect.Value
-	cur   int
+	root     *symbol
+	brackets []bracketPair
+	state    [][]item
+	toks     []reflect.Value
+	cur      int
+
+	// the index of the token that the parse failed at
+	failedAt int
+
+	// for each symbol, by ID, one more than the last position at which it was predicted
+	predicted []int
+
+	// the items in the current and next state sets, by the parity of their position, once the sets
+	// are too large to search
+	members [2]map[item]bool
+
+	// whether to skip over chains of items completed by right recursion, and what was skipped
+	leo      bool
+	leoMemo  map[leoKey]leoResult
+	leoLinks map[leoKey][]leoLink
+
+	// the items of each finished state set, by the symbol that they await, once they have been
+	// searched often enough to be worth indexing
+	awaiting []awaitIndex
+
+	// the number of items that have been added to state sets or looked at while searching them,
+	// which grows as the time taken to match does
+	work int
+}
+
+func newMatcher(gr *grammar, root *symbol, toks []reflect.Value, leo bool) *matcher {
+	return &matcher{
+		root:     root,
+		brackets: gr.brackets,
+		state:    make([][]item, min(1, len(toks)), len(toks)),
+		toks:     toks,
+		leo:      leo,
+	}
+}
+
+type awaitIndex struct {
+	searches int
+	items    map[*symbol][]item
 }
 
 type item struct {
@@ -353,18 +761,31 @@ type item struct {
 
 func (p *matcher) run() error {
 	p.state = [][]item{nil}
-	p.predict(p.root)
+	p.leoMemo = map[leoKey]leoResult{}
+	p.leoLinks = map[leoKey][]leoLink{}
+	p.awaiting = make([]awaitIndex, len(p.toks)+1)
+	if len(p.toks) == 0 {
+		p.predictEmpty(p.root)
+	} else {
+		_, isHole := holeValue(p.toks[0])
+		p.predictFor(p.root, p.toks[0], isHole)
+	}
 	for _, t := range p.toks {
 		p.state = append(p.state, nil)
 
 		p.step(t)
 		p.cur++
+		clear(p.members[(p.cur+1)%2])
 	}
 	p.finalStep()
-	return p.matches(p.root)
+	if err := p.matches(p.root); err != nil {
+		return p.bracketError(err)
+	}
+	return nil
 }
 
 func (p *matcher) step(tok reflect.Value) {
+	hole, isHole := holeValue(tok)
 	for i := 0; i < len(p.state[p.cur]); i++ {
 		item := p.state[p.cur][i]
 		next, ok := item.nextSymbol()
@@ -372,16 +793,32 @@ func (p *matcher) step(tok reflect.Value) {
 			p.complete(item)
 			continue
 		}
+		if isHole && hole.Type().AssignableTo(next.valueType()) {
+			p.scan(item)
+		}
 		if next.TokenType != nil {
 			if tok.Type().AssignableTo(next.TokenType) {
 				p.scan(item)
 			}
 			continue
 		}
+		if next.NotFollowedBy != nil {
+			if !tok.Type().AssignableTo(next.NotFollowedBy) {
+				p.advance(item)
+			}
+			continue
+		}
+		if next.Choices != nil && !isHole {
+			p.scanChoice(next, tok)
+			continue
+		}
 		if next.Nullable {
 			p.advance(item)
 		}
-		p.predict(next)
+		p.predictFor(next, tok, isHole)
+		if next.Conditional {
+			p.advanceEmpty(item, next)
+		}
 	}
 }
 
@@ -393,9 +830,17 @@ func (p *matcher) finalStep() {
 			p.complete(item)
 			continue
 		}
+		if next.NotFollowedBy != nil {
+			p.advance(item)
+			continue
+		}
 		if next.Nullable {
 			p.advance(item)
-			p.predict(next)
+			p.predictEmpty(next)
+		}
+		if next.Conditional {
+			p.predictEmpty(next)
+			p.advanceEmpty(item, next)
 		}
 	}
 }
@@ -406,8 +851,11 @@ func (p *matcher) matches(root *symbol) error {
 			if len(p.state[i+1]) != 0 {
 				continue
 			}
+			p.failedAt = i
 			return &ErrUnexpectedToken{
-				p.toks[i].Interface(),
+				Token: p.toks[i].Interface(),
+				Index: i,
+				While: p.labelAt(i),
 			}
 		}
 	}
@@ -423,28 +871,46 @@ func (p *matcher) matches(root *symbol) error {
 		}
 		return nil
 	}
+	p.failedAt = len(p.toks)
 	return io.ErrUnexpectedEOF
 }
 
-func (p *matcher) predict(s *symbol) {
-	for _, prediction := range s.Predictions {
-		p.addToCur(item{
-			rule:     prediction,
-			position: p.cur,
-		})
-	}
-}
-
 func (p *matcher) advance(x item) {
 	p.addToCur(x.makeProgress())
 }
 
+// Advance past a symbol if it has already been found to be empty at the current position. Symbols
+// that are always empty are advanced past without looking, but those that are only sometimes empty
+// may have been completed before the item was added, in which case completion would not see it.
+func (p *matcher) advanceEmpty(x item, next *symbol) {
+	for _, y := range p.state[p.cur] {
+		p.work++
+		if y.rule.Implements == next && y.position == p.cur && y.complete() {
+			p.advance(x)
+			return
+		}
+	}
+}
+
 func (p *matcher) scan(x item) {
 	p.addToNext(x.makeProgress())
 }
 
 func (p *matcher) complete(x item) {
+	if p.leo && x.position < p.cur {
+		if top, ok := p.leoItem(x.position, x.rule.Implements); ok {
+			p.addToCur(top)
+			return
+		}
+	}
+	if found, ok := p.indexedAwaiting(x.position, x.rule.Implements); ok {
+		for _, y := range found {
+			p.addToCur(y.makeProgress())
+		}
+		return
+	}
 	for _, y := range p.state[x.position] {
+		p.work++
 		next, ok := y.nextSymbol()
 		if !ok {
 			continue
@@ -455,6 +921,32 @@ func (p *matcher) complete(x item) {
 	}
 }
 
+// Finished state sets are searched this many times before they are indexed.
+const indexAfter = 8
+
+// The items in a state set that await a symbol, if the set has been indexed. A finished set that is
+// large and often searched is indexed, as it will not change.
+func (p *matcher) indexedAwaiting(at int, sym *symbol) ([]item, bool) {
+	index := &p.awaiting[at]
+	if index.items == nil {
+		if at == p.cur || len(p.state[at]) < smallSet {
+			return nil, false
+		}
+		index.searches++
+		if index.searches <= indexAfter {
+			return nil, false
+		}
+		index.items = map[*symbol][]item{}
+		for _, y := range p.state[at] {
+			p.work++
+			if next, ok := y.nextSymbol(); ok {
+				index.items[next] = append(index.items[next], y)
+			}
+		}
+	}
+	return index.items[sym], true
+}
+
 func (p *matcher) addToCur(x item) {
 	p.addTo(p.cur, x)
 }
@@ -463,12 +955,42 @@ func (p *matcher) addToNext(x item) {
 	p.addTo(p.cur+1, x)
 }
 
+// State sets up to this size are searched for duplicates, rather than keeping a map of their items.
+const smallSet = 16
+
 func (p *matcher) addTo(pos int, x item) {
-	if !slices.Contains(p.state[pos], x) {
-		p.state[pos] = append(p.state[pos], x)
+	p.work++
+	set := p.state[pos]
+	if len(set) < smallSet {
+		if !slices.Contains(set, x) {
+			p.state[pos] = append(set, x)
+		}
+		return
+	}
+	members := p.members[pos%2]
+	if members == nil {
+		members = map[item]bool{}
+		p.members[pos%2] = members
+	}
+	if len(members) == 0 {
+		for _, y := range set {
+			members[y] = true
+		}
+	}
+	if !members[x] {
+		members[x] = true
+		p.state[pos] = append(set, x)
 	}
 }
 
+// Add an item that is known not to be in a state set.
+func (p *matcher) appendTo(pos int, x item) {
+	p.work++
+	if members := p.members[pos%2]; len(members) != 0 {
+		members[x] = true
+	}
+	p.state[pos] = append(p.state[pos], x)
+}
 func (x item) complete() bool {
 	_, ok := x.nextSymbol()
 	return !ok
@@ -490,9 +1012,32 @@ func (x item) makeProgress() item {
 }
 
 type builder struct {
-	root  *symbol
+	host reflect.Value
+	root *symbol
+	seen []reflect.Value
+
+	// the completed items at each position, with their positions flipped to be where they end,
+	// grouped by the symbol that they implement and in order of preference within each group
 	state [][]item
-	seen  []reflect.Value
+
+	// if set, the rules are recorded here as they are applied
+	trace *[]Derivation
+
+	// set if the host was created for this parse, so its rules may modify it
+	ownHost bool
+
+	// the rules that have rejected the tokens that they matched
+	rejected map[spanKey]bool
+
+	// the rules that are left out of the parse, and the errors that using them would lead to
+	excluded map[*rule]func(Span) error
+
+	// the index of the first token within the whole input, where only part of it is being parsed
+	offset int
+
+	// the links that the matcher skipped over, and the ends found by following them
+	leoLinks map[leoKey][]leoLink
+	endsMemo map[endsKey][]int
 }
 
 type span struct {
@@ -502,10 +1047,13 @@ type span struct {
 	children []span
 }
 
-func (p *matcher) builder() *builder {
+func (p *matcher) builder(host reflect.Value) *builder {
 	flipped := p.flipState()
 	for _, s := range flipped {
 		slices.SortFunc(s, func(a, b item) int {
+			if a.rule.Implements != b.rule.Implements {
+				return a.rule.Implements.ID - b.rule.Implements.ID
+			}
 			if a.rule.Index == b.rule.Index {
 				return a.position - b.position
 			}
@@ -513,12 +1061,26 @@ func (p *matcher) builder() *builder {
 		})
 	}
 	return &builder{
-		root:  p.root,
-		state: flipped,
-		seen:  p.toks,
+		host:     host,
+		root:     p.root,
+		state:    flipped,
+		seen:     p.toks,
+		leoLinks: p.leoLinks,
+		endsMemo: map[endsKey][]int{},
 	}
 }
 
+// The completed items for a symbol that begin at a position.
+func (b *builder) completed(at int, sym *symbol) []item {
+	set := b.state[at]
+	byID := func(x item, id int) int {
+		return x.rule.Implements.ID - id
+	}
+	start, _ := slices.BinarySearchFunc(set, sym.ID, byID)
+	end, _ := slices.BinarySearchFunc(set, sym.ID+1, byID)
+	return set[start:end]
+}
+
 func (p *matcher) flipState() [][]item {
 	flipped := make([][]item, len(p.state))
 	for i, set := range p.state {
@@ -536,17 +1098,14 @@ func (p *matcher) flipState() [][]item {
 	return flipped
 }
 
-func (b *builder) build() (reflect.Value, error) {
-	for _, top := range b.state[0] {
-		if top.rule.Implements != b.root {
-			continue
-		}
+func (b *builder) buildTree() (reflect.Value, error) {
+	for _, top := range b.completed(0, b.root) {
 		if top.position != len(b.seen) {
 			continue
 		}
 		span, ok := b.findSpan(top, 0)
 		if !ok {
-			return reflect.Value{}, ErrFailedMatch
+			continue
 		}
 		return b.buildFromSpan(span)
 	}
@@ -554,6 +1113,12 @@ func (b *builder) build() (reflect.Value, error) {
 }
 
 func (b *builder) findSpan(x item, at int) (span, bool) {
+	if b.rejected[spanKey{x.rule, at, x.position}] || b.excluded[x.rule] != nil {
+		return span{}, false
+	}
+	if !b.layoutAllows(x.rule, at, x.position) {
+		return span{}, false
+	}
 	children, ok := b.findSpanChildren(x.rule.Deps, at, x.position)
 	if !ok {
 		return span{}, false
@@ -572,6 +1137,9 @@ func (b *builder) buildFromSpan(s span) (reflect.Value, error) {
 	r := s.item.rule
 	args := make([]reflect.Value, len(s.children)+1)
 	args[0] = r.Host
+	if !args[0].IsValid() {
+		args[0] = b.host
+	}
 	for i, c := range s.children {
 		child, err := b.buildFromSpan(c)
 		if err != nil {
@@ -580,13 +1148,47 @@ func (b *builder) buildFromSpan(s span) (reflect.Value, error) {
 		args[i+1] = child
 	}
 
-	rets := r.Method(args)
+	var rets []reflect.Value
+	if b.ownHost && !r.Host.IsValid() {
+		rets = r.Method(args)
+	} else {
+		rets = callRule(r, args)
+	}
 	if len(rets) == 2 && !rets[1].IsNil() {
-		return reflect.Value{}, rets[1].Interface().(error)
+		err := &RuleError{
+			Rule:  r.Name,
+			Label: r.label(),
+			Span:  b.span(s),
+			Err:   rets[1].Interface().(error),
+		}
+		if errors.Is(err.Err, ErrReject) {
+			if b.rejected == nil {
+				b.rejected = map[spanKey]bool{}
+			}
+			b.rejected[spanKey{r, s.at, s.item.position}] = true
+		}
+		return reflect.Value{}, err
+	}
+	if b.trace != nil {
+		*b.trace = append(*b.trace, Derivation{
+			Rule:     r.Name,
+			Label:    r.label(),
+			Produces: r.Produces.String(),
+			Span:     b.span(s),
+		})
+	}
+	if rets[0].Type().Implements(spannedType) {
+		sp := rets[0].Interface().(spanned).withSpan(b.span(s))
+		return reflect.ValueOf(sp), nil
 	}
 	return rets[0], nil
 }
 
+// The tokens that a span covers, counted from the beginning of the whole input.
+func (b *builder) span(s span) Span {
+	return Span{Start: b.offset + s.at, End: b.offset + s.item.position}
+}
+
 func (b *builder) findSpanChildren(deps []*symbol, at, end int) ([]span, bool) {
 	if len(deps) == 0 {
 		return nil, at == end
@@ -594,15 +1196,22 @@ func (b *builder) findSpanChildren(deps []*symbol, at, end int) ([]span, bool) {
 	if deps[0].TokenType != nil {
 		return b.tokenSpan(deps, at, end)
 	}
+	if deps[0].NotFollowedBy != nil {
+		return b.lookaheadSpan(deps, at, end)
+	}
+	if deps[0].Layout != nil {
+		return b.layoutSpan(deps, at, end)
+	}
+	if spans, ok := b.holeSpan(deps, at, end); ok {
+		return spans, true
+	}
 	return b.ruleSpan(deps, at, end)
 }
 
 func (b *builder) ruleSpan(deps []*symbol, at, end int) ([]span, bool) {
 	sym := deps[0]
-	for _, found := range b.state[at] {
-		if found.rule.Implements != sym {
-			continue
-		}
+	lo, hi := endRange(deps[1:], at, end)
+	for found := range b.candidates(at, sym, lo, hi) {
 		next, ok := b.findSpanChildren(deps[1:], found.position, end)
 		if !ok {
 			continue
@@ -617,18 +1226,47 @@ func (b *builder) ruleSpan(deps []*symbol, at, end int) ([]span, bool) {
 }
 
 func (b *builder) tokenSpan(deps []*symbol, at, end int) ([]span, bool) {
-	sym := deps[0]
-	if at >= len(b.seen) {
+	value, ok := b.tokenAt(deps[0], at)
+	if !ok {
 		return nil, false
 	}
+	next, ok := b.findSpanChildren(deps[1:], at+1, end)
+	if !ok {
+		return nil, false
+	}
+	return append([]span{{
+		value: value,
+		at:    at,
+	}}, next...), true
+}
+
+// Find the value of the token at a position, if it can be used as the given token symbol.
+func (b *builder) tokenAt(sym *symbol, at int) (reflect.Value, bool) {
+	if at >= len(b.seen) {
+		return reflect.Value{}, false
+	}
 	if b.seen[at].Type().AssignableTo(sym.TokenType) {
-		next, ok := b.findSpanChildren(deps[1:], at+1, end)
-		if ok {
-			return append([]span{{
-				value: b.seen[at],
-				at:    at,
-			}}, next...), true
-		}
+		return b.seen[at], true
 	}
-	return nil, false
+	return b.holeAt(sym, at)
+}
+
+func (b *builder) lookaheadSpan(deps []*symbol, at, end int) ([]span, bool) {
+	sym := deps[0]
+	if !b.allows(sym, at) {
+		return nil, false
+	}
+	next, ok := b.findSpanChildren(deps[1:], at, end)
+	if !ok {
+		return nil, false
+	}
+	return append([]span{{
+		value: reflect.Zero(sym.Type),
+		at:    at,
+	}}, next...), true
+}
+
+// Whether a NotFollowedBy restriction allows the token at a position.
+func (b *builder) allows(sym *symbol, at int) bool {
+	return at == len(b.seen) || !b.seen[at].Type().AssignableTo(sym.NotFollowedBy)
 }
@@ -1,6 +1,10 @@
 package tp
 
 import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/bobappleyard/assert"
@@ -79,7 +83,7 @@ func TestNullableGrammarFail(t *testing.T) {
 	}
 
 	_, err := Parse(nullableRuleset{}, toks)
-	assert.Equal(t, *(err.(*ErrUnexpectedToken)), ErrUnexpectedToken{Token: plusTok{}})
+	assert.Equal(t, *(err.(*ErrUnexpectedToken)), ErrUnexpectedToken{Token: plusTok{}, Index: 1})
 }
 
 type nullableRightRuleset struct {
@@ -180,3 +184,165 @@ func TestOptionalSuffix(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, intList{[]int{1}}, expr)
 }
+
+type hookedRuleset struct {
+	scale  int
+	seen   map[int]int
+	events []string
+}
+
+func (r *hookedRuleset) BeforeParse(n int) {
+	r.seen = map[int]int{}
+	r.events = append(r.events, fmt.Sprintf("before %d", n))
+}
+
+func (r *hookedRuleset) AfterParse(x intList, err error) {
+	r.seen = nil
+	r.events = append(r.events, fmt.Sprintf("after %v %v", x.vals, err))
+}
+
+func (r *hookedRuleset) Parse(x intList) (intList, error) {
+	return x, nil
+}
+
+func (r *hookedRuleset) ParseInts(xs []intTok) intList {
+	vals := make([]int, len(xs))
+	for i, x := range xs {
+		r.seen[x.value]++
+		vals[i] = x.value * r.scale * r.seen[x.value]
+	}
+	return intList{vals: vals}
+}
+
+func TestParseHooks(t *testing.T) {
+	r := &hookedRuleset{scale: 1}
+
+	expr, err := Parse(r, []testTok{intTok{1}, intTok{2}, intTok{1}})
+	assert.Nil(t, err)
+	assert.Equal(t, expr, intList{[]int{1, 2, 2}})
+
+	_, err = Parse(r, []testTok{intTok{1}, plusTok{}})
+	assert.True(t, err != nil)
+
+	assert.Equal(t, r.events, []string{
+		"before 3",
+		"after [1 2 2] <nil>",
+		"before 2",
+		"after [] unexpected token: tp.plusTok{}",
+	})
+	assert.True(t, r.seen == nil)
+
+	// rules are called on the grammar that is passed in, not the one that was first scanned
+	expr, err = Parse(&hookedRuleset{scale: 10}, []testTok{intTok{1}})
+	assert.Nil(t, err)
+	assert.Equal(t, expr, intList{[]int{10}})
+}
+
+type atLeast[T any] struct {
+	Min   int
+	items []T
+}
+
+type atLeastGrammar[T any] struct {
+	min int
+}
+
+type anyNumber[T any] struct{}
+
+func (a atLeast[T]) Grammar() any {
+	if a.Min == 0 {
+		return anyNumber[T]{}
+	}
+	return atLeastGrammar[T]{min: a.Min}
+}
+
+func (g atLeastGrammar[T]) ParseItems(xs []T) (atLeast[T], error) {
+	if len(xs) < g.min {
+		return atLeast[T]{}, fmt.Errorf("need at least %d items, got %d", g.min, len(xs))
+	}
+	return atLeast[T]{items: xs}, nil
+}
+
+func (anyNumber[T]) ParseItems(xs []T) atLeast[T] {
+	return atLeast[T]{items: xs}
+}
+
+type configuredRuleset struct {
+	Ints atLeast[intTok]
+}
+
+func (configuredRuleset) Parse(x intList) (intList, error) {
+	return x, nil
+}
+
+func (configuredRuleset) ParseInts(xs atLeast[intTok]) intList {
+	var vals []int
+	for _, x := range xs.items {
+		vals = append(vals, x.value)
+	}
+	return intList{vals: vals}
+}
+
+func TestConfiguredGrammar(t *testing.T) {
+	toks := []testTok{intTok{1}, intTok{2}}
+
+	expr, err := Parse(configuredRuleset{}, toks)
+	assert.Nil(t, err)
+	assert.Equal(t, expr, intList{[]int{1, 2}})
+
+	expr, err = Parse(configuredRuleset{Ints: atLeast[intTok]{Min: 2}}, toks)
+	assert.Nil(t, err)
+	assert.Equal(t, expr, intList{[]int{1, 2}})
+
+	_, err = Parse(configuredRuleset{Ints: atLeast[intTok]{Min: 3}}, toks)
+	assert.Equal(t, err.Error(), "ParseItems at tokens 0 to 2: need at least 3 items, got 2")
+}
+
+type sumRuleset struct {
+}
+
+func (sumRuleset) Parse(x testExpr) (testExpr, error) {
+	return x, nil
+}
+
+func (sumRuleset) ParseInt(val intTok) intVal {
+	return intVal{val.value}
+}
+
+func (sumRuleset) ParseAdd(left testExpr, _ plusTok, right testExpr) add {
+	return add{left, right}
+}
+
+func TestCompletionIndex(t *testing.T) {
+	// the sum is ambiguous, so its state sets are large and often completed into
+	toks := []testTok{intTok{1}}
+	for i := range 40 {
+		toks = append(toks, plusTok{}, intTok{i + 2})
+	}
+	gr := scanGrammar(reflect.ValueOf(sumRuleset{}), reflect.TypeFor[testExpr]())
+	m := &matcher{
+		root:     gr.root,
+		brackets: gr.brackets,
+		toks:     tokenValues(toks),
+	}
+	assert.Nil(t, m.run())
+
+	indexed := 0
+	for at, index := range m.awaiting {
+		if index.items == nil {
+			continue
+		}
+		indexed++
+		awaiting := map[*symbol][]item{}
+		for _, y := range m.state[at] {
+			if next, ok := y.nextSymbol(); ok {
+				awaiting[next] = append(awaiting[next], y)
+			}
+		}
+		assert.True(t, maps.EqualFunc(index.items, awaiting, slices.Equal))
+	}
+	assert.True(t, indexed > 0)
+
+	_, err := Parse(sumRuleset{}, toks)
+	assert.Nil(t, err)
+}
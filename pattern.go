@@ -0,0 +1,194 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// PatternHole is implemented by placeholders that name the part of a tree that they stand for, so
+// that a TreePattern can capture it. Hole implements this interface.
+type PatternHole interface {
+	Placeholder
+	HoleName() string
+}
+
+func (h Hole) HoleName() string {
+	return h.Name
+}
+
+// TreePattern matches trees that have the same shape as a template, capturing the parts that the
+// template leaves open. This allows e.g. a lint rule to be written as the syntax that it looks for,
+// such as `$x + 0`, rather than as code that walks the tree.
+type TreePattern struct {
+	tree  reflect.Value
+	holes []patternHole
+}
+
+type patternHole struct {
+	name  string
+	value reflect.Value
+}
+
+// Compile a template into a pattern by parsing it with a grammar. The template contains PatternHole
+// tokens for the open parts. The values of the holes must be distinct from anything that can be
+// parsed, and holes with the same name match parts of the tree that are equal.
+func CompilePattern[T, U, V any](g Grammar[U, V], toks []T) (*TreePattern, error) {
+	tree, err := Parse(g, toks)
+	if err != nil {
+		return nil, err
+	}
+	p := &TreePattern{tree: reflect.ValueOf(&tree).Elem()}
+	for _, t := range toks {
+		if h, ok := any(t).(PatternHole); ok {
+			p.holes = append(p.holes, patternHole{
+				name:  h.HoleName(),
+				value: reflect.ValueOf(h.PlaceholderValue()),
+			})
+		}
+	}
+	return p, nil
+}
+
+// Match a tree against the pattern, returning the part of the tree that each hole stands for. The
+// tree is compared with the pattern's by value, looking through pointers and interfaces.
+//
+// Captured parts must be reachable through exported fields, as otherwise they cannot be returned.
+func (p *TreePattern) Match(tree any) (map[string]any, bool) {
+	m := patternMatch{pattern: p, captures: map[string]reflect.Value{}}
+	if !m.match(p.tree, reflect.ValueOf(tree)) {
+		return nil, false
+	}
+	return m.result(), true
+}
+
+// Find every part of a tree that matches the pattern, in depth-first order, returning the captures
+// for each.
+func (p *TreePattern) FindAll(tree any) []map[string]any {
+	var res []map[string]any
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+		}
+		m := patternMatch{pattern: p, captures: map[string]reflect.Value{}}
+		if m.match(p.tree, v) {
+			res = append(res, m.result())
+		}
+		switch v.Kind() {
+		case reflect.Pointer:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			for i := range v.NumField() {
+				walk(v.Field(i))
+			}
+		case reflect.Slice, reflect.Array:
+			for i := range v.Len() {
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			for it := v.MapRange(); it.Next(); {
+				walk(it.Value())
+			}
+		}
+	}
+	walk(reflect.ValueOf(tree))
+	return res
+}
+
+type patternMatch struct {
+	pattern  *TreePattern
+	captures map[string]reflect.Value
+}
+
+func (m *patternMatch) result() map[string]any {
+	res := make(map[string]any, len(m.captures))
+	for name, v := range m.captures {
+		if !v.CanInterface() {
+			panic(fmt.Sprintf("tp: cannot capture %s for hole %q through an unexported field", v.Type(), name))
+		}
+		res[name] = v.Interface()
+	}
+	return res
+}
+
+// Compare part of the pattern with part of the tree. Values are compared without converting them
+// back into interfaces, as they may have been read from unexported fields.
+func (m *patternMatch) match(p, v reflect.Value) bool {
+	for p.Kind() == reflect.Interface && !p.IsNil() {
+		p = p.Elem()
+	}
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !p.IsValid() || !v.IsValid() {
+		return p.IsValid() == v.IsValid()
+	}
+
+	if m.captures != nil {
+		for _, h := range m.pattern.holes {
+			if p.Type() != h.value.Type() || !(&patternMatch{}).match(p, h.value) {
+				continue
+			}
+			if prev, ok := m.captures[h.name]; ok {
+				return (&patternMatch{}).match(prev, v)
+			}
+			m.captures[h.name] = v
+			return true
+		}
+	}
+
+	if p.Type() != v.Type() {
+		return false
+	}
+	switch p.Kind() {
+	case reflect.Bool:
+		return p.Bool() == v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return p.Int() == v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return p.Uint() == v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return p.Float() == v.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return p.Complex() == v.Complex()
+	case reflect.String:
+		return p.String() == v.String()
+	case reflect.Pointer, reflect.Interface:
+		if p.IsNil() || v.IsNil() {
+			return p.IsNil() == v.IsNil()
+		}
+		return m.match(p.Elem(), v.Elem())
+	case reflect.Struct:
+		for i := range p.NumField() {
+			if !m.match(p.Field(i), v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Array:
+		if p.Len() != v.Len() {
+			return false
+		}
+		for i := range p.Len() {
+			if !m.match(p.Index(i), v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if p.Len() != v.Len() {
+			return false
+		}
+		for it := p.MapRange(); it.Next(); {
+			w := v.MapIndex(it.Key())
+			if !w.IsValid() || !m.match(it.Value(), w) {
+				return false
+			}
+		}
+		return true
+	}
+	// functions and channels can only be compared for identity
+	return p.Pointer() == v.Pointer()
+}
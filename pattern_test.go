@@ -0,0 +1,90 @@
+package tp_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type SumExpr interface {
+	sumExpr()
+}
+
+type Sum struct {
+	Left  SumExpr
+	Right Num
+}
+
+type Num struct {
+	Value int
+}
+
+type SumHole struct {
+	Name string
+}
+
+func (Sum) sumExpr()     {}
+func (Num) sumExpr()     {}
+func (SumHole) sumExpr() {}
+
+type sumGrammar struct{}
+
+func (sumGrammar) Parse(x SumExpr) (SumExpr, error) {
+	return x, nil
+}
+
+func (sumGrammar) Num(x intTok) Num {
+	return Num{x.value}
+}
+
+func (sumGrammar) Sum(left SumExpr, _ plusTok, right Num) Sum {
+	return Sum{left, right}
+}
+
+func sumHole(name string) tp.Hole {
+	return tp.Hole{Name: name, Value: SumHole{name}}
+}
+
+func ExampleTreePattern() {
+	// $x + 0
+	p, err := tp.CompilePattern(sumGrammar{}, []any{sumHole("x"), plusTok{}, intTok{0}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// 1 + 0 + 2 + 0
+	tree, err := tp.Parse(sumGrammar{}, []any{
+		intTok{1}, plusTok{}, intTok{0}, plusTok{}, intTok{2}, plusTok{}, intTok{0},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, m := range p.FindAll(tree) {
+		fmt.Printf("%+v\n", m["x"])
+	}
+
+	// Output:
+	// {Left:{Left:{Value:1} Right:{Value:0}} Right:{Value:2}}
+	// {Value:1}
+}
+
+func TestTreePattern(t *testing.T) {
+	// the right hand side of a sum is a number, which the hole cannot stand for
+	_, err := tp.CompilePattern(sumGrammar{}, []any{sumHole("x"), plusTok{}, sumHole("y")})
+	assert.True(t, err != nil)
+
+	p, err := tp.CompilePattern(sumGrammar{}, []any{sumHole("x"), plusTok{}, intTok{1}})
+	assert.Nil(t, err)
+
+	m, ok := p.Match(Sum{Sum{Num{2}, Num{3}}, Num{1}})
+	assert.True(t, ok)
+	assert.Equal(t, m, map[string]any{"x": Sum{Num{2}, Num{3}}})
+
+	_, ok = p.Match(Sum{Num{2}, Num{3}})
+	assert.False(t, ok)
+}
@@ -0,0 +1,146 @@
+// Package pipeline tokenizes and parses texts in stages that run at the same time.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"slices"
+	"sync"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Pipeline tokenizes and parses a text in stages that run at the same time, for servers that deal
+// with large inputs as they arrive. The lexer runs in a goroutine of its own, as does each filter,
+// passing tokens to the next stage through a buffer, and the items of the text are parsed as
+// tp.ParseEach parses them. A stage that gets ahead of the next one waits once its buffer is full,
+// so that the tokens of the text are not all held at once.
+type Pipeline[T, I, V any] struct {
+	Lexer *tp.Lexer[T]
+
+	// Tokens in any of these categories are dropped by the lexer, as for tp.Language.
+	Skip []string
+
+	// Stages that tokens pass through between the lexer and the parser, in order.
+	Filters []Filter[T]
+
+	// The grammar, whose Parse method accepts a slice of the items that are passed to Run's each.
+	Grammar tp.Grammar[[]I, V]
+
+	// The number of tokens that each stage can get ahead of the next one by. If this is zero then each
+	// stage waits for the next one to take every token.
+	Buffer int
+}
+
+// Filter is a stage of a Pipeline. It is called with each token in turn, and passes tokens on to
+// the next stage by calling emit, which it may do any number of times, so that tokens can be
+// changed, dropped or added. If it returns an error then the pipeline stops with that error.
+type Filter[T any] func(tok T, emit func(T)) error
+
+// Tokenize and parse the text read from r, calling each with the items of the text in order.
+//
+// Run stops at the first problem: an error from the lexer, a filter, the parser or each, or the
+// context being cancelled. It waits for every stage to stop, and returns the problem that stopped
+// it, or nil once every item has been dealt with. The parser counts tokens after the lexer and the
+// filters are done with them, so errors such as tp.ErrUnexpectedToken give the index of the token
+// in that sequence.
+//
+// A stage that is waiting for r to be read is not stopped by the context, so r should be closed, or
+// given a deadline, when the context is cancelled.
+func (p *Pipeline[T, I, V]) Run(ctx context.Context, r io.Reader, each func(I) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	toks := p.lex(ctx, cancel, &wg, r)
+	for _, f := range p.Filters {
+		toks = p.filter(ctx, cancel, &wg, f, toks)
+	}
+
+	for x, err := range tp.ParseEach(p.Grammar, receive(toks)) {
+		if err == nil && ctx.Err() == nil {
+			err = each(x)
+		}
+		if err != nil || ctx.Err() != nil {
+			cancel(err)
+			break
+		}
+	}
+
+	// the stages that feed the parser stop once the context is done, or once they have nothing left
+	// to send
+	cancel(errPipelineDone)
+	for range toks {
+	}
+	wg.Wait()
+
+	// the first problem is the one that stopped the pipeline
+	if err := context.Cause(ctx); err != errPipelineDone {
+		return err
+	}
+	return nil
+}
+
+var errPipelineDone = errors.New("pipeline done")
+
+func (p *Pipeline[T, I, V]) lex(ctx context.Context, stop context.CancelCauseFunc, wg *sync.WaitGroup, r io.Reader) <-chan T {
+	out := make(chan T, p.Buffer)
+	wg.Go(func() {
+		defer close(out)
+		s := p.Lexer.TokenizeReader(r)
+		for s.Next() {
+			if slices.Contains(p.Skip, s.Category()) {
+				continue
+			}
+			if !send(ctx, out, s.This()) {
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			stop(err)
+		}
+	})
+	return out
+}
+
+func (p *Pipeline[T, I, V]) filter(ctx context.Context, stop context.CancelCauseFunc, wg *sync.WaitGroup, f Filter[T], in <-chan T) <-chan T {
+	out := make(chan T, p.Buffer)
+	wg.Go(func() {
+		defer close(out)
+		emit := func(tok T) {
+			send(ctx, out, tok)
+		}
+		for tok := range in {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := f(tok, emit); err != nil {
+				stop(err)
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Send a value on a channel, unless the context is done first.
+func send[T any](ctx context.Context, out chan<- T, x T) bool {
+	select {
+	case out <- x:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func receive[T any](in <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for x := range in {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp/internal/testlang"
+	"github.com/bobappleyard/tp/pipeline"
+)
+
+func TestPipeline(t *testing.T) {
+	errStop := errors.New("stop")
+
+	dropSpaces := func(tok testlang.Token, emit func(testlang.Token)) error {
+		if _, ok := tok.(testlang.Space); !ok {
+			emit(tok)
+		}
+		return nil
+	}
+	double := func(tok testlang.Token, emit func(testlang.Token)) error {
+		emit(tok)
+		if _, ok := tok.(testlang.Number); ok {
+			emit(testlang.Comma{})
+			emit(tok)
+		}
+		return nil
+	}
+	noEmpty := func() pipeline.Filter[testlang.Token] {
+		open := false
+		return func(tok testlang.Token, emit func(testlang.Token)) error {
+			if _, ok := tok.(testlang.Close); ok && open {
+				return errStop
+			}
+			_, open = tok.(testlang.Open)
+			emit(tok)
+			return nil
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		src     string
+		filters func() []pipeline.Filter[testlang.Token]
+		stopAt  int
+		lens    []int
+		err     string
+	}{
+		{
+			name: "Items",
+			src:  `[1, 2] [] [3]`,
+			lens: []int{2, 0, 1},
+		},
+		{
+			name: "Filters",
+			src:  `[1, 2] [3]`,
+			filters: func() []pipeline.Filter[testlang.Token] {
+				return []pipeline.Filter[testlang.Token]{dropSpaces, double}
+			},
+			lens: []int{4, 2},
+		},
+		{
+			name: "FilterError",
+			src:  `[1] [] [2]`,
+			filters: func() []pipeline.Filter[testlang.Token] {
+				return []pipeline.Filter[testlang.Token]{dropSpaces, noEmpty()}
+			},
+			err: "stop",
+		},
+		{
+			name: "LexerError",
+			src:  `[1] ?`,
+			err:  "failed to match: no token begins with '?' at offset 4",
+		},
+		{
+			name: "ParserError",
+			src:  `[1] [2,, 3]`,
+			lens: []int{1},
+			err:  "unexpected token: testlang.Comma{}",
+		},
+		{
+			name:   "EachError",
+			src:    `[1] [2] [3]`,
+			stopAt: 2,
+			lens:   []int{1, 1},
+			err:    "stop",
+		},
+	} {
+		for _, buffer := range []int{0, 4} {
+			t.Run(test.name, func(t *testing.T) {
+				p := &pipeline.Pipeline[testlang.Token, testlang.List, []testlang.List]{
+					Lexer:   testlang.Numbers.Lexer,
+					Skip:    testlang.Numbers.Skip,
+					Grammar: testlang.Lists{},
+					Buffer:  buffer,
+				}
+				if test.filters != nil {
+					p.Skip = nil
+					p.Filters = test.filters()
+				}
+
+				var lens []int
+				err := p.Run(context.Background(), strings.NewReader(test.src), func(l testlang.List) error {
+					lens = append(lens, len(l.Values))
+					if len(lens) == test.stopAt {
+						return errStop
+					}
+					return nil
+				})
+
+				if test.err == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.True(t, err != nil)
+					assert.Equal(t, err.Error(), test.err)
+				}
+				if test.lens != nil {
+					assert.Equal(t, lens, test.lens)
+				}
+			})
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &pipeline.Pipeline[testlang.Token, testlang.List, []testlang.List]{
+		Lexer:   testlang.Numbers.Lexer,
+		Skip:    testlang.Numbers.Skip,
+		Grammar: testlang.Lists{},
+	}
+
+	var lens []int
+	err := p.Run(ctx, strings.NewReader(strings.Repeat(`[1] `, 1000)), func(l testlang.List) error {
+		lens = append(lens, len(l.Values))
+		if len(lens) == 3 {
+			cancel()
+		}
+		return nil
+	})
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, len(lens) < 1000)
+}
@@ -0,0 +1,77 @@
+package tp
+
+import "reflect"
+
+// Placeholder is implemented by tokens that stand in for a whole part of the tree, such as the holes
+// in a template like `x + $y`. Wherever the grammar expects a symbol, a placeholder whose value is
+// assignable to the symbol's type is accepted in place of the tokens that would have formed it, and
+// its value appears in the tree where the symbol would have been.
+//
+// So the type of the value decides which symbols a placeholder can stand for. For a grammar with an
+// interface for expressions, a hole type that implements the interface can be placed anywhere an
+// expression can, and then found in the tree that results.
+//
+// Placeholders stand for the symbols that rules are made of, so the input as a whole cannot be a
+// single placeholder.
+type Placeholder interface {
+	PlaceholderValue() any
+}
+
+// Hole is a simple Placeholder, which can be used as a token or embedded in one.
+type Hole struct {
+	// Identifies the hole, e.g. so that it can be filled in later.
+	Name string
+
+	// The value that is placed in the tree. This must not be nil.
+	Value any
+}
+
+func (h Hole) PlaceholderValue() any {
+	return h.Value
+}
+
+var placeholderType = reflect.TypeFor[Placeholder]()
+
+// Find the value that a token stands in for, if it is a placeholder.
+func holeValue(tok reflect.Value) (reflect.Value, bool) {
+	if !tok.Type().Implements(placeholderType) {
+		return reflect.Value{}, false
+	}
+	v := reflect.ValueOf(tok.Interface().(Placeholder).PlaceholderValue())
+	return v, v.IsValid()
+}
+
+// The type of value that a symbol stands for in the tree.
+func (s *symbol) valueType() reflect.Type {
+	if s.TokenType != nil {
+		return s.TokenType
+	}
+	return s.Type
+}
+
+// Find the value of the placeholder at a position, if it can stand for the given symbol.
+func (b *builder) holeAt(sym *symbol, at int) (reflect.Value, bool) {
+	if at >= len(b.seen) {
+		return reflect.Value{}, false
+	}
+	v, ok := holeValue(b.seen[at])
+	if !ok || !v.Type().AssignableTo(sym.valueType()) {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+func (b *builder) holeSpan(deps []*symbol, at, end int) ([]span, bool) {
+	value, ok := b.holeAt(deps[0], at)
+	if !ok {
+		return nil, false
+	}
+	next, ok := b.findSpanChildren(deps[1:], at+1, end)
+	if !ok {
+		return nil, false
+	}
+	return append([]span{{
+		value: value,
+		at:    at,
+	}}, next...), true
+}
@@ -0,0 +1,43 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type exprHole struct {
+	name string
+}
+
+func (exprHole) testExpr() {}
+
+func ExampleHole() {
+	// 1 + $x + 2
+	toks := []any{
+		intTok{1},
+		plusTok{},
+		tp.Hole{Name: "x", Value: exprHole{"x"}},
+	}
+
+	x, err := tp.Parse(interfaceGrammar{}, toks)
+	fmt.Printf("%#v %v\n", x, err)
+
+	// Output: tp_test.add{left:tp_test.intVal{value:1}, right:tp_test.exprHole{name:"x"}} <nil>
+}
+
+func TestHole(t *testing.T) {
+	// a hole can stand for a token
+	x, err := tp.Parse(interfaceGrammar{}, []any{intTok{1}, tp.Hole{Value: plusTok{}}, intTok{2}})
+	assert.Nil(t, err)
+	assert.Equal[expr](t, x, add{intVal{1}, intVal{2}})
+
+	// but only one of the right type
+	_, err = tp.Parse(interfaceGrammar{}, []any{intTok{1}, plusTok{}, tp.Hole{Value: "x"}})
+	var unexpected *tp.ErrUnexpectedToken
+	assert.True(t, errors.As(err, &unexpected))
+	assert.Equal(t, unexpected.Index, 2)
+}
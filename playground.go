@@ -0,0 +1,37 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Write the tokens of a text, and then either the tree or the error, to out, for trying out changes
+// to the language while it is being developed. The playground package runs this for each text that
+// is entered, and the tool in cmd/tp runs that for a language loaded from a Go plugin.
+func (l *Language[T, U, V]) Show(out io.Writer, src []byte) {
+	s := l.Lexer.Tokenize(src)
+	for s.Next() {
+		if cat := s.Category(); cat != "" {
+			skip := ""
+			if slices.Contains(l.Skip, cat) {
+				skip = ", skipped"
+			}
+			fmt.Fprintf(out, "  %#v (%s%s)\n", s.This(), cat, skip)
+			continue
+		}
+		fmt.Fprintf(out, "  %#v\n", s.This())
+	}
+
+	res, err := l.Parse(src)
+	if err != nil {
+		fmt.Fprintf(out, "error: %s\n", err)
+		var syntax *SyntaxError
+		if errors.As(err, &syntax) {
+			fmt.Fprint(out, Excerpt(src, syntax.Offset, syntax.Offset, ExcerptOptions{}))
+		}
+		return
+	}
+	fmt.Fprintf(out, "%+v\n", res)
+}
@@ -0,0 +1,46 @@
+// Package playground runs languages built with tp interactively, for trying out changes to them
+// while they are being developed.
+package playground
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Language is a language that can be played with, such as a *tp.Language.
+type Language interface {
+	Show(out io.Writer, src []byte)
+}
+
+// Play with a language. Texts are read from in, a line at a time, and for each one the tokens, and
+// then either the tree or the error, are written to out. A line that ends with a backslash is
+// continued on the next line, so that texts of several lines can be entered.
+//
+// This runs until in is exhausted. The tool in cmd/tp runs this for a language loaded from a Go
+// plugin.
+func Play(l Language, in io.Reader, out io.Writer) error {
+	lines := bufio.NewScanner(in)
+	var text strings.Builder
+	for {
+		if text.Len() == 0 {
+			fmt.Fprint(out, "> ")
+		} else {
+			fmt.Fprint(out, ". ")
+		}
+		if !lines.Scan() {
+			fmt.Fprintln(out)
+			return lines.Err()
+		}
+		line := lines.Text()
+		if cont, ok := strings.CutSuffix(line, `\`); ok {
+			text.WriteString(cont)
+			text.WriteByte('\n')
+			continue
+		}
+		text.WriteString(line)
+		l.Show(out, []byte(text.String()))
+		text.Reset()
+	}
+}
@@ -0,0 +1,31 @@
+package playground_test
+
+import (
+	"os"
+	"strings"
+
+	"github.com/bobappleyard/tp/internal/testlang"
+	"github.com/bobappleyard/tp/playground"
+)
+
+func ExamplePlay() {
+	in := strings.NewReader("[1, x]\n[1,\\\n22]\n")
+	playground.Play(testlang.Numbers, in, os.Stdout)
+
+	// Output:
+	// >   testlang.Open{}
+	//   testlang.Number{Value:1}
+	//   testlang.Comma{}
+	//   testlang.Space{} (trivia, skipped)
+	// error: offset 4: failed to match: no token begins with 'x' at offset 4
+	// 1 | [1, x]
+	//   |     ^
+	// > .   testlang.Open{}
+	//   testlang.Number{Value:1}
+	//   testlang.Comma{}
+	//   testlang.Space{} (trivia, skipped)
+	//   testlang.Number{Value:22}
+	//   testlang.Close{}
+	// [1 22]
+	// >
+}
@@ -0,0 +1,37 @@
+package tp_test
+
+import (
+	"os"
+
+	"github.com/bobappleyard/tp"
+)
+
+var jsonLanguage = &tp.Language[jsonToken, jsonValue, jsonValue]{
+	Lexer: must(tp.NewLexer(
+		tp.Regex(`{`, emptyToken[objectStartToken]()),
+		tp.Regex(`}`, emptyToken[objectEndToken]()),
+		tp.Regex(`\[`, emptyToken[arrayStartToken]()),
+		tp.Regex(`\]`, emptyToken[arrayEndToken]()),
+		tp.Regex(`,`, emptyToken[commaToken]()),
+		tp.Regex(`:`, emptyToken[colonToken]()),
+		tp.Categorize("trivia", tp.Regex(`\s+`, emptyToken[whitespaceToken]())),
+		tp.Regex(`\d+`, func(start int, text string) (jsonToken, error) {
+			return numberToken{value: float64(len(text))}, nil
+		}),
+	)),
+	Grammar: jsonGrammar{},
+	Skip:    []string{"trivia"},
+}
+
+func ExampleLanguage_Show() {
+	jsonLanguage.Show(os.Stdout, []byte("[1, x]"))
+
+	// Output:
+	//   tp_test.arrayStartToken{}
+	//   tp_test.numberToken{value:1}
+	//   tp_test.commaToken{}
+	//   tp_test.whitespaceToken{} (trivia, skipped)
+	// error: offset 4: failed to match: no token begins with 'x' at offset 4
+	// 1 | [1, x]
+	//   |     ^
+}
@@ -0,0 +1,188 @@
+package tp
+
+import "fmt"
+
+// DirectiveKind classifies the tokens that control a Preprocessor.
+type DirectiveKind int
+
+const (
+	// An ordinary token, which is passed on, or substituted if it names a definition.
+	NotDirective DirectiveKind = iota
+
+	// Begin a section that is only kept if the name given by the next token is, or is not, defined.
+	IfDefined
+	IfNotDefined
+
+	// Switch to keeping the rest of the section if the first part was dropped, and vice versa.
+	Else
+
+	// End a section.
+	EndIf
+
+	// Define, or remove the definition of, the name given by the next token.
+	Define
+	Undefine
+)
+
+func (k DirectiveKind) String() string {
+	switch k {
+	case NotDirective:
+		return "not a directive"
+	case IfDefined:
+		return "if defined"
+	case IfNotDefined:
+		return "if not defined"
+	case Else:
+		return "else"
+	case EndIf:
+		return "end if"
+	case Define:
+		return "define"
+	case Undefine:
+		return "undefine"
+	}
+	return fmt.Sprintf("DirectiveKind(%d)", int(k))
+}
+
+// Preprocessor is a stage that runs between a lexer and a parser. It drops sections of the tokens
+// depending on which names are defined, in the manner of #ifdef in C, and replaces tokens that name
+// a definition with the tokens that it is defined as.
+//
+// The directives are themselves tokens, which are recognised by Directive. Directives that need a
+// name take it from the token that follows them. Neither directives nor their names are passed on to
+// the parser.
+type Preprocessor[T any] struct {
+	// Decide whether a token is a directive, and which.
+	Directive func(tok T) DirectiveKind
+
+	// Find the name that a token gives, if it gives one, such as for an identifier.
+	Name func(tok T) (string, bool)
+
+	// The names that are defined before processing begins, and the tokens that each is replaced by.
+	// A name that is defined as no tokens is removed wherever it appears. Replacement tokens are not
+	// themselves processed.
+	Defines map[string][]T
+}
+
+// ErrPreprocess reports a misplaced or incomplete directive.
+type ErrPreprocess struct {
+	// The index of the directive in the input.
+	Index int
+
+	Directive DirectiveKind
+	Problem   string
+}
+
+func (e *ErrPreprocess) Error() string {
+	return fmt.Sprintf("token %d: %s: %s", e.Index, e.Directive, e.Problem)
+}
+
+// A conditional section that has been entered.
+type preprocessSection struct {
+	start     int
+	keep      bool
+	seenElse  bool
+	outerKeep bool
+}
+
+// Process the tokens, returning those that remain along with, for each of them, the index of the
+// token in the input that it came from. Tokens that replace a name come from the name.
+//
+// The Defines of the preprocessor are not modified by Define and Undefine directives, so a
+// Preprocessor may be used for any number of inputs.
+func (p *Preprocessor[T]) Process(toks []T) ([]T, []int, error) {
+	defines := make(map[string][]T, len(p.Defines))
+	for k, v := range p.Defines {
+		defines[k] = v
+	}
+
+	var res []T
+	var from []int
+	var sections []preprocessSection
+	keep := true
+
+	for i := 0; i < len(toks); i++ {
+		kind := p.Directive(toks[i])
+
+		name := func() (string, error) {
+			if i+1 < len(toks) {
+				if n, ok := p.Name(toks[i+1]); ok {
+					i++
+					return n, nil
+				}
+			}
+			return "", &ErrPreprocess{Index: i, Directive: kind, Problem: "expected a name"}
+		}
+
+		switch kind {
+		case NotDirective:
+			if !keep {
+				continue
+			}
+			if n, ok := p.Name(toks[i]); ok {
+				if repl, ok := defines[n]; ok {
+					for _, t := range repl {
+						res = append(res, t)
+						from = append(from, i)
+					}
+					continue
+				}
+			}
+			res = append(res, toks[i])
+			from = append(from, i)
+
+		case IfDefined, IfNotDefined:
+			start := i
+			n, err := name()
+			if err != nil {
+				return nil, nil, err
+			}
+			_, defined := defines[n]
+			sections = append(sections, preprocessSection{
+				start:     start,
+				keep:      defined == (kind == IfDefined),
+				outerKeep: keep,
+			})
+			keep = keep && sections[len(sections)-1].keep
+
+		case Else:
+			if len(sections) == 0 {
+				return nil, nil, &ErrPreprocess{Index: i, Directive: kind, Problem: "not in a section"}
+			}
+			s := &sections[len(sections)-1]
+			if s.seenElse {
+				return nil, nil, &ErrPreprocess{Index: i, Directive: kind, Problem: "section already has an else"}
+			}
+			s.seenElse = true
+			s.keep = !s.keep
+			keep = s.outerKeep && s.keep
+
+		case EndIf:
+			if len(sections) == 0 {
+				return nil, nil, &ErrPreprocess{Index: i, Directive: kind, Problem: "not in a section"}
+			}
+			keep = sections[len(sections)-1].outerKeep
+			sections = sections[:len(sections)-1]
+
+		case Define, Undefine:
+			n, err := name()
+			if err != nil {
+				return nil, nil, err
+			}
+			if !keep {
+				continue
+			}
+			if kind == Define {
+				defines[n] = nil
+			} else {
+				delete(defines, n)
+			}
+		}
+	}
+
+	if len(sections) != 0 {
+		s := sections[len(sections)-1]
+		return nil, nil, &ErrPreprocess{Index: s.start, Directive: p.Directive(toks[s.start]), Problem: "section is not ended"}
+	}
+	return res, from, nil
+}
@@ -0,0 +1,106 @@
+package tp_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+var directives = map[string]tp.DirectiveKind{
+	"#ifdef":  tp.IfDefined,
+	"#ifndef": tp.IfNotDefined,
+	"#else":   tp.Else,
+	"#endif":  tp.EndIf,
+	"#define": tp.Define,
+	"#undef":  tp.Undefine,
+}
+
+func newPreprocessor(defines map[string][]string) *tp.Preprocessor[string] {
+	return &tp.Preprocessor[string]{
+		Directive: func(tok string) tp.DirectiveKind {
+			return directives[tok]
+		},
+		Name: func(tok string) (string, bool) {
+			return tok, !strings.HasPrefix(tok, "#")
+		},
+		Defines: defines,
+	}
+}
+
+func ExamplePreprocessor() {
+	p := newPreprocessor(map[string][]string{
+		"DEBUG": nil,
+		"ONE":   {"1"},
+	})
+
+	toks := strings.Fields("#ifdef DEBUG log ONE #else ONE #endif")
+	out, from, err := p.Process(toks)
+	fmt.Println(out, from, err)
+
+	// Output: [log 1] [2 3] <nil>
+}
+
+func TestPreprocessor(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		in   string
+		out  []string
+		err  string
+	}{
+		{
+			name: "NotDefined",
+			in:   "a #ifdef X b #endif c",
+			out:  []string{"a", "c"},
+		},
+		{
+			name: "Define",
+			in:   "#define X #ifdef X b #endif",
+			out:  []string{"b"},
+		},
+		{
+			name: "Undefine",
+			in:   "#define X #undef X #ifndef X b #endif",
+			out:  []string{"b"},
+		},
+		{
+			name: "Nested",
+			in:   "#ifdef X #ifndef Y a #else b #endif #else #ifndef Y c #else d #endif #endif",
+			out:  []string{"c"},
+		},
+		{
+			name: "DefineInDroppedSection",
+			in:   "#ifdef X #define Y #endif #ifdef Y a #endif",
+			out:  nil,
+		},
+		{
+			name: "Unended",
+			in:   "a #ifdef X b",
+			err:  "token 1: if defined: section is not ended",
+		},
+		{
+			name: "StrayElse",
+			in:   "a #else",
+			err:  "token 1: else: not in a section",
+		},
+		{
+			name: "MissingName",
+			in:   "#define #endif",
+			err:  "token 0: define: expected a name",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := newPreprocessor(nil)
+			out, _, err := p.Process(strings.Fields(test.in))
+			if test.err != "" {
+				assert.Equal(t, err.Error(), test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, out, test.out)
+			assert.Equal(t, len(p.Defines), 0)
+		})
+	}
+}
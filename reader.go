@@ -0,0 +1,112 @@
+package tp
+
+import (
+	"io"
+	"slices"
+	"unicode/utf8"
+	"weak"
+)
+
+// Begin executing the described machine against text read from r. The text is read as it is
+// needed, and only the text of the token being matched is kept, so inputs that do not fit in
+// memory can be tokenized. Offsets are from the beginning of what is read.
+//
+// The Match given to a MatchConstructor refers to the text that is held, so its text must be taken
+// before the stream is advanced, as with a BytesConstructor.
+//
+// Clones of the stream, and marks made by Mark, share what has been read with the original. Text is
+// held from the earliest position of any of them that is still in use, so a clone or a mark that is
+// kept while the stream is advanced keeps the text after it from being discarded. The line index
+// of the stream only holds the lines of the text that is held, so offsets before it have no
+// position. An error from r, other than io.EOF, becomes the error of the stream once the tokens
+// before it have been read.
+func (p *Lexer[T]) TokenizeReader(r io.Reader) *Stream[T] {
+	return &Stream[T]{
+		prog: p,
+		in:   (&readerSource{r: r}).cursor(0),
+		this: make([]bool, p.maxState+1),
+		next: make([]bool, p.maxState+1),
+	}
+}
+
+// The least that is read from a reader at once.
+const readSize = 4096
+
+// The text read from a reader so far, which a stream shares with its clones.
+type readerSource struct {
+	r io.Reader
+
+	// set once there is nothing more to read, along with the error that stopped reading if it was
+	// not io.EOF
+	eof bool
+	err error
+
+	// the text that is held, and its offset within the whole text
+	buf  []byte
+	base int
+
+	// the positions of the streams and marks that share the text, which are held weakly so that
+	// those that are no longer used do not keep text from being discarded
+	cursors []weak.Pointer[readerCursor]
+}
+
+// A stream's or a mark's view of a readerSource, along with the position that it may next need the
+// text from.
+type readerCursor struct {
+	*readerSource
+	pos int
+}
+
+// Add a cursor at a position.
+func (s *readerSource) cursor(pos int) *readerCursor {
+	c := &readerCursor{readerSource: s, pos: pos}
+	s.cursors = append(s.cursors, weak.Make(c))
+	return c
+}
+
+// The earliest position of any cursor that is still in use, forgetting those that are not.
+func (s *readerSource) keep() int {
+	keep := -1
+	s.cursors = slices.DeleteFunc(s.cursors, func(w weak.Pointer[readerCursor]) bool {
+		c := w.Value()
+		if c == nil {
+			return true
+		}
+		if keep == -1 || c.pos < keep {
+			keep = c.pos
+		}
+		return false
+	})
+	return keep
+}
+
+// Whether more text is needed to read the character at a position.
+func (s *readerSource) needs(pos int) bool {
+	return !s.eof && pos-s.base+utf8.UTFMax > len(s.buf)
+}
+
+// Read until there is a whole character at a position, or there is no more text. Text before every
+// cursor is no longer needed.
+func (s *readerSource) fill(pos int) {
+	if keep := s.keep(); keep > s.base {
+		n := copy(s.buf, s.buf[keep-s.base:])
+		s.buf = s.buf[:n]
+		s.base = keep
+	}
+	for empty := 0; s.needs(pos); {
+		s.buf = slices.Grow(s.buf, readSize)
+		n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		switch {
+		case err == io.EOF:
+			s.eof = true
+		case err != nil:
+			s.eof, s.err = true, err
+		case n == 0:
+			empty++
+			if empty == 100 {
+				s.eof, s.err = true, io.ErrNoProgress
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package tp
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/bobappleyard/assert"
+)
+
+func readerTestLexer(t *testing.T) *Lexer[string] {
+	l, err := NewLexer(
+		RegexMatch(`[^\s]+`, func(m Match) (string, error) {
+			return m.Text(), nil
+		}),
+		Regex(`\s`, func(start int, text string) (string, error) {
+			return "space", nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestTokenizeReader(t *testing.T) {
+	l := readerTestLexer(t)
+	src := "éé é\nabc"
+
+	// characters are split between reads
+	s := l.TokenizeReader(iotest.OneByteReader(strings.NewReader(src)))
+	toks, err := s.Force()
+	assert.Nil(t, err)
+	assert.Equal(t, toks, []string{"éé", "space", "é", "space", "abc"})
+	assert.Equal(t, s.srcPos, len(src))
+	assert.Equal(t, s.Lines().Position(9), Position{Offset: 9, Line: 2, Column: 2})
+}
+
+func TestTokenizeReaderDiscardsText(t *testing.T) {
+	l := readerTestLexer(t)
+	const n = 100000
+
+	s := l.TokenizeReader(strings.NewReader(strings.Repeat("word ", n)))
+	count := 0
+	for s.Next() {
+		count++
+	}
+	assert.Nil(t, s.Err())
+	assert.Equal(t, count, 2*n)
+	assert.True(t, cap(s.in.buf) <= 2*readSize)
+}
+
+func TestTokenizeReaderError(t *testing.T) {
+	l := readerTestLexer(t)
+	failure := errors.New("failure")
+
+	s := l.TokenizeReader(io.MultiReader(strings.NewReader("a b"), iotest.ErrReader(failure)))
+	toks, err := s.Force()
+	assert.Equal(t, toks, []string{"a", "space"})
+	assert.Equal(t, err, failure)
+}
+
+func TestTokenizeReaderClone(t *testing.T) {
+	l := readerTestLexer(t)
+
+	s := l.TokenizeReader(iotest.HalfReader(strings.NewReader("a bc d")))
+	assert.True(t, s.Next())
+
+	ahead := s.Clone()
+	rest, err := ahead.Force()
+	assert.Nil(t, err)
+	assert.Equal(t, rest, []string{"space", "bc", "space", "d"})
+
+	rest, err = s.Force()
+	assert.Nil(t, err)
+	assert.Equal(t, rest, []string{"space", "bc", "space", "d"})
+}
+
+func TestTokenizeReaderReleasesClones(t *testing.T) {
+	l := readerTestLexer(t)
+	const n = 100000
+
+	s := l.TokenizeReader(strings.NewReader(strings.Repeat("word\n", n)))
+	ahead := s.Clone()
+	assert.True(t, ahead.Next())
+	ahead = nil
+	runtime.GC()
+
+	count := 0
+	for s.Next() {
+		count++
+	}
+	assert.Nil(t, s.Err())
+	assert.Equal(t, count, 2*n)
+	assert.True(t, cap(s.in.buf) <= 2*readSize)
+
+	// the lines are counted, but only those of the text that is held are kept
+	assert.Equal(t, s.Lines().Lines(), n+1)
+	assert.True(t, len(s.lines.starts) <= 2*readSize)
+	assert.Equal(t, s.Lines().Position(5*n-1), Position{Offset: 5*n - 1, Line: n, Column: 5})
+	assert.Equal(t, s.Lines().Position(0), Position{Offset: 0})
+	_, ok := s.Lines().Offset(1, 1)
+	assert.False(t, ok)
+}
+
+func TestTokenizeReaderMarkHoldsText(t *testing.T) {
+	l := readerTestLexer(t)
+	const n = 10000
+
+	s := l.TokenizeReader(strings.NewReader(strings.Repeat("word ", n)))
+	assert.True(t, s.Next())
+	m := s.Mark()
+	first, err := s.Force()
+	assert.Nil(t, err)
+	assert.True(t, cap(s.in.buf) >= 5*n-4)
+
+	s.Reset(m)
+	again, err := s.Force()
+	assert.Nil(t, err)
+	assert.Equal(t, again, first)
+}
@@ -0,0 +1,79 @@
+package record
+
+type token interface {
+	token()
+}
+
+// The text of a field, and where it begins.
+type field struct {
+	start int
+	text  string
+}
+
+// The separator between fields, and where it ends.
+type separatorToken struct {
+	end int
+}
+
+type newline struct {
+	start int
+}
+
+func (field) token()          {}
+func (separatorToken) token() {}
+func (newline) token()        {}
+
+type record struct {
+	values []value
+}
+
+// The text of a value, and where it begins.
+type value struct {
+	start int
+	text  string
+}
+
+// A value after the first in a record.
+type nextValue struct {
+	value
+}
+
+type grammar struct{}
+
+func (grammar) Parse(x []record) ([]record, error) {
+	return x, nil
+}
+
+func (grammar) Record(first value, rest []nextValue, nl newline) record {
+	res := []value{first}
+	for _, x := range rest {
+		res = append(res, x.value)
+	}
+
+	// an empty value begins where the text that follows it does
+	for i := len(res) - 1; i >= 0; i-- {
+		if res[i].start != -1 {
+			continue
+		}
+		res[i].start = nl.start
+		if i+1 < len(res) {
+			res[i].start = res[i+1].start
+		}
+	}
+	return record{values: res}
+}
+
+func (grammar) Next(sep separatorToken, x value) nextValue {
+	if x.start == -1 {
+		x.start = sep.end
+	}
+	return nextValue{x}
+}
+
+func (grammar) Value(f field) value {
+	return value{start: f.start, text: f.text}
+}
+
+func (grammar) Empty() value {
+	return value{start: -1}
+}
@@ -0,0 +1,293 @@
+// Package record reads files of flat records, such as comma-separated values, into slices of
+// structs, in the manner of the encoding packages. It is a small language built with tp, for formats
+// where writing a grammar of one's own would be more than is needed.
+package record
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Format describes a kind of file of records. Each record is a line of fields separated by Comma. A
+// field may be quoted with '"', in which case it may contain the separator, line breaks, and quotes
+// that are written twice. Blank lines are ignored.
+type Format struct {
+	// The rune that separates fields, or ',' if this is zero. It cannot be '"', '\r' or '\n'.
+	Comma rune
+
+	// Whether the first record is a header, naming the struct field that each column is stored in.
+	Header bool
+}
+
+// ParseError describes a problem with a file of records, and where it was found.
+type ParseError struct {
+	Line, Column int
+
+	// The struct field that the text was to be stored in, if any.
+	Field string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("record: line %d, column %d: field %s: %s", e.Line, e.Column, e.Field, e.Err)
+	}
+	return fmt.Sprintf("record: line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrFieldCount is the error of a record, in a format without a header, that does not have a field
+// for each struct field.
+var ErrFieldCount = errors.New("wrong number of fields")
+
+// Read comma-separated records without a header into v, as Format.Unmarshal does.
+func Unmarshal(data []byte, v any) error {
+	return Format{}.Unmarshal(data, v)
+}
+
+// Read the records of a file into v, which must be a pointer to a slice of structs. Each record is
+// appended to the slice, with its fields stored in the exported fields of the struct in order, or
+// for a format with a header, in the fields that the header names. Columns that the header names but
+// the struct does not have are ignored.
+//
+// A struct field is named by its record tag if it has one, and otherwise by its name, ignoring case.
+// Fields tagged `record:"-"` are left alone. Fields can be strings, booleans, numbers, or types
+// whose pointers implement encoding.TextUnmarshaler.
+//
+// Problems with the file are described by a ParseError.
+func (f Format) Unmarshal(data []byte, v any) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Slice || ptr.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("record: cannot unmarshal into %T", v)
+	}
+	fields, err := structFields(ptr.Elem().Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	lang, err := f.language()
+	if err != nil {
+		return err
+	}
+	if len(data) != 0 && data[len(data)-1] != '\n' {
+		data = append(data[:len(data):len(data)], '\n')
+	}
+	lines := tp.NewLineIndex(data)
+	recs, err := lang.Parse(data)
+	var syntax *tp.SyntaxError
+	if errors.As(err, &syntax) {
+		pos := lines.Position(syntax.Offset)
+		return &ParseError{Line: pos.Line, Column: pos.Column, Err: syntax.Err}
+	}
+	if err != nil {
+		return err
+	}
+
+	var columns []*structField
+	if !f.Header {
+		columns = fields
+	}
+	res := ptr.Elem()
+	for _, rec := range recs {
+		rec := rec.values
+		if len(rec) == 1 && rec[0].text == "" {
+			continue
+		}
+		if columns == nil {
+			columns = headerColumns(fields, rec)
+			continue
+		}
+		if !f.Header && len(rec) != len(columns) {
+			pos := lines.Position(rec[0].start)
+			return &ParseError{Line: pos.Line, Column: pos.Column, Err: ErrFieldCount}
+		}
+
+		x := reflect.New(res.Type().Elem()).Elem()
+		for i, val := range rec {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			if err := columns[i].store(x.Field(columns[i].index), val.text); err != nil {
+				pos := lines.Position(val.start)
+				return &ParseError{Line: pos.Line, Column: pos.Column, Field: columns[i].name, Err: err}
+			}
+		}
+		res.Set(reflect.Append(res, x))
+	}
+	return nil
+}
+
+// A field of a struct that records are stored in.
+type structField struct {
+	name, column string
+	index        int
+	store        func(v reflect.Value, text string) error
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// Find the fields of a struct that records can be stored in.
+func structFields(t reflect.Type) ([]*structField, error) {
+	var res []*structField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		column := f.Tag.Get("record")
+		if !f.IsExported() || column == "-" {
+			continue
+		}
+		if column == "" {
+			column = f.Name
+		}
+		store := storeFunc(f.Type)
+		if store == nil {
+			return nil, fmt.Errorf("record: cannot store text in field %s of type %s", f.Name, f.Type)
+		}
+		res = append(res, &structField{name: f.Name, column: column, index: i, store: store})
+	}
+	return res, nil
+}
+
+// Find how to store text in values of a type, or nil if it cannot be.
+func storeFunc(t reflect.Type) func(v reflect.Value, text string) error {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return func(v reflect.Value, text string) error {
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value, text string) error {
+			v.SetString(text)
+			return nil
+		}
+	case reflect.Bool:
+		return func(v reflect.Value, text string) error {
+			b, err := strconv.ParseBool(text)
+			v.SetBool(b)
+			return err
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value, text string) error {
+			n, err := strconv.ParseInt(text, 10, t.Bits())
+			v.SetInt(n)
+			return err
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value, text string) error {
+			n, err := strconv.ParseUint(text, 10, t.Bits())
+			v.SetUint(n)
+			return err
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value, text string) error {
+			n, err := strconv.ParseFloat(text, t.Bits())
+			v.SetFloat(n)
+			return err
+		}
+	}
+	return nil
+}
+
+// Match the columns named by a header with the fields of a struct.
+func headerColumns(fields []*structField, header []value) []*structField {
+	res := make([]*structField, len(header))
+	for i, h := range header {
+		for _, f := range fields {
+			if strings.EqualFold(f.column, h.text) {
+				res[i] = f
+				break
+			}
+		}
+	}
+	return res
+}
+
+// The languages of the formats that have been used, by their separator.
+var (
+	lock      sync.Mutex
+	languages = map[rune]*tp.Language[token, []record, []record]{}
+)
+
+func (f Format) language() (*tp.Language[token, []record, []record], error) {
+	comma := f.Comma
+	if comma == 0 {
+		comma = ','
+	}
+	if comma < 0 || comma == '"' || comma == '\r' || comma == '\n' || comma > unicode.MaxRune {
+		return nil, fmt.Errorf("record: cannot separate fields with %q", comma)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if l, ok := languages[comma]; ok {
+		return l, nil
+	}
+	lexer, err := tp.NewLexer(
+		separator(comma),
+		unquotedField(comma),
+		tp.Regex(`"([^"]|"")*"`, func(start int, text string) (token, error) {
+			text = strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+			return field{start: start, text: text}, nil
+		}),
+		tp.Regex(`\r?\n`, func(start int, text string) (token, error) {
+			return newline{start: start}, nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	l := &tp.Language[token, []record, []record]{Lexer: lexer, Grammar: grammar{}}
+	languages[comma] = l
+	return l, nil
+}
+
+// Match the separator.
+func separator(comma rune) tp.TokenSpec[token] {
+	return func(l *tp.Lexer[token]) error {
+		end := l.State()
+		l.Rune(0, end, comma)
+		l.Final(end, func(start int, text string) (token, error) {
+			return separatorToken{end: start + len(text)}, nil
+		})
+		return nil
+	}
+}
+
+// Match a field that is not quoted, which is any text up to the next separator or line break.
+func unquotedField(comma rune) tp.TokenSpec[token] {
+	return func(l *tp.Lexer[token]) error {
+		end := l.State()
+		next := rune(0)
+		excluded := []rune{'\n', '\r', '"', comma}
+		slices.Sort(excluded)
+		for _, c := range excluded {
+			if c < next {
+				continue
+			}
+			if c > next {
+				l.Range(0, end, next, c-1)
+				l.Range(end, end, next, c-1)
+			}
+			next = c + 1
+		}
+		l.Range(0, end, next, unicode.MaxRune)
+		l.Range(end, end, next, unicode.MaxRune)
+		l.Final(end, func(start int, text string) (token, error) {
+			return field{start: start, text: text}, nil
+		})
+		return nil
+	}
+}
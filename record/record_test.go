@@ -0,0 +1,143 @@
+package record_test
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp/record"
+)
+
+func ExampleUnmarshal() {
+	type Person struct {
+		Name string
+		Age  int
+	}
+
+	var people []Person
+	err := record.Unmarshal([]byte("Ada,36\n\"Hopper, Grace\",85\n"), &people)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%+v\n", people)
+
+	// Output:
+	// [{Name:Ada Age:36} {Name:Hopper, Grace Age:85}]
+}
+
+func ExampleFormat_Unmarshal() {
+	type Host struct {
+		Addr    netip.Addr `record:"address"`
+		Name    string
+		Comment string `record:"-"`
+	}
+
+	var hosts []Host
+	f := record.Format{Comma: '\t', Header: true}
+	err := f.Unmarshal([]byte("name\taddress\tcomment\nlocalhost\t127.0.0.1\tthis one\n"), &hosts)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%+v\n", hosts)
+
+	// Output:
+	// [{Addr:127.0.0.1 Name:localhost Comment:}]
+}
+
+type row struct {
+	Text   string
+	N      int8
+	F      float64
+	OK     bool
+	hidden int
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		src  string
+		out  []row
+	}{
+		{
+			name: "Values",
+			src:  "a,1,1.5,true\nb,-2,0,false",
+			out:  []row{{"a", 1, 1.5, true, 0}, {"b", -2, 0, false, 0}},
+		},
+		{
+			name: "Quoted",
+			src:  "\"say \"\"hi\"\",\r\nthen go\",1,2,true\r\n",
+			out:  []row{{"say \"hi\",\r\nthen go", 1, 2, true, 0}},
+		},
+		{
+			name: "Empty",
+			src:  ",0,0,false\n\n\"\",0,0,false\n",
+			out:  []row{{"", 0, 0, false, 0}, {"", 0, 0, false, 0}},
+		},
+		{
+			name: "None",
+			src:  "",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var out []row
+			err := record.Unmarshal([]byte(test.src), &out)
+			assert.Nil(t, err)
+			assert.Equal(t, out, test.out)
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{
+			name: "FieldCount",
+			src:  "a,1,1.5,true\nb,2\n",
+			err:  "record: line 2, column 1: wrong number of fields",
+		},
+		{
+			name: "Conversion",
+			src:  "a,1,1.5,true\nb,1000,1.5,true\n",
+			err:  `record: line 2, column 3: field N: strconv.ParseInt: parsing "1000": value out of range`,
+		},
+		{
+			name: "EmptyConversion",
+			src:  "a,1,1.5,\n",
+			err:  `record: line 1, column 9: field OK: strconv.ParseBool: parsing "": invalid syntax`,
+		},
+		{
+			name: "BareQuote",
+			src:  "a,1\"\n",
+			err:  "record: line 1, column 4: failed to match: text ended at offset 5 in a token that began at offset 3",
+		},
+		{
+			name: "UnclosedQuote",
+			src:  "a,\"1\n",
+			err:  "record: line 1, column 3: failed to match: text ended at offset 5 in a token that began at offset 2",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var out []row
+			err := record.Unmarshal([]byte(test.src), &out)
+			var perr *record.ParseError
+			assert.True(t, errors.As(err, &perr))
+			assert.Equal(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestUnmarshalTarget(t *testing.T) {
+	var notSlice row
+	assert.Equal(t, record.Unmarshal(nil, &notSlice).Error(), "record: cannot unmarshal into *record_test.row")
+
+	var badField []struct{ C chan int }
+	assert.Equal(t, record.Unmarshal(nil, &badField).Error(), "record: cannot store text in field C of type chan int")
+
+	var rows []row
+	assert.Equal(t, record.Format{Comma: '"'}.Unmarshal(nil, &rows).Error(), `record: cannot separate fields with '"'`)
+}
@@ -0,0 +1,329 @@
+package tp
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Suggest how the rules of a grammar could be changed to do without a nonterminal, named as EBNF
+// names it, by writing Go source for the methods that would replace the rules that use it. Each use
+// is replaced by what one of the nonterminal's rules matches, so there is a method for every way of
+// choosing those rules, named after the rules it was made from. The methods are skeletons: each has
+// a comment that shows how the old rules would have been called, and its body is left to be written.
+//
+// Only the grammar's own rules are considered, not those of reusable grammars that it contains. A
+// nonterminal that refers to itself, or that no rule uses, cannot be inlined.
+func Inline[U, V any](g Grammar[U, V], name string) (string, error) {
+	r, err := newRefactoring(g)
+	if err != nil {
+		return "", err
+	}
+	sym := r.symbol(name)
+	if sym == nil {
+		return "", fmt.Errorf("tp: no nonterminal named %q", name)
+	}
+	for _, alt := range sym.Predictions {
+		if slices.Contains(alt.Deps, sym) {
+			return "", fmt.Errorf("tp: %s refers to itself, so it cannot be inlined", name)
+		}
+	}
+
+	var b strings.Builder
+	for _, use := range r.rules() {
+		if !slices.Contains(use.Deps, sym) {
+			continue
+		}
+		for _, alts := range inlineChoices(use, sym) {
+			r.writeInlined(&b, use, sym, alts)
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("tp: no rules use %s", name)
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// Suggest how the rules of a grammar could be changed to match a sequence of symbols, named as EBNF
+// names them, with a new nonterminal instead, by writing Go source for the nonterminal's type and
+// rule, and for the methods that would replace the rules that match the sequence. The new type is
+// given the name, and its rule the name with its first letter in upper case. The methods that
+// replace the old rules are skeletons, as for Inline.
+//
+// Only the grammar's own rules are considered, not those of reusable grammars that it contains.
+func Extract[U, V any](g Grammar[U, V], name string, seq ...string) (string, error) {
+	r, err := newRefactoring(g)
+	if err != nil {
+		return "", err
+	}
+	if !isIdentifier(name) || r.symbol(name) != nil {
+		return "", fmt.Errorf("tp: cannot extract a nonterminal named %q", name)
+	}
+	if len(seq) == 0 {
+		return "", errors.New("tp: cannot extract an empty sequence")
+	}
+	syms := make([]*symbol, len(seq))
+	for i, s := range seq {
+		for _, sym := range r.gr.symbols {
+			if ebnfName(sym.Type) == s {
+				syms[i] = sym
+			}
+		}
+		if syms[i] == nil {
+			return "", fmt.Errorf("tp: no symbol named %q", s)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	width := len(fmt.Sprintf("x%d", len(syms)-1))
+	for i, sym := range syms {
+		fmt.Fprintf(&b, "\t%-*s %s\n", width, fmt.Sprintf("x%d", i), r.typeName(sym.Type))
+	}
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "func %s %s(%s) %s {\n", r.receiver, ruleName(name), r.params(syms, 0), name)
+	fmt.Fprintf(&b, "\treturn %s{%s}\n}\n\n", name, argList(0, len(syms)))
+
+	found := false
+	for _, use := range r.rules() {
+		at := -1
+		for i := range len(use.Deps) - len(syms) + 1 {
+			if slices.Equal(use.Deps[i:i+len(syms)], syms) {
+				at = i
+				break
+			}
+		}
+		if at == -1 {
+			continue
+		}
+		found = true
+
+		params := slices.Clone(use.Deps[:at])
+		params = append(params, nil)
+		params = append(params, use.Deps[at+len(syms):]...)
+		args := make([]string, len(use.Deps))
+		for i := range args {
+			switch {
+			case i < at:
+				args[i] = fmt.Sprintf("x%d", i)
+			case i < at+len(syms):
+				args[i] = fmt.Sprintf("x%d.x%d", at, i-at)
+			default:
+				args[i] = fmt.Sprintf("x%d", i-len(syms)+1)
+			}
+		}
+
+		fmt.Fprintf(&b, "func %s %s(", r.receiver, use.Name)
+		for i, p := range params {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if p == nil {
+				fmt.Fprintf(&b, "x%d %s", i, name)
+				continue
+			}
+			fmt.Fprintf(&b, "x%d %s", i, r.typeName(p.Type))
+		}
+		fmt.Fprintf(&b, ") %s {\n", r.results(use.Produces, r.fails(use)))
+		fmt.Fprintf(&b, "\t// %s(%s)\n", use.Name, strings.Join(args, ", "))
+		b.WriteString("\tpanic(\"unimplemented\")\n}\n\n")
+	}
+	if !found {
+		return "", fmt.Errorf("tp: no rules match %s", strings.Join(seq, " "))
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// The grammar being refactored, and how to write Go source for it.
+type refactoring struct {
+	gr       *grammar
+	host     reflect.Type
+	receiver string
+
+	// the package of the grammar's type, whose names are written unqualified
+	pkgPath, pkgName string
+}
+
+func newRefactoring[U, V any](g Grammar[U, V]) (*refactoring, error) {
+	if _, ok := any(g).(interface{ funcRules() *funcRules }); ok {
+		return nil, errors.New("tp: the rules of a RuleSet cannot be refactored")
+	}
+	host := reflect.TypeOf(g)
+	named := host
+	receiver := "(g " + host.Name() + ")"
+	if host.Kind() == reflect.Pointer {
+		named = host.Elem()
+		receiver = "(g *" + named.Name() + ")"
+	}
+	return &refactoring{
+		gr:       scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]()),
+		host:     host,
+		receiver: receiver,
+		pkgPath:  named.PkgPath(),
+		pkgName:  strings.TrimSuffix(named.String(), "."+named.Name()),
+	}, nil
+}
+
+// The nonterminal with the given name, or nil if there is none.
+func (r *refactoring) symbol(name string) *symbol {
+	for _, sym := range r.gr.symbols {
+		if len(sym.Predictions) != 0 && ebnfName(sym.Type) == name {
+			return sym
+		}
+	}
+	return nil
+}
+
+// The grammar's own rules, in order of name.
+func (r *refactoring) rules() []*rule {
+	var res []*rule
+	for _, sym := range r.gr.symbols {
+		for _, p := range sym.Predictions {
+			// rules that implement an interface are also predicted by the interface
+			if p.Host.IsValid() || p.Index < 0 || p.Implements.Type != p.Produces {
+				continue
+			}
+			res = append(res, p)
+		}
+	}
+	slices.SortFunc(res, func(a, b *rule) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+// Whether a rule's method can return an error.
+func (r *refactoring) fails(p *rule) bool {
+	m, ok := r.host.MethodByName(p.Name)
+	return ok && m.Type.NumOut() == 2
+}
+
+// Every way of choosing a rule for each use of sym by a rule.
+func inlineChoices(use *rule, sym *symbol) [][]*rule {
+	alts := slices.Clone(sym.Predictions)
+	slices.SortStableFunc(alts, func(a, b *rule) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	res := [][]*rule{nil}
+	for _, d := range use.Deps {
+		if d != sym {
+			continue
+		}
+		var next [][]*rule
+		for _, prefix := range res {
+			for _, alt := range alts {
+				next = append(next, append(slices.Clip(prefix), alt))
+			}
+		}
+		res = next
+	}
+	return res
+}
+
+// Write the skeleton of a rule with each use of sym replaced by what a rule for it matches.
+func (r *refactoring) writeInlined(b *strings.Builder, use *rule, sym *symbol, alts []*rule) {
+	name := use.Name
+	fails := r.fails(use)
+	for _, alt := range alts {
+		name += alt.Name
+		fails = fails || r.fails(alt)
+	}
+
+	var params []*symbol
+	args := make([]string, len(use.Deps))
+	next := 0
+	for i, d := range use.Deps {
+		if d != sym {
+			params = append(params, d)
+			args[i] = fmt.Sprintf("x%d", len(params)-1)
+			continue
+		}
+		alt := alts[next]
+		next++
+		args[i] = fmt.Sprintf("%s(%s)", alt.Name, argList(len(params), len(alt.Deps)))
+		params = append(params, alt.Deps...)
+	}
+
+	fmt.Fprintf(b, "func %s %s(%s) %s {\n", r.receiver, name, r.params(params, 0), r.results(use.Produces, fails))
+	fmt.Fprintf(b, "\t// %s(%s)\n", use.Name, strings.Join(args, ", "))
+	b.WriteString("\tpanic(\"unimplemented\")\n}\n\n")
+}
+
+// Parameters named x0, x1 and so on, beginning at from, for a sequence of symbols.
+func (r *refactoring) params(syms []*symbol, from int) string {
+	parts := make([]string, len(syms))
+	for i, sym := range syms {
+		parts[i] = fmt.Sprintf("x%d %s", from+i, r.typeName(sym.Type))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// The results of a rule that produces t.
+func (r *refactoring) results(t reflect.Type, fails bool) string {
+	if fails {
+		return "(" + r.typeName(t) + ", error)"
+	}
+	return r.typeName(t)
+}
+
+// Name a type as it would be written in the grammar's package, and as gofmt would lay it out.
+func (r *refactoring) typeName(t reflect.Type) string {
+	name := t.String()
+	if r.pkgPath != "" {
+		name = strings.ReplaceAll(name, r.pkgPath+".", "")
+	}
+	name = strings.ReplaceAll(name, r.pkgName+".", "")
+
+	// reflect leaves out the space after the commas between type arguments, and puts one before the
+	// braces of interface and struct types
+	var b strings.Builder
+	for i, c := range name {
+		b.WriteRune(c)
+		if c == ',' && !strings.HasPrefix(name[i+1:], " ") {
+			b.WriteByte(' ')
+		}
+	}
+	return strings.NewReplacer("interface {", "interface{", "struct {", "struct{").Replace(b.String())
+}
+
+// Whether a name can be used as a Go identifier.
+func isIdentifier(name string) bool {
+	if name == "" || goKeywords[name] {
+		return false
+	}
+	for i, c := range name {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true,
+	"var": true,
+}
+
+// Arguments named x0, x1 and so on, beginning at from.
+func argList(from, n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("x%d", from+i)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// The name of a rule that produces a type, which must be exported to be found.
+func ruleName(typeName string) string {
+	c, n := utf8.DecodeRuneInString(typeName)
+	return string(unicode.ToUpper(c)) + typeName[n:]
+}
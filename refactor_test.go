@@ -0,0 +1,143 @@
+package tp_test
+
+import (
+	"errors"
+	"go/format"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type term struct {
+	value int
+}
+
+type termSum struct {
+	left, right term
+}
+
+type termGrammar struct{}
+
+func (termGrammar) Parse(x termSum) (termSum, error) {
+	return x, nil
+}
+
+func (termGrammar) Sum(x term, op plusTok, y term) termSum {
+	return termSum{left: x, right: y}
+}
+
+func (termGrammar) Int(x intTok) term {
+	return term{value: x.value}
+}
+
+func (termGrammar) Positive(op plusTok, x intTok) (term, error) {
+	if x.value == 0 {
+		return term{}, errors.New("zero is not positive")
+	}
+	return term{value: x.value}, nil
+}
+
+func TestInline(t *testing.T) {
+	src, err := tp.Inline(termGrammar{}, "term")
+	assert.Nil(t, err)
+	assert.Equal(t, src, `func (g termGrammar) SumIntInt(x0 intTok, x1 plusTok, x2 intTok) termSum {
+	// Sum(Int(x0), x1, Int(x2))
+	panic("unimplemented")
+}
+
+func (g termGrammar) SumIntPositive(x0 intTok, x1 plusTok, x2 plusTok, x3 intTok) (termSum, error) {
+	// Sum(Int(x0), x1, Positive(x2, x3))
+	panic("unimplemented")
+}
+
+func (g termGrammar) SumPositiveInt(x0 plusTok, x1 intTok, x2 plusTok, x3 intTok) (termSum, error) {
+	// Sum(Positive(x0, x1), x2, Int(x3))
+	panic("unimplemented")
+}
+
+func (g termGrammar) SumPositivePositive(x0 plusTok, x1 intTok, x2 plusTok, x3 plusTok, x4 intTok) (termSum, error) {
+	// Sum(Positive(x0, x1), x2, Positive(x3, x4))
+	panic("unimplemented")
+}
+`)
+}
+
+func TestInlineErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		g    tp.Grammar[expr, expr]
+		sym  string
+		err  string
+	}{
+		{"Missing", interfaceGrammar{}, "term", `tp: no nonterminal named "term"`},
+		{"Recursive", interfaceGrammar{}, "expr", "tp: expr refers to itself, so it cannot be inlined"},
+		{"Unused", interfaceGrammar{}, "intVal", "tp: no rules use intVal"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tp.Inline(test.g, test.sym)
+			assert.Equal(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	src, err := tp.Extract(termGrammar{}, "addend", "plusTok", "term")
+	assert.Nil(t, err)
+	assert.Equal(t, src, `type addend struct {
+	x0 plusTok
+	x1 term
+}
+
+func (g termGrammar) Addend(x0 plusTok, x1 term) addend {
+	return addend{x0, x1}
+}
+
+func (g termGrammar) Sum(x0 term, x1 addend) termSum {
+	// Sum(x0, x1.x0, x1.x1)
+	panic("unimplemented")
+}
+`)
+
+	for _, test := range []struct {
+		name string
+		seq  []string
+		err  string
+	}{
+		{"Existing", []string{"term"}, `tp: cannot extract a nonterminal named "termSum"`},
+		{"Empty", nil, "tp: cannot extract an empty sequence"},
+		{"Missing", []string{"minusTok"}, `tp: no symbol named "minusTok"`},
+		{"Unmatched", []string{"intTok", "intTok"}, "tp: no rules match intTok intTok"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			name := "pair"
+			if test.name == "Existing" {
+				name = "termSum"
+			}
+			_, err := tp.Extract(termGrammar{}, name, test.seq...)
+			assert.Equal(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestRefactorFormatted(t *testing.T) {
+	for _, refactor := range []func() (string, error){
+		func() (string, error) {
+			return tp.Inline(termGrammar{}, "term")
+		},
+		func() (string, error) {
+			return tp.Extract(termGrammar{}, "addend", "plusTok", "term")
+		},
+		func() (string, error) {
+			return tp.Extract(numberListGrammar{}, "items", "Delimited[numberToken,commaToken]", "arrayEndToken")
+		},
+	} {
+		src, err := refactor()
+		if !assert.Nil(t, err) {
+			continue
+		}
+		formatted, err := format.Source([]byte(src))
+		assert.Nil(t, err)
+		assert.Equal(t, src, string(formatted))
+	}
+}
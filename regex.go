@@ -1,7 +1,12 @@
 package tp
 
 import (
-	"sort"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -15,33 +20,155 @@ import (
 //	.     // any character
 //	[a-z] // character set
 //	\.    // escape special characters
+//	\x{h} // the character with the hexadecimal code point h
+//	\d    // character class, see EscapeClass
 //	e?    // zero or one
 //	e+    // one or more
 //	e*    // zero, one or more
-//	(s)   // grouping
+//	(s)   // grouping, capturing the text matched by s
+//	(?:s) // grouping without capturing
+//	\g{f} // the pattern of the fragment named f
+//	^s    // anchoring, see below
+//
+// Flags that change how part of a pattern matches can be set anywhere in it, as (?flags), for the
+// rest of the group that they appear in, or for a group of their own, as (?flags:s). The supported
+// flags are:
+//
+//	i     // match letters regardless of case
+//	s     // let . match \n, which it does anyway
+//	m     // let a leading ^ match at the start of any line
+//
+// A pattern that begins with ^, after any flags, only matches at the start of the text, as with
+// AtTextStart, or with the m flag at the start of a line, as with AtLineStart. As a token is always
+// matched from where the previous one ended, ^ has no meaning anywhere else in a pattern.
+//
+// Syntax from other dialects that has no meaning here, such as backreferences, $ or lazy
+// quantifiers, is rejected with an ErrUnsupportedRegex. RegexSyntax describes the syntax in full.
 //
 // So, e.g. a simple regex for a floating point number would be
 //
 //	[0-9]+\.[0-9]+
 func Regex[T any](re string, yield TokenConstructor[T]) TokenSpec[T] {
+	return regexSpec(re, func(l *Lexer[T], end LexerState, e parsedRegex) {
+		l.Final(end, yield)
+	})
+}
+
+// As Regex, but the token constructor is given a description of the match.
+func RegexMatch[T any](re string, yield MatchConstructor[T]) TokenSpec[T] {
+	return regexSpec(re, func(l *Lexer[T], end LexerState, e parsedRegex) {
+		l.FinalMatch(end, yield)
+	})
+}
+
+// As Regex, but the token constructor is given a view of the matched text. See BytesConstructor.
+func RegexBytes[T any](re string, yield BytesConstructor[T]) TokenSpec[T] {
+	return regexSpec(re, func(l *Lexer[T], end LexerState, e parsedRegex) {
+		l.FinalBytes(end, yield)
+	})
+}
+
+// Skip text that matches a regular expression, such as whitespace or comments, so that the stream
+// does not yield a token for it.
+func Skip[T any](re string) TokenSpec[T] {
+	return regexSpec(re, func(l *Lexer[T], end LexerState, e parsedRegex) {
+		l.FinalSkip(end)
+	})
+}
+
+// Match a literal piece of text regardless of case, so that LiteralCI("select", yield) matches
+// "select", "SELECT", "Select" and so on. Each letter matches the same runes as it would with the
+// i flag of Regex, but the text is not a pattern and needs no escaping. The text must not be empty.
+func LiteralCI[T any](text string, yield TokenConstructor[T]) TokenSpec[T] {
+	var e run = empty{}
+	for _, c := range text {
+		t := charset{ranges: foldRange(c, c)}.eval()
+		if _, ok := e.(empty); ok {
+			e = t
+			continue
+		}
+		e = seq{left: e, right: t}
+	}
 	return func(l *Lexer[T]) error {
+		if text == "" {
+			return errors.New("a case-insensitive literal must have some text")
+		}
+		l.describeRule(exprString(e))
 		end := l.State()
 		l.Final(end, yield)
+		e.compile(l, 0, end)
+		return nil
+	}
+}
 
-		s, err := regexProg.Tokenize([]byte(re)).Force()
-		if err != nil {
-			return err
-		}
-		e, err := Parse(regexParser, s)
+func regexSpec[T any](re string, final func(l *Lexer[T], end LexerState, e parsedRegex)) TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		l.describeRule(re)
+
+		e, err := parseRegex(re, l.fragments, l.escapes)
 		if err != nil {
 			return err
 		}
 
-		e.compile(l, 0, end)
+		end := l.State()
+		final(l, end, e)
+		e.expr.compile(l, l.anchorState(e.anchor), end)
 		return nil
 	}
 }
 
+type parsedRegex struct {
+	expr expr
+
+	// where a token matching the pattern may begin, as given by a leading ^
+	anchor int
+
+	// the offsets of the opening parenthesis of each group, in order
+	groups []int
+}
+
+func parseRegex(re string, fragments map[string]expr, escapes map[rune]charset) (parsedRegex, error) {
+	s, err := regexProg.Tokenize([]byte(re)).Force()
+	if err != nil {
+		return parsedRegex{}, err
+	}
+	anchor, s, err := leadingAnchor(s)
+	if err != nil {
+		return parsedRegex{}, err
+	}
+	if err := rejectUnsupported(s, escapes); err != nil {
+		return parsedRegex{}, err
+	}
+	if err := applyFlags(s); err != nil {
+		return parsedRegex{}, err
+	}
+	// the flags have been applied to the characters that they affect, wherever they were set
+	s = slices.DeleteFunc(s, func(t token) bool {
+		_, ok := t.(flagGroup)
+		return ok
+	})
+	if err := resolveFragments(s, fragments); err != nil {
+		return parsedRegex{}, err
+	}
+	e, err := Parse(regexParser.with(escapes), s)
+	if ruleErr := (*RuleError)(nil); errors.As(err, &ruleErr) {
+		// the rule's name and token positions mean nothing to the pattern's author
+		return parsedRegex{}, ruleErr.Err
+	}
+	if err != nil {
+		return parsedRegex{}, err
+	}
+
+	var groups []int
+	for _, t := range s {
+		if g, ok := t.(groupOpen); ok {
+			groups = append(groups, g.at)
+		}
+	}
+
+	return parsedRegex{expr: e, anchor: anchor, groups: groups}, nil
+}
+
 func (e empty) compile(prog programOps, start, end LexerState) {
 	prog.Empty(start, end)
 }
@@ -74,6 +201,10 @@ func (e nest) compile(prog programOps, start, end LexerState) {
 	e.nested.compile(prog, start, end)
 }
 
+func (e capture) compile(prog programOps, start, end LexerState) {
+	e.nested.compile(prog, start, end)
+}
+
 var regexParser = &regexRules{map[rune]charset{
 	'n': {ranges: []match{
 		{start: '\n', end: '\n'},
@@ -113,13 +244,28 @@ type charsetOpen struct{}
 type charsetClose struct{}
 type charsetRange struct{}
 type charsetInvert struct{}
-type groupOpen struct{}
+type groupOpen struct{ at int }
 type groupClose struct{}
 type quantity struct{ of rune }
 type bar struct{}
 type dot struct{}
-type slash struct{ of rune }
-type char struct{ of rune }
+type slash struct {
+	of   rune
+	fold bool
+}
+type char struct {
+	of   rune
+	fold bool
+
+	// whether the character was written as a numeric escape
+	hex bool
+}
+type flagGroup struct{ flags string }
+type flagOpen struct{ flags string }
+type fragmentRef struct {
+	name string
+	expr expr
+}
 
 func (charsetOpen) token()   {}
 func (charsetClose) token()  {}
@@ -132,6 +278,9 @@ func (bar) token()           {}
 func (dot) token()           {}
 func (slash) token()         {}
 func (char) token()          {}
+func (flagGroup) token()     {}
+func (flagOpen) token()      {}
+func (fragmentRef) token()   {}
 
 var regexProg Lexer[token]
 
@@ -153,11 +302,16 @@ func init() {
 	singleCharOp(']', func() token { return charsetClose{} })
 	singleCharOp('-', func() token { return charsetRange{} })
 	singleCharOp('^', func() token { return charsetInvert{} })
-	singleCharOp('(', func() token { return groupOpen{} })
 	singleCharOp(')', func() token { return groupClose{} })
 	singleCharOp('|', func() token { return bar{} })
 	singleCharOp('.', func() token { return dot{} })
 
+	gEnd := regexProg.State()
+	regexProg.Rune(0, gEnd, '(')
+	regexProg.Final(gEnd, func(start int, text string) (token, error) {
+		return groupOpen{at: start}, nil
+	})
+
 	qEnd := regexProg.State()
 	regexProg.Rune(0, qEnd, '*')
 	regexProg.Rune(0, qEnd, '?')
@@ -166,6 +320,58 @@ func init() {
 		return quantity{of: charRune(text)}, nil
 	})
 
+	flagMid := regexProg.State()
+	flagSet := regexProg.State()
+	flagGroupEnd := regexProg.State()
+	flagOpenEnd := regexProg.State()
+	regexProg.Rune(0, flagMid, '(')
+	regexProg.Rune(flagMid, flagSet, '?')
+	regexProg.Range(flagSet, flagSet, 'a', 'z')
+	regexProg.Rune(flagSet, flagSet, '-')
+	regexProg.Rune(flagSet, flagGroupEnd, ')')
+	regexProg.Rune(flagSet, flagOpenEnd, ':')
+	regexProg.Final(flagGroupEnd, func(start int, text string) (token, error) {
+		return flagGroup{flags: text[2 : len(text)-1]}, nil
+	})
+	regexProg.Final(flagOpenEnd, func(start int, text string) (token, error) {
+		return flagOpen{flags: text[2 : len(text)-1]}, nil
+	})
+
+	fragMid := regexProg.State()
+	fragOpen := regexProg.State()
+	fragName := regexProg.State()
+	fragEnd := regexProg.State()
+	regexProg.Rune(0, fragMid, '\\')
+	regexProg.Rune(fragMid, fragOpen, 'g')
+	regexProg.Rune(fragOpen, fragName, '{')
+	regexProg.Range(fragName, fragName, 'a', 'z')
+	regexProg.Range(fragName, fragName, 'A', 'Z')
+	regexProg.Range(fragName, fragName, '0', '9')
+	regexProg.Rune(fragName, fragName, '_')
+	regexProg.Rune(fragName, fragEnd, '}')
+	regexProg.Final(fragEnd, func(start int, text string) (token, error) {
+		return fragmentRef{name: text[3 : len(text)-1]}, nil
+	})
+
+	hexMid := regexProg.State()
+	hexOpen := regexProg.State()
+	hexDigits := regexProg.State()
+	hexEnd := regexProg.State()
+	regexProg.Rune(0, hexMid, '\\')
+	regexProg.Rune(hexMid, hexOpen, 'x')
+	regexProg.Rune(hexOpen, hexDigits, '{')
+	regexProg.Range(hexDigits, hexDigits, '0', '9')
+	regexProg.Range(hexDigits, hexDigits, 'a', 'f')
+	regexProg.Range(hexDigits, hexDigits, 'A', 'F')
+	regexProg.Rune(hexDigits, hexEnd, '}')
+	regexProg.Final(hexEnd, func(start int, text string) (token, error) {
+		r, err := strconv.ParseUint(text[3:len(text)-1], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return nil, fmt.Errorf("%s is not a valid character", text)
+		}
+		return char{of: rune(r), hex: true}, nil
+	})
+
 	escMid := regexProg.State()
 	escEnd := regexProg.State()
 	regexProg.Rune(0, escMid, '\\')
@@ -190,6 +396,8 @@ type programOps interface {
 type expr interface {
 	expr()
 	compile(p programOps, start, end LexerState)
+	submatch(m *submatcher, pos int, k func(pos int) bool) bool
+	write(b *strings.Builder, prec int)
 }
 
 type run interface {
@@ -229,6 +437,13 @@ type nest struct {
 	nested expr
 }
 
+type capture struct {
+	nested expr
+
+	// where the group begins in the pattern, which identifies it
+	at int
+}
+
 func (choice) expr() {}
 
 func (empty) run()  {}
@@ -244,6 +459,10 @@ func (nest) term() {}
 func (nest) run()  {}
 func (nest) expr() {}
 
+func (capture) term() {}
+func (capture) run()  {}
+func (capture) expr() {}
+
 func (match) term() {}
 func (match) run()  {}
 func (match) expr() {}
@@ -252,6 +471,16 @@ type regexRules struct {
 	escMap map[rune]charset
 }
 
+// The rules for patterns that may also use the given escapes.
+func (r *regexRules) with(escapes map[rune]charset) *regexRules {
+	if len(escapes) == 0 {
+		return r
+	}
+	res := &regexRules{escMap: maps.Clone(r.escMap)}
+	maps.Copy(res.escMap, escapes)
+	return res
+}
+
 func (r *regexRules) Parse(e expr) (expr, error) {
 	return e, nil
 }
@@ -261,6 +490,9 @@ func (r *regexRules) ParseDot(e dot) term {
 }
 
 func (r *regexRules) ParseChar(e char) term {
+	if e.fold {
+		return charset{ranges: foldRange(e.of, e.of)}.eval()
+	}
 	return match{start: e.of, end: e.of}
 }
 
@@ -269,21 +501,40 @@ func (r *regexRules) ParseRange(e charsetRange) term {
 }
 
 func (r *regexRules) ParseGroup(open groupOpen, e expr, close groupClose) term {
+	return capture{nested: e, at: open.at}
+}
+
+func (r *regexRules) ParseFlagGroup(open flagOpen, e expr, close groupClose) term {
 	return nest{e}
 }
 
+func (r *regexRules) ParseEmptyGroup(open flagOpen, close groupClose) term {
+	return nest{empty{}}
+}
+
+func (r *regexRules) ParseFragment(f fragmentRef) term {
+	return nest{f.expr}
+}
+
 func (r *regexRules) ParseCharset(op charsetOpen, contents charset, cl charsetClose) term {
 	return contents.eval()
 }
 
-func (r *regexRules) ParseInverseCharset(op charsetOpen, inv charsetInvert, contents charset, cl charsetClose) term {
-	return contents.inverse().eval()
+func (r *regexRules) ParseInverseCharset(op charsetOpen, inv charsetInvert, contents charset, cl charsetClose) (term, error) {
+	inverse, ok := contents.inverse()
+	if !ok {
+		return nil, errors.New("character set matches no characters")
+	}
+	return inverse.eval(), nil
 }
 
 func (r *regexRules) ParseEscaped(s slash) term {
 	if e, ok := r.escMap[s.of]; ok {
 		return e.eval()
 	}
+	if s.fold {
+		return charset{ranges: foldRange(s.of, s.of)}.eval()
+	}
 	return match{start: s.of, end: s.of}
 }
 
@@ -312,6 +563,9 @@ func (r *regexRules) ParseMoreChoice(left choice, _ bar, right run) choice {
 }
 
 func (r *regexRules) ParseCharsetChar(c char) charset {
+	if c.fold {
+		return charset{ranges: foldRange(c.of, c.of)}
+	}
 	return charset{ranges: []match{{start: c.of, end: c.of}}}
 }
 
@@ -319,6 +573,9 @@ func (r *regexRules) ParseCharsetEsc(c slash) charset {
 	if e, ok := r.escMap[c.of]; ok {
 		return e
 	}
+	if c.fold {
+		return charset{ranges: foldRange(c.of, c.of)}
+	}
 	return charset{ranges: []match{{start: c.of, end: c.of}}}
 }
 
@@ -335,6 +592,9 @@ func (r *regexRules) ParseCharsetDot(x dot) charset {
 }
 
 func (r *regexRules) ParseCharsetRange(left char, op charsetRange, right char) charset {
+	if left.fold {
+		return charset{ranges: foldRange(left.of, right.of)}
+	}
 	return charset{ranges: []match{{start: left.of, end: right.of}}}
 }
 
@@ -352,29 +612,35 @@ func (contents charset) eval() term {
 	return nest{res}
 }
 
-func (s charset) inverse() charset {
-	sort.Slice(s.ranges, func(i, j int) bool {
-		return s.ranges[i].start < s.ranges[j].start
-	})
+// The runes that can be encoded in UTF-8, which is all of them apart from the surrogate halves.
+var scalarRanges = []match{
+	{start: 0, end: 0xD7FF},
+	{start: 0xE000, end: unicode.MaxRune},
+}
 
-	var res []match
-	var last rune
+// Find the runes that can appear in text but are not in the set. If the set includes all of them,
+// there are none left and the result is not ok.
+func (s charset) inverse() (charset, bool) {
+	ranges := mergeRanges(slices.DeleteFunc(slices.Clone(s.ranges), func(r match) bool {
+		return r.end < r.start
+	}))
 
-	for _, r := range s.ranges {
-		if r.end < r.start {
-			continue
-		}
-		if r.start > last {
-			res = append(res, match{start: last, end: r.start - 1})
+	var res []match
+	for _, avail := range scalarRanges {
+		next := avail.start
+		for _, r := range ranges {
+			if r.end < next || r.start > avail.end {
+				continue
+			}
+			if r.start > next {
+				res = append(res, match{start: next, end: r.start - 1})
+			}
+			next = r.end + 1
 		}
-		if r.end >= last {
-			last = r.end + 1
+		if next <= avail.end {
+			res = append(res, match{start: next, end: avail.end})
 		}
 	}
 
-	if last < unicode.MaxRune {
-		res = append(res, match{start: last, end: unicode.MaxRune})
-	}
-
-	return charset{ranges: res}
+	return charset{ranges: res}, len(res) != 0
 }
@@ -1,8 +1,12 @@
 package tp
 
 import (
+	"math/rand"
+	"regexp"
+	"slices"
 	"testing"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/bobappleyard/assert"
 )
@@ -156,7 +160,7 @@ func TestParse(t *testing.T) {
 		{
 			name: "Group",
 			in:   `(ab)+`,
-			out: repeat{nest{nested: seq{
+			out: repeat{capture{nested: seq{
 				left:  match{start: 'a', end: 'a'},
 				right: match{start: 'b', end: 'b'},
 			}}},
@@ -199,16 +203,22 @@ func TestParse(t *testing.T) {
 			name: "InverseCharset",
 			in:   `[^b-y]`,
 			out: nest{choice{
-				left:  match{start: 0, end: 'a'},
-				right: match{start: 'z', end: unicode.MaxRune},
+				left: choice{
+					left:  match{start: 0, end: 'a'},
+					right: match{start: 'z', end: 0xD7FF},
+				},
+				right: match{start: 0xE000, end: unicode.MaxRune},
 			}},
 		},
 		{
 			name: "InverseCharset",
 			in:   `[^bcd]`,
 			out: nest{choice{
-				left:  match{start: 0, end: 'a'},
-				right: match{start: 'e', end: unicode.MaxRune},
+				left: choice{
+					left:  match{start: 0, end: 'a'},
+					right: match{start: 'e', end: 0xD7FF},
+				},
+				right: match{start: 0xE000, end: unicode.MaxRune},
 			}},
 		},
 	} {
@@ -225,3 +235,273 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestRegexCapture(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		pattern string
+		in      string
+		groups  []string
+	}{
+		{
+			name:    "NoGroups",
+			pattern: `abc`,
+			in:      "abc",
+			groups:  []string{"abc"},
+		},
+		{
+			name:    "Sequence",
+			pattern: `(\d+)\.(\d+)`,
+			in:      "12.5",
+			groups:  []string{"12.5", "12", "5"},
+		},
+		{
+			name:    "Optional",
+			pattern: `(\d+)(\.(\d+))?`,
+			in:      "12",
+			groups:  []string{"12", "12", "", ""},
+		},
+		{
+			name:    "OptionalPresent",
+			pattern: `(\d+)(\.(\d+))?`,
+			in:      "12.5",
+			groups:  []string{"12.5", "12", ".5", "5"},
+		},
+		{
+			name:    "RepeatLastWins",
+			pattern: `(a|b)+c`,
+			in:      "abac",
+			groups:  []string{"abac", "a"},
+		},
+		{
+			name:    "RepeatKeepsInner",
+			pattern: `((a)|b)+`,
+			in:      "ab",
+			groups:  []string{"ab", "b", "a"},
+		},
+		{
+			name:    "Greedy",
+			pattern: `(a*)(a*)`,
+			in:      "aaa",
+			groups:  []string{"aaa", "aaa", ""},
+		},
+		{
+			name:    "LeftFirst",
+			pattern: `(a|ab)(c|bcd)?`,
+			in:      "abcd",
+			groups:  []string{"abcd", "a", "bcd"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewLexer(RegexCapture(test.pattern, func(m Match, groups []Match) ([]string, error) {
+				texts := make([]string, len(groups))
+				for i, g := range groups {
+					texts[i] = g.Text()
+				}
+				return texts, nil
+			}))
+			if !assert.Nil(t, err) {
+				return
+			}
+			groups, n, err := p.Match([]byte(test.in))
+			assert.Nil(t, err)
+			assert.Equal(t, n, len(test.in))
+			assert.Equal(t, groups, test.groups)
+		})
+	}
+}
+
+func TestRegexExhaustive(t *testing.T) {
+	for _, test := range []struct {
+		pattern, reference string
+	}{
+		{pattern: `abc`},
+		{pattern: `a|bc?`},
+		{pattern: `(?:ab)*c?`},
+		{pattern: `a+b*`},
+		{pattern: `.`},
+		{pattern: `[^a]`},
+		{pattern: `[^a-cx-z]+`},
+		{pattern: `[^\n]*`},
+		{pattern: `[a-cb-f]`},
+		{pattern: `\d+\.\d*`},
+		{pattern: `\s`, reference: `[\n\t ]`},
+		{pattern: `\c\w*`, reference: `[a-zA-Z_][a-zA-Z0-9_]*`},
+		{pattern: `(?i)k[^k]`},
+		{pattern: `(?i:a)b`},
+		{pattern: `((a)|b)+`},
+	} {
+		t.Run(test.pattern, func(t *testing.T) {
+			ref := test.reference
+			if ref == "" {
+				ref = test.pattern
+			}
+			checkRegexExhaustive(t, test.pattern, ref, 3)
+		})
+	}
+}
+
+// Check that the lexer compiled from a pattern accepts exactly the same strings as the reference
+// pattern, interpreted by the standard library. Every string up to maxLen runes long is tried,
+// using an alphabet made from the runes either side of the boundaries of each range in the pattern.
+func checkRegexExhaustive(t *testing.T, pattern, reference string, maxLen int) {
+	t.Helper()
+
+	p, err := ParseRegex(pattern)
+	if !assert.Nil(t, err) {
+		return
+	}
+	l, err := NewLexer(Regex(pattern, func(start int, text string) (bool, error) {
+		return true, nil
+	}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	l.Freeze()
+	ref := regexp.MustCompile(`^(?s:` + reference + `)$`)
+
+	alphabet := regexAlphabet(p.parsed.expr)
+	var buf []rune
+	var try func()
+	try = func() {
+		if len(buf) > 0 {
+			src := string(buf)
+			_, n, err := l.Match([]byte(src))
+			got := err == nil && n == len(src)
+			if want := ref.MatchString(src); got != want {
+				t.Errorf("%q: got %v, want %v", src, got, want)
+			}
+		}
+		if len(buf) == maxLen {
+			return
+		}
+		for _, r := range alphabet {
+			buf = append(buf, r)
+			try()
+			buf = buf[:len(buf)-1]
+		}
+	}
+	try()
+}
+
+// Find the runes at and either side of the ends of every range in an expression, along with the
+// extremes of the rune space.
+func regexAlphabet(e expr) []rune {
+	res := []rune{0, unicode.MaxRune}
+	var walk func(e expr)
+	walk = func(e expr) {
+		switch e := e.(type) {
+		case match:
+			res = append(res, e.start-1, e.start, e.end, e.end+1)
+		case seq:
+			walk(e.left)
+			walk(e.right)
+		case choice:
+			walk(e.left)
+			walk(e.right)
+		case repeat:
+			walk(e.repeated)
+		case nest:
+			walk(e.nested)
+		case capture:
+			walk(e.nested)
+		}
+	}
+	walk(e)
+
+	res = slices.DeleteFunc(res, func(r rune) bool {
+		return !utf8.ValidRune(r)
+	})
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+func TestCharsetInverse(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// ranges are drawn close to the interesting boundaries so that they touch them often
+	bounds := []rune{0, 'a', 0xD7FF, 0xD800, 0xDFFF, 0xE000, unicode.MaxRune}
+	randRune := func() rune {
+		r := bounds[rng.Intn(len(bounds))] + rune(rng.Intn(5)-2)
+		return min(max(r, 0), unicode.MaxRune)
+	}
+
+	_, ok := charset{ranges: scalarRanges}.inverse()
+	assert.False(t, ok)
+	_, ok = charset{ranges: []match{{start: 0, end: unicode.MaxRune}}}.inverse()
+	assert.False(t, ok)
+
+	for i := 0; i < 1000; i++ {
+		var set charset
+		for range rng.Intn(4) {
+			set.ranges = append(set.ranges, match{start: randRune(), end: randRune()})
+		}
+		inv, ok := set.inverse()
+		assert.Equal(t, ok, len(inv.ranges) != 0)
+
+		for j, r := range inv.ranges {
+			assert.True(t, r.start <= r.end)
+			if j > 0 {
+				// sorted, and not overlapping or touching
+				assert.True(t, inv.ranges[j-1].end+1 < r.start)
+			}
+		}
+
+		probes := slices.Clone(bounds)
+		for _, r := range set.ranges {
+			probes = append(probes, r.start-1, r.start, r.end, r.end+1)
+		}
+		for _, r := range probes {
+			if r < 0 || r > unicode.MaxRune {
+				continue
+			}
+			want := utf8.ValidRune(r) && !charsetContains(set, r)
+			if got := charsetContains(inv, r); got != want {
+				t.Errorf("%v: inverse contains %U: got %v, want %v", set.ranges, r, got, want)
+			}
+		}
+	}
+}
+
+func charsetContains(s charset, r rune) bool {
+	for _, m := range s.ranges {
+		if m.start <= r && r <= m.end {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRegexHexEscape(t *testing.T) {
+	l, err := NewLexer(Regex(`caf\x{e9}[\x{3b1}-\x{3c9}]+`, func(start int, text string) (string, error) {
+		return text, nil
+	}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	tok, n, err := l.Match([]byte("caféλμ"))
+	assert.Nil(t, err)
+	assert.Equal(t, tok, "caféλμ")
+	assert.Equal(t, n, len("caféλμ"))
+
+	for _, re := range []string{`\x{d800}`, `\x{110000}`, `\x{123456789}`} {
+		_, err := ParseRegex(re)
+		assert.Equal(t, err.Error(), re+" is not a valid character")
+	}
+}
+
+func TestRegexEmptyCharset(t *testing.T) {
+	for _, re := range []string{`[^\x{0}-\x{10ffff}]`, `a[^\x{0}-\x{d7ff}\x{e000}-\x{10ffff}]`} {
+		_, err := ParseRegex(re)
+		if assert.True(t, err != nil) {
+			assert.Equal(t, err.Error(), "character set matches no characters")
+		}
+
+		_, err = NewLexer(Regex(re, func(start int, text string) (string, error) {
+			return text, nil
+		}))
+		if assert.True(t, err != nil) {
+			assert.Equal(t, err.Error(), "rule 0: `"+re+"`: character set matches no characters")
+		}
+	}
+}
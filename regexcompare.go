@@ -0,0 +1,51 @@
+package tp
+
+import "slices"
+
+// Whether there is any string that both patterns match.
+//
+// The empty string is not considered, as a lexer never produces a token for it.
+func (p *RegexPattern) Overlaps(q *RegexPattern) bool {
+	both, _, _ := comparePatterns(p, q)
+	return both
+}
+
+// Whether every string that q matches is also matched by p.
+//
+// The empty string is not considered, as a lexer never produces a token for it.
+func (p *RegexPattern) Subsumes(q *RegexPattern) bool {
+	_, _, onlyQ := comparePatterns(p, q)
+	return !onlyQ
+}
+
+// Whether the patterns match exactly the same strings.
+//
+// The empty string is not considered, as a lexer never produces a token for it.
+func (p *RegexPattern) Equivalent(q *RegexPattern) bool {
+	_, onlyP, onlyQ := comparePatterns(p, q)
+	return !onlyP && !onlyQ
+}
+
+// Run the subset construction over a machine that recognises both patterns, and report whether
+// there are strings that both match, that only p matches, and that only q matches.
+func comparePatterns(p, q *RegexPattern) (both, onlyP, onlyQ bool) {
+	l := new(Lexer[int])
+	for _, e := range []expr{p.parsed.expr, q.parsed.expr} {
+		end := l.State()
+		l.Final(end, nil)
+		e.compile(l, 0, end)
+	}
+
+	for _, set := range l.subsets() {
+		if !set.Moved {
+			continue
+		}
+		finals := l.subsetFinals(set.States)
+		inP := slices.Contains(finals, 0)
+		inQ := slices.Contains(finals, 1)
+		both = both || inP && inQ
+		onlyP = onlyP || inP && !inQ
+		onlyQ = onlyQ || inQ && !inP
+	}
+	return both, onlyP, onlyQ
+}
@@ -0,0 +1,40 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestRegexCompare(t *testing.T) {
+	for _, test := range []struct {
+		p, q                           string
+		overlaps, subsumes, equivalent bool
+	}{
+		{p: `[a-z]+`, q: `if`, overlaps: true, subsumes: true},
+		{p: `if`, q: `[a-z]+`, overlaps: true},
+		{p: `[a-m]+`, q: `[h-z]+`, overlaps: true},
+		{p: `[0-9]+`, q: `[a-z]+`},
+		{p: `a|b`, q: `[ab]`, overlaps: true, subsumes: true, equivalent: true},
+		{p: `a*`, q: `a+`, overlaps: true, subsumes: true, equivalent: true},
+		{p: `(?i)if`, q: `IF|If|iF|if`, overlaps: true, subsumes: true, equivalent: true},
+		{p: `[^a]`, q: `a`},
+		{p: `.`, q: `[^a]`, overlaps: true, subsumes: true},
+		{p: `a(?:ba)*`, q: `(?:ab)*a`, overlaps: true, subsumes: true, equivalent: true},
+	} {
+		t.Run(test.p+" "+test.q, func(t *testing.T) {
+			p, err := ParseRegex(test.p)
+			if !assert.Nil(t, err) {
+				return
+			}
+			q, err := ParseRegex(test.q)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, p.Overlaps(q), test.overlaps)
+			assert.Equal(t, q.Overlaps(p), test.overlaps)
+			assert.Equal(t, p.Subsumes(q), test.subsumes)
+			assert.Equal(t, p.Equivalent(q), test.equivalent)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package tp
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// RegexPattern is a parsed regular expression, in the syntax accepted by Regex.
+type RegexPattern struct {
+	parsed parsedRegex
+}
+
+// Parse a regular expression without adding it to a lexer.
+func ParseRegex(re string) (*RegexPattern, error) {
+	parsed, err := parseRegex(re, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexPattern{parsed: parsed}, nil
+}
+
+// Render the pattern in the syntax accepted by Regex. Groups that are only needed to make the
+// pattern parse correctly are written as (?:s), and character classes are written out in full.
+func (p *RegexPattern) String() string {
+	var b strings.Builder
+	prec := precChoice
+	switch p.parsed.anchor {
+	case anchorLine:
+		b.WriteString("(?m)^")
+		prec = precSeq
+	case anchorText:
+		b.WriteString("^")
+		prec = precSeq
+	}
+	p.parsed.expr.write(&b, prec)
+	return b.String()
+}
+
+// Produce an equivalent pattern in a simpler form, by merging character sets and removing
+// unnecessary nesting.
+func (p *RegexPattern) Simplify() *RegexPattern {
+	return &RegexPattern{parsed: parsedRegex{
+		expr:   simplify(p.parsed.expr),
+		anchor: p.parsed.anchor,
+		groups: p.parsed.groups,
+	}}
+}
+
+// How tightly an expression binds, which decides whether it needs grouping when it appears inside
+// another.
+const (
+	precChoice = iota
+	precSeq
+	precRepeat
+	precAtom
+)
+
+func simplify(e expr) expr {
+	if set, ok := asSet(e); ok {
+		return charset{ranges: mergeRanges(set)}.evalExpr()
+	}
+
+	switch e := e.(type) {
+	case nest:
+		return simplify(e.nested)
+
+	case capture:
+		return capture{nested: simplify(e.nested), at: e.at}
+
+	case seq:
+		return seq{left: asRun(simplify(e.left)), right: asRun(simplify(e.right))}
+
+	case repeat:
+		inner := simplify(e.repeated)
+		if r, ok := inner.(repeat); ok {
+			return r
+		}
+		return repeat{repeated: asTerm(inner)}
+
+	case choice:
+		// Only neighbouring sets are merged, as the order of the alternatives decides which one
+		// the submatches are taken from.
+		var alts []expr
+		var set []match
+		for _, alt := range choiceAlternatives(e) {
+			alt = simplify(alt)
+			if s, ok := asSet(alt); ok {
+				set = append(set, s...)
+				continue
+			}
+			if len(set) != 0 {
+				alts = append(alts, charset{ranges: mergeRanges(set)}.evalExpr())
+				set = nil
+			}
+			alts = append(alts, alt)
+		}
+		if len(set) != 0 {
+			alts = append(alts, charset{ranges: mergeRanges(set)}.evalExpr())
+		}
+		// a repeated alternative with a capturing group still counts towards the group numbers
+		alts = slices.CompactFunc(alts, func(a, b expr) bool {
+			return !hasCapture(b) && exprString(a) == exprString(b)
+		})
+		res := alts[0]
+		for _, alt := range alts[1:] {
+			res = choice{left: res, right: alt}
+		}
+		return res
+	}
+
+	return e
+}
+
+// As eval, but avoiding nesting where it is not needed.
+func (contents charset) evalExpr() expr {
+	if len(contents.ranges) == 1 {
+		return contents.ranges[0]
+	}
+	return contents.eval()
+}
+
+func choiceAlternatives(e expr) []expr {
+	switch e := e.(type) {
+	case choice:
+		return append(choiceAlternatives(e.left), choiceAlternatives(e.right)...)
+	case nest:
+		if _, ok := e.nested.(choice); ok {
+			return choiceAlternatives(e.nested)
+		}
+	}
+	return []expr{e}
+}
+
+// Find whether an expression only ever matches a single rune, and if so which ones.
+func asSet(e expr) ([]match, bool) {
+	switch e := e.(type) {
+	case match:
+		return []match{e}, true
+	case nest:
+		return asSet(e.nested)
+	case choice:
+		left, ok := asSet(e.left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := asSet(e.right)
+		if !ok {
+			return nil, false
+		}
+		return append(left, right...), true
+	}
+	return nil, false
+}
+
+// Sort ranges and combine those that overlap or touch.
+func mergeRanges(ranges []match) []match {
+	ranges = slices.Clone(ranges)
+	slices.SortFunc(ranges, func(a, b match) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	var res []match
+	for _, r := range ranges {
+		if len(res) > 0 && r.start <= res[len(res)-1].end+1 {
+			last := &res[len(res)-1]
+			last.end = max(last.end, r.end)
+			continue
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+func hasCapture(e expr) bool {
+	switch e := e.(type) {
+	case capture:
+		return true
+	case nest:
+		return hasCapture(e.nested)
+	case seq:
+		return hasCapture(e.left) || hasCapture(e.right)
+	case choice:
+		return hasCapture(e.left) || hasCapture(e.right)
+	case repeat:
+		return hasCapture(e.repeated)
+	}
+	return false
+}
+
+func exprString(e expr) string {
+	var b strings.Builder
+	e.write(&b, precChoice)
+	return b.String()
+}
+
+func (e empty) write(b *strings.Builder, prec int) {
+	b.WriteString("(?:)")
+}
+
+func (e match) write(b *strings.Builder, prec int) {
+	if e.start == 0 && e.end == unicode.MaxRune {
+		b.WriteByte('.')
+		return
+	}
+	if e.start == e.end {
+		writeRegexRune(b, e.start, `.[]()|?+*\^${`)
+		return
+	}
+	writeRegexSet(b, []match{e})
+}
+
+func (e seq) write(b *strings.Builder, prec int) {
+	if prec > precSeq {
+		b.WriteString("(?:")
+		defer b.WriteString(")")
+	}
+	e.left.write(b, precSeq)
+	e.right.write(b, precSeq)
+}
+
+func (e choice) write(b *strings.Builder, prec int) {
+	if set, ok := asSet(e); ok {
+		writeRegexSet(b, set)
+		return
+	}
+	if _, ok := e.right.(empty); ok {
+		if prec > precRepeat {
+			b.WriteString("(?:")
+			defer b.WriteString(")")
+		}
+		if r, ok := e.left.(repeat); ok {
+			r.repeated.write(b, precAtom)
+			b.WriteByte('*')
+			return
+		}
+		e.left.write(b, precAtom)
+		b.WriteByte('?')
+		return
+	}
+	if prec > precChoice {
+		b.WriteString("(?:")
+		defer b.WriteString(")")
+	}
+	e.left.write(b, precChoice)
+	b.WriteByte('|')
+	e.right.write(b, precChoice)
+}
+
+func (e repeat) write(b *strings.Builder, prec int) {
+	if prec > precRepeat {
+		b.WriteString("(?:")
+		defer b.WriteString(")")
+	}
+	e.repeated.write(b, precAtom)
+	b.WriteByte('+')
+}
+
+func (e nest) write(b *strings.Builder, prec int) {
+	e.nested.write(b, prec)
+}
+
+func (e capture) write(b *strings.Builder, prec int) {
+	b.WriteByte('(')
+	e.nested.write(b, precChoice)
+	b.WriteByte(')')
+}
+
+// Write a set of ranges as a character class. Sets that include the extremes of the rune space are
+// written as the inverse of what they exclude.
+func writeRegexSet(b *strings.Builder, set []match) {
+	set = mergeRanges(set)
+	invert := set[0].start == 0 && set[len(set)-1].end == unicode.MaxRune
+	if invert {
+		inverse, ok := charset{ranges: set}.inverse()
+		if !ok {
+			// everything, apart perhaps from the surrogate halves, which never appear in text
+			b.WriteByte('.')
+			return
+		}
+		set = inverse.ranges
+	}
+	b.WriteByte('[')
+	if invert {
+		b.WriteByte('^')
+	}
+	for _, r := range set {
+		writeRegexRune(b, r.start, `[]\^-`)
+		if r.end == r.start {
+			continue
+		}
+		if r.end > r.start+1 {
+			b.WriteByte('-')
+		}
+		writeRegexRune(b, r.end, `[]\^-`)
+	}
+	b.WriteByte(']')
+}
+
+// Write a rune so that it is read back as itself. Runes that cannot be written in a pattern as they
+// are, such as control characters and anything beyond ASCII, are written as numeric escapes.
+func writeRegexRune(b *strings.Builder, r rune, special string) {
+	switch {
+	case r == '\n':
+		b.WriteString(`\n`)
+	case r == '\r':
+		b.WriteString(`\r`)
+	case r == '\t':
+		b.WriteString(`\t`)
+	case r < ' ' || r > '~':
+		fmt.Fprintf(b, `\x{%x}`, r)
+	default:
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+}
+
+func asRun(e expr) run {
+	if r, ok := e.(run); ok {
+		return r
+	}
+	return nest{e}
+}
+
+func asTerm(e expr) term {
+	if t, ok := e.(term); ok {
+		return t
+	}
+	return nest{e}
+}
@@ -0,0 +1,82 @@
+package tp
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestRegexString(t *testing.T) {
+	for _, test := range []struct {
+		in, out, simple string
+	}{
+		{in: `abc`, out: `abc`, simple: `abc`},
+		{in: `.`, out: `.`, simple: `.`},
+		{in: `a?`, out: `a?`, simple: `a?`},
+		{in: `(ab)*`, out: `(ab)*`, simple: `(ab)*`},
+		{in: `ab|cd`, out: `ab|cd`, simple: `ab|cd`},
+		{in: `(?:ab|cd)e`, out: `(?:ab|cd)e`, simple: `(?:ab|cd)e`},
+		{in: `(?:ab)+`, out: `(?:ab)+`, simple: `(?:ab)+`},
+		{in: `\d+\.\d+`, out: `[0-9]+\.[0-9]+`, simple: `[0-9]+\.[0-9]+`},
+		{in: `\s`, out: `[\t\n ]`, simple: `[\t\n ]`},
+		{in: `[a-cb-f]`, out: `[a-f]`, simple: `[a-f]`},
+		{in: `a|b|c`, out: `[a-c]`, simple: `[a-c]`},
+		{in: `a|bc|d`, out: `a|bc|d`, simple: `a|bc|d`},
+		{in: `a|b|cd|e`, out: `[ab]|cd|e`, simple: `[ab]|cd|e`},
+		{in: `a|a`, out: `[a]`, simple: `a`},
+		{in: `[^a]`, out: `[^a]`, simple: `[^a]`},
+		{in: `[\^\]]`, out: `[\]\^]`, simple: `[\]\^]`},
+		{in: `\(\.\)`, out: `\(\.\)`, simple: `\(\.\)`},
+		{in: `(?i)a`, out: `[Aa]`, simple: `[Aa]`},
+		{in: `(?i)k`, out: `[Kk\x{212a}]`, simple: `[Kk\x{212a}]`},
+		{in: `(?i)s`, out: `[Ss\x{17f}]`, simple: `[Ss\x{17f}]`},
+		{in: `\x{e9}\x{7F}`, out: `\x{e9}\x{7f}`, simple: `\x{e9}\x{7f}`},
+		{in: `\$\{2}`, out: `\$\{2}`, simple: `\$\{2}`},
+		{in: `(?:(?:a+)+)`, out: `(?:a+)+`, simple: `a+`},
+		{in: `(?:)`, out: `(?:)`, simple: `(?:)`},
+		{in: `a(?:)b`, out: `a(?:)b`, simple: `a(?:)b`},
+		{in: `(a)|(a)`, out: `(a)|(a)`, simple: `(a)|(a)`},
+		{in: `(?:ab)|(?:ab)`, out: `ab|ab`, simple: `ab`},
+		{in: `[\x{0}-\x{d7ff}\x{e000}-\x{10ffff}]`, out: `.`, simple: `.`},
+		{in: `^ab`, out: `^ab`, simple: `^ab`},
+		{in: `(?i)(?m)^a`, out: `(?m)^[Aa]`, simple: `(?m)^[Aa]`},
+		{in: `^(?:ab|cd)`, out: `^(?:ab|cd)`, simple: `^(?:ab|cd)`},
+	} {
+		t.Run(test.in, func(t *testing.T) {
+			p, err := ParseRegex(test.in)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, p.String(), test.out)
+			assert.Equal(t, p.Simplify().String(), test.simple)
+
+			again, err := ParseRegex(p.Simplify().String())
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, again.Simplify().String(), test.simple)
+		})
+	}
+}
+
+func TestRegexStringFolding(t *testing.T) {
+	for r := range rune(0x20000) {
+		if unicode.SimpleFold(r) == r {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString("(?i)")
+		writeRegexRune(&b, r, `.[]()|?+*\^${`)
+		p, err := ParseRegex(b.String())
+		if !assert.Nil(t, err) {
+			return
+		}
+		again, err := ParseRegex(p.String())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, again.String(), p.String())
+	}
+}
@@ -0,0 +1,58 @@
+package tp
+
+import (
+	"reflect"
+)
+
+// The names of the symbols of the regex grammar, as they appear in RegexSyntax. Tokens that stand
+// for a single character are written as that character.
+var regexSymbolNames = map[reflect.Type]string{
+	reflect.TypeFor[expr]():    "Pattern",
+	reflect.TypeFor[choice]():  "Choice",
+	reflect.TypeFor[run]():     "Sequence",
+	reflect.TypeFor[term]():    "Term",
+	reflect.TypeFor[charset](): "SetItems",
+
+	reflect.TypeFor[char]():        "char",
+	reflect.TypeFor[slash]():       "escape",
+	reflect.TypeFor[quantity]():    "quantifier",
+	reflect.TypeFor[flagOpen]():    "flagOpen",
+	reflect.TypeFor[fragmentRef](): "fragment",
+
+	reflect.TypeFor[bar]():           `"|"`,
+	reflect.TypeFor[dot]():           `"."`,
+	reflect.TypeFor[charsetOpen]():   `"["`,
+	reflect.TypeFor[charsetClose]():  `"]"`,
+	reflect.TypeFor[charsetRange]():  `"-"`,
+	reflect.TypeFor[charsetInvert](): `"^"`,
+	reflect.TypeFor[groupOpen]():     `"("`,
+	reflect.TypeFor[groupClose]():    `")"`,
+}
+
+// The tokens of the regex grammar that are more than a single character, as read by regexProg.
+const regexTokenSyntax = `char = /* a character from " " to "~" that is not part of another token */ | "\\x{" hexDigit { hexDigit } "}" .
+hexDigit = "0" … "9" | "a" … "f" | "A" … "F" .
+escape = "\\" " " … "~" .
+quantifier = "?" | "+" | "*" .
+flagOpen = "(?" { "a" … "z" | "-" } ":" .
+fragment = "\\g{" nameChar { nameChar } "}" .
+nameChar = "a" … "z" | "A" … "Z" | "0" … "9" | "_" .
+`
+
+// Describe the syntax accepted by Regex in the Extended Backus-Naur Form used by the Go
+// specification, as produced by EBNF. The productions that begin with a capital letter are those of
+// the grammar that patterns are parsed with, and the others are its tokens, which are read without
+// anything between them. A pattern may also begin with ^, and may set flags anywhere, as (?flags),
+// as described by Regex. These are dealt with before the pattern is parsed, so are not part of the
+// grammar.
+//
+// Some patterns that fit the syntax are still rejected. Syntax from other dialects that would be
+// read as something else here is rejected with an ErrUnsupportedRegex, as are unknown flags and
+// references to fragments that have not been defined. ParseRegex checks a pattern without
+// compiling it.
+func RegexSyntax() string {
+	gr := scanGrammar(reflect.ValueOf(regexParser), reflect.TypeFor[expr]())
+	return gr.ebnf(func(t reflect.Type) string {
+		return regexSymbolNames[t]
+	}) + regexTokenSyntax
+}
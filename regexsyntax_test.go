@@ -0,0 +1,35 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func ExampleRegexSyntax() {
+	fmt.Print(RegexSyntax())
+
+	// Output:
+	// Pattern = Choice | Sequence .
+	// Choice = Sequence "|" Sequence | Choice "|" Sequence .
+	// Sequence = Term | Term quantifier | Sequence Sequence .
+	// SetItems = "|" | char | SetItems SetItems | "." | escape | quantifier | char "-" char .
+	// Term = char | "[" SetItems "]" | "." | flagOpen ")" | escape | flagOpen Pattern ")" | fragment | "(" Pattern ")" | "[" "^" SetItems "]" | "-" .
+	// char = /* a character from " " to "~" that is not part of another token */ | "\\x{" hexDigit { hexDigit } "}" .
+	// hexDigit = "0" … "9" | "a" … "f" | "A" … "F" .
+	// escape = "\\" " " … "~" .
+	// quantifier = "?" | "+" | "*" .
+	// flagOpen = "(?" { "a" … "z" | "-" } ":" .
+	// fragment = "\\g{" nameChar { nameChar } "}" .
+	// nameChar = "a" … "z" | "A" … "Z" | "0" … "9" | "_" .
+}
+
+func TestRegexSyntaxNamesEverySymbol(t *testing.T) {
+	gr := scanGrammar(reflect.ValueOf(regexParser), reflect.TypeFor[expr]())
+	for typ := range gr.symbols {
+		_, ok := regexSymbolNames[typ]
+		assert.True(t, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package tp
+
+import (
+	"sync/atomic"
+)
+
+// Reloadable holds a grammar that can be replaced while it is in use, for long-running processes
+// whose grammar changes, such as one loaded from configuration.
+//
+// Parses take the grammar from Grammar as they begin, and so carry on with it if it is replaced
+// while they run. Parses begun after a replacement use the new grammar.
+type Reloadable[U, V any] struct {
+	cur atomic.Pointer[Grammar[U, V]]
+}
+
+// Create a Reloadable holding a grammar, which must be valid as for Load.
+func NewReloadable[U, V any](g Grammar[U, V]) (*Reloadable[U, V], error) {
+	r := &Reloadable[U, V]{}
+	if err := r.Load(g); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// The current grammar.
+func (r *Reloadable[U, V]) Grammar() Grammar[U, V] {
+	return *r.cur.Load()
+}
+
+// Replace the current grammar. The new grammar is prepared for parsing before it replaces the old
+// one, so this may take some time, but the old grammar remains in use until it is done. If the new
+// grammar is not valid then the old one is kept and an error is returned.
+func (r *Reloadable[U, V]) Load(g Grammar[U, V]) error {
+	if err := checkGrammar(g); err != nil {
+		return err
+	}
+	r.cur.Store(&g)
+	return nil
+}
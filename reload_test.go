@@ -0,0 +1,66 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func sumRules(right func(intTok) intVal) *tp.RuleSet[expr, expr] {
+	g := tp.NewRuleSet(func(x expr) (expr, error) {
+		return x, nil
+	})
+	g.AddRule(right)
+	g.AddRule(func(left expr, op plusTok, right intVal) add {
+		return add{left: left, right: right}
+	})
+	return g
+}
+
+func TestReloadable(t *testing.T) {
+	toks := []any{intTok{1}, plusTok{}, intTok{2}}
+
+	r, err := tp.NewReloadable[expr, expr](sumRules(func(x intTok) intVal {
+		return intVal{x.value}
+	}))
+	assert.Nil(t, err)
+
+	old := r.Grammar()
+	assert.Nil(t, r.Load(sumRules(func(x intTok) intVal {
+		return intVal{x.value * 10}
+	})))
+
+	res, err := tp.Parse(old, toks)
+	assert.Nil(t, err)
+	assert.Equal[expr](t, res, add{left: intVal{1}, right: intVal{2}})
+
+	res, err = tp.Parse(r.Grammar(), toks)
+	assert.Nil(t, err)
+	assert.Equal[expr](t, res, add{left: intVal{10}, right: intVal{20}})
+}
+
+type sliceRuleGrammar struct{}
+
+func (sliceRuleGrammar) Parse(x expr) (expr, error) {
+	return x, nil
+}
+
+func (sliceRuleGrammar) Ints(x intTok) []intVal {
+	return nil
+}
+
+func TestReloadableInvalid(t *testing.T) {
+	r, err := tp.NewReloadable[expr, expr](interfaceGrammar{})
+	assert.Nil(t, err)
+
+	err = r.Load(sliceRuleGrammar{})
+	assert.Equal(t, err.Error(), "invalid grammar: explicit slice rules are not supported")
+
+	err = r.Load(tp.NewRuleSet(func(x expr) (expr, error) {
+		return x, nil
+	}))
+	assert.Equal(t, err.Error(), "invalid grammar: no rules produce tp_test.expr")
+
+	assert.Equal[tp.Grammar[expr, expr]](t, r.Grammar(), interfaceGrammar{})
+}
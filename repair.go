@@ -0,0 +1,216 @@
+package tp
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// RepairCost assigns a cost to each kind of edit that ParseRepair may make to its input, by the
+// types of the tokens involved. Cheaper edits are preferred, so e.g. making the insertion of a
+// semicolon cheaper than the insertion of a string literal produces more plausible repairs for a
+// language where semicolons are often forgotten.
+type RepairCost interface {
+	Insert(t reflect.Type) int
+	Delete(t reflect.Type) int
+	Replace(from, to reflect.Type) int
+}
+
+// UniformCost is a RepairCost that gives every edit a cost of 1.
+type UniformCost struct{}
+
+func (UniformCost) Insert(t reflect.Type) int         { return 1 }
+func (UniformCost) Delete(t reflect.Type) int         { return 1 }
+func (UniformCost) Replace(from, to reflect.Type) int { return 1 }
+
+type RepairKind int
+
+const (
+	RepairInsert RepairKind = iota
+	RepairDelete
+	RepairReplace
+)
+
+func (k RepairKind) String() string {
+	switch k {
+	case RepairInsert:
+		return "insert"
+	case RepairDelete:
+		return "delete"
+	case RepairReplace:
+		return "replace"
+	}
+	return fmt.Sprintf("RepairKind(%d)", int(k))
+}
+
+// Repair describes an edit made to the input of a parse.
+type Repair struct {
+	Kind RepairKind
+
+	// The index of the affected token in the original input. Tokens are inserted before this index.
+	Index int
+
+	// The token that was inserted, or that replaced the original token. Inserted tokens are the zero
+	// value of their type.
+	Token any
+}
+
+// The most edits that ParseRepair will make before giving up.
+const maxRepairs = 10
+
+// As Parse, but if the input does not fit the grammar then try to edit it so that it does, and
+// return the result of parsing the edited input along with the edits that were made. This allows a
+// tree to be built for an input with mistakes in it, so that e.g. an editor can still offer help
+// with the rest of the input.
+//
+// Edits are made where the parse fails. The cheapest edit, according to cost, that lets the parse
+// continue past the next token of the input is chosen. If cost is nil then UniformCost is used. If
+// the input cannot be repaired then the error is that of the original input.
+func ParseRepair[T, U, V any](g Grammar[U, V], toks []T, cost RepairCost) (V, []Repair, error) {
+	if cost == nil {
+		cost = UniformCost{}
+	}
+
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	terminals := terminalTypes[T](gr)
+
+	cur := slices.Clone(toks)
+	orig := make([]int, len(toks))
+	for i := range orig {
+		orig[i] = i
+	}
+
+	var repairs []Repair
+	var firstErr error
+	for {
+		failedAt, err := recognize(gr, cur)
+		if err == nil {
+			break
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if len(repairs) == maxRepairs {
+			var zero V
+			return zero, nil, firstErr
+		}
+
+		edit, ok := cheapestRepair(gr, cur, failedAt, terminals, cost)
+		if !ok {
+			var zero V
+			return zero, nil, firstErr
+		}
+
+		at := len(toks)
+		if failedAt < len(orig) {
+			at = orig[failedAt]
+		}
+		r := Repair{Kind: edit.kind, Index: at}
+		if edit.kind != RepairDelete {
+			r.Token = edit.token
+		}
+		repairs = append(repairs, r)
+		cur = applyRepair(cur, edit.kind, failedAt, edit.token)
+		orig = applyRepair(orig, edit.kind, failedAt, -1)
+	}
+
+	res, err := parseHooked(g, cur, parseOptions{})
+	if err != nil {
+		return res, nil, err
+	}
+	return res, repairs, nil
+}
+
+// The token types of the grammar that can appear in an input of T, in a consistent order.
+func terminalTypes[T any](gr *grammar) []reflect.Type {
+	tokType := reflect.TypeFor[T]()
+	var res []reflect.Type
+	for t, sym := range gr.symbols {
+		if sym.TokenType == nil || t.Kind() == reflect.Interface || !t.AssignableTo(tokType) {
+			continue
+		}
+		res = append(res, t)
+	}
+	slices.SortFunc(res, func(a, b reflect.Type) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	return res
+}
+
+type repairEdit[T any] struct {
+	kind  RepairKind
+	token T
+	cost  int
+}
+
+// Make an edit at a position, without modifying the original slice.
+func applyRepair[X any](xs []X, kind RepairKind, at int, x X) []X {
+	switch kind {
+	case RepairInsert:
+		return slices.Insert(slices.Clone(xs), at, x)
+	case RepairDelete:
+		return slices.Delete(slices.Clone(xs), at, at+1)
+	default:
+		res := slices.Clone(xs)
+		res[at] = x
+		return res
+	}
+}
+
+// Find the cheapest edit at the position where a parse failed that allows it to continue.
+func cheapestRepair[T any](gr *grammar, toks []T, failedAt int, terminals []reflect.Type, cost RepairCost) (repairEdit[T], bool) {
+	var candidates []repairEdit[T]
+	if failedAt < len(toks) {
+		from := reflect.TypeOf(toks[failedAt])
+		candidates = append(candidates, repairEdit[T]{kind: RepairDelete, cost: cost.Delete(from)})
+		for _, t := range terminals {
+			if t == from {
+				continue
+			}
+			candidates = append(candidates, repairEdit[T]{
+				kind:  RepairReplace,
+				token: reflect.Zero(t).Interface().(T),
+				cost:  cost.Replace(from, t),
+			})
+		}
+	}
+	for _, t := range terminals {
+		candidates = append(candidates, repairEdit[T]{
+			kind:  RepairInsert,
+			token: reflect.Zero(t).Interface().(T),
+			cost:  cost.Insert(t),
+		})
+	}
+	slices.SortStableFunc(candidates, func(a, b repairEdit[T]) int {
+		return cmp.Compare(a.cost, b.cost)
+	})
+
+	for _, c := range candidates {
+		edited := applyRepair(toks, c.kind, failedAt, c.token)
+
+		// the edit must allow at least the token following it to be read, unless it finishes the
+		// input
+		next := failedAt + 1
+		if c.kind == RepairDelete {
+			next = failedAt
+		}
+		got, err := recognize(gr, edited)
+		if err == nil || got > next {
+			return c, true
+		}
+	}
+	return repairEdit[T]{}, false
+}
+
+// Run the matcher over the tokens without building anything, returning where it failed if it did.
+func recognize[T any](gr *grammar, toks []T) (int, error) {
+	m := &matcher{
+		root:     gr.root,
+		brackets: gr.brackets,
+		toks:     tokenValues(toks),
+		leo:      true,
+	}
+	err := m.run()
+	return m.failedAt, err
+}
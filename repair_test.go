@@ -0,0 +1,54 @@
+package tp_test
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Punctuation is often forgotten, so it is cheap to insert, while values are expensive to insert
+// and nothing should be deleted unless it has to be.
+type jsonRepairCost struct{}
+
+func (jsonRepairCost) Insert(t reflect.Type) int {
+	switch t {
+	case reflect.TypeFor[commaToken](), reflect.TypeFor[colonToken]():
+		return 1
+	}
+	return 5
+}
+
+func (jsonRepairCost) Delete(t reflect.Type) int {
+	return 10
+}
+
+func (jsonRepairCost) Replace(from, to reflect.Type) int {
+	return 10
+}
+
+func ExampleParseRepair() {
+	show := func(value jsonValue, repairs []tp.Repair, err error) {
+		fmt.Println(value, err)
+		for _, r := range repairs {
+			fmt.Printf("  %s at %d: %#v\n", r.Kind, r.Index, r.Token)
+		}
+	}
+
+	toks := removeWhitespace(must(lexicon.Tokenize([]byte(`[1 2]`)).Force()))
+	show(tp.ParseRepair(jsonGrammar{}, toks, nil))
+	show(tp.ParseRepair(jsonGrammar{}, toks, jsonRepairCost{}))
+
+	toks = removeWhitespace(must(lexicon.Tokenize([]byte(`{"a" 1 "b": [}`)).Force()))
+	show(tp.ParseRepair(jsonGrammar{}, toks, jsonRepairCost{}))
+
+	// Output:
+	// [1] <nil>
+	//   delete at 2: <nil>
+	// [1 2] <nil>
+	//   insert at 2: tp_test.commaToken{}
+	// map[a:1 b:[]] <nil>
+	//   insert at 2: tp_test.colonToken{}
+	//   insert at 3: tp_test.commaToken{}
+	//   insert at 6: tp_test.arrayEndToken{}
+}
@@ -0,0 +1,179 @@
+package tp
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Repetition decides how many items a slice matches where the input can be divided between the
+// slice and the symbols that follow it in more than one way, as in Pair(xs []Item, ys []Item). A
+// grammar chooses by having a method Repetition() Repetition, which is not a rule and is called
+// once per type of grammar. It applies to every slice in the grammar.
+type Repetition int
+
+const (
+	// Slices match as few items as they can. This is the default.
+	RepetitionLazy Repetition = iota
+
+	// Slices match as many items as they can.
+	RepetitionGreedy
+
+	// The grammar is invalid if a slice is followed by a symbol that can begin with one of its
+	// items, as reported by CheckRepetition, so that there is no choice to make.
+	RepetitionSeparated
+)
+
+func (r Repetition) String() string {
+	switch r {
+	case RepetitionLazy:
+		return "lazy"
+	case RepetitionGreedy:
+		return "greedy"
+	case RepetitionSeparated:
+		return "separated"
+	}
+	return fmt.Sprintf("Repetition(%d)", int(r))
+}
+
+// The repetition chosen by a grammar's Repetition method, if it has one.
+func repetitionOf(host reflect.Value) Repetition {
+	m, ok := host.Type().MethodByName("Repetition")
+	if !ok {
+		return RepetitionLazy
+	}
+	return m.Func.Call([]reflect.Value{host})[0].Interface().(Repetition)
+}
+
+// Apply the grammar's choice of repetition to its slices.
+func (s *scanner) markRepetition() {
+	switch repetitionOf(s.host) {
+	case RepetitionGreedy:
+		for t, sym := range s.types {
+			sym.Greedy = t.Kind() == reflect.Slice
+		}
+	case RepetitionSeparated:
+		conflicts := repetitionConflicts(s.types)
+		if len(conflicts) == 0 {
+			return
+		}
+		msgs := make([]string, len(conflicts))
+		for i, c := range conflicts {
+			msgs[i] = c.String()
+		}
+		panic(fmt.Sprintf("slices are not separated from what follows them: %s", strings.Join(msgs, "; ")))
+	}
+}
+
+// RepetitionConflict describes a rule where a slice is followed by a symbol that can begin with one
+// of the slice's items. Where an item is found, it can either be added to the slice or begin what
+// follows, and the grammar's Repetition decides which.
+type RepetitionConflict struct {
+	// The rule, and the types of the slice and the symbol that follows it.
+	Rule        string
+	Slice, Next string
+}
+
+func (c RepetitionConflict) String() string {
+	return fmt.Sprintf("%s: %s is followed by %s, which can begin with the same tokens", c.Rule, c.Slice, c.Next)
+}
+
+// Find the rules of a grammar where it matters whether slices are lazy or greedy. Only what follows
+// a slice within the same rule is considered, and the rules furnished by this package, such as
+// those of Delimited, are not reported.
+func CheckRepetition[U, V any](g Grammar[U, V]) []RepetitionConflict {
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	return repetitionConflicts(gr.symbols)
+}
+
+func repetitionConflicts(symbols map[reflect.Type]*symbol) []RepetitionConflict {
+	var res []RepetitionConflict
+	for _, sym := range symbols {
+		for _, r := range sym.Predictions {
+			if r.Produces != sym.Type || sym.Type.Kind() == reflect.Slice || ownRule(r) {
+				// an interface's copy of another symbol's rule, or one that is not the grammar's
+				continue
+			}
+			for i, d := range r.Deps {
+				if d.Type.Kind() != reflect.Slice || i+1 == len(r.Deps) {
+					continue
+				}
+				item := symbols[d.Type.Elem()]
+				if !firstOf(item).overlaps(seqFirst(r.Deps[i+1:])) {
+					continue
+				}
+				res = append(res, RepetitionConflict{
+					Rule:  r.Name,
+					Slice: d.Type.String(),
+					Next:  r.Deps[i+1].Type.String(),
+				})
+			}
+		}
+	}
+	slices.SortFunc(res, func(a, b RepetitionConflict) int {
+		return cmp.Or(cmp.Compare(a.Rule, b.Rule), cmp.Compare(a.Slice, b.Slice))
+	})
+	return res
+}
+
+// Whether a rule is furnished by one of the types of this package, such as Delimited.
+func ownRule(r *rule) bool {
+	return r.Host.IsValid() && r.Host.Type().PkgPath() == reflect.TypeFor[Span]().PkgPath()
+}
+
+// The tokens that can begin a symbol.
+func firstOf(sym *symbol) firstSet {
+	return seqFirst([]*symbol{sym})
+}
+
+// The tokens that can begin a sequence of symbols, which is marked as empty if the whole sequence
+// can be.
+func seqFirst(seq []*symbol) firstSet {
+	res := firstSet{types: map[reflect.Type]bool{}}
+	for _, d := range seq {
+		switch {
+		case d.TokenType != nil:
+			res.add(d.TokenType)
+			return res
+		case d.NotFollowedBy != nil, d.Layout != nil:
+			continue
+		}
+		for _, r := range d.Predictions {
+			for t := range r.First.types {
+				res.add(t)
+			}
+			for _, t := range r.First.interfaces {
+				res.add(t)
+			}
+		}
+		if !d.Nullable {
+			return res
+		}
+	}
+	res.empty = true
+	return res
+}
+
+// Whether a token could begin both of two sets, ignoring whether they can be empty.
+func (f firstSet) overlaps(g firstSet) bool {
+	for t := range f.types {
+		if g.types[t] || slices.ContainsFunc(g.interfaces, t.Implements) {
+			return true
+		}
+	}
+	for _, t := range f.interfaces {
+		for u := range g.types {
+			if u.Implements(t) {
+				return true
+			}
+		}
+		for _, u := range g.interfaces {
+			if t == u || t.Implements(u) || u.Implements(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type intSplit struct {
+	left, right []intTok
+}
+
+// The integers can be divided between the two slices in any way.
+type splitGrammar struct{}
+
+func (splitGrammar) Parse(x intSplit) (intSplit, error) {
+	return x, nil
+}
+
+func (splitGrammar) Split(left []intTok, _ plusTok, right []intTok) intSplit {
+	return intSplit{left: left, right: right}
+}
+
+func (splitGrammar) Pair(left []intTok, right []intTok) intSplit {
+	return intSplit{left: left, right: right}
+}
+
+type greedySplitGrammar struct {
+	splitGrammar
+}
+
+func (greedySplitGrammar) Repetition() tp.Repetition {
+	return tp.RepetitionGreedy
+}
+
+type separatedSplitGrammar struct {
+	splitGrammar
+}
+
+func (separatedSplitGrammar) Repetition() tp.Repetition {
+	return tp.RepetitionSeparated
+}
+
+func TestRepetition(t *testing.T) {
+	toks := []any{intTok{1}, intTok{2}, intTok{3}}
+
+	lazy, err := tp.Parse(splitGrammar{}, toks)
+	assert.Nil(t, err)
+	assert.Equal(t, len(lazy.left), 0)
+	assert.Equal(t, len(lazy.right), 3)
+
+	greedy, err := tp.Parse(greedySplitGrammar{}, toks)
+	assert.Nil(t, err)
+	assert.Equal(t, len(greedy.left), 3)
+	assert.Equal(t, len(greedy.right), 0)
+
+	// where the separator decides, the choice makes no difference
+	split, err := tp.Parse(greedySplitGrammar{}, []any{intTok{1}, plusTok{}, intTok{2}})
+	assert.Nil(t, err)
+	assert.Equal(t, split, intSplit{left: []intTok{{1}}, right: []intTok{{2}}})
+}
+
+func TestCheckRepetition(t *testing.T) {
+	assert.Equal(t, tp.CheckRepetition(splitGrammar{}), []tp.RepetitionConflict{
+		{Rule: "Pair", Slice: "[]tp_test.intTok", Next: "[]tp_test.intTok"},
+	})
+
+	// the rules of Delimited are not the grammar's own
+	trailing := numberListGrammar{Lists: tp.Delimited[numberToken, commaToken]{Trailing: true}}
+	assert.Equal(t, len(tp.CheckRepetition(trailing)), 0)
+
+	_, err := tp.NewParser[any](separatedSplitGrammar{})
+	assert.Equal(t, err.Error(), "invalid grammar: slices are not separated from what follows them: "+
+		"Pair: []tp_test.intTok is followed by []tp_test.intTok, which can begin with the same tokens")
+}
@@ -0,0 +1,198 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ReviewLimits are what Review requires of a grammar before it is considered safe to use. A limit
+// that is zero is not checked.
+type ReviewLimits struct {
+	// The most rules and symbols that the grammar may have.
+	MaxRules, MaxSymbols int
+
+	// The worst complexity that the grammar may be predicted to have.
+	MaxComplexity Complexity
+
+	// The length of the longest input to search for ambiguity, which makes parsing cubic. The number
+	// of inputs searched grows very quickly with their length, see FindAmbiguity, so this should be
+	// kept small.
+	AmbiguityLength int
+}
+
+// Complexity describes how the time taken to parse an input grows with its length.
+type Complexity int
+
+const (
+	Linear Complexity = iota + 1
+	Quadratic
+	Cubic
+)
+
+func (c Complexity) String() string {
+	switch c {
+	case Linear:
+		return "linear"
+	case Quadratic:
+		return "quadratic"
+	case Cubic:
+		return "cubic"
+	}
+	return fmt.Sprintf("Complexity(%d)", int(c))
+}
+
+// GrammarReview describes a grammar for deciding whether it is safe to use, such as one that has
+// been assembled from rules supplied by users with a RuleSet.
+type GrammarReview struct {
+	// The number of rules and symbols that the grammar has.
+	Rules, Symbols int
+
+	// The nonterminals, named as EBNF names them, that can match themselves without matching any
+	// tokens. Such a grammar can parse some inputs in infinitely many ways.
+	Cycles []string
+
+	// The predicted complexity of parsing with the grammar, and the rules that lead to it being worse
+	// than linear.
+	Complexity Complexity
+	Causes     []string
+
+	// An input that can be parsed in more than one way, if one was found, as an AmbiguityError.
+	Ambiguity error
+
+	// How the grammar breaks the limits that it was reviewed against.
+	Problems []string
+}
+
+// Whether the grammar is within the limits that it was reviewed against, and has no cycles.
+func (r *GrammarReview) Safe() bool {
+	return len(r.Problems) == 0
+}
+
+// Review a grammar for inputs of T without calling any of its rules, finding how large it is,
+// whether it has cycles, and how the time taken to parse an input with it is predicted to grow.
+// Grammars that are not safe can be rejected before they are used, and grammars that are can be
+// used to check untrusted inputs with Recognize, which also calls no rules.
+//
+// Mistakes in the grammar are returned as errors, as with NewParser. Only the rules, and Parse,
+// BeforeParse and AfterParse, are not called. The methods that configure a grammar, which are
+// RuleOrder, Labels, Repetition, Versions and Features, are called to scan it, as are those of the
+// grammars that it contains, so this is not a sandbox: those methods must be trusted.
+//
+// Complexity is predicted from the shape of the rules, as described at Grammar. A grammar is cubic
+// if it has cycles or an ambiguous input was found, and quadratic if a rule has a symbol that can
+// match any number of tokens that is followed by another, other than in left or right recursion.
+// The prediction is an estimate, which errs towards the worse complexity.
+func Review[T, U, V any](g Grammar[U, V], limits ReviewLimits) (*GrammarReview, error) {
+	if err := checkGrammar(g); err != nil {
+		return nil, err
+	}
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+
+	res := &GrammarReview{Symbols: len(gr.symbols), Complexity: Linear}
+	for _, sym := range gr.symbols {
+		for _, r := range sym.Predictions {
+			// rules that implement an interface are also predicted by the interface
+			if r.Implements.Type != r.Produces {
+				continue
+			}
+			res.Rules++
+			if cause := quadraticCause(r); cause != "" {
+				res.Complexity = Quadratic
+				res.Causes = append(res.Causes, cause)
+			}
+		}
+	}
+	res.Cycles = gr.emptyCycles()
+	if len(res.Cycles) != 0 {
+		res.Complexity = Cubic
+	} else if limits.AmbiguityLength > 0 {
+		if _, err := FindAmbiguity[T](g, limits.AmbiguityLength); err != nil {
+			res.Complexity = Cubic
+			res.Ambiguity = err
+		}
+	}
+	slices.Sort(res.Causes)
+
+	if limits.MaxRules > 0 && res.Rules > limits.MaxRules {
+		res.Problems = append(res.Problems, fmt.Sprintf("%d rules is more than the limit of %d", res.Rules, limits.MaxRules))
+	}
+	if limits.MaxSymbols > 0 && res.Symbols > limits.MaxSymbols {
+		res.Problems = append(res.Problems, fmt.Sprintf("%d symbols is more than the limit of %d", res.Symbols, limits.MaxSymbols))
+	}
+	if len(res.Cycles) != 0 {
+		res.Problems = append(res.Problems, fmt.Sprintf("%s can match themselves without matching any tokens", strings.Join(res.Cycles, ", ")))
+	}
+	if limits.MaxComplexity > 0 && res.Complexity > limits.MaxComplexity {
+		res.Problems = append(res.Problems, fmt.Sprintf("%s complexity is worse than the limit of %s", res.Complexity, limits.MaxComplexity))
+	}
+	return res, nil
+}
+
+// Describe why a rule may take quadratic time, or return the empty string if it does not.
+func quadraticCause(r *rule) string {
+	last := len(r.Deps) - 1
+	for i, d := range r.Deps {
+		if d.MaxLen != unbounded || i == 0 && d == r.Implements {
+			continue
+		}
+		for j := i + 1; j <= last; j++ {
+			e := r.Deps[j]
+			if e.MaxLen != unbounded || j == last && e == r.Implements {
+				continue
+			}
+			return fmt.Sprintf("%s: %s is followed by %s", r.Name, ebnfName(d.Type), ebnfName(e.Type))
+		}
+	}
+	return ""
+}
+
+// Find the nonterminals that can match themselves without matching any tokens, in order of name.
+func (gr *grammar) emptyCycles() []string {
+	// the symbols that a symbol can match with nothing else around it
+	unit := map[*symbol][]*symbol{}
+	for _, sym := range gr.symbols {
+		for _, r := range sym.Predictions {
+			for i, d := range r.Deps {
+				others := slices.Concat(r.Deps[:i], r.Deps[i+1:])
+				if slices.ContainsFunc(others, func(o *symbol) bool { return o.MinLen > 0 }) {
+					continue
+				}
+				unit[sym] = append(unit[sym], d)
+			}
+		}
+	}
+
+	var res []string
+	for _, sym := range gr.symbols {
+		seen := map[*symbol]bool{}
+		todo := slices.Clone(unit[sym])
+		for len(todo) > 0 {
+			next := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			todo = append(todo, unit[next]...)
+		}
+		if seen[sym] {
+			res = append(res, ebnfName(sym.Type))
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// Report whether the tokens fit a grammar, without calling any of its rules or its Parse method,
+// so that a grammar that is not trusted can check inputs, see Review. As with Review, the methods
+// that configure the grammar are called. The error is as it would be from Parse, other than those
+// returned by rules, and mistakes in the grammar are returned as errors, as with NewParser.
+func Recognize[T, U, V any](g Grammar[U, V], toks []T) error {
+	if err := checkGrammar(g); err != nil {
+		return err
+	}
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+	return newMatcher(gr, gr.root, tokenValues(toks), true).run()
+}
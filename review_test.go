@@ -0,0 +1,77 @@
+package tp_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type cycleA struct{}
+type cycleB struct{}
+
+type cycleGrammar struct{}
+
+func (cycleGrammar) Parse(x cycleA) (cycleA, error) {
+	panic("rules are not called")
+}
+
+func (cycleGrammar) Wrap(x cycleA) cycleB {
+	panic("rules are not called")
+}
+
+func (cycleGrammar) Unwrap(x cycleB) cycleA {
+	panic("rules are not called")
+}
+
+func (cycleGrammar) Leaf(intTok) cycleA {
+	panic("rules are not called")
+}
+
+func TestReview(t *testing.T) {
+	r, err := tp.Review[intTok](rightListGrammar{}, tp.ReviewLimits{MaxComplexity: tp.Linear, AmbiguityLength: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, r, &tp.GrammarReview{Rules: 2, Symbols: 2, Complexity: tp.Linear})
+	assert.True(t, r.Safe())
+
+	r, err = tp.Review[any](interfaceGrammar{}, tp.ReviewLimits{MaxRules: 1, AmbiguityLength: 5})
+	assert.Nil(t, err)
+	assert.Equal(t, r.Rules, 2)
+	assert.Equal(t, r.Complexity, tp.Cubic)
+	assert.Equal(t, r.Causes, []string{"Add: expr is followed by expr"})
+	assert.True(t, errors.Is(r.Ambiguity, tp.ErrAmbiguousParse))
+	assert.Equal(t, r.Problems, []string{"2 rules is more than the limit of 1"})
+
+	// without searching for ambiguity, the grammar is only known to be quadratic
+	r, err = tp.Review[any](interfaceGrammar{}, tp.ReviewLimits{MaxComplexity: tp.Linear})
+	assert.Nil(t, err)
+	assert.Equal(t, r.Complexity, tp.Quadratic)
+	assert.Equal(t, r.Problems, []string{"quadratic complexity is worse than the limit of linear"})
+
+	r, err = tp.Review[intTok](cycleGrammar{}, tp.ReviewLimits{MaxSymbols: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, r.Cycles, []string{"cycleA", "cycleB"})
+	assert.Equal(t, r.Complexity, tp.Cubic)
+	assert.Equal(t, r.Problems, []string{
+		"3 symbols is more than the limit of 2",
+		"cycleA, cycleB can match themselves without matching any tokens",
+	})
+	assert.False(t, r.Safe())
+}
+
+func TestRecognize(t *testing.T) {
+	assert.Nil(t, tp.Recognize(cycleGrammar{}, []intTok{{1}}))
+	assert.Equal(t, tp.Recognize(cycleGrammar{}, []intTok{}), io.ErrUnexpectedEOF)
+
+	err := tp.Recognize(cycleGrammar{}, []any{intTok{1}, plusTok{}})
+	var unexpected *tp.ErrUnexpectedToken
+	assert.True(t, errors.As(err, &unexpected))
+	assert.Equal(t, unexpected.Index, 1)
+
+	// mistakes in the grammar are errors rather than panics
+	err = tp.Recognize(threeResultGrammar{}, []intTok{{1}})
+	var gerr *tp.GrammarError
+	assert.True(t, errors.As(err, &gerr))
+}
@@ -0,0 +1,70 @@
+package tp
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrReject may be returned, or wrapped, by a rule to say that it does not apply to the tokens that
+// it matched, so that the input is parsed in another way instead.
+var ErrReject = errors.New("rule rejected its input")
+
+// RuleError reports an error returned by a rule, along with the rule and the tokens that it matched.
+// This distinguishes the errors of a grammar's rules from those of the parser, such as
+// ErrUnexpectedToken.
+type RuleError struct {
+	// The name of the rule's method, its label or its name if it does not have one, and the tokens
+	// that it matched.
+	Rule  string
+	Label string
+	Span  Span
+
+	Err error
+}
+
+func (e *RuleError) Error() string {
+	return EnglishMessages{}.RuleError(e, e.Err.Error())
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// A rule matched over a range of tokens.
+type spanKey struct {
+	rule    *rule
+	at, end int
+}
+
+// Build the tree described by the preferred derivation of the input. If a rule rejects the tokens
+// that it matched then the derivation is abandoned and the next preferred one that avoids the
+// rejected rules is built instead. The error of the last rejection is returned if there is none.
+// Rules that are excluded from the parse are avoided in the same way, and if the input can only be
+// parsed with them then the error says which was needed.
+func (b *builder) build() (reflect.Value, error) {
+	var rejection error
+	traced := 0
+	if b.trace != nil {
+		traced = len(*b.trace)
+	}
+	for {
+		rejected := len(b.rejected)
+		res, err := b.buildTree()
+		if len(b.rejected) > rejected {
+			rejection = err
+			if b.trace != nil {
+				*b.trace = (*b.trace)[:traced]
+			}
+			continue
+		}
+		if err == ErrFailedMatch && b.excluded != nil {
+			if excluded := b.exclusionError(); excluded != nil {
+				return res, excluded
+			}
+		}
+		if err == ErrFailedMatch && rejection != nil {
+			return res, rejection
+		}
+		return res, err
+	}
+}
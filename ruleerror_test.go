@@ -0,0 +1,112 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type phrase interface {
+	phrase()
+}
+
+type keyword struct {
+	text string
+}
+
+type ident struct {
+	text string
+}
+
+func (keyword) phrase() {}
+func (ident) phrase()   {}
+
+// Words are keywords where possible and identifiers otherwise.
+type keywordGrammar struct{}
+
+func (keywordGrammar) Parse(x []phrase) ([]phrase, error) {
+	return x, nil
+}
+
+func (keywordGrammar) RuleOrder() []string {
+	return []string{"Keyword", "Ident"}
+}
+
+func (keywordGrammar) Ident(w wordTok) ident {
+	return ident{w.text}
+}
+
+func (keywordGrammar) Keyword(w wordTok) (keyword, error) {
+	return newKeyword(w)
+}
+
+func newKeyword(w wordTok) (keyword, error) {
+	if w.text != "if" && w.text != "else" {
+		return keyword{}, fmt.Errorf("%q: %w", w.text, tp.ErrReject)
+	}
+	return keyword{w.text}, nil
+}
+
+func TestReject(t *testing.T) {
+	res, trace, err := tp.ParseTrace(keywordGrammar{}, []wordTok{{text: "if"}, {text: "x"}, {text: "else"}})
+	assert.Nil(t, err)
+	assert.Equal(t, res, []phrase{keyword{"if"}, ident{"x"}, keyword{"else"}})
+
+	// the rejected rule is not part of the derivation
+	var rules []string
+	for _, d := range trace {
+		if d.Rule == "Keyword" || d.Rule == "Ident" {
+			rules = append(rules, d.Rule)
+		}
+	}
+	assert.Equal(t, rules, []string{"Keyword", "Ident", "Keyword"})
+}
+
+// Every word must be a keyword.
+type keywordsOnlyGrammar struct{}
+
+func (keywordsOnlyGrammar) Parse(x []keyword) ([]keyword, error) {
+	return x, nil
+}
+
+func (keywordsOnlyGrammar) Keyword(w wordTok) (keyword, error) {
+	return newKeyword(w)
+}
+
+func TestRejectAll(t *testing.T) {
+	_, err := tp.Parse(keywordsOnlyGrammar{}, []wordTok{{text: "if"}, {text: "x"}})
+	assert.True(t, errors.Is(err, tp.ErrReject))
+
+	var ruleErr *tp.RuleError
+	if !assert.True(t, errors.As(err, &ruleErr)) {
+		return
+	}
+	assert.Equal(t, ruleErr.Rule, "Keyword")
+	assert.Equal(t, ruleErr.Span, tp.Span{Start: 1, End: 2})
+	assert.Equal(t, err.Error(), `Keyword at tokens 1 to 2: "x": rule rejected its input`)
+}
+
+func TestRuleError(t *testing.T) {
+	failed := errors.New("failed")
+	g := tp.NewRuleSet(func(x []intVal) ([]intVal, error) {
+		return x, nil
+	})
+	g.AddRule(func(x intTok) (intVal, error) {
+		if x.value == 3 {
+			return intVal{}, failed
+		}
+		return intVal{x.value}, nil
+	})
+
+	_, err := tp.Parse(g, []intTok{{1}, {2}, {3}})
+	assert.True(t, errors.Is(err, failed))
+
+	var ruleErr *tp.RuleError
+	if !assert.True(t, errors.As(err, &ruleErr)) {
+		return
+	}
+	assert.Equal(t, ruleErr.Span, tp.Span{Start: 2, End: 3})
+}
@@ -0,0 +1,104 @@
+package tp
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// The preference given to each rule of a host by its RuleOrder method, if it has one. The first
+// rule named is the most preferred.
+func ruleOrder(hostType reflect.Type, host reflect.Value) map[string]int {
+	m, ok := hostType.MethodByName("RuleOrder")
+	if !ok {
+		return nil
+	}
+	names := m.Func.Call([]reflect.Value{host})[0].Interface().([]string)
+	res := map[string]int{}
+	for i, name := range names {
+		if _, ok := hostType.MethodByName(name); !ok {
+			panic(fmt.Sprintf("RuleOrder names %s, which is not a rule of %s", name, hostType))
+		}
+		if _, ok := res[name]; ok {
+			panic(fmt.Sprintf("RuleOrder names %s more than once", name))
+		}
+		res[name] = i
+	}
+	return res
+}
+
+// Rules that RuleOrder names come before the others, which keep the order of their names.
+func ruleIndex(order map[string]int, m reflect.Method) int {
+	if i, ok := order[m.Name]; ok {
+		return i
+	}
+	return len(order) + m.Index
+}
+
+// ReorderedRule describes a pair of rules that a grammar's RuleOrder method prefers in the opposite
+// order to the one given by their names.
+type ReorderedRule struct {
+	// The type that both rules can produce.
+	Symbol string
+
+	// The names of the rules, with the one that is now preferred first.
+	Preferred, Over string
+}
+
+func (r ReorderedRule) String() string {
+	return fmt.Sprintf(
+		"%s: %s is preferred over %s, but was not before RuleOrder was given",
+		r.Symbol, r.Preferred, r.Over,
+	)
+}
+
+// Find the pairs of rules whose preference changes when a grammar is given a RuleOrder method. Where
+// a parse is ambiguous, these are the rules that may now produce a different tree, so this can be
+// used to check that adding a RuleOrder method to an existing grammar keeps the trees that it
+// builds the same.
+//
+// The rules of a RuleSet are always preferred in the order that they were added, so there is nothing
+// to report for one.
+func CheckRuleOrder[U, V any](g Grammar[U, V]) []ReorderedRule {
+	if _, ok := g.(interface{ funcRules() *funcRules }); ok {
+		return nil
+	}
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+
+	syms := make([]reflect.Type, 0, len(gr.symbols))
+	for t := range gr.symbols {
+		syms = append(syms, t)
+	}
+	slices.SortFunc(syms, func(a, b reflect.Type) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+
+	seen := map[[2]string]bool{}
+	var res []ReorderedRule
+	for _, t := range syms {
+		rules := gr.symbols[t].Predictions
+		for _, a := range rules {
+			for _, b := range rules {
+				if !sameHost(a, b) || a.Index >= b.Index || a.Name <= b.Name {
+					continue
+				}
+				key := [2]string{a.Name, b.Name}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				res = append(res, ReorderedRule{Symbol: t.String(), Preferred: a.Name, Over: b.Name})
+			}
+		}
+	}
+	return res
+}
+
+// Rules are only ordered against the other rules of their host.
+func sameHost(a, b *rule) bool {
+	if !a.Host.IsValid() || !b.Host.IsValid() {
+		return a.Host.IsValid() == b.Host.IsValid()
+	}
+	return a.Host.Type() == b.Host.Type()
+}
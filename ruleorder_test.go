@@ -0,0 +1,64 @@
+package tp_test
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+// Both rules match a single token, so which one is used depends on the order of preference.
+type doublingGrammar struct{}
+
+func (doublingGrammar) Parse(x intVal) (intVal, error) {
+	return x, nil
+}
+
+func (doublingGrammar) Double(x intTok) intVal {
+	return intVal{x.value * 2}
+}
+
+func (doublingGrammar) Single(x intTok) intVal {
+	return intVal{x.value}
+}
+
+type orderedDoublingGrammar struct {
+	doublingGrammar
+}
+
+func (orderedDoublingGrammar) RuleOrder() []string {
+	return []string{"Single", "Double"}
+}
+
+func TestRuleOrder(t *testing.T) {
+	byName, err := tp.Parse(doublingGrammar{}, []intTok{{2}})
+	assert.Nil(t, err)
+	assert.Equal(t, byName, intVal{4})
+
+	ordered, err := tp.Parse(orderedDoublingGrammar{}, []intTok{{2}})
+	assert.Nil(t, err)
+	assert.Equal(t, ordered, intVal{2})
+}
+
+func TestCheckRuleOrder(t *testing.T) {
+	assert.Equal(t, tp.CheckRuleOrder(doublingGrammar{}), nil)
+	assert.Equal(t, tp.CheckRuleOrder(orderedDoublingGrammar{}), []tp.ReorderedRule{
+		{Symbol: "tp_test.intVal", Preferred: "Single", Over: "Double"},
+	})
+}
+
+type badOrderGrammar struct {
+	doublingGrammar
+}
+
+func (badOrderGrammar) RuleOrder() []string {
+	return []string{"Triple"}
+}
+
+func TestRuleOrderUnknownRule(t *testing.T) {
+	defer func() {
+		assert.Equal(t, recover(), any("RuleOrder names Triple, which is not a rule of tp_test.badOrderGrammar"))
+	}()
+	tp.Parse(badOrderGrammar{}, []intTok{{2}})
+	t.Error("expected a panic")
+}
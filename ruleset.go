@@ -0,0 +1,108 @@
+package tp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// RuleSet is a grammar whose rules are functions added at runtime, rather than methods. This allows
+// a grammar to be assembled from plugins or configuration without defining a new type for it.
+//
+// The rules are given as ordinary functions, and are otherwise treated as the methods of a grammar
+// are: the arguments are what the rule matches, and the result is what it produces. Types used in
+// the arguments that have a Grammar method furnish rules as usual. Where an input can be parsed in
+// more than one way, rules added earlier are preferred.
+//
+// Rules may be added between parses. A parse uses the rules that had been added when it began.
+type RuleSet[U, V any] struct {
+	parse func(U) (V, error)
+	rules *funcRules
+}
+
+// The rules of a RuleSet. These are kept apart from it so that adding a rule does not count as
+// modifying the grammar.
+type funcRules struct {
+	lock  sync.Mutex
+	funcs []funcRule
+	gr    *grammar
+}
+
+type funcRule struct {
+	name string
+	fn   reflect.Value
+}
+
+// Create a RuleSet with no rules, that passes the parse tree to the given function to yield the
+// result of a parse.
+func NewRuleSet[U, V any](parse func(U) (V, error)) *RuleSet[U, V] {
+	return &RuleSet[U, V]{
+		parse: parse,
+		rules: &funcRules{},
+	}
+}
+
+func (s *RuleSet[U, V]) Parse(x U) (V, error) {
+	return s.parse(x)
+}
+
+func (s *RuleSet[U, V]) funcRules() *funcRules {
+	return s.rules
+}
+
+// Add a rule to the grammar. The rule must be a function with one result, of the type it produces,
+// or two, where the second is an error, otherwise a GrammarError is returned. As with methods, a
+// rule that returns an error stops the parse.
+func (s *RuleSet[U, V]) AddRule(fn any) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("rule must be a function, not %T", fn)
+	}
+	ft := v.Type()
+	name := runtime.FuncForPC(v.Pointer()).Name()
+	name = name[strings.LastIndexByte(name, '/')+1:]
+	if err := checkRuleSignature(name, ft); err != nil {
+		return err
+	}
+	if ft.Out(0).Kind() == reflect.Slice {
+		return errors.New("explicit slice rules are not supported")
+	}
+
+	s.rules.lock.Lock()
+	defer s.rules.lock.Unlock()
+	s.rules.funcs = append(s.rules.funcs, funcRule{
+		name: name,
+		fn:   v,
+	})
+	s.rules.gr = nil
+	return nil
+}
+
+var errorType = reflect.TypeFor[error]()
+
+// Scan the rules, if they have changed since they were last scanned.
+func (r *funcRules) scan(host reflect.Value, rootType reflect.Type) *grammar {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.gr != nil {
+		return r.gr
+	}
+
+	s := &scanner{
+		host:     host,
+		rootType: rootType,
+		types:    map[reflect.Type]*symbol{},
+	}
+	s.ensure(rootType)
+	for i, f := range r.funcs {
+		s.addRule(f.name, f.fn.Type(), 0, reflect.Value{}, i, func(args []reflect.Value) []reflect.Value {
+			return f.fn.Call(args[1:])
+		})
+	}
+	r.gr = s.finish()
+	return r.gr
+}
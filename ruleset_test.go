@@ -0,0 +1,95 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleRuleSet() {
+	g := tp.NewRuleSet(func(x expr) (expr, error) {
+		return x, nil
+	})
+	g.AddRule(func(val intTok) intVal {
+		return intVal(val)
+	})
+	g.AddRule(func(left expr, op plusTok, right intVal) add {
+		return add{left: left, right: right}
+	})
+
+	res, err := tp.Parse(g, []any{intTok{1}, plusTok{}, intTok{2}})
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Printf("%#v\n", res)
+
+	// Output:
+	// tp_test.add{left:tp_test.intVal{value:1}, right:tp_test.intVal{value:2}}
+}
+
+func TestRuleSetAddBetweenParses(t *testing.T) {
+	g := tp.NewRuleSet(func(x expr) (expr, error) {
+		return x, nil
+	})
+	assert.Nil(t, g.AddRule(func(val intTok) intVal {
+		return intVal(val)
+	}))
+
+	_, err := tp.Parse(g, []any{intTok{1}, plusTok{}, intTok{2}})
+	assert.True(t, err != nil)
+
+	assert.Nil(t, g.AddRule(func(left expr, op plusTok, right intVal) add {
+		return add{left: left, right: right}
+	}))
+
+	res, err := tp.Parse(g, []any{intTok{1}, plusTok{}, intTok{2}})
+	assert.Nil(t, err)
+	assert.Equal[expr](t, res, add{left: intVal{1}, right: intVal{2}})
+}
+
+func TestRuleSetOrder(t *testing.T) {
+	g := tp.NewRuleSet(func(x intVal) (intVal, error) {
+		return x, nil
+	})
+	g.AddRule(func(x intTok) intVal {
+		return intVal{x.value}
+	})
+	g.AddRule(func(x intTok) intVal {
+		return intVal{x.value * 2}
+	})
+
+	res, err := tp.Parse(g, []intTok{{2}})
+	assert.Nil(t, err)
+	assert.Equal(t, res, intVal{2})
+}
+
+func TestRuleSetRuleError(t *testing.T) {
+	failed := errors.New("failed")
+	g := tp.NewRuleSet(func(x intVal) (intVal, error) {
+		return x, nil
+	})
+	g.AddRule(func(x intTok) (intVal, error) {
+		return intVal{}, failed
+	})
+
+	_, err := tp.Parse(g, []intTok{{2}})
+	assert.Equal(t, err, failed)
+}
+
+func TestRuleSetInvalidRule(t *testing.T) {
+	g := tp.NewRuleSet(func(x intVal) (intVal, error) {
+		return x, nil
+	})
+	for _, fn := range []any{
+		1,
+		func(x intTok) {},
+		func(x intTok) (intVal, int) { return intVal{}, 0 },
+		func(x ...intTok) intVal { return intVal{} },
+		func(x intTok) []intVal { return nil },
+	} {
+		assert.True(t, g.AddRule(fn) != nil)
+	}
+}
@@ -0,0 +1,175 @@
+// Package sarif writes diagnostics in the Static Analysis Results Interchange Format, so that the
+// problems found when parsing files can be shown by code review and continuous integration systems.
+package sarif
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+
+	"github.com/bobappleyard/tp"
+)
+
+// Tool describes the program that found the problems.
+type Tool struct {
+	Name           string
+	Version        string
+	InformationURI string
+
+	// The catalog used to describe the problems. If this is nil then they are described in English.
+	Messages tp.Messages
+}
+
+// Write a SARIF 2.1.0 log with a single run of the tool, holding a result for each diagnostic.
+// Results are ordered by path and then by position, and each is given a rule ID that says what kind
+// of problem it is:
+//
+//	unreadable       // the file could not be read
+//	no-match         // no token matches the text
+//	unexpected-token // a token does not fit the grammar
+//	unexpected-eof   // the text ended before the grammar was satisfied
+//	rule-error       // a rule of the grammar returned an error
+//	error            // anything else
+//
+// Diagnostics are located by line and byte offset. Their columns are not written, as SARIF counts
+// columns in characters where a Position counts them in bytes.
+//
+// The diagnostics of a batch run can be written with
+//
+//	sarif.Encode(w, tool, slices.Concat(slices.Collect(maps.Values(res.Diagnostics))...))
+func Encode(w io.Writer, tool Tool, diags []tp.Diagnostic) error {
+	m := tool.Messages
+	if m == nil {
+		m = tp.EnglishMessages{}
+	}
+
+	diags = slices.Clone(diags)
+	slices.SortStableFunc(diags, func(a, b tp.Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.Position.Offset, b.Position.Offset))
+	})
+
+	results := make([]result, len(diags))
+	for i, d := range diags {
+		loc := location{
+			PhysicalLocation: physicalLocation{
+				ArtifactLocation: artifactLocation{URI: pathURI(d.Path)},
+			},
+		}
+		if d.Position.Line != 0 {
+			loc.PhysicalLocation.Region = &region{
+				StartLine:  d.Position.Line,
+				ByteOffset: d.Position.Offset,
+			}
+		}
+		results[i] = result{
+			RuleID:    ruleID(d),
+			Level:     "error",
+			Message:   message{Text: tp.FormatError(d.Err, m)},
+			Locations: []location{loc},
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []run{{
+			Tool: toolComponent{Driver: driver{
+				Name:           tool.Name,
+				Version:        tool.Version,
+				InformationURI: tool.InformationURI,
+			}},
+			Results: results,
+		}},
+	})
+}
+
+// Classify the problem that a diagnostic describes.
+func ruleID(d tp.Diagnostic) string {
+	var (
+		unexpected *tp.ErrUnexpectedToken
+		rule       *tp.RuleError
+	)
+	switch {
+	case d.Position.Line == 0:
+		return "unreadable"
+	case errors.As(d.Err, &rule):
+		// checked first, as rules may return the other errors
+		return "rule-error"
+	case errors.Is(d.Err, tp.ErrFailedMatch):
+		return "no-match"
+	case errors.As(d.Err, &unexpected):
+		return "unexpected-token"
+	case errors.Is(d.Err, io.ErrUnexpectedEOF):
+		return "unexpected-eof"
+	}
+	return "error"
+}
+
+// Write a path as a URI reference, which is relative if the path is.
+func pathURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+		if u.Path[0] != '/' {
+			// a Windows path with a drive letter
+			u.Path = "/" + u.Path
+		}
+	}
+	return u.String()
+}
+
+type log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []run  `json:"runs"`
+}
+
+type run struct {
+	Tool    toolComponent `json:"tool"`
+	Results []result      `json:"results"`
+}
+
+type toolComponent struct {
+	Driver driver `json:"driver"`
+}
+
+type driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+}
+
+type result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   message    `json:"message"`
+	Locations []location `json:"locations"`
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+type location struct {
+	PhysicalLocation physicalLocation `json:"physicalLocation"`
+}
+
+type physicalLocation struct {
+	ArtifactLocation artifactLocation `json:"artifactLocation"`
+	Region           *region          `json:"region,omitempty"`
+}
+
+type artifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type region struct {
+	StartLine  int `json:"startLine"`
+	ByteOffset int `json:"byteOffset"`
+}
@@ -0,0 +1,135 @@
+package sarif_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+	"github.com/bobappleyard/tp/batch"
+	"github.com/bobappleyard/tp/internal/testlang"
+	"github.com/bobappleyard/tp/sarif"
+)
+
+func TestEncode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		"ok.json":    "[1]",
+		"comma.json": "[\n  1,,\n]",
+		"eof.json":   "[1,",
+		"odd.json":   "[1 x]",
+	}
+	var paths []string
+	for name, src := range files {
+		assert.Nil(t, os.WriteFile(name, []byte(src), 0o644))
+		paths = append(paths, name)
+	}
+	paths = append(paths, "missing file.json")
+
+	res, err := batch.ParseFiles(context.Background(), testlang.Numbers, paths, 1)
+	assert.Nil(t, err)
+
+	var diags []tp.Diagnostic
+	for _, ds := range res.Diagnostics {
+		diags = append(diags, ds...)
+	}
+
+	var b strings.Builder
+	err = sarif.Encode(&b, sarif.Tool{Name: "numbers", Version: "1.0"}, diags)
+	assert.Nil(t, err)
+	assert.Equal(t, b.String(), `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "numbers",
+          "version": "1.0"
+        }
+      },
+      "results": [
+        {
+          "ruleId": "unexpected-token",
+          "level": "error",
+          "message": {
+            "text": "unexpected token: testlang.Comma{}"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "comma.json"
+                },
+                "region": {
+                  "startLine": 2,
+                  "byteOffset": 6
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "unexpected-eof",
+          "level": "error",
+          "message": {
+            "text": "unexpected EOF"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "eof.json"
+                },
+                "region": {
+                  "startLine": 1,
+                  "byteOffset": 3
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "unreadable",
+          "level": "error",
+          "message": {
+            "text": "open missing file.json: no such file or directory"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "missing%20file.json"
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "no-match",
+          "level": "error",
+          "message": {
+            "text": "failed to match: no token begins with 'x' at offset 3"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "odd.json"
+                },
+                "region": {
+                  "startLine": 1,
+                  "byteOffset": 3
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`)
+}
@@ -0,0 +1,22 @@
+package tp_test
+
+import (
+	"fmt"
+
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleSkip() {
+	l := must(tp.NewLexer(
+		tp.Skip[string](`\s+`),
+		tp.Skip[string](`//[^\n]*`),
+		tp.Regex(`[a-z]+`, func(start int, text string) (string, error) {
+			return text, nil
+		}),
+	))
+
+	toks := must(l.Tokenize([]byte("one // the first\n  two")).Force())
+	fmt.Println(toks)
+
+	// Output: [one two]
+}
@@ -0,0 +1,205 @@
+package tp
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// A set of states that the machine can be in simultaneously, along with the sets that it moves to
+// on reading each rune. Together these form the subset construction of the machine.
+type subsetState struct {
+	States []LexerState
+	Moves  []subsetMove
+
+	// whether this set can be entered by reading at least one rune
+	Moved bool
+}
+
+type subsetMove struct {
+	Min, Max rune
+	Then     int
+}
+
+// Perform the subset construction, yielding every set of states that the machine can reach from its
+// start states. The first set is always the start state that is used away from the start of a line.
+func (p *Lexer[T]) subsets() []subsetState {
+	var res []subsetState
+	seen := map[string]int{}
+
+	add := func(states []LexerState) int {
+		key := fmt.Sprint(states)
+		if i, ok := seen[key]; ok {
+			return i
+		}
+		seen[key] = len(res)
+		res = append(res, subsetState{States: states})
+		return len(res) - 1
+	}
+
+	for a := range anchors {
+		add(p.closure(p.startStates(a)))
+	}
+	for i := 0; i < len(res); i++ {
+		moves := p.subsetMoves(res[i].States)
+		for j, m := range moves {
+			moves[j].Then = add(m.states)
+			res[moves[j].Then].Moved = true
+		}
+		for _, m := range moves {
+			res[i].Moves = append(res[i].Moves, m.subsetMove)
+		}
+	}
+
+	return res
+}
+
+// Extend a set of states to include those reachable through empty transitions.
+func (p *Lexer[T]) closure(states []LexerState) []LexerState {
+	res := slices.Clone(states)
+	for _, op := range p.closeTransitions {
+		if slices.Contains(states, op.Given) && !slices.Contains(res, op.Then) {
+			res = append(res, op.Then)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+type pendingMove struct {
+	subsetMove
+	states []LexerState
+}
+
+// Divide the runes into ranges that move a set of states to the same place, and find out where that
+// is.
+func (p *Lexer[T]) subsetMoves(states []LexerState) []pendingMove {
+	var bounds []rune
+	for _, op := range p.moveTransitions {
+		if !slices.Contains(states, op.Given) {
+			continue
+		}
+		bounds = append(bounds, op.Min, op.Max+1)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	var res []pendingMove
+	for i := 0; i+1 < len(bounds); i++ {
+		min, max := bounds[i], bounds[i+1]-1
+		var then []LexerState
+		for _, op := range p.moveTransitions {
+			if !slices.Contains(states, op.Given) {
+				continue
+			}
+			if op.Min > min || op.Max < max {
+				continue
+			}
+			if !slices.Contains(then, op.Then) {
+				then = append(then, op.Then)
+			}
+		}
+		if len(then) == 0 {
+			continue
+		}
+		res = append(res, pendingMove{
+			subsetMove: subsetMove{Min: min, Max: max},
+			states:     p.closure(then),
+		})
+	}
+	return res
+}
+
+// Find the final state that the machine would yield a token for if it stopped in the given set of
+// states, or -1 if it contains no final state.
+func (p *Lexer[T]) subsetFinal(states []LexerState) int {
+	finals := p.subsetFinals(states)
+	if len(finals) == 0 {
+		return -1
+	}
+	return finals[0]
+}
+
+// Find every final state in the given set of states, in order of preference.
+func (p *Lexer[T]) subsetFinals(states []LexerState) []int {
+	var res []int
+	for i, op := range p.finalStates {
+		if slices.Contains(states, op.Given) {
+			res = append(res, i)
+		}
+	}
+	slices.SortStableFunc(res, func(a, b int) int {
+		return cmp.Compare(p.finalStates[b].Priority, p.finalStates[a].Priority)
+	})
+	return res
+}
+
+// ShadowedRule describes a token spec that can never produce a token, because every string that it
+// matches is also matched by a spec that takes priority over it.
+type ShadowedRule struct {
+	Rule, By               int
+	Pattern, ShadowPattern string
+}
+
+func (s ShadowedRule) String() string {
+	return fmt.Sprintf(
+		"%s is shadowed by %s",
+		lexerRule{Index: s.Rule, Pattern: s.Pattern},
+		lexerRule{Index: s.By, Pattern: s.ShadowPattern},
+	)
+}
+
+// Find the token specs given to NewLexer that can never produce a token, along with the specs that
+// produce tokens in their place. A spec that is shadowed by a combination of other specs is
+// reported once for each of them.
+func (p *Lexer[T]) Shadowed() []ShadowedRule {
+	accepts := make([]bool, len(p.rules))
+	wins := make([]bool, len(p.rules))
+	by := make([]map[int]bool, len(p.rules))
+
+	for _, set := range p.subsets() {
+		if !set.Moved {
+			continue
+		}
+		winner := p.subsetFinal(set.States)
+		if winner == -1 {
+			continue
+		}
+		winRule := p.finalStates[winner].Rule
+		for _, i := range p.subsetFinals(set.States) {
+			op := p.finalStates[i]
+			if op.Rule < 0 || op.Rule >= len(p.rules) {
+				continue
+			}
+			accepts[op.Rule] = true
+			if op.Rule == winRule {
+				wins[op.Rule] = true
+				continue
+			}
+			if by[op.Rule] == nil {
+				by[op.Rule] = map[int]bool{}
+			}
+			by[op.Rule][winRule] = true
+		}
+	}
+
+	var res []ShadowedRule
+	for i, r := range p.rules {
+		if !accepts[i] || wins[i] {
+			continue
+		}
+		for _, j := range slices.Sorted(maps.Keys(by[i])) {
+			if j < 0 || j >= len(p.rules) {
+				continue
+			}
+			res = append(res, ShadowedRule{
+				Rule:          i,
+				By:            j,
+				Pattern:       r.Pattern,
+				ShadowPattern: p.rules[j].Pattern,
+			})
+		}
+	}
+	return res
+}
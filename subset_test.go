@@ -0,0 +1,62 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestShadowed(t *testing.T) {
+	yield := func(start int, text string) (string, error) {
+		return text, nil
+	}
+
+	for _, test := range []struct {
+		name     string
+		patterns []string
+		out      []string
+	}{
+		{
+			name:     "KeywordAfterIdent",
+			patterns: []string{`[a-z]+`, `if`},
+			out:      []string{"rule 1: `if` is shadowed by rule 0: `[a-z]+`"},
+		},
+		{
+			name:     "KeywordBeforeIdent",
+			patterns: []string{`if`, `[a-z]+`},
+		},
+		{
+			name:     "Overlapping",
+			patterns: []string{`[a-m]+`, `[h-z]+`},
+		},
+		{
+			name:     "Union",
+			patterns: []string{`a`, `b`, `a|b`},
+			out: []string{
+				"rule 2: `a|b` is shadowed by rule 0: `a`",
+				"rule 2: `a|b` is shadowed by rule 1: `b`",
+			},
+		},
+		{
+			name:     "Duplicate",
+			patterns: []string{`\d+`, `[0-9]+`},
+			out:      []string{"rule 1: `[0-9]+` is shadowed by rule 0: `\\d+`"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var specs []TokenSpec[string]
+			for _, p := range test.patterns {
+				specs = append(specs, Regex(p, yield))
+			}
+			l, err := NewLexer(specs...)
+			if !assert.Nil(t, err) {
+				return
+			}
+			var out []string
+			for _, s := range l.Shadowed() {
+				out = append(out, s.String())
+			}
+			assert.Equal(t, out, test.out)
+		})
+	}
+}
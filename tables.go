@@ -0,0 +1,88 @@
+package tp
+
+import (
+	"errors"
+	"slices"
+)
+
+// LexerTables describe a lexer in its compiled form, see Compile, for programs that match text with
+// tables rather than by running the machine, such as those written by the gogen package.
+type LexerTables struct {
+	// the final states that States refer to
+	Finals []TableFinal
+
+	// the states of the compiled machine, beginning with the state that matching begins in
+	States []TableState
+}
+
+// A final state of a lexer's tables.
+type TableFinal struct {
+	// the spec that the state belongs to, by its position in the arguments to NewLexer, or for
+	// machines that are built by hand the order that the final state was declared in
+	Rule int
+
+	// set if the text is skipped rather than yielding a token
+	Skip bool
+}
+
+// A state of a lexer's tables.
+type TableState struct {
+	// the index in Finals of the token that would be yielded in this state, or -1
+	Final int
+
+	// the runes that lead out of the state, in order, and the state that each range leads to
+	Moves []TableMove
+}
+
+// A range of runes that leads from one state of a lexer's tables to another.
+type TableMove struct {
+	Min, Max rune
+	Then     int
+}
+
+// Describe the lexer in its compiled form. If it has not been compiled then a copy of it is, so the
+// lexer is left as it was. Actions added with Enter cannot be carried over into tables, so lexers
+// that have them cannot be described, and neither can lexers with tokens that only match at the
+// start of a line or of the text.
+func (p *Lexer[T]) Tables() (LexerTables, error) {
+	if len(p.enterActions) != 0 {
+		return LexerTables{}, errors.New("tp: lexers with actions added by Enter cannot be described by tables")
+	}
+	if p.lineStart != 0 || p.textStart != 0 {
+		return LexerTables{}, errors.New("tp: lexers with tokens anchored to the start of a line cannot be described by tables")
+	}
+
+	c := p
+	if p.dfa == nil {
+		c = p.clone()
+		c.Compile()
+	}
+
+	var res LexerTables
+	for i, f := range c.finalStates {
+		rule := f.Rule
+		if len(c.rules) == 0 {
+			rule = i
+		}
+		res.Finals = append(res.Finals, TableFinal{Rule: rule, Skip: f.Skip})
+	}
+	for _, s := range c.dfa {
+		state := TableState{Final: s.final}
+		for _, m := range s.moves {
+			state.Moves = append(state.Moves, TableMove(m))
+		}
+		res.States = append(res.States, state)
+	}
+	return res, nil
+}
+
+// Copy the machine, so that the copy can be frozen and compiled without affecting the original.
+func (p *Lexer[T]) clone() *Lexer[T] {
+	c := *p
+	c.closeTransitions = slices.Clone(p.closeTransitions)
+	c.moveTransitions = slices.Clone(p.moveTransitions)
+	c.finalStates = slices.Clone(p.finalStates)
+	c.enterActions = slices.Clone(p.enterActions)
+	c.rules = slices.Clone(p.rules)
+	return &c
+}
@@ -0,0 +1,35 @@
+package tp
+
+import (
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestTables(t *testing.T) {
+	var lp Lexer[int]
+	s := lp.State()
+	lp.Range(0, s, 'a', 'z')
+	lp.Range(s, s, 'a', 'z')
+	lp.Final(s, func(start int, text string) (int, error) {
+		return 0, nil
+	})
+
+	tables, err := lp.Tables()
+	assert.Nil(t, err)
+	assert.Equal(t, tables, LexerTables{
+		Finals: []TableFinal{{Rule: 0}},
+		States: []TableState{
+			{Final: -1, Moves: []TableMove{{Min: 'a', Max: 'z', Then: 1}}},
+			{Final: 0, Moves: []TableMove{{Min: 'a', Max: 'z', Then: 1}}},
+		},
+	})
+
+	// the lexer is not compiled, or frozen
+	assert.False(t, lp.frozen)
+	assert.True(t, lp.dfa == nil)
+	lp.Enter(s, func(start, pos int) {})
+
+	_, err = lp.Tables()
+	assert.Equal(t, err.Error(), "tp: lexers with actions added by Enter cannot be described by tables")
+}
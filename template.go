@@ -0,0 +1,120 @@
+package tp
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Template is a language of templates: literal text with expressions embedded in it between
+// delimiters, as in the mustache and jinja style of "Hello, {{ user.name }}!". The text is kept as it
+// is, and each expression is parsed with Expr.
+//
+// An expression ends at the first Close after its Open that is not inside one of Expr's tokens, so
+// that e.g. a string in an expression can contain Close.
+type Template[T, U, V any] struct {
+	Open, Close string
+	Expr        *Language[T, U, V]
+}
+
+// TemplateSegment is a part of a parsed template, either literal text or an expression.
+type TemplateSegment[V any] struct {
+	// The byte offset of the segment in the template. For an expression, this is the offset of its
+	// Open delimiter.
+	Offset int
+
+	// The literal text, for a segment that is not an expression.
+	Text string
+
+	// The parsed expression, for a segment that is one.
+	Expr   V
+	IsExpr bool
+}
+
+// ErrUnclosedExpr is the error of a template with an expression that has no Close delimiter.
+var ErrUnclosedExpr = errors.New("expression is not closed")
+
+// Parse a template into segments, in order. Segments of text are never empty, so a template without
+// expressions is a single segment, or no segments if it is empty.
+//
+// Problems with the syntax of the template, including those with the syntax of its expressions, are
+// described by a SyntaxError whose offset is within the whole template. Errors returned by rules are
+// returned as they are.
+func (t *Template[T, U, V]) Parse(src []byte) ([]TemplateSegment[V], error) {
+	if t.Open == "" || t.Close == "" {
+		return nil, errors.New("tp: template delimiters cannot be empty")
+	}
+	open, close := []byte(t.Open), []byte(t.Close)
+
+	var res []TemplateSegment[V]
+	pos := 0
+	for pos < len(src) {
+		n := bytes.Index(src[pos:], open)
+		if n == -1 {
+			n = len(src) - pos
+		}
+		if n > 0 {
+			res = append(res, TemplateSegment[V]{Offset: pos, Text: string(src[pos : pos+n])})
+			pos += n
+		}
+		if pos == len(src) {
+			break
+		}
+
+		start := pos + len(open)
+		end, err := t.exprEnd(src, pos, start, close)
+		if err != nil {
+			return nil, err
+		}
+		x, err := t.Expr.Parse(src[start:end])
+		var syntax *SyntaxError
+		if errors.As(err, &syntax) {
+			return nil, &SyntaxError{Offset: start + syntax.Offset, Err: syntax.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, TemplateSegment[V]{Offset: pos, Expr: x, IsExpr: true})
+		pos = end + len(close)
+	}
+	return res, nil
+}
+
+// Find where an expression beginning at start, with its Open delimiter at open, ends. This is at the
+// first Close delimiter such that the text before it can be tokenized.
+func (t *Template[T, U, V]) exprEnd(src []byte, open, start int, close []byte) (int, error) {
+	var first error
+	for end := start; ; end++ {
+		n := bytes.Index(src[end:], close)
+		if n == -1 {
+			break
+		}
+		end += n
+
+		s := t.Expr.Lexer.Tokenize(src[start:end])
+		for s.Next() {
+		}
+		err := s.Err()
+		if err == nil && s.srcPos < end-start {
+			err = ErrFailedMatch
+		}
+		if err == nil {
+			return end, nil
+		}
+		if first != nil {
+			continue
+		}
+		var noMatch *ErrNoMatch
+		if errors.As(err, &noMatch) {
+			// describe the offsets within the whole template
+			shifted := *noMatch
+			shifted.Start += start
+			shifted.Offset += start
+			err = &shifted
+		}
+		first = &SyntaxError{Offset: start + s.srcPos, Err: err}
+	}
+	if first != nil {
+		return 0, first
+	}
+	return 0, &SyntaxError{Offset: open, Err: ErrUnclosedExpr}
+}
@@ -0,0 +1,125 @@
+package tp_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+// An expression in a template: a variable, and filters to apply to it, as in {{ name | upper }}.
+type tmplExpr struct {
+	name    string
+	filters []string
+}
+
+type tmplToken interface {
+	tmplToken()
+}
+
+type tmplName struct{ name string }
+type tmplString struct{ text string }
+type tmplPipe struct{}
+
+func (tmplName) tmplToken()   {}
+func (tmplString) tmplToken() {}
+func (tmplPipe) tmplToken()   {}
+
+type tmplGrammar struct{}
+
+func (tmplGrammar) Parse(x tmplExpr) (tmplExpr, error) {
+	return x, nil
+}
+
+func (tmplGrammar) Var(x tmplName) tmplExpr {
+	return tmplExpr{name: x.name}
+}
+
+func (tmplGrammar) Quote(x tmplString) tmplExpr {
+	return tmplExpr{name: x.text}
+}
+
+func (tmplGrammar) Filter(x tmplExpr, _ tmplPipe, f tmplName) tmplExpr {
+	return tmplExpr{name: x.name, filters: append(x.filters, f.name)}
+}
+
+var tmplLanguage = &tp.Template[tmplToken, tmplExpr, tmplExpr]{
+	Open:  "{{",
+	Close: "}}",
+	Expr: &tp.Language[tmplToken, tmplExpr, tmplExpr]{
+		Lexer: must(tp.NewLexer(
+			tp.Skip[tmplToken](`\s+`),
+			tp.Regex(`\c\w*`, func(start int, text string) (tmplToken, error) {
+				return tmplName{text}, nil
+			}),
+			tp.Regex(`"[^"]*"`, func(start int, text string) (tmplToken, error) {
+				return tmplString{text[1 : len(text)-1]}, nil
+			}),
+			tp.Regex(`\|`, func(start int, text string) (tmplToken, error) {
+				return tmplPipe{}, nil
+			}),
+		)),
+		Grammar: tmplGrammar{},
+	},
+}
+
+func ExampleTemplate() {
+	segs, err := tmplLanguage.Parse([]byte(`Hello, {{ name | upper }}!`))
+	if err != nil {
+		panic(err)
+	}
+
+	vars := map[string]string{"name": "world"}
+	var b strings.Builder
+	for _, seg := range segs {
+		if !seg.IsExpr {
+			b.WriteString(seg.Text)
+			continue
+		}
+		v := vars[seg.Expr.name]
+		for _, f := range seg.Expr.filters {
+			if f == "upper" {
+				v = strings.ToUpper(v)
+			}
+		}
+		b.WriteString(v)
+	}
+	fmt.Println(b.String())
+
+	// Output: Hello, WORLD!
+}
+
+func TestTemplate(t *testing.T) {
+	segs, err := tmplLanguage.Parse([]byte(`a{{x}}{{ "}}" | f | g }}b{c}`))
+	assert.Nil(t, err)
+	assert.Equal(t, segs, []tp.TemplateSegment[tmplExpr]{
+		{Offset: 0, Text: "a"},
+		{Offset: 1, Expr: tmplExpr{name: "x"}, IsExpr: true},
+		{Offset: 6, Expr: tmplExpr{name: "}}", filters: []string{"f", "g"}}, IsExpr: true},
+		{Offset: 24, Text: "b{c}"},
+	})
+
+	segs, err = tmplLanguage.Parse(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, len(segs), 0)
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, src, err string
+	}{
+		{"Unclosed", "ab {{ x", "offset 3: expression is not closed"},
+		{"Token", "ab {{ x ? }}", "offset 8: failed to match: no token begins with '?' at offset 8"},
+		{"Syntax", "ab {{ x | }}", "offset 10: unexpected EOF"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tmplLanguage.Parse([]byte(test.src))
+			var syntax *tp.SyntaxError
+			assert.True(t, errors.As(err, &syntax))
+			assert.Equal(t, err.Error(), test.err)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// TokenDecl declares a terminal of a language. See Token.
+type TokenDecl[T any] struct {
+	name string
+	typ  reflect.Type
+	spec TokenSpec[T]
+}
+
+// Declare a terminal by name, along with the Go type of its tokens, X, and the spec that the lexer
+// uses to produce them. The spec may be nil for tokens that are produced by other means, e.g. by
+// processing the output of the lexer.
+func Token[T, X any](name string, spec TokenSpec[T]) TokenDecl[T] {
+	return TokenDecl[T]{name: name, typ: reflect.TypeFor[X](), spec: spec}
+}
+
+// TokenSet gathers the terminals of a language in one place, so that both the lexer and the grammar
+// can be checked against it. A token that is added to the set but not to the grammar, or the other
+// way around, is then reported rather than going unnoticed.
+type TokenSet[T any] struct {
+	decls []TokenDecl[T]
+}
+
+func NewTokenSet[T any](decls ...TokenDecl[T]) (*TokenSet[T], error) {
+	tokType := reflect.TypeFor[T]()
+	for i, d := range decls {
+		if !d.typ.AssignableTo(tokType) {
+			return nil, fmt.Errorf("token %q: %s is not a %s", d.name, d.typ, tokType)
+		}
+		for _, e := range decls[:i] {
+			if e.name == d.name {
+				return nil, fmt.Errorf("token %q is declared more than once", d.name)
+			}
+			if e.typ == d.typ {
+				return nil, fmt.Errorf("tokens %q and %q have the same type %s", e.name, d.name, d.typ)
+			}
+		}
+	}
+	return &TokenSet[T]{decls: decls}, nil
+}
+
+// Build a lexer from the specs of the declared tokens, in the order that they were declared. The
+// lexer fails with an error if a spec produces a token of a different type to the one declared.
+func (s *TokenSet[T]) Lexer() (*Lexer[T], error) {
+	specs := make([]TokenSpec[T], len(s.decls))
+	for i, d := range s.decls {
+		specs[i] = d.lexerSpec()
+	}
+	return NewLexer(specs...)
+}
+
+func (d TokenDecl[T]) lexerSpec() TokenSpec[T] {
+	return func(l *Lexer[T]) error {
+		if d.spec == nil {
+			return nil
+		}
+		from := len(l.finalStates)
+		if err := d.spec(l); err != nil {
+			return err
+		}
+		for i := from; i < len(l.finalStates); i++ {
+			f := &l.finalStates[i]
+			if then := f.Then; then != nil {
+				f.Then = func(start int, text string) (T, error) {
+					return d.check(then(start, text))
+				}
+			}
+			if then := f.ThenMatch; then != nil {
+				f.ThenMatch = func(m Match) (T, error) {
+					return d.check(then(m))
+				}
+			}
+			if then := f.ThenBytes; then != nil {
+				f.ThenBytes = func(start int, text []byte) (T, error) {
+					return d.check(then(start, text))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func (d TokenDecl[T]) check(tok T, err error) (T, error) {
+	if err != nil {
+		return tok, err
+	}
+	if got := reflect.TypeOf(tok); got != d.typ {
+		return tok, fmt.Errorf("token %q: produced %v, declared as %s", d.name, got, d.typ)
+	}
+	return tok, nil
+}
+
+// The name of the token's declaration, or the empty string if its type was not declared.
+func (s *TokenSet[T]) Name(tok T) string {
+	t := reflect.TypeOf(tok)
+	for _, d := range s.decls {
+		if d.typ == t {
+			return d.name
+		}
+	}
+	return ""
+}
+
+// ErrTokenMismatch reports the differences between a TokenSet and the tokens used by a grammar.
+type ErrTokenMismatch struct {
+	// Token types that the grammar uses but that are not declared.
+	Undeclared []string
+
+	// Names of tokens that are declared but that the grammar has no use for.
+	Unused []string
+}
+
+func (e *ErrTokenMismatch) Error() string {
+	var parts []string
+	if len(e.Undeclared) != 0 {
+		parts = append(parts, "undeclared tokens "+strings.Join(e.Undeclared, ", "))
+	}
+	if len(e.Unused) != 0 {
+		parts = append(parts, "unused tokens "+strings.Join(e.Unused, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Check that the grammar uses exactly the tokens that are declared in the set. Token types in the
+// grammar that are interfaces are satisfied by any declared token that implements them.
+func CheckTokens[T, U, V any](s *TokenSet[T], g Grammar[U, V]) error {
+	gr := scanGrammar(reflect.ValueOf(g), reflect.TypeFor[U]())
+
+	var used []reflect.Type
+	for _, sym := range gr.symbols {
+		if sym.TokenType != nil {
+			used = append(used, sym.TokenType)
+		}
+		if sym.NotFollowedBy != nil {
+			used = append(used, sym.NotFollowedBy)
+		}
+	}
+
+	res := &ErrTokenMismatch{}
+	for _, t := range used {
+		if !slices.ContainsFunc(s.decls, func(d TokenDecl[T]) bool { return d.typ.AssignableTo(t) }) {
+			res.Undeclared = append(res.Undeclared, t.String())
+		}
+	}
+	for _, d := range s.decls {
+		if !slices.ContainsFunc(used, d.typ.AssignableTo) {
+			res.Unused = append(res.Unused, d.name)
+		}
+	}
+	if len(res.Undeclared) == 0 && len(res.Unused) == 0 {
+		return nil
+	}
+	slices.Sort(res.Undeclared)
+	return res
+}
@@ -0,0 +1,96 @@
+package tp_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleTokenSet() {
+	tokens, err := tp.NewTokenSet(
+		tp.Token[any, intTok]("int", tp.Regex(`[0-9]+`, func(start int, text string) (any, error) {
+			value, err := strconv.Atoi(text)
+			return intTok{value}, err
+		})),
+		tp.Token[any, plusTok]("plus", tp.Regex(`\+`, func(start int, text string) (any, error) {
+			return plusTok{}, nil
+		})),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := tp.CheckTokens(tokens, interfaceGrammar{}); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	lexer, err := tokens.Lexer()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	toks, err := lexer.Tokenize([]byte("1+2")).Force()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, t := range toks {
+		fmt.Println(tokens.Name(t))
+	}
+	fmt.Println(tp.Parse(interfaceGrammar{}, toks))
+
+	// Output:
+	// int
+	// plus
+	// int
+	// {{1} {2}} <nil>
+}
+
+func TestTokenSetMismatch(t *testing.T) {
+	tokens, err := tp.NewTokenSet(
+		tp.Token[any, intTok]("int", nil),
+		tp.Token[any, ifTok]("if", nil),
+	)
+	assert.Nil(t, err)
+
+	err = tp.CheckTokens(tokens, interfaceGrammar{})
+	assert.Equal(t, err.Error(), "undeclared tokens tp_test.plusTok; unused tokens if")
+}
+
+func TestTokenSetDeclarations(t *testing.T) {
+	_, err := tp.NewTokenSet(
+		tp.Token[any, intTok]("int", nil),
+		tp.Token[any, intTok]("number", nil),
+	)
+	assert.Equal(t, err.Error(), `tokens "int" and "number" have the same type tp_test.intTok`)
+
+	_, err = tp.NewTokenSet(
+		tp.Token[any, intTok]("int", nil),
+		tp.Token[any, plusTok]("int", nil),
+	)
+	assert.Equal(t, err.Error(), `token "int" is declared more than once`)
+
+	_, err = tp.NewTokenSet(
+		tp.Token[expr, intTok]("int", nil),
+	)
+	assert.Equal(t, err.Error(), `token "int": tp_test.intTok is not a tp_test.expr`)
+}
+
+func TestTokenSetWrongType(t *testing.T) {
+	tokens, err := tp.NewTokenSet(
+		tp.Token[any, intTok]("int", tp.Regex(`[0-9]+`, func(start int, text string) (any, error) {
+			return plusTok{}, nil
+		})),
+	)
+	assert.Nil(t, err)
+
+	lexer, err := tokens.Lexer()
+	assert.Nil(t, err)
+
+	_, err = lexer.Tokenize([]byte("1")).Force()
+	assert.Equal(t, err.Error(), `token "int": produced tp_test.plusTok, declared as tp_test.intTok`)
+}
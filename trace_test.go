@@ -0,0 +1,32 @@
+package tp_test
+
+import (
+	"fmt"
+
+	"github.com/bobappleyard/tp"
+)
+
+func ExampleParseTrace() {
+	toks := []any{
+		intTok{1},
+		plusTok{},
+		intTok{2},
+		plusTok{},
+		intTok{3},
+	}
+
+	_, trace, err := tp.ParseTrace(interfaceGrammar{}, toks)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, d := range trace {
+		fmt.Println(d.Rule, d.Produces, d.Span)
+	}
+
+	// Output:
+	// Int tp_test.intVal {0 1}
+	// Int tp_test.intVal {2 3}
+	// Add tp_test.add {0 3}
+	// Int tp_test.intVal {4 5}
+	// Add tp_test.add {0 5}
+}
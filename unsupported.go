@@ -0,0 +1,227 @@
+package tp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedRegex is returned for syntax that other regular expression dialects support but
+// that this one does not, so that a pattern using it is rejected instead of being matched as
+// something else.
+type ErrUnsupportedRegex struct {
+	Construct  string
+	Suggestion string
+}
+
+func (e *ErrUnsupportedRegex) Error() string {
+	return fmt.Sprintf("%s is not supported: %s", e.Construct, e.Suggestion)
+}
+
+// Look for constructs from other dialects. Many of these would otherwise be read as literals, e.g.
+// \1 as 1 or a{2} as the text "a{2}", while others would give a confusing syntax error.
+func rejectUnsupported(toks []token, escapes map[rune]charset) error {
+	inClass := false
+
+	for i, t := range toks {
+		switch t := t.(type) {
+		case charsetOpen:
+			inClass = true
+		case charsetClose:
+			inClass = false
+		case slash:
+			if err := unsupportedEscape(t.of, escapes); err != nil {
+				return err
+			}
+		}
+		if inClass {
+			continue
+		}
+
+		switch t := t.(type) {
+		case charsetInvert:
+			return &ErrUnsupportedRegex{
+				Construct:  "^ after the start of a pattern",
+				Suggestion: "tokens are always matched from where the previous token ended, so ^ can only anchor a whole pattern; use \\^ to match ^",
+			}
+
+		case char:
+			if t.hex {
+				continue
+			}
+			if t.of == '$' {
+				return &ErrUnsupportedRegex{
+					Construct:  "$",
+					Suggestion: "tokens end where the longest match ends; use \\$ to match $",
+				}
+			}
+			if t.of == '{' {
+				if count, ok := countedRepetition(toks[i+1:]); ok {
+					return &ErrUnsupportedRegex{
+						Construct:  "counted repetition {" + count + "}",
+						Suggestion: "write the repetitions out, using ? for the optional ones",
+					}
+				}
+			}
+
+		case quantity:
+			if i+1 >= len(toks) {
+				continue
+			}
+			next, ok := toks[i+1].(quantity)
+			if !ok {
+				continue
+			}
+			switch next.of {
+			case '?':
+				return &ErrUnsupportedRegex{
+					Construct:  fmt.Sprintf("lazy quantifier %c?", t.of),
+					Suggestion: "the lexer always takes the longest match; exclude the text that should end the token instead, e.g. [^\"]* rather than .*?",
+				}
+			case '+':
+				return &ErrUnsupportedRegex{
+					Construct:  fmt.Sprintf("possessive quantifier %c+", t.of),
+					Suggestion: "the lexer does not backtrack, so there is nothing to prevent; remove the +",
+				}
+			}
+
+		case groupOpen:
+			if i+1 >= len(toks) {
+				continue
+			}
+			if q, ok := toks[i+1].(quantity); ok && q.of == '?' {
+				return unsupportedGroup(toks[i+2:])
+			}
+		}
+	}
+
+	return nil
+}
+
+func unsupportedEscape(c rune, escapes map[rune]charset) error {
+	if err := reservedEscape(c); err != nil {
+		return err
+	}
+	if _, ok := regexParser.escMap[c]; ok {
+		return nil
+	}
+	if _, ok := escapes[c]; ok {
+		return nil
+	}
+	if code, ok := controlEscapes[c]; ok {
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("control character escape \\%c", c),
+			Suggestion: fmt.Sprintf("write the character by its code point, as \\x{%x}", code),
+		}
+	}
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("escape \\%c", c),
+			Suggestion: "letters do not need escaping; use EscapeClass to give the escape a meaning",
+		}
+	}
+	return nil
+}
+
+// The escapes for control characters that other dialects have, apart from \n, \r and \t, and the
+// characters that they stand for.
+var controlEscapes = map[rune]rune{'a': '\a', 'e': 0x1b, 'f': '\f', 'v': '\v'}
+
+// Find whether an escape has a meaning in other dialects that it does not have here.
+func reservedEscape(c rune) error {
+	switch c {
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9', 'k':
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("backreference \\%c", c),
+			Suggestion: "a lexer cannot refer back to text it has matched; capture the text and compare it in the token constructor",
+		}
+	case 'b', 'B', 'A', 'z', 'Z', 'G':
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("assertion \\%c", c),
+			Suggestion: "there are no zero-width assertions; tokens are matched from where the previous token ended and are as long as possible",
+		}
+	case 'D', 'S', 'W':
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("negated class \\%c", c),
+			Suggestion: fmt.Sprintf("use [^\\%c] instead", c-'A'+'a'),
+		}
+	case 'p', 'P':
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("Unicode class \\%c", c),
+			Suggestion: "use a character set that lists the ranges",
+		}
+	case 'x', 'u', 'U', '0':
+		return &ErrUnsupportedRegex{
+			Construct:  fmt.Sprintf("numeric escape \\%c", c),
+			Suggestion: "write the code point in hexadecimal inside braces, e.g. \\x{e9}",
+		}
+	case 'g':
+		return &ErrUnsupportedRegex{
+			Construct:  "\\g without a name",
+			Suggestion: "refer to fragments as \\g{name}",
+		}
+	}
+	return nil
+}
+
+// Find whether the tokens following a { describe a count, such as 2}, 2,} or 1,3}.
+func countedRepetition(toks []token) (string, bool) {
+	var count strings.Builder
+	for _, t := range toks {
+		c, ok := t.(char)
+		if !ok {
+			return "", false
+		}
+		switch {
+		case c.of >= '0' && c.of <= '9' || c.of == ',':
+			count.WriteRune(c.of)
+		case c.of == '}':
+			s := count.String()
+			lo, hi, _ := strings.Cut(s, ",")
+			return s, lo != "" && !strings.Contains(hi, ",")
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// Describe a group beginning with (? that is not a flag group.
+func unsupportedGroup(rest []token) error {
+	var next []rune
+	for _, t := range rest[:min(len(rest), 2)] {
+		if c, ok := t.(char); ok {
+			next = append(next, c.of)
+		} else {
+			break
+		}
+	}
+	lookaround := &ErrUnsupportedRegex{
+		Suggestion: "a lexer cannot look beyond the text that it matches; declare a token for the longer text that takes priority instead",
+	}
+
+	switch {
+	case strings.HasPrefix(string(next), "="):
+		lookaround.Construct = "lookahead (?="
+		return lookaround
+	case strings.HasPrefix(string(next), "!"):
+		lookaround.Construct = "negative lookahead (?!"
+		return lookaround
+	case strings.HasPrefix(string(next), "<="):
+		lookaround.Construct = "lookbehind (?<="
+		return lookaround
+	case strings.HasPrefix(string(next), "<!"):
+		lookaround.Construct = "negative lookbehind (?<!"
+		return lookaround
+	case strings.HasPrefix(string(next), "<"), strings.HasPrefix(string(next), "P"):
+		return &ErrUnsupportedRegex{
+			Construct:  "named group",
+			Suggestion: "groups are numbered in the order that they appear; use (s) instead",
+		}
+	case strings.HasPrefix(string(next), ">"):
+		return &ErrUnsupportedRegex{
+			Construct:  "atomic group (?>",
+			Suggestion: "the lexer does not backtrack, so there is nothing to prevent; use (?:s) instead",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package tp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+func TestUnsupportedRegex(t *testing.T) {
+	for _, test := range []struct {
+		in        string
+		construct string
+	}{
+		{in: `(a)\1`, construct: `backreference \1`},
+		{in: `(?P<x>a)\k<x>`, construct: `named group`},
+		{in: `\bfoo\b`, construct: `assertion \b`},
+		{in: `\Afoo`, construct: `assertion \A`},
+		{in: `\D+`, construct: `negated class \D`},
+		{in: `[\S]`, construct: `negated class \S`},
+		{in: `\p{Greek}`, construct: `Unicode class \p`},
+		{in: `\x41`, construct: `numeric escape \x`},
+		{in: `\e`, construct: `control character escape \e`},
+		{in: `[\f\v]`, construct: `control character escape \f`},
+		{in: `\a`, construct: `control character escape \a`},
+		{in: `\q`, construct: `escape \q`},
+		{in: `\gx`, construct: `\g without a name`},
+		{in: `a^foo`, construct: `^ after the start of a pattern`},
+		{in: `(?m:^foo)`, construct: `^ after the start of a pattern`},
+		{in: `^a|b`, construct: `^ before one of several alternatives`},
+		{in: `foo$`, construct: `$`},
+		{in: `a{2}`, construct: `counted repetition {2}`},
+		{in: `a{1,3}`, construct: `counted repetition {1,3}`},
+		{in: `".*?"`, construct: `lazy quantifier *?`},
+		{in: `a++`, construct: `possessive quantifier ++`},
+		{in: `foo(?=bar)`, construct: `lookahead (?=`},
+		{in: `foo(?!bar)`, construct: `negative lookahead (?!`},
+		{in: `(?<=a)b`, construct: `lookbehind (?<=`},
+		{in: `(?<!a)b`, construct: `negative lookbehind (?<!`},
+		{in: `(?<x>a)`, construct: `named group`},
+		{in: `(?>a+)`, construct: `atomic group (?>`},
+	} {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := ParseRegex(test.in)
+			var unsupported *ErrUnsupportedRegex
+			if assert.True(t, errors.As(err, &unsupported)) {
+				assert.Equal(t, unsupported.Construct, test.construct)
+			}
+		})
+	}
+}
+
+func TestSupportedLookalikes(t *testing.T) {
+	for _, in := range []string{
+		`{`,
+		`a{b}`,
+		`{,}`,
+		`[$^]`,
+		`[*?]`,
+		`\$\^`,
+		`\{2\}`,
+		`(?i)a`,
+		`^a`,
+		`(?m)^a`,
+		`^(?:a|b)`,
+		`^[a|b]`,
+		`\g{x}`,
+		`\x{24}`,
+		`a\x{7b}2}`,
+	} {
+		t.Run(in, func(t *testing.T) {
+			_, err := ParseRegex(in)
+			var unsupported *ErrUnsupportedRegex
+			assert.False(t, errors.As(err, &unsupported))
+		})
+	}
+}
+
+func TestUnsupportedControlEscape(t *testing.T) {
+	_, err := ParseRegex(`\f`)
+	assert.Equal(t, err.Error(), `control character escape \f is not supported: write the character by its code point, as \x{c}`)
+
+	// the suggestion is one that works
+	p, err := ParseRegex(`\x{c}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, p.String(), `\x{c}`)
+	}
+}
@@ -0,0 +1,134 @@
+package tp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Versions describes the versions of a language whose rules are not all in every version, so that
+// one grammar can parse each of them. A grammar declares its versions with a Versions method,
+// which is called for every parse:
+//
+//	func (g pyGrammar) Versions() tp.Versions {
+//		return tp.Versions{
+//			Names:   []string{"2", "3"},
+//			Current: g.Version,
+//			Rules:   map[string]tp.VersionRange{"PrintStatement": {Until: "3"}},
+//		}
+//	}
+//
+// A text that can only be parsed with rules that are not in the current version fails with an
+// ErrVersion, which names the version that would be needed. Naming a version that is not one of the
+// Names is a GrammarError, which NewParser reports up front.
+type Versions struct {
+	// The names of the versions, from the oldest to the newest.
+	Names []string
+
+	// The version to parse, or the newest if this is empty.
+	Current string
+
+	// The versions that the grammar's own rules are in, by the names of their methods. Rules that
+	// are not given are in every version.
+	Rules map[string]VersionRange
+}
+
+// VersionRange gives the versions that a rule is in: those from Since, and before Until. An empty
+// Since is the oldest version, and an empty Until means that the rule is in the newest version.
+type VersionRange struct {
+	Since, Until string
+}
+
+// ErrVersion is the error of a parse that needs a rule that is not in the version being parsed.
+type ErrVersion struct {
+	// The name of the rule's method, its label or its name if it does not have one, and the tokens
+	// that it matched.
+	Rule  string
+	Label string
+	Span  Span
+
+	// The version being parsed.
+	Version string
+
+	// The version that the rule was added in, if the version being parsed is older than that, or
+	// otherwise the version that it was removed in.
+	Added, Removed string
+}
+
+func (e *ErrVersion) Error() string {
+	return EnglishMessages{}.Version(e)
+}
+
+// Find the rules of a grammar that are not in the version being parsed, and the error that each
+// leads to.
+func versionExclusions(g any, gr *grammar) (map[*rule]func(Span) error, error) {
+	v, ok := g.(interface{ Versions() Versions })
+	if !ok {
+		return nil, nil
+	}
+	vs := v.Versions()
+	current := len(vs.Names) - 1
+	if vs.Current != "" {
+		var err error
+		current, err = versionIndex(g, vs, vs.Current, "as the current version")
+		if err != nil {
+			return nil, err
+		}
+	}
+	version := ""
+	if current >= 0 {
+		version = vs.Names[current]
+	}
+
+	res := map[*rule]func(Span) error{}
+	for _, sym := range gr.symbols {
+		for _, r := range sym.Predictions {
+			rng, ok := vs.Rules[r.Name]
+			if !ok || r.Host.IsValid() {
+				continue
+			}
+			var err error
+			since, until := 0, len(vs.Names)
+			if rng.Since != "" {
+				since, err = versionIndex(g, vs, rng.Since, "for "+r.Name)
+			}
+			if rng.Until != "" && err == nil {
+				until, err = versionIndex(g, vs, rng.Until, "for "+r.Name)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if current >= since && current < until {
+				continue
+			}
+			e := ErrVersion{Rule: r.Name, Label: r.label(), Version: version}
+			if current < since {
+				e.Added = rng.Since
+			} else {
+				e.Removed = rng.Until
+			}
+			res[r] = func(s Span) error {
+				e := e
+				e.Span = s
+				return &e
+			}
+		}
+	}
+	checkRuleNames("Versions", g, vs.Rules)
+	return res, nil
+}
+
+// Find where a version comes in the order of the versions, returning a GrammarError if it is not one
+// of them.
+func versionIndex(g any, vs Versions, name, use string) (int, error) {
+	i := slices.Index(vs.Names, name)
+	if i == -1 {
+		m, _ := reflect.TypeOf(g).MethodByName("Versions")
+		return 0, &GrammarError{
+			Rule:   "Versions",
+			Type:   m.Type,
+			Reason: fmt.Sprintf("Versions gives %q %s, which is not one of %q", name, use, vs.Names),
+		}
+	}
+	return i, nil
+}
@@ -0,0 +1,147 @@
+package tp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+	"github.com/bobappleyard/tp"
+)
+
+type versionedGrammar struct {
+	interfaceGrammar
+	version string
+}
+
+func (g versionedGrammar) Versions() tp.Versions {
+	return tp.Versions{
+		Names:   []string{"1", "2", "3"},
+		Current: g.version,
+		Rules: map[string]tp.VersionRange{
+			"Digits": {Until: "2"},
+			"Pair":   {Since: "2"},
+			"Double": {Since: "2"},
+			"Unary":  {Until: "3"},
+		},
+	}
+}
+
+// Two numbers side by side were digits of one number, and are now added together.
+func (versionedGrammar) Digits(x, y intTok) intVal {
+	return intVal{x.value*10 + y.value}
+}
+
+func (versionedGrammar) Pair(x, y intTok) add {
+	return add{intVal(x), intVal(y)}
+}
+
+func (versionedGrammar) Double(x intTok, _, _ plusTok) add {
+	return add{intVal(x), intVal(x)}
+}
+
+func (versionedGrammar) Unary(_ plusTok, x intTok) intVal {
+	return intVal(x)
+}
+
+func TestVersions(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		version string
+		toks    []any
+		want    expr
+	}{
+		{"Removed", "1", []any{intTok{1}, intTok{2}}, intVal{12}},
+		{"Added", "2", []any{intTok{1}, intTok{2}}, add{intVal{1}, intVal{2}}},
+		{"Newest", "", []any{intTok{1}, intTok{2}}, add{intVal{1}, intVal{2}}},
+		{"Unchanged", "1", []any{intTok{1}, plusTok{}, plusTok{}, intTok{2}}, add{intVal{1}, intVal{2}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			x, err := tp.Parse(versionedGrammar{version: test.version}, test.toks)
+			assert.Nil(t, err)
+			assert.Equal(t, x, test.want)
+		})
+	}
+}
+
+func TestVersionErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		version string
+		toks    []any
+		want    *tp.ErrVersion
+		msg     string
+	}{
+		{
+			name:    "Added",
+			version: "1",
+			toks:    []any{intTok{1}, plusTok{}, intTok{2}, plusTok{}, plusTok{}},
+			want:    &tp.ErrVersion{Rule: "Double", Label: "Double", Span: tp.Span{Start: 2, End: 5}, Version: "1", Added: "2"},
+			msg:     "Double requires version 2 or later, but the version is 1",
+		},
+		{
+			name:    "Removed",
+			version: "",
+			toks:    []any{plusTok{}, intTok{1}},
+			want:    &tp.ErrVersion{Rule: "Unary", Label: "Unary", Span: tp.Span{Start: 0, End: 2}, Version: "3", Removed: "3"},
+			msg:     "Unary was removed in version 3, but the version is 3",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tp.Parse(versionedGrammar{version: test.version}, test.toks)
+			var version *tp.ErrVersion
+			assert.True(t, errors.As(err, &version))
+			assert.Equal(t, *version, *test.want)
+			assert.Equal(t, err.Error(), test.msg)
+		})
+	}
+
+	// tokens that fit no version are reported as they would be otherwise
+	_, err := tp.Parse(versionedGrammar{version: "1"}, []any{plusTok{}, plusTok{}})
+	var unexpected *tp.ErrUnexpectedToken
+	assert.True(t, errors.As(err, &unexpected))
+
+	_, err = tp.Parse(versionedGrammar{version: "4"}, []any{intTok{1}})
+	assert.Equal(t, err.Error(), `invalid grammar: Versions gives "4" as the current version, which is not one of ["1" "2" "3"]`)
+}
+
+type misversionedGrammar struct {
+	interfaceGrammar
+	version string
+}
+
+func (g misversionedGrammar) Versions() tp.Versions {
+	return tp.Versions{
+		Names:   []string{"1", "2"},
+		Current: g.version,
+		Rules: map[string]tp.VersionRange{
+			"Unary": {Until: "3"},
+		},
+	}
+}
+
+func (misversionedGrammar) Unary(_ plusTok, x intTok) intVal {
+	return intVal(x)
+}
+
+func TestVersionsInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		version string
+		err     string
+	}{
+		{"Current", "4", `invalid grammar: Versions gives "4" as the current version, which is not one of ["1" "2"]`},
+		{"Until", "", `invalid grammar: Versions gives "3" for Unary, which is not one of ["1" "2"]`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tp.NewParser[any](misversionedGrammar{version: test.version})
+			var gerr *tp.GrammarError
+			if assert.True(t, errors.As(err, &gerr)) {
+				assert.Equal(t, gerr.Rule, "Versions")
+				assert.Equal(t, err.Error(), test.err)
+			}
+
+			_, err = tp.Parse(misversionedGrammar{version: test.version}, []any{intTok{1}})
+			assert.True(t, errors.As(err, &gerr))
+		})
+	}
+}
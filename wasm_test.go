@@ -0,0 +1,60 @@
+package tp
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bobappleyard/assert"
+)
+
+// The most that a program that tokenizes and parses with tp, and does little else, may take when
+// built for browsers.
+const wasmBudget = 6 << 20
+
+// Packages that only tools need, which the core package should not bring into the programs that use
+// it, as they make programs built for browsers larger. Each stands for the packages beneath it too.
+var toolingPackages = []string{"bufio", "context", "go", "math/rand", "net", "os/exec", "plugin"}
+
+func goTool(t *testing.T, env []string, args ...string) string {
+	t.Helper()
+	path, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is not available")
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %s: %s\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func TestCoreDependencies(t *testing.T) {
+	deps := goTool(t, nil, "list", "-deps", ".")
+	for _, dep := range strings.Fields(deps) {
+		for _, p := range toolingPackages {
+			if dep == p || strings.HasPrefix(dep, p+"/") {
+				t.Errorf("the core package depends on %s", dep)
+			}
+		}
+	}
+}
+
+func TestWasmSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building for js/wasm is slow")
+	}
+	out := filepath.Join(t.TempDir(), "tp.wasm")
+	goTool(t, []string{"GOOS=js", "GOARCH=wasm"}, "build", "-o", out, "./internal/wasmsize")
+	info, err := os.Stat(out)
+	if !assert.Nil(t, err) {
+		return
+	}
+	if info.Size() > wasmBudget {
+		t.Errorf("the js/wasm build is %d bytes, over the budget of %d", info.Size(), wasmBudget)
+	}
+}